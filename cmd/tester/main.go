@@ -196,6 +196,10 @@ func main() {
 		logging.FromContext(ctx).Infof("The Kuberentes resource will be used with includeSpec\n")
 	}
 
+	// kc is the keychain of the local docker config, both for the image and
+	// for the TrustRoot.
+	kc := authn.DefaultKeychain
+
 	if *trustRootFilePath != "" {
 		logging.FromContext(ctx).Infof("Parsing the custom trust root\n")
 
@@ -210,7 +214,7 @@ func main() {
 			log.Fatal(err)
 		}
 
-		keys, err := GetKeysFromTrustRoot(ctx, tr)
+		keys, err := GetKeysFromTrustRoot(ctx, tr, kc)
 		if err != nil {
 			log.Fatal(err)
 		}
@@ -228,7 +232,7 @@ func main() {
 	logging.FromContext(ctx).Infof("Verifying the provided image against the policy\n")
 
 	errStrings := []string{}
-	if err := vfy.Verify(ctx, ref, authn.DefaultKeychain); err != nil {
+	if err := vfy.Verify(ctx, ref, kc); err != nil {
 		errStrings = append(errStrings, strings.Trim(err.Error(), "\n"))
 	}
 
@@ -19,13 +19,20 @@ import (
 	"context"
 	"fmt"
 
+	"github.com/google/go-containerregistry/pkg/authn"
+	"github.com/google/go-containerregistry/pkg/name"
+	"github.com/google/go-containerregistry/pkg/v1/remote"
 	"github.com/sigstore/policy-controller/pkg/apis/config"
 	"github.com/sigstore/policy-controller/pkg/apis/policy/v1alpha1"
 	"github.com/sigstore/policy-controller/pkg/reconciler/trustroot"
 	"github.com/sigstore/policy-controller/pkg/tuf"
 )
 
-func GetKeysFromTrustRoot(ctx context.Context, tr *v1alpha1.TrustRoot) (*config.SigstoreKeys, error) {
+// GetKeysFromTrustRoot returns the keys of the TrustRoot like the
+// reconciler does. The pullSecrets of an OCI repository can only be resolved
+// in the cluster, so it is pulled with kc, the keychain the image is
+// verified with.
+func GetKeysFromTrustRoot(ctx context.Context, tr *v1alpha1.TrustRoot, kc authn.Keychain) (*config.SigstoreKeys, error) {
 	switch {
 	case tr.Spec.Remote != nil:
 		mirror := tr.Spec.Remote.Mirror.String()
@@ -34,6 +41,20 @@ func GetKeysFromTrustRoot(ctx context.Context, tr *v1alpha1.TrustRoot) (*config.
 			return nil, fmt.Errorf("failed to initialize TUF client from remote: %w", err)
 		}
 		return trustroot.GetSigstoreKeysFromTuf(ctx, client, "")
+	case tr.Spec.OCI != nil:
+		ref, err := name.ParseReference(tr.Spec.OCI.Image)
+		if err != nil {
+			return nil, fmt.Errorf("invalid OCI image: %w", err)
+		}
+		client, err := tuf.ClientFromOCI(ctx, ref, tr.Spec.OCI.Root, tr.Spec.OCI.Targets, remote.WithAuthFromKeychain(kc))
+		if err != nil {
+			return nil, fmt.Errorf("failed to initialize TUF client from OCI: %w", err)
+		}
+		trustedRootTarget := "trusted_root.json"
+		if tr.Spec.OCI.TrustedRootTarget != "" {
+			trustedRootTarget = tr.Spec.OCI.TrustedRootTarget
+		}
+		return trustroot.GetSigstoreKeysFromTuf(ctx, client, trustedRootTarget)
 	case tr.Spec.Repository != nil:
 		client, err := tuf.ClientFromSerializedMirror(context.Background(), tr.Spec.Repository.MirrorFS, tr.Spec.Repository.Root, tr.Spec.Repository.Targets, v1alpha1.DefaultTUFRepoPrefix)
 		if err != nil {
@@ -43,6 +64,10 @@ func GetKeysFromTrustRoot(ctx context.Context, tr *v1alpha1.TrustRoot) (*config.
 		return trustroot.GetSigstoreKeysFromTuf(ctx, client, "")
 	case tr.Spec.SigstoreKeys != nil:
 		return config.ConvertSigstoreKeys(context.Background(), tr.Spec.SigstoreKeys)
+	case tr.Spec.TrustedRoot != nil && tr.Spec.TrustedRoot.Data != "":
+		// ConfigMap and Secret references can only be resolved in the
+		// cluster.
+		return trustroot.GetSigstoreKeysFromTrustedRoot([]byte(tr.Spec.TrustedRoot.Data))
 	}
 	return nil, fmt.Errorf("provided trust root configuration is not supported")
 }
@@ -17,9 +17,14 @@ package main
 
 import (
 	"context"
+	"crypto/tls"
+	"crypto/x509"
 	"flag"
 	"fmt"
 	"log"
+	"net/http"
+	"net/http/httputil"
+	"net/url"
 	"os"
 	"time"
 
@@ -44,6 +49,7 @@ import (
 	"knative.dev/pkg/controller"
 	"knative.dev/pkg/injection/sharedmain"
 	"knative.dev/pkg/logging"
+	"knative.dev/pkg/network"
 	"knative.dev/pkg/signals"
 	"knative.dev/pkg/webhook"
 	"knative.dev/pkg/webhook/certificates"
@@ -58,6 +64,7 @@ import (
 	"github.com/sigstore/policy-controller/pkg/apis/config"
 	pctuf "github.com/sigstore/policy-controller/pkg/tuf"
 	cwebhook "github.com/sigstore/policy-controller/pkg/webhook"
+	"github.com/sigstore/policy-controller/pkg/webhook/registryauth"
 )
 
 var (
@@ -103,6 +110,29 @@ var (
 	// trustrootResyncPeriod holds the interval which the TrustRoot will resync
 	// This is essential for triggering a reconcile update for potentially stale TUF metadata.
 	trustrootResyncPeriod = flag.Duration("trustroot-resync-period", 24*time.Hour, "The resync period for ClusterImagePolicies. The default is 24h.")
+
+	// trustrootExpiryWindow holds how long before they expire the TUF
+	// metadata, certificate authorities and keys of a TrustRoot are reported
+	// as expiring. It should be longer than the trustroot-resync-period.
+	trustrootExpiryWindow = flag.Duration("trustroot-expiry-window", pctuf.DefaultTrustRootExpiryWindow, "How long before they expire the parts of a TrustRoot are reported with a Warning condition and Events. The default is 168h.")
+
+	// tufFetchTimeout, tufFetchRetries, tufFetchProxy and tufFetchCABundle
+	// configure how the TrustRoot reconciler fetches TUF repositories.
+	tufFetchTimeout  = flag.Duration("tuf-fetch-timeout", pctuf.DefaultFetchOptions.Timeout, "The timeout of each attempt to fetch TUF metadata or targets for TrustRoots. The default is 30s.")
+	tufFetchRetries  = flag.Int("tuf-fetch-retries", pctuf.DefaultFetchOptions.Retries, "How many times a failed fetch of TUF metadata or targets for TrustRoots is retried. The default is 3.")
+	tufFetchProxy    = flag.String("tuf-fetch-proxy", "", "The URL of the proxy to fetch TUF repositories for TrustRoots through. If left blank, the HTTPS_PROXY and NO_PROXY environment variables are used.")
+	tufFetchCABundle = flag.String("tuf-fetch-ca-bundle", "", "The path to a PEM file of CA certificates trusted, in addition to the system ones, for fetching TUF repositories for TrustRoots.")
+
+	// imageCredentialProviderConfig and imageCredentialProviderBinDir are
+	// named like the kubelet flags, so that the registry credentials of the
+	// nodes can be used for verification.
+	imageCredentialProviderConfig = flag.String("image-credential-provider-config", "", "The path to a kubelet CredentialProviderConfig. If set, registry credentials are also obtained from the credential provider plugins it configures.")
+	imageCredentialProviderBinDir = flag.String("image-credential-provider-bin-dir", "", "The directory of the credential provider plugin binaries.")
+
+	// tufStatusPort is the port of the /tuf endpoint that reports the state
+	// of the TUF root (see pctuf.StatusHandler), and of the /readyz endpoint
+	// of the readiness probe (see pctuf.ReadinessHandler).
+	tufStatusPort = flag.Int("tuf-status-port", 8081, "The port on which to serve /tuf, which responds with 503 while the TUF root is initializing or failing to initialize, for monitoring, and /readyz, which also probes the webhook, for the readiness probe. Zero disables them.")
 )
 
 func main() {
@@ -129,15 +159,41 @@ func main() {
 				logging.FromContext(ctx).Panicf("Failed to read alternate TUF root file %s : %v", *tufRoot, err)
 			}
 		}
+		// The TUF root is initialized in the background, so that a flaky
+		// mirror or an expired root only fails the authorities that depend
+		// on it instead of the whole webhook.
 		logging.FromContext(ctx).Infof("Initializing TUF root from %s => %s", *tufRoot, *tufMirror)
-		if err := tuf.Initialize(ctx, *tufMirror, tufRootBytes); err != nil {
-			logging.FromContext(ctx).Panicf("Failed to initialize TUF client from %s : %v", *tufRoot, err)
+		pctuf.InitializePublicGood(ctx, *tufMirror, tufRootBytes)
+	}
+
+	if *tufStatusPort > 0 {
+		go func() {
+			mux := http.NewServeMux()
+			mux.Handle("/tuf", pctuf.StatusHandler())
+			mux.Handle("/readyz", pctuf.ReadinessHandler(webhookProbe(webhook.GetOptions(ctx).Port)))
+			server := &http.Server{
+				Addr:              fmt.Sprintf(":%d", *tufStatusPort),
+				Handler:           mux,
+				ReadHeaderTimeout: 10 * time.Second,
+			}
+			if err := server.ListenAndServe(); err != nil {
+				logging.FromContext(ctx).Errorf("TUF status server failed: %v", err)
+			}
+		}()
+	}
+
+	if *imageCredentialProviderConfig != "" {
+		logging.FromContext(ctx).Infof("Loading credential providers from %s", *imageCredentialProviderConfig)
+		if err := registryauth.LoadCredentialProviders(*imageCredentialProviderConfig, *imageCredentialProviderBinDir); err != nil {
+			logging.FromContext(ctx).Panicf("Failed to load credential providers from %s: %v", *imageCredentialProviderConfig, err)
 		}
 	}
 
 	// Set the policy and trust root resync periods
 	ctx = clusterimagepolicy.ToContext(ctx, *policyResyncPeriod)
 	ctx = pctuf.ToContext(ctx, *trustrootResyncPeriod)
+	ctx = pctuf.ToContextWithExpiryWindow(ctx, *trustrootExpiryWindow)
+	ctx = pctuf.ToContextWithFetchOptions(ctx, tufFetchOptions(ctx))
 
 	// This must match the set of resources we configure in
 	// cmd/webhook/main.go in the "types" map.
@@ -269,7 +325,14 @@ func NewValidatingAdmissionController(ctx context.Context, cmw configmap.Watcher
 	)
 }
 
-func NewMutatingAdmissionController(ctx context.Context, _ configmap.Watcher) *controller.Impl {
+func NewMutatingAdmissionController(ctx context.Context, cmw configmap.Watcher) *controller.Impl {
+	// Decorate contexts with the current state of the config, so that the
+	// verification results can be recorded for the policies that ask for it.
+	store := config.NewStore(logging.FromContext(ctx).Named("config-store"))
+	store.WatchConfigs(cmw)
+	policyControllerConfigStore := policycontrollerconfig.NewStore(logging.FromContext(ctx).Named("config-policy-controller"))
+	policyControllerConfigStore.WatchConfigs(cmw)
+
 	kc := kubeclient.Get(ctx)
 	logger := logging.FromContext(ctx)
 	woptions := webhook.GetOptions(ctx)
@@ -293,6 +356,8 @@ func NewMutatingAdmissionController(ctx context.Context, _ configmap.Watcher) *c
 		// A function that infuses the context passed to Validate/SetDefaults with custom metadata.
 		func(ctx context.Context) context.Context {
 			ctx = context.WithValue(ctx, kubeclient.Key{}, kc)
+			ctx = store.ToContext(ctx)
+			ctx = policyControllerConfigStore.ToContext(ctx)
 			ctx = policyduckv1beta1.WithPodScalableDefaulter(ctx, validator.ResolvePodScalable)
 			ctx = duckv1.WithPodDefaulter(ctx, validator.ResolvePod)
 			ctx = duckv1.WithPodSpecDefaulter(ctx, validator.ResolvePodSpecable)
@@ -391,3 +456,48 @@ func newConversionController(ctx context.Context, _ configmap.Watcher) *controll
 		},
 	)
 }
+
+// webhookProbe returns a handler that probes the webhook server on the port
+// of this pod, the same way as the kubelet.
+func webhookProbe(port int) http.Handler {
+	proxy := httputil.NewSingleHostReverseProxy(&url.URL{Scheme: "https", Host: fmt.Sprintf("127.0.0.1:%d", port)})
+	director := proxy.Director
+	proxy.Director = func(r *http.Request) {
+		director(r)
+		r.URL.Path = "/"
+		r.Header.Set(network.KubeletProbeHeaderName, "webhook")
+	}
+	// The probe only checks that the webhook of this pod serves, like the
+	// kubelet does not verify the certificate either.
+	proxy.Transport = &http.Transport{TLSClientConfig: &tls.Config{InsecureSkipVerify: true}} //nolint:gosec
+	return proxy
+}
+
+// tufFetchOptions returns the FetchOptions of the tuf-fetch-* flags.
+func tufFetchOptions(ctx context.Context) pctuf.FetchOptions {
+	opts := pctuf.DefaultFetchOptions
+	opts.Timeout = *tufFetchTimeout
+	opts.Retries = *tufFetchRetries
+	if *tufFetchProxy != "" {
+		proxy, err := url.Parse(*tufFetchProxy)
+		if err != nil {
+			logging.FromContext(ctx).Panicf("Invalid TUF fetch proxy %s : %v", *tufFetchProxy, err)
+		}
+		opts.Proxy = proxy
+	}
+	if *tufFetchCABundle != "" {
+		pem, err := os.ReadFile(*tufFetchCABundle)
+		if err != nil {
+			logging.FromContext(ctx).Panicf("Failed to read TUF fetch CA bundle %s : %v", *tufFetchCABundle, err)
+		}
+		pool, err := x509.SystemCertPool()
+		if err != nil {
+			pool = x509.NewCertPool()
+		}
+		if !pool.AppendCertsFromPEM(pem) {
+			logging.FromContext(ctx).Panicf("No CA certificates found in TUF fetch CA bundle %s", *tufFetchCABundle)
+		}
+		opts.RootCAs = pool
+	}
+	return opts
+}
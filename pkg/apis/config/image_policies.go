@@ -20,7 +20,8 @@ import (
 	"errors"
 	"fmt"
 
-	"github.com/sigstore/policy-controller/pkg/apis/glob"
+	"github.com/google/go-containerregistry/pkg/name"
+	"github.com/sigstore/policy-controller/pkg/apis/policy/v1alpha1"
 	webhookcip "github.com/sigstore/policy-controller/pkg/webhook/clusterimagepolicy"
 	corev1 "k8s.io/api/core/v1"
 	"k8s.io/apimachinery/pkg/api/meta"
@@ -40,12 +41,25 @@ type ImagePolicyConfig struct {
 	// This is the list of ImagePolicies that a admission controller uses
 	// to make policy decisions.
 	Policies map[string]webhookcip.ClusterImagePolicy
+
+	// index holds the compiled image patterns of the Policies.
+	index *policyIndex
+}
+
+// NewImagePolicyConfig creates an ImagePolicyConfig for the given policies,
+// compiling their image patterns once up front. The policies must not be
+// modified afterwards.
+func NewImagePolicyConfig(policies map[string]webhookcip.ClusterImagePolicy) *ImagePolicyConfig {
+	return &ImagePolicyConfig{
+		Policies: policies,
+		index:    newPolicyIndex(policies),
+	}
 }
 
 // NewImagePoliciesConfigFromMap creates an ImagePolicyConfig from the supplied
 // Map
 func NewImagePoliciesConfigFromMap(data map[string]string) (*ImagePolicyConfig, error) {
-	ret := &ImagePolicyConfig{Policies: make(map[string]webhookcip.ClusterImagePolicy, len(data))}
+	policies := make(map[string]webhookcip.ClusterImagePolicy, len(data))
 	// Spin through the ConfigMap. Each key will point to resolved
 	// ImagePatterns.
 	for k, v := range data {
@@ -61,9 +75,9 @@ func NewImagePoliciesConfigFromMap(data map[string]string) (*ImagePolicyConfig,
 		if err := parseEntry(v, clusterImagePolicy); err != nil {
 			return nil, fmt.Errorf("failed to parse the entry %q : %q : %w", k, v, err)
 		}
-		ret.Policies[k] = *clusterImagePolicy
+		policies[k] = *clusterImagePolicy
 	}
-	return ret, nil
+	return NewImagePolicyConfig(policies), nil
 }
 
 // NewImagePoliciesConfigFromConfigMap creates a Features from the supplied ConfigMap
@@ -79,11 +93,37 @@ func parseEntry(entry string, out interface{}) error {
 	return json.Unmarshal(j, &out)
 }
 
+// MatchesDigests returns true if any of the Policies has image patterns that
+// list digests. Those patterns only match images referenced by digest, so
+// callers holding a tag should resolve it first.
+func (p *ImagePolicyConfig) MatchesDigests() bool {
+	if p == nil {
+		return false
+	}
+	idx := p.index
+	if idx == nil {
+		idx = newPolicyIndex(p.Policies)
+	}
+	return idx.digests
+}
+
 // GetMatchingPolicies returns all matching Policies and their Authorities that
 // need to be matched for the given kind, version and labels (if provided) to then match the Image.
 // Returned map contains the name of the CIP as the key, and a normalized
 // ClusterImagePolicy for it.
 func (p *ImagePolicyConfig) GetMatchingPolicies(image string, kind, apiVersion string, labels map[string]string) (map[string]webhookcip.ClusterImagePolicy, error) {
+	return p.getMatchingPolicies(image, nil, kind, apiVersion, labels)
+}
+
+// GetMatchingPoliciesResolved is like GetMatchingPolicies for an image that
+// is referenced by tag and has been resolved to the given digest. The image
+// patterns are matched against the image as it is referenced, while the
+// patterns that list digests are matched against the resolved digest.
+func (p *ImagePolicyConfig) GetMatchingPoliciesResolved(image string, resolved name.Digest, kind, apiVersion string, labels map[string]string) (map[string]webhookcip.ClusterImagePolicy, error) {
+	return p.getMatchingPolicies(image, &resolved, kind, apiVersion, labels)
+}
+
+func (p *ImagePolicyConfig) getMatchingPolicies(image string, resolved *name.Digest, kind, apiVersion string, labels map[string]string) (map[string]webhookcip.ClusterImagePolicy, error) {
 	if p == nil {
 		return nil, errors.New("config is nil")
 	}
@@ -95,57 +135,88 @@ func (p *ImagePolicyConfig) GetMatchingPolicies(image string, kind, apiVersion s
 	// While unsafe, this is correct (safe!) for everything we care about.
 	gvr, _ := meta.UnsafeGuessKindToResource(gv.WithKind(kind))
 
+	idx := p.index
+	if idx == nil {
+		// The config was not created with NewImagePolicyConfig, so we have
+		// to compile the patterns on every call.
+		idx = newPolicyIndex(p.Policies)
+	}
+
+	// Parse the image only once for all the patterns. If it is invalid, every
+	// policy that applies to the resource reports the error.
+	ref, refErr := name.ParseReference(image, name.WeakValidation)
+	var candidates []*compiledPolicy
+	if refErr != nil {
+		candidates = idx.all()
+	} else {
+		candidates = idx.candidates(ref, image)
+		if resolved != nil {
+			candidates = idx.union(candidates, idx.candidates(*resolved, resolved.Name()))
+		}
+	}
+
 	var lastError error
 	ret := make(map[string]webhookcip.ClusterImagePolicy)
 
-	// TODO(vaikas): this is very inefficient, we should have a better
-	// way to go from image to Authorities, but just seeing if this is even
-	// workable so fine for now.
-	for k, v := range p.Policies {
-		if len(v.Match) > 0 {
-			foundMatch := false
-			for _, matchResource := range v.Match {
-				if matchResource.Resource != gvr.Resource {
-					// Resource doesn't match.
-					continue
-				}
-				if matchResource.Version != gvr.Version && matchResource.Version != "*" {
-					// Version doesn't match exactly or wildcard.
-					continue
-				}
-				if matchResource.Group != gvr.Group {
-					// Group doesn't match.
-					continue
-				}
-
-				if matchResource.ResourceSelector != nil {
-					selector, err := metav1.LabelSelectorAsSelector(matchResource.ResourceSelector)
-					if err != nil {
-						return nil, errors.New("policy with wrong match label selector")
-					}
-					if !selector.Matches(metalabels.Set(labels)) {
-						continue
-					}
-				}
-				// We found a set of match criteria that this resource satisfies
-				foundMatch = true
-				break
-			}
-			if !foundMatch {
-				// We didn't find any match with the current resource types, so we continue looking for policies
+	for _, cp := range candidates {
+		v := p.Policies[cp.name]
+		if matched, err := matchResource(v.Match, gvr, labels); err != nil {
+			return nil, err
+		} else if !matched {
+			// We didn't find any match with the current resource types, so we continue looking for policies
+			continue
+		}
+
+		for i := range cp.patterns {
+			if refErr != nil {
+				lastError = refErr
 				continue
 			}
+			patternRef, patternImage := ref, image
+			if resolved != nil && cp.patterns[i].digests != nil {
+				patternRef, patternImage = *resolved, resolved.Name()
+			}
+			if matched, err := cp.patterns[i].match(patternRef, patternImage); err != nil {
+				lastError = err
+			} else if matched {
+				ret[cp.name] = v
+			}
 		}
+	}
+	return ret, lastError
+}
 
-		for _, pattern := range v.Images {
-			if pattern.Glob != "" {
-				if matched, err := glob.Match(pattern.Glob, image); err != nil {
-					lastError = err
-				} else if matched {
-					ret[k] = v
-				}
+// matchResource returns true if there are no match criteria, or if the
+// resource satisfies at least one of them.
+func matchResource(match []v1alpha1.MatchResource, gvr schema.GroupVersionResource, labels map[string]string) (bool, error) {
+	if len(match) == 0 {
+		return true, nil
+	}
+	for _, matchResource := range match {
+		if matchResource.Resource != gvr.Resource {
+			// Resource doesn't match.
+			continue
+		}
+		if matchResource.Version != gvr.Version && matchResource.Version != "*" {
+			// Version doesn't match exactly or wildcard.
+			continue
+		}
+		if matchResource.Group != gvr.Group {
+			// Group doesn't match.
+			continue
+		}
+
+		if matchResource.ResourceSelector != nil {
+			selector, err := metav1.LabelSelectorAsSelector(matchResource.ResourceSelector)
+			if err != nil {
+				return false, errors.New("policy with wrong match label selector")
+			}
+			if !selector.Matches(metalabels.Set(labels)) {
+				continue
 			}
 		}
+		// We found a set of match criteria that this resource satisfies
+		return true, nil
 	}
-	return ret, lastError
+	return false, nil
 }
@@ -0,0 +1,246 @@
+// Copyright 2022 The Sigstore Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package config
+
+import (
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/google/go-containerregistry/pkg/name"
+	"github.com/sigstore/policy-controller/pkg/apis/glob"
+	"github.com/sigstore/policy-controller/pkg/apis/policy/v1alpha1"
+	webhookcip "github.com/sigstore/policy-controller/pkg/webhook/clusterimagepolicy"
+	"k8s.io/apimachinery/pkg/util/sets"
+)
+
+// imagePatternMatcher is an ImagePattern with its Glob or Regex and its
+// Exclude patterns compiled ahead of time, so that matching an image does not
+// need to compile them again.
+type imagePatternMatcher struct {
+	re       *regexp.Regexp
+	excludes []*regexp.Regexp
+	// digests is the set of digests the image must resolve to, if any.
+	digests sets.Set[string]
+	// err holds the error from compiling the pattern, if any. It is
+	// returned every time the pattern is matched, the same as glob.Match
+	// would do for an invalid glob.
+	err error
+}
+
+func compileImagePattern(pattern v1alpha1.ImagePattern) imagePatternMatcher {
+	var re *regexp.Regexp
+	var err error
+	if pattern.Regex != "" {
+		re, err = glob.CompileRegex(pattern.Regex)
+	} else {
+		re, err = glob.Compile(pattern.Glob)
+	}
+	if err != nil {
+		return imagePatternMatcher{err: err}
+	}
+	m := imagePatternMatcher{re: re}
+	if len(pattern.Digests) > 0 {
+		m.digests = sets.New(pattern.Digests...)
+	}
+	for _, exclude := range pattern.Exclude {
+		var excludeRe *regexp.Regexp
+		switch {
+		case exclude.Glob != "":
+			excludeRe, err = glob.Compile(exclude.Glob)
+		case exclude.Regex != "":
+			excludeRe, err = glob.CompileRegex(exclude.Regex)
+		default:
+			continue
+		}
+		if err != nil {
+			return imagePatternMatcher{err: err}
+		}
+		m.excludes = append(m.excludes, excludeRe)
+	}
+	return m
+}
+
+// match returns true if the image matches the pattern and none of its
+// exclusions. If the pattern lists digests, the image must also be a
+// reference by one of those digests.
+func (m *imagePatternMatcher) match(ref name.Reference, image string) (bool, error) {
+	if m.err != nil {
+		return false, m.err
+	}
+	if m.digests != nil {
+		digest, ok := ref.(name.Digest)
+		if !ok || !m.digests.Has(digest.DigestStr()) {
+			return false, nil
+		}
+	}
+	if !glob.MatchReference(m.re, ref, image) {
+		return false, nil
+	}
+	for _, exclude := range m.excludes {
+		if glob.MatchReference(exclude, ref, image) {
+			return false, nil
+		}
+	}
+	return true, nil
+}
+
+// pathSegments returns the leading "/" separated segments of the image
+// references that the pattern can match. An empty result means that the
+// pattern may match any image.
+func (m *imagePatternMatcher) pathSegments() []string {
+	if m.err != nil {
+		return nil
+	}
+	prefix, _ := m.re.LiteralPrefix()
+	// Only complete segments are fixed, the last one may still be followed
+	// by other characters (e.g. "ghcr.io/foo" also matches "ghcr.io/foobar").
+	i := strings.LastIndex(prefix, "/")
+	if i < 0 {
+		return nil
+	}
+	return strings.Split(prefix[:i], "/")
+}
+
+// compiledPolicy holds the compiled image patterns of a ClusterImagePolicy.
+type compiledPolicy struct {
+	name     string
+	patterns []imagePatternMatcher
+}
+
+// policyIndex is a trie of the registry and repository path segments that
+// the image patterns of the policies start with. It is used to narrow down
+// the policies that need to be evaluated for an image.
+type policyIndex struct {
+	root     indexNode
+	policies []compiledPolicy
+	// digests is true if any of the patterns lists digests.
+	digests bool
+}
+
+type indexNode struct {
+	children map[string]*indexNode
+	// policies holds the indices of the policies that have at least one
+	// pattern whose fixed path segments end at this node.
+	policies []int
+}
+
+func newPolicyIndex(policies map[string]webhookcip.ClusterImagePolicy) *policyIndex {
+	names := make([]string, 0, len(policies))
+	for k := range policies {
+		names = append(names, k)
+	}
+	sort.Strings(names)
+
+	idx := &policyIndex{policies: make([]compiledPolicy, 0, len(names))}
+	for i, k := range names {
+		cp := compiledPolicy{name: k}
+		for _, pattern := range policies[k].Images {
+			if pattern.Glob == "" && pattern.Regex == "" {
+				continue
+			}
+			m := compileImagePattern(pattern)
+			cp.patterns = append(cp.patterns, m)
+			idx.insert(m.pathSegments(), i)
+			if m.digests != nil {
+				idx.digests = true
+			}
+		}
+		idx.policies = append(idx.policies, cp)
+	}
+	return idx
+}
+
+func (idx *policyIndex) insert(segments []string, policy int) {
+	node := &idx.root
+	for _, segment := range segments {
+		child, ok := node.children[segment]
+		if !ok {
+			if node.children == nil {
+				node.children = make(map[string]*indexNode)
+			}
+			child = &indexNode{}
+			node.children[segment] = child
+		}
+		node = child
+	}
+	// Policies are inserted in order, so this is enough to avoid duplicates
+	// when several patterns of the same policy end at the same node.
+	if n := len(node.policies); n == 0 || node.policies[n-1] != policy {
+		node.policies = append(node.policies, policy)
+	}
+}
+
+// candidates returns the policies that may have a pattern matching the image.
+// Like glob.Match, both the fully qualified reference and the original image
+// are taken into account.
+func (idx *policyIndex) candidates(ref name.Reference, image string) []*compiledPolicy {
+	seen := make([]bool, len(idx.policies))
+	idx.lookup(ref.Name(), seen)
+	if ref.Name() != image {
+		idx.lookup(image, seen)
+	}
+	var ret []*compiledPolicy
+	for i := range idx.policies {
+		if seen[i] {
+			ret = append(ret, &idx.policies[i])
+		}
+	}
+	return ret
+}
+
+func (idx *policyIndex) lookup(image string, seen []bool) {
+	node := &idx.root
+	for _, segment := range strings.Split(image, "/") {
+		for _, i := range node.policies {
+			seen[i] = true
+		}
+		child, ok := node.children[segment]
+		if !ok {
+			return
+		}
+		node = child
+	}
+	for _, i := range node.policies {
+		seen[i] = true
+	}
+}
+
+// union returns the policies in either a or b, in the order of the index.
+func (idx *policyIndex) union(a, b []*compiledPolicy) []*compiledPolicy {
+	seen := make(map[*compiledPolicy]bool, len(a)+len(b))
+	for _, cp := range a {
+		seen[cp] = true
+	}
+	for _, cp := range b {
+		seen[cp] = true
+	}
+	ret := make([]*compiledPolicy, 0, len(seen))
+	for i := range idx.policies {
+		if seen[&idx.policies[i]] {
+			ret = append(ret, &idx.policies[i])
+		}
+	}
+	return ret
+}
+
+// all returns every policy in the index.
+func (idx *policyIndex) all() []*compiledPolicy {
+	ret := make([]*compiledPolicy, 0, len(idx.policies))
+	for i := range idx.policies {
+		ret = append(ret, &idx.policies[i])
+	}
+	return ret
+}
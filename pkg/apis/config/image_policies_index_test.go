@@ -0,0 +1,219 @@
+// Copyright 2022 The Sigstore Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package config
+
+import (
+	"fmt"
+	"sort"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/google/go-containerregistry/pkg/name"
+	"github.com/sigstore/policy-controller/pkg/apis/policy/v1alpha1"
+	webhookcip "github.com/sigstore/policy-controller/pkg/webhook/clusterimagepolicy"
+)
+
+func TestPolicyIndexCandidates(t *testing.T) {
+	policies := map[string]webhookcip.ClusterImagePolicy{
+		"everything":      {Images: []v1alpha1.ImagePattern{{Glob: "**"}}},
+		"dockerhub":       {Images: []v1alpha1.ImagePattern{{Glob: "*"}}},
+		"ghcr":            {Images: []v1alpha1.ImagePattern{{Glob: "ghcr.io/**"}}},
+		"ghcr-foo":        {Images: []v1alpha1.ImagePattern{{Glob: "ghcr.io/foo/*"}}},
+		"ghcr-foo-prefix": {Images: []v1alpha1.ImagePattern{{Glob: "ghcr.io/foo*"}}},
+		"ghcr-bar":        {Images: []v1alpha1.ImagePattern{{Glob: "ghcr.io/bar/*"}}},
+		"multiple":        {Images: []v1alpha1.ImagePattern{{Glob: "gcr.io/a/*"}, {Glob: "gcr.io/b/*"}}},
+		"invalid":         {Images: []v1alpha1.ImagePattern{{Glob: "gcr.io/$FOO"}}},
+		"unqualified":     {Images: []v1alpha1.ImagePattern{{Glob: "myuser/*"}}},
+	}
+	idx := newPolicyIndex(policies)
+
+	for _, c := range []struct {
+		image string
+		want  []string
+	}{{
+		image: "ghcr.io/foo/bar",
+		want:  []string{"everything", "ghcr", "ghcr-foo", "ghcr-foo-prefix", "invalid"},
+	}, {
+		image: "ghcr.io/bar/baz",
+		want:  []string{"everything", "ghcr", "ghcr-bar", "ghcr-foo-prefix", "invalid"},
+	}, {
+		image: "gcr.io/b/c",
+		want:  []string{"everything", "invalid", "multiple"},
+	}, {
+		image: "ubuntu",
+		want:  []string{"dockerhub", "everything", "invalid"},
+	}, {
+		// Unqualified images are also looked up as is.
+		image: "myuser/app",
+		want:  []string{"everything", "invalid", "unqualified"},
+	}} {
+		t.Run(c.image, func(t *testing.T) {
+			ref, err := name.ParseReference(c.image, name.WeakValidation)
+			if err != nil {
+				t.Fatalf("ParseReference() = %v", err)
+			}
+			var got []string
+			for _, cp := range idx.candidates(ref, c.image) {
+				got = append(got, cp.name)
+			}
+			sort.Strings(got)
+			if diff := cmp.Diff(c.want, got); diff != "" {
+				t.Errorf("unexpected candidates (-want +got): %s", diff)
+			}
+		})
+	}
+}
+
+func TestGetMatchingPoliciesIndexed(t *testing.T) {
+	policies := map[string]webhookcip.ClusterImagePolicy{
+		"ghcr-foo":    {Images: []v1alpha1.ImagePattern{{Glob: "ghcr.io/foo/*"}}},
+		"ghcr-all":    {Images: []v1alpha1.ImagePattern{{Glob: "ghcr.io/**", Exclude: []v1alpha1.ExcludePattern{{Regex: `.*/debug`}}}}},
+		"dockerhub":   {Images: []v1alpha1.ImagePattern{{Glob: "*"}}},
+		"unqualified": {Images: []v1alpha1.ImagePattern{{Glob: "myuser/*"}}},
+		"quay-regex":  {Images: []v1alpha1.ImagePattern{{Regex: `quay\.io/(foo|bar)/[a-z]+(@sha256:[0-9a-f]{64})?`}}},
+		"pinned": {Images: []v1alpha1.ImagePattern{{
+			Glob:    "registry.example.com/**",
+			Digests: []string{"sha256:5504f2a95018e3d8a52d80d9e1a128c6ea337581808ff9fe96f5628ce2336350"},
+		}}},
+	}
+	indexed := NewImagePolicyConfig(policies)
+	unindexed := &ImagePolicyConfig{Policies: policies}
+
+	for _, c := range []struct {
+		image string
+		want  []string
+	}{
+		{image: "ghcr.io/foo/bar", want: []string{"ghcr-all", "ghcr-foo"}},
+		{image: "ghcr.io/foo/debug", want: []string{"ghcr-foo"}},
+		{image: "ghcr.io/baz", want: []string{"ghcr-all"}},
+		{image: "ubuntu", want: []string{"dockerhub"}},
+		{image: "myuser/app", want: []string{"unqualified"}},
+		{image: "quay.io/foo/bar", want: []string{"quay-regex"}},
+		{image: "quay.io/bar/baz@sha256:5504f2a95018e3d8a52d80d9e1a128c6ea337581808ff9fe96f5628ce2336350", want: []string{"quay-regex"}},
+		{image: "quay.io/baz/bar", want: []string{}},
+		{image: "quay.io/foo/bar/baz", want: []string{}},
+		{image: "registry.example.com/app@sha256:5504f2a95018e3d8a52d80d9e1a128c6ea337581808ff9fe96f5628ce2336350", want: []string{"pinned"}},
+		{image: "registry.example.com/app:v1@sha256:5504f2a95018e3d8a52d80d9e1a128c6ea337581808ff9fe96f5628ce2336350", want: []string{"pinned"}},
+		{image: "registry.example.com/app@sha256:be5d77c62dbe7fedfb0a4e5ec2f91078080800ab1f18358e5f31fcc8faa023c4", want: []string{}},
+		{image: "registry.example.com/app:v1", want: []string{}},
+	} {
+		t.Run(c.image, func(t *testing.T) {
+			for _, ipc := range []*ImagePolicyConfig{indexed, unindexed} {
+				matches, err := ipc.GetMatchingPolicies(c.image, "Pod", "v1", nil)
+				if err != nil {
+					t.Fatalf("GetMatchingPolicies() = %v", err)
+				}
+				got := []string{}
+				for k := range matches {
+					got = append(got, k)
+				}
+				sort.Strings(got)
+				if diff := cmp.Diff(c.want, got); diff != "" {
+					t.Errorf("unexpected matches (-want +got): %s", diff)
+				}
+			}
+		})
+	}
+
+	if _, err := indexed.GetMatchingPolicies("invalid&name", "Pod", "v1", nil); err == nil {
+		t.Error("GetMatchingPolicies() with an invalid image did not fail")
+	}
+	if !indexed.MatchesDigests() || !unindexed.MatchesDigests() {
+		t.Error("MatchesDigests() = false, wanted true")
+	}
+	delete(policies, "pinned")
+	if NewImagePolicyConfig(policies).MatchesDigests() {
+		t.Error("MatchesDigests() = true, wanted false")
+	}
+}
+
+func TestGetMatchingPoliciesResolved(t *testing.T) {
+	const digest = "sha256:5504f2a95018e3d8a52d80d9e1a128c6ea337581808ff9fe96f5628ce2336350"
+	policies := map[string]webhookcip.ClusterImagePolicy{
+		"tagged": {Images: []v1alpha1.ImagePattern{{Glob: "registry.example.com/app:v1*"}}},
+		"pinned": {Images: []v1alpha1.ImagePattern{{Glob: "registry.example.com/**", Digests: []string{digest}}}},
+		"other":  {Images: []v1alpha1.ImagePattern{{Glob: "ghcr.io/**", Digests: []string{digest}}}},
+	}
+	ipc := NewImagePolicyConfig(policies)
+
+	for _, c := range []struct {
+		image    string
+		resolved string
+		want     []string
+	}{
+		{image: "registry.example.com/app:v1", resolved: digest, want: []string{"pinned", "tagged"}},
+		{image: "registry.example.com/app:v1.2", resolved: "sha256:be5d77c62dbe7fedfb0a4e5ec2f91078080800ab1f18358e5f31fcc8faa023c4", want: []string{"tagged"}},
+		{image: "registry.example.com/app:v2", resolved: digest, want: []string{"pinned"}},
+	} {
+		t.Run(c.image, func(t *testing.T) {
+			ref, err := name.ParseReference(c.image)
+			if err != nil {
+				t.Fatalf("ParseReference() = %v", err)
+			}
+			matches, err := ipc.GetMatchingPoliciesResolved(c.image, ref.Context().Digest(c.resolved), "Pod", "v1", nil)
+			if err != nil {
+				t.Fatalf("GetMatchingPoliciesResolved() = %v", err)
+			}
+			got := []string{}
+			for k := range matches {
+				got = append(got, k)
+			}
+			sort.Strings(got)
+			if diff := cmp.Diff(c.want, got); diff != "" {
+				t.Errorf("unexpected matches (-want +got): %s", diff)
+			}
+		})
+	}
+}
+
+// benchmarkPolicies returns n policies spread over a few registries and many
+// repositories, roughly the shape of a large cluster.
+func benchmarkPolicies(n int) map[string]webhookcip.ClusterImagePolicy {
+	registries := []string{"ghcr.io", "gcr.io", "quay.io", "registry.example.com"}
+	policies := make(map[string]webhookcip.ClusterImagePolicy, n)
+	for i := 0; i < n; i++ {
+		glob := fmt.Sprintf("%s/team-%d/*", registries[i%len(registries)], i)
+		policies[fmt.Sprintf("policy-%d", i)] = webhookcip.ClusterImagePolicy{
+			Images: []v1alpha1.ImagePattern{{Glob: glob}},
+		}
+	}
+	return policies
+}
+
+func BenchmarkGetMatchingPolicies(b *testing.B) {
+	policies := benchmarkPolicies(300)
+	image := "quay.io/team-42/app:v1.2.3"
+
+	for _, bc := range []struct {
+		name string
+		ipc  *ImagePolicyConfig
+	}{
+		{name: "indexed", ipc: NewImagePolicyConfig(policies)},
+		{name: "unindexed", ipc: &ImagePolicyConfig{Policies: policies}},
+	} {
+		b.Run(bc.name, func(b *testing.B) {
+			b.ReportAllocs()
+			for i := 0; i < b.N; i++ {
+				matches, err := bc.ipc.GetMatchingPolicies(image, "Pod", "v1", nil)
+				if err != nil {
+					b.Fatalf("GetMatchingPolicies() = %v", err)
+				}
+				if len(matches) != 1 {
+					b.Fatalf("wanted 1 match, got %d", len(matches))
+				}
+			}
+		})
+	}
+}
@@ -238,6 +238,23 @@ func TestGetAuthorities(t *testing.T) {
 	if len(c) != 0 {
 		t.Errorf("Wanted 0 matches, got %d", len(c))
 	}
+
+	// Test exclude patterns
+	matchedPolicy = "cluster-image-policy-exclude"
+	c, err = defaults.GetMatchingPolicies("excludes/included", "Pod", "v1", map[string]string{})
+	checkGetMatches(t, c, err)
+	if _, ok := c[matchedPolicy]; !ok {
+		t.Errorf("Wanted %q to match, got %+v", matchedPolicy, c)
+	}
+	for _, image := range []string{"excludes/skippedimage", "excludes/debug-image", "index.docker.io/excludes/test-image:latest"} {
+		c, err = defaults.GetMatchingPolicies(image, "Pod", "v1", map[string]string{})
+		if err != nil {
+			t.Fatalf("GetMatchingPolicies(%q) = %v", image, err)
+		}
+		if len(c) != 0 {
+			t.Errorf("Wanted 0 matches for excluded image %q, got %d", image, len(c))
+		}
+	}
 }
 
 func TestFailsToLoadInvalid(t *testing.T) {
@@ -19,6 +19,7 @@ import (
 	"context"
 	"crypto"
 	"crypto/ecdsa"
+	"crypto/ed25519"
 	"crypto/elliptic"
 	"crypto/rsa"
 	"encoding/pem"
@@ -147,14 +148,27 @@ func ConvertCertificateAuthority(source v1alpha1.CertificateAuthority) (*pbtrust
 		},
 		Uri:       source.URI.String(),
 		CertChain: certChain,
-		ValidFor: &pbcommon.TimeRange{
-			Start: &timestamppb.Timestamp{
-				Seconds: 0, // TODO: Add support for time range to v1alpha1.CertificateAuthority
-			},
-		},
+		ValidFor:  ConvertTimeRange(source.ValidFor),
 	}, nil
 }
 
+// ConvertTimeRange converts public into private TimeRange. A nil TimeRange
+// is converted into one that starts at the epoch, i.e. that is always valid.
+func ConvertTimeRange(source *v1alpha1.TimeRange) *pbcommon.TimeRange {
+	if source == nil {
+		return &pbcommon.TimeRange{
+			Start: &timestamppb.Timestamp{Seconds: 0},
+		}
+	}
+	tr := &pbcommon.TimeRange{
+		Start: timestamppb.New(source.Start.Time),
+	}
+	if source.End != nil {
+		tr.End = timestamppb.New(source.End.Time)
+	}
+	return tr
+}
+
 // ConvertTransparencyLogInstance converts public into private
 // TransparencyLogInstance.
 func ConvertTransparencyLogInstance(source v1alpha1.TransparencyLogInstance) (*pbtrustroot.TransparencyLogInstance, error) {
@@ -166,6 +180,7 @@ func ConvertTransparencyLogInstance(source v1alpha1.TransparencyLogInstance) (*p
 	if err != nil {
 		return nil, err
 	}
+	pbpk.ValidFor = ConvertTimeRange(source.ValidFor)
 
 	return &pbtrustroot.TransparencyLogInstance{
 		BaseUrl:       source.BaseURL.String(),
@@ -247,16 +262,20 @@ func DeserializePublicKey(publicKey []byte) (*pbcommon.PublicKey, crypto.PublicK
 			keyDetails = pbcommon.PublicKeyDetails_PUBLIC_KEY_DETAILS_UNSPECIFIED
 		}
 	case *rsa.PublicKey:
-		switch k.Size() {
+		// Transparency logs (Rekor, and CT logs per RFC 6962) sign with
+		// PKCS#1 v1.5 when they use RSA keys.
+		switch k.Size() * 8 {
 		case 2048:
-			keyDetails = pbcommon.PublicKeyDetails_PKIX_RSA_PSS_2048_SHA256
+			keyDetails = pbcommon.PublicKeyDetails_PKIX_RSA_PKCS1V15_2048_SHA256
 		case 3072:
-			keyDetails = pbcommon.PublicKeyDetails_PKIX_RSA_PSS_3072_SHA256
+			keyDetails = pbcommon.PublicKeyDetails_PKIX_RSA_PKCS1V15_3072_SHA256
 		case 4096:
-			keyDetails = pbcommon.PublicKeyDetails_PKIX_RSA_PSS_4096_SHA256
+			keyDetails = pbcommon.PublicKeyDetails_PKIX_RSA_PKCS1V15_4096_SHA256
 		default:
 			keyDetails = pbcommon.PublicKeyDetails_PUBLIC_KEY_DETAILS_UNSPECIFIED
 		}
+	case ed25519.PublicKey:
+		keyDetails = pbcommon.PublicKeyDetails_PKIX_ED25519
 	default:
 		keyDetails = pbcommon.PublicKeyDetails_PUBLIC_KEY_DETAILS_UNSPECIFIED
 	}
@@ -266,7 +285,7 @@ func DeserializePublicKey(publicKey []byte) (*pbcommon.PublicKey, crypto.PublicK
 		KeyDetails: keyDetails,
 		ValidFor: &pbcommon.TimeRange{
 			Start: &timestamppb.Timestamp{
-				Seconds: 0, // TODO: Add support for time range to v1alpha.TransparencyLogInstance
+				Seconds: 0, // ConvertTransparencyLogInstance sets the time range of the v1alpha1.TransparencyLogInstance
 			},
 		},
 	}, pk, nil
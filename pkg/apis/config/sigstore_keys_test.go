@@ -16,9 +16,23 @@ package config
 
 import (
 	"bytes"
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
 	"encoding/pem"
 	"testing"
+	"time"
 
+	"github.com/sigstore/policy-controller/pkg/apis/policy/v1alpha1"
+	pbcommon "github.com/sigstore/protobuf-specs/gen/pb-go/common/v1"
+	"github.com/sigstore/sigstore/pkg/cryptoutils"
+	"google.golang.org/protobuf/encoding/protojson"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"knative.dev/pkg/apis"
 	. "knative.dev/pkg/configmap/testing"
 	_ "knative.dev/pkg/system/testing"
 )
@@ -95,3 +109,91 @@ func TestDefaultsSigstoreKeysConfigurationFromFile(t *testing.T) {
 		}
 	}
 }
+
+func TestConvertSigstoreKeysValidFor(t *testing.T) {
+	start := metav1.NewTime(time.Date(2023, 1, 1, 0, 0, 0, 0, time.UTC))
+	end := metav1.NewTime(time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC))
+	source := &v1alpha1.SigstoreKeys{
+		TimeStampAuthorities: []v1alpha1.CertificateAuthority{{
+			Subject:   v1alpha1.DistinguishedName{Organization: "local", CommonName: "Test TSA"},
+			URI:       *apis.HTTPS("tsa.example.com"),
+			CertChain: []byte(tsaCertChain),
+			ValidFor:  &v1alpha1.TimeRange{Start: start, End: &end},
+		}},
+		TLogs: []v1alpha1.TransparencyLogInstance{{
+			BaseURL:       *apis.HTTPS("rekor.example.com"),
+			HashAlgorithm: "sha-256",
+			PublicKey:     []byte(rekorPublicKey),
+			ValidFor:      &v1alpha1.TimeRange{Start: start},
+		}, {
+			BaseURL:       *apis.HTTPS("rekor2.example.com"),
+			HashAlgorithm: "sha-256",
+			PublicKey:     []byte(rekorPublicKey),
+		}},
+	}
+	sk, err := ConvertSigstoreKeys(context.Background(), source)
+	if err != nil {
+		t.Fatalf("ConvertSigstoreKeys() = %v", err)
+	}
+	// Round trip through the serialization of the ConfigMap entries.
+	b, err := protojson.Marshal(sk)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got := &SigstoreKeys{}
+	if err := parseSigstoreKeys(string(b), got); err != nil {
+		t.Fatalf("parseSigstoreKeys() = %v", err)
+	}
+
+	tsaValidFor := got.TimestampAuthorities[0].ValidFor
+	if !tsaValidFor.Start.AsTime().Equal(start.Time) || !tsaValidFor.End.AsTime().Equal(end.Time) {
+		t.Errorf("TSA validFor = %v, wanted [%v, %v]", tsaValidFor, start, end)
+	}
+	tlogValidFor := got.Tlogs[0].PublicKey.ValidFor
+	if !tlogValidFor.Start.AsTime().Equal(start.Time) || tlogValidFor.End != nil {
+		t.Errorf("TLog validFor = %v, wanted [%v, )", tlogValidFor, start)
+	}
+	// Without validFor, the entries are always valid.
+	if validFor := got.Tlogs[1].PublicKey.ValidFor; validFor.Start.AsTime().Unix() != 0 || validFor.End != nil {
+		t.Errorf("TLog validFor = %v, wanted [epoch, )", validFor)
+	}
+}
+
+func TestDeserializePublicKeyDetails(t *testing.T) {
+	ecpk, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	edpk, _, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	rsapk, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		name string
+		pk   crypto.PublicKey
+		want pbcommon.PublicKeyDetails
+	}{
+		{"ecdsa", ecpk.Public(), pbcommon.PublicKeyDetails_PKIX_ECDSA_P256_SHA_256},
+		{"ed25519", edpk, pbcommon.PublicKeyDetails_PKIX_ED25519},
+		{"rsa", rsapk.Public(), pbcommon.PublicKeyDetails_PKIX_RSA_PKCS1V15_2048_SHA256},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			pemBytes, err := cryptoutils.MarshalPublicKeyToPEM(tc.pk)
+			if err != nil {
+				t.Fatal(err)
+			}
+			pbpk, _, err := DeserializePublicKey(pemBytes)
+			if err != nil {
+				t.Fatalf("DeserializePublicKey() = %v", err)
+			}
+			if pbpk.KeyDetails != tc.want {
+				t.Errorf("DeserializePublicKey() key details = %s, wanted %s", pbpk.KeyDetails, tc.want)
+			}
+		})
+	}
+}
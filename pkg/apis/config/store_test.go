@@ -31,6 +31,8 @@ import (
 var ignoreStuff = cmp.Options{
 	protocmp.Transform(),
 	cmpopts.IgnoreUnexported(resource.Quantity{}),
+	// Ignore the compiled image patterns
+	cmpopts.IgnoreUnexported(ImagePolicyConfig{}),
 	// Ignore functional remote options
 	cmpopts.IgnoreTypes((remote.Option)(nil)),
 }
@@ -84,16 +84,47 @@ func Match(glob, image string) (bool, error) {
 
 	// TODO: do we want ":" to count as a separator like "/" is?
 
-	ref, err := name.ParseReference(image, name.WeakValidation)
+	return matchImage(re, image)
+}
+
+// CompileRegex compiles the regular expression so that it must match the
+// whole image reference, i.e. it is implicitly anchored at both ends.
+func CompileRegex(regex string) (*regexp.Regexp, error) {
+	return regexp.Compile(fmt.Sprintf("^(?:%s)$", regex))
+}
+
+// MatchRegex will return true if the image reference matches the requested
+// regular expression. The regular expression must match the whole image
+// reference, so it is implicitly anchored at both ends.
+//
+// Like Match, the regular expression is first matched against the fully
+// qualified image reference and then against the original image string.
+func MatchRegex(regex, image string) (bool, error) {
+	re, err := CompileRegex(regex)
 	if err != nil {
 		return false, err
 	}
+	return matchImage(re, image)
+}
 
+// MatchReference will return true if the compiled pattern (as returned by
+// Compile or CompileRegex) matches the already parsed image reference. The
+// image is the original, possibly non-fully-qualified, image string that the
+// reference was parsed from.
+func MatchReference(re *regexp.Regexp, ref name.Reference, image string) bool {
 	match := re.MatchString(ref.Name())
 	if !match && ref.Name() != image {
 		// If the image was not fully qualified, try matching the glob against the original non-fully-qualified.
 		// This should be a warning and this behavior should eventually be removed.
 		match = re.MatchString(image)
 	}
-	return match, nil
+	return match
+}
+
+func matchImage(re *regexp.Regexp, image string) (bool, error) {
+	ref, err := name.ParseReference(image, name.WeakValidation)
+	if err != nil {
+		return false, err
+	}
+	return MatchReference(re, ref, image), nil
 }
@@ -91,3 +91,38 @@ func TestGlobMatch(t *testing.T) {
 		})
 	}
 }
+
+func TestRegexMatch(t *testing.T) {
+	for _, c := range []struct {
+		image, regex string
+		wantMatch    bool
+		wantErr      bool
+	}{
+		{image: "foo", regex: `index\.docker\.io/library/foo:latest`, wantMatch: true},
+		{image: "foo", regex: `index\.docker\.io/library/.*`, wantMatch: true},
+		{image: "foo", regex: "foo", wantMatch: true}, // matches because of deprecated fallback logic.
+		{image: "ghcr.io/foo/bar", regex: `ghcr\.io/foo/(bar|baz)`, wantMatch: true},
+		{image: "ghcr.io/foo/qux", regex: `ghcr\.io/foo/(bar|baz)`, wantMatch: false},
+		{image: "ghcr.io/foo/bar:v1", regex: `ghcr\.io/foo/bar:v[0-9]+`, wantMatch: true},
+
+		// Regular expressions are anchored at both ends.
+		{image: "ghcr.io/foo/bar", regex: "foo/bar", wantMatch: false},
+		{image: "ghcr.io/foo/bar", regex: "ghcr.io", wantMatch: false},
+		{image: "ghcr.io/foo/bar", regex: "ghcr.io/foo/bar|foo", wantMatch: true},
+		{image: "ghcr.io/foo/barbaz", regex: "ghcr.io/foo/bar|foo", wantMatch: false},
+
+		// Various error cases.
+		{image: "invalid&name", regex: ".*", wantMatch: false, wantErr: true}, // invalid refs are not matched.
+		{image: "ghcr.io/foo", regex: "(", wantMatch: false, wantErr: true},   // invalid regexes are rejected.
+	} {
+		t.Run(c.image+"|"+c.regex, func(t *testing.T) {
+			match, err := MatchRegex(c.regex, c.image)
+			if match != c.wantMatch {
+				t.Errorf("match: got %t, want %t", match, c.wantMatch)
+			}
+			if gotErr := err != nil; gotErr != c.wantErr {
+				t.Errorf("err: got %v, want %t", err, c.wantErr)
+			}
+		})
+	}
+}
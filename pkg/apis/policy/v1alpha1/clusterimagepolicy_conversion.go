@@ -17,6 +17,7 @@ package v1alpha1
 import (
 	"context"
 	"fmt"
+	"slices"
 
 	"github.com/sigstore/policy-controller/pkg/apis/policy/v1beta1"
 	v1 "k8s.io/api/core/v1"
@@ -52,7 +53,9 @@ func (c *ClusterImagePolicy) ConvertFrom(ctx context.Context, obj apis.Convertib
 
 func (spec *ClusterImagePolicySpec) ConvertTo(ctx context.Context, sink *v1beta1.ClusterImagePolicySpec) error {
 	for _, image := range spec.Images {
-		sink.Images = append(sink.Images, v1beta1.ImagePattern{Glob: image.Glob})
+		v1beta1Image := v1beta1.ImagePattern{}
+		image.ConvertTo(ctx, &v1beta1Image)
+		sink.Images = append(sink.Images, v1beta1Image)
 	}
 	for _, authority := range spec.Authorities {
 		v1beta1Authority := v1beta1.Authority{}
@@ -75,9 +78,22 @@ func (spec *ClusterImagePolicySpec) ConvertTo(ctx context.Context, sink *v1beta1
 		spec.Policy.ConvertTo(ctx, sink.Policy)
 	}
 	sink.Mode = spec.Mode
+	sink.InfraFailureMode = spec.InfraFailureMode
+	if spec.AnnotateVerification != nil {
+		sink.AnnotateVerification = ptr.Bool(*spec.AnnotateVerification)
+	}
 	return nil
 }
 
+func (image *ImagePattern) ConvertTo(_ context.Context, sink *v1beta1.ImagePattern) {
+	sink.Glob = image.Glob
+	sink.Regex = image.Regex
+	sink.Digests = append(sink.Digests, image.Digests...)
+	for _, exclude := range image.Exclude {
+		sink.Exclude = append(sink.Exclude, v1beta1.ExcludePattern{Glob: exclude.Glob, Regex: exclude.Regex})
+	}
+}
+
 func (matchResource *MatchResource) ConvertTo(_ context.Context, sink *v1beta1.MatchResource) error {
 	sink.GroupVersionResource = *matchResource.GroupVersionResource.DeepCopy()
 	if matchResource.ResourceSelector != nil {
@@ -92,13 +108,15 @@ func (authority *Authority) ConvertTo(ctx context.Context, sink *v1beta1.Authori
 	sink.SignatureFormat = authority.SignatureFormat
 	if authority.CTLog != nil && authority.CTLog.URL != nil {
 		sink.CTLog = &v1beta1.TLog{
-			URL:          authority.CTLog.URL.DeepCopy(),
-			TrustRootRef: authority.CTLog.TrustRootRef,
+			URL:           authority.CTLog.URL.DeepCopy(),
+			TrustRootRef:  authority.CTLog.TrustRootRef,
+			TrustRootRefs: slices.Clone(authority.CTLog.TrustRootRefs),
 		}
 	}
-	if authority.RFC3161Timestamp != nil && authority.RFC3161Timestamp.TrustRootRef != "" {
+	if authority.RFC3161Timestamp != nil && (authority.RFC3161Timestamp.TrustRootRef != "" || len(authority.RFC3161Timestamp.TrustRootRefs) > 0) {
 		sink.RFC3161Timestamp = &v1beta1.RFC3161Timestamp{}
 		sink.RFC3161Timestamp.TrustRootRef = authority.RFC3161Timestamp.TrustRootRef
+		sink.RFC3161Timestamp.TrustRootRefs = slices.Clone(authority.RFC3161Timestamp.TrustRootRefs)
 	}
 	for _, source := range authority.Sources {
 		v1beta1Source := v1beta1.Source{}
@@ -125,8 +143,9 @@ func (authority *Authority) ConvertTo(ctx context.Context, sink *v1beta1.Authori
 	}
 	if authority.Keyless != nil {
 		sink.Keyless = &v1beta1.KeylessRef{
-			URL:          authority.Keyless.URL.DeepCopy(),
-			TrustRootRef: authority.Keyless.TrustRootRef,
+			URL:           authority.Keyless.URL.DeepCopy(),
+			TrustRootRef:  authority.Keyless.TrustRootRef,
+			TrustRootRefs: slices.Clone(authority.Keyless.TrustRootRefs),
 		}
 		for _, id := range authority.Keyless.Identities {
 			sink.Keyless.Identities = append(sink.Keyless.Identities, v1beta1.Identity{Issuer: id.Issuer, Subject: id.Subject, IssuerRegExp: id.IssuerRegExp, SubjectRegExp: id.SubjectRegExp})
@@ -216,8 +235,10 @@ func (key *KeyRef) ConvertTo(_ context.Context, sink *v1beta1.KeyRef) {
 }
 
 func (spec *ClusterImagePolicySpec) ConvertFrom(ctx context.Context, source *v1beta1.ClusterImagePolicySpec) error {
-	for _, image := range source.Images {
-		spec.Images = append(spec.Images, ImagePattern{Glob: image.Glob})
+	for i := range source.Images {
+		image := ImagePattern{}
+		image.ConvertFrom(ctx, &source.Images[i])
+		spec.Images = append(spec.Images, image)
 	}
 	for i := range source.Authorities {
 		authority := Authority{}
@@ -236,6 +257,10 @@ func (spec *ClusterImagePolicySpec) ConvertFrom(ctx context.Context, source *v1b
 		spec.Match = append(spec.Match, matchResource)
 	}
 	spec.Mode = source.Mode
+	spec.InfraFailureMode = source.InfraFailureMode
+	if source.AnnotateVerification != nil {
+		spec.AnnotateVerification = ptr.Bool(*source.AnnotateVerification)
+	}
 	if source.Policy != nil {
 		spec.Policy = &Policy{}
 		spec.Policy.ConvertFrom(ctx, source.Policy)
@@ -248,13 +273,15 @@ func (authority *Authority) ConvertFrom(ctx context.Context, source *v1beta1.Aut
 	authority.SignatureFormat = source.SignatureFormat
 	if source.CTLog != nil && source.CTLog.URL != nil {
 		authority.CTLog = &TLog{
-			URL:          source.CTLog.URL.DeepCopy(),
-			TrustRootRef: source.CTLog.TrustRootRef,
+			URL:           source.CTLog.URL.DeepCopy(),
+			TrustRootRef:  source.CTLog.TrustRootRef,
+			TrustRootRefs: slices.Clone(source.CTLog.TrustRootRefs),
 		}
 	}
-	if source.RFC3161Timestamp != nil && source.RFC3161Timestamp.TrustRootRef != "" {
+	if source.RFC3161Timestamp != nil && (source.RFC3161Timestamp.TrustRootRef != "" || len(source.RFC3161Timestamp.TrustRootRefs) > 0) {
 		authority.RFC3161Timestamp = &RFC3161Timestamp{}
 		authority.RFC3161Timestamp.TrustRootRef = source.RFC3161Timestamp.TrustRootRef
+		authority.RFC3161Timestamp.TrustRootRefs = slices.Clone(source.RFC3161Timestamp.TrustRootRefs)
 	}
 	for _, s := range source.Sources {
 		src := Source{}
@@ -281,8 +308,9 @@ func (authority *Authority) ConvertFrom(ctx context.Context, source *v1beta1.Aut
 	}
 	if source.Keyless != nil {
 		authority.Keyless = &KeylessRef{
-			URL:          source.Keyless.URL.DeepCopy(),
-			TrustRootRef: source.Keyless.TrustRootRef,
+			URL:           source.Keyless.URL.DeepCopy(),
+			TrustRootRef:  source.Keyless.TrustRootRef,
+			TrustRootRefs: slices.Clone(source.Keyless.TrustRootRefs),
 		}
 		for _, id := range source.Keyless.Identities {
 			authority.Keyless.Identities = append(authority.Keyless.Identities, Identity{Issuer: id.Issuer, Subject: id.Subject, IssuerRegExp: id.IssuerRegExp, SubjectRegExp: id.SubjectRegExp})
@@ -311,6 +339,15 @@ func (key *KeyRef) ConvertFrom(_ context.Context, source *v1beta1.KeyRef) {
 	key.HashAlgorithm = source.HashAlgorithm
 }
 
+func (image *ImagePattern) ConvertFrom(_ context.Context, source *v1beta1.ImagePattern) {
+	image.Glob = source.Glob
+	image.Regex = source.Regex
+	image.Digests = append(image.Digests, source.Digests...)
+	for _, exclude := range source.Exclude {
+		image.Exclude = append(image.Exclude, ExcludePattern{Glob: exclude.Glob, Regex: exclude.Regex})
+	}
+}
+
 func (matchResource *MatchResource) ConvertFrom(_ context.Context, source *v1beta1.MatchResource) error {
 	matchResource.GroupVersionResource = *source.GroupVersionResource.DeepCopy()
 	if source.ResourceSelector != nil {
@@ -21,6 +21,7 @@ import (
 	"github.com/google/go-cmp/cmp"
 	v1 "k8s.io/api/core/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"knative.dev/pkg/apis"
 	"knative.dev/pkg/ptr"
 
 	"github.com/sigstore/policy-controller/pkg/apis/policy/v1beta1"
@@ -48,6 +49,21 @@ func TestConversionRoundTripV1alpha1(t *testing.T) {
 				},
 			},
 		},
+	}, {name: "image exclusions",
+		in: &ClusterImagePolicy{
+			ObjectMeta: metav1.ObjectMeta{
+				Name: "test-cip",
+			},
+			Spec: ClusterImagePolicySpec{
+				Images: []ImagePattern{{
+					Glob:    "ghcr.io/example/**",
+					Exclude: []ExcludePattern{{Glob: "ghcr.io/example/debug*"}, {Regex: `ghcr\.io/example/.*-test`}},
+				}, {
+					Regex:   `quay\.io/example/(foo|bar)`,
+					Digests: []string{"sha256:5504f2a95018e3d8a52d80d9e1a128c6ea337581808ff9fe96f5628ce2336350"},
+				}},
+			},
+		},
 	}, {name: "key, keyless, and static, regexp",
 		in: &ClusterImagePolicy{
 			ObjectMeta: metav1.ObjectMeta{
@@ -89,8 +105,10 @@ func TestConversionRoundTripV1alpha1(t *testing.T) {
 				Name: "test-cip",
 			},
 			Spec: ClusterImagePolicySpec{
-				Mode:   "warn",
-				Images: []ImagePattern{{Glob: "*"}},
+				Mode:                 "warn",
+				AnnotateVerification: ptr.Bool(true),
+				InfraFailureMode:     "warn",
+				Images:               []ImagePattern{{Glob: "*"}},
 				Authorities: []Authority{
 					{Key: &KeyRef{
 						SecretRef: &v1.SecretReference{Name: "mysecret"}}},
@@ -142,6 +160,21 @@ func TestConversionRoundTripV1beta1(t *testing.T) {
 				Images: []v1beta1.ImagePattern{{Glob: "*"}},
 			},
 		},
+	}, {name: "image exclusions",
+		in: &v1beta1.ClusterImagePolicy{
+			ObjectMeta: metav1.ObjectMeta{
+				Name: "test-cip",
+			},
+			Spec: v1beta1.ClusterImagePolicySpec{
+				Images: []v1beta1.ImagePattern{{
+					Glob:    "ghcr.io/example/**",
+					Exclude: []v1beta1.ExcludePattern{{Glob: "ghcr.io/example/debug*"}, {Regex: `ghcr\.io/example/.*-test`}},
+				}, {
+					Regex:   `quay\.io/example/(foo|bar)`,
+					Digests: []string{"sha256:5504f2a95018e3d8a52d80d9e1a128c6ea337581808ff9fe96f5628ce2336350"},
+				}},
+			},
+		},
 	}, {name: "another",
 		in: &v1beta1.ClusterImagePolicy{
 			ObjectMeta: metav1.ObjectMeta{
@@ -313,6 +346,32 @@ func TestConversionRoundTripV1beta1(t *testing.T) {
 				},
 			},
 		},
+	}, {name: "keyless, ctlog, and rfc3161timestamp with multiple trust roots",
+		in: &v1beta1.ClusterImagePolicy{
+			ObjectMeta: metav1.ObjectMeta{
+				Name: "test-cip",
+			},
+			Spec: v1beta1.ClusterImagePolicySpec{
+				Images: []v1beta1.ImagePattern{{Glob: "*"}},
+				Authorities: []v1beta1.Authority{
+					{Keyless: &v1beta1.KeylessRef{
+						URL:           &apis.URL{Host: "fulcio.example.com"},
+						Identities:    []v1beta1.Identity{{SubjectRegExp: "subjectregexp", IssuerRegExp: "issuerregexp"}},
+						TrustRootRef:  "old-root",
+						TrustRootRefs: []string{"new-root"},
+					},
+						CTLog: &v1beta1.TLog{
+							URL:           &apis.URL{Host: "rekor.example.com"},
+							TrustRootRefs: []string{"old-root", "new-root"},
+						},
+					},
+					{Key: &v1beta1.KeyRef{
+						SecretRef: &v1.SecretReference{Name: "mysecret"}},
+						RFC3161Timestamp: &v1beta1.RFC3161Timestamp{TrustRootRefs: []string{"old-tsa-root", "new-tsa-root"}},
+					},
+				},
+			},
+		},
 	}}
 	for _, test := range tests {
 		t.Run(test.name, func(t *testing.T) {
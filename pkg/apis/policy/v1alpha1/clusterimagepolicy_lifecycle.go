@@ -22,12 +22,14 @@ const (
 	inlineKeysFailedReason     = "InliningKeysFailed"
 	inlinePoliciesFailedReason = "InliningPoliciesFailed"
 	updateCMFailedReason       = "UpdatingConfigMap"
+	trustRootsFailedReason     = "ResolvingTrustRoots"
 )
 
 var cipCondSet = apis.NewLivingConditionSet(
 	ClusterImagePolicyConditionKeysInlined,
 	ClusterImagePolicyConditionPoliciesInlined,
 	ClusterImagePolicyConditionCMUpdated,
+	ClusterImagePolicyConditionTrustRootsResolved,
 )
 
 // GetConditionSet retrieves the condition set for this resource.
@@ -91,3 +93,15 @@ func (cs *ClusterImagePolicyStatus) MarkCMUpdateFailed(msg string) {
 func (cs *ClusterImagePolicyStatus) MarkCMUpdatedOK() {
 	cipCondSet.Manage(cs).MarkTrue(ClusterImagePolicyConditionCMUpdated)
 }
+
+// MarkTrustRootsFailed surfaces a failure that the TrustRoots referenced by
+// the authorities do not exist or are not Ready.
+func (cs *ClusterImagePolicyStatus) MarkTrustRootsFailed(msg string) {
+	cipCondSet.Manage(cs).MarkFalse(ClusterImagePolicyConditionTrustRootsResolved, trustRootsFailedReason, msg)
+}
+
+// MarkTrustRootsOk marks the status saying that the referenced TrustRoots
+// are all Ready.
+func (cs *ClusterImagePolicyStatus) MarkTrustRootsOk() {
+	cipCondSet.Manage(cs).MarkTrue(ClusterImagePolicyConditionTrustRootsResolved)
+}
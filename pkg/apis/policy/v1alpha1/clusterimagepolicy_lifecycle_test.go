@@ -127,6 +127,9 @@ func TestClusterImagePolicyIsReady(t *testing.T) {
 				}, {
 					Type:   ClusterImagePolicyConditionCMUpdated,
 					Status: corev1.ConditionTrue,
+				}, {
+					Type:   ClusterImagePolicyConditionTrustRootsResolved,
+					Status: corev1.ConditionTrue,
 				}, {
 					Type:   ClusterImagePolicyConditionReady,
 					Status: corev1.ConditionTrue,
@@ -36,8 +36,8 @@ func TestImagePatternValidation(t *testing.T) {
 		errorString string
 		policy      ClusterImagePolicy
 	}{{
-		name:        "Should fail when glob is not present",
-		errorString: "missing field(s): spec.authorities, spec.images[0].glob",
+		name:        "Should fail when neither glob nor regex is present",
+		errorString: "expected exactly one, got neither: spec.images[0].glob, spec.images[0].regex\nmissing field(s): spec.authorities",
 		policy: ClusterImagePolicy{
 			Spec: ClusterImagePolicySpec{
 				Images: []ImagePattern{
@@ -69,6 +69,109 @@ func TestImagePatternValidation(t *testing.T) {
 				},
 			},
 		},
+	}, {
+		name:        "Should fail when both glob and regex are present",
+		errorString: "expected exactly one, got both: spec.images[0].glob, spec.images[0].regex\nmissing field(s): spec.authorities",
+		policy: ClusterImagePolicy{
+			Spec: ClusterImagePolicySpec{
+				Images: []ImagePattern{
+					{
+						Glob:  "ghcr.io/example/*",
+						Regex: `ghcr\.io/example/.*`,
+					},
+				},
+			},
+		},
+	}, {
+		name:        "Regex should fail with invalid regex",
+		errorString: "invalid value: ghcr.io/example/(: spec.images[0].regex\nregex is invalid: error parsing regexp: missing closing ): `ghcr.io/example/(`\nmissing field(s): spec.authorities",
+		policy: ClusterImagePolicy{
+			Spec: ClusterImagePolicySpec{
+				Images: []ImagePattern{
+					{
+						Regex: "ghcr.io/example/(",
+					},
+				},
+			},
+		},
+	}, {
+		name:        "Regex with digests should pass",
+		errorString: "missing field(s): spec.authorities",
+		policy: ClusterImagePolicy{
+			Spec: ClusterImagePolicySpec{
+				Images: []ImagePattern{
+					{
+						Regex:   `ghcr\.io/example/(foo|bar)`,
+						Digests: []string{"sha256:5504f2a95018e3d8a52d80d9e1a128c6ea337581808ff9fe96f5628ce2336350"},
+					},
+				},
+			},
+		},
+	}, {
+		name:        "Digests should fail with invalid digest",
+		errorString: "invalid value: sha256:nothex: spec.images[0].digests[1]\ndigest is invalid: found non-hex character in hash: n\nmissing field(s): spec.authorities",
+		policy: ClusterImagePolicy{
+			Spec: ClusterImagePolicySpec{
+				Images: []ImagePattern{
+					{
+						Glob:    "ghcr.io/example/*",
+						Digests: []string{"sha256:5504f2a95018e3d8a52d80d9e1a128c6ea337581808ff9fe96f5628ce2336350", "sha256:nothex"},
+					},
+				},
+			},
+		},
+	}, {
+		name:        "Exclude should fail when neither glob nor regex is present",
+		errorString: "expected exactly one, got neither: spec.images[0].exclude[0].glob, spec.images[0].exclude[0].regex\nmissing field(s): spec.authorities",
+		policy: ClusterImagePolicy{
+			Spec: ClusterImagePolicySpec{
+				Images: []ImagePattern{
+					{
+						Glob:    "ghcr.io/example/*",
+						Exclude: []ExcludePattern{{}},
+					},
+				},
+			},
+		},
+	}, {
+		name:        "Exclude should fail when both glob and regex are present",
+		errorString: "expected exactly one, got both: spec.images[0].exclude[0].glob, spec.images[0].exclude[0].regex\nmissing field(s): spec.authorities",
+		policy: ClusterImagePolicy{
+			Spec: ClusterImagePolicySpec{
+				Images: []ImagePattern{
+					{
+						Glob:    "ghcr.io/example/*",
+						Exclude: []ExcludePattern{{Glob: "ghcr.io/example/debug", Regex: ".*-debug"}},
+					},
+				},
+			},
+		},
+	}, {
+		name:        "Exclude should fail with invalid glob",
+		errorString: "invalid value: [: spec.images[0].exclude[1].glob\nglob is invalid: syntax error in pattern\nmissing field(s): spec.authorities",
+		policy: ClusterImagePolicy{
+			Spec: ClusterImagePolicySpec{
+				Images: []ImagePattern{
+					{
+						Glob:    "ghcr.io/example/*",
+						Exclude: []ExcludePattern{{Glob: "ghcr.io/example/debug"}, {Glob: "["}},
+					},
+				},
+			},
+		},
+	}, {
+		name:        "Exclude should fail with invalid regex",
+		errorString: "invalid value: (: spec.images[0].exclude[0].regex\nregex is invalid: error parsing regexp: missing closing ): `(`\nmissing field(s): spec.authorities",
+		policy: ClusterImagePolicy{
+			Spec: ClusterImagePolicySpec{
+				Images: []ImagePattern{
+					{
+						Glob:    "ghcr.io/example/*",
+						Exclude: []ExcludePattern{{Regex: "("}},
+					},
+				},
+			},
+		},
 	}, {
 		name:        "missing image and authorities in the spec",
 		errorString: "missing field(s): spec.authorities, spec.images",
@@ -340,6 +443,68 @@ func TestKeylessValidation(t *testing.T) {
 				},
 			},
 		},
+	}, {
+		name:        "Should fail when a trustRootRefs entry is empty",
+		errorString: "missing field(s): spec.authorities[0].ctlog.trustRootRefs[0], spec.authorities[0].keyless.trustRootRefs[1]",
+		policy: ClusterImagePolicy{
+			Spec: ClusterImagePolicySpec{
+				Images: []ImagePattern{
+					{
+						Glob: "globbityglob",
+					},
+				},
+				Authorities: []Authority{
+					{
+						Keyless: &KeylessRef{
+							URL: &apis.URL{
+								Host: "myhost",
+							},
+							Identities: []Identity{
+								{
+									Subject: "somesubject",
+									Issuer:  "someissuer",
+								},
+							},
+							TrustRootRefs: []string{"old-root", ""},
+						},
+						CTLog: &TLog{
+							TrustRootRefs: []string{""},
+						},
+					},
+				},
+			},
+		},
+	}, {
+		name: "Should pass when keyless refers to multiple trust roots",
+		policy: ClusterImagePolicy{
+			Spec: ClusterImagePolicySpec{
+				Images: []ImagePattern{
+					{
+						Glob: "globbityglob",
+					},
+				},
+				Authorities: []Authority{
+					{
+						Keyless: &KeylessRef{
+							URL: &apis.URL{
+								Host: "myhost",
+							},
+							Identities: []Identity{
+								{
+									Subject: "somesubject",
+									Issuer:  "someissuer",
+								},
+							},
+							TrustRootRef:  "old-root",
+							TrustRootRefs: []string{"new-root"},
+						},
+						CTLog: &TLog{
+							TrustRootRefs: []string{"old-root", "new-root"},
+						},
+					},
+				},
+			},
+		},
 	},
 	}
 
@@ -552,6 +717,41 @@ func TestModeValidation(t *testing.T) {
 	}
 }
 
+func TestInfraFailureModeValidation(t *testing.T) {
+	tests := []struct {
+		name             string
+		errorString      string
+		infraFailureMode string
+	}{{
+		name:             "Should work when infraFailureMode is empty",
+		infraFailureMode: "",
+	}, {
+		name:             "Should work with infraFailureMode enforce",
+		infraFailureMode: "enforce",
+	}, {
+		name:             "Should work with infraFailureMode warn",
+		infraFailureMode: "warn",
+	}, {
+		name:             "Should not work with infraFailureMode garbage",
+		infraFailureMode: "garbage",
+		errorString:      "invalid value: garbage: spec.infraFailureMode\nunsupported mode",
+	}}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			policy := ClusterImagePolicy{
+				Spec: ClusterImagePolicySpec{
+					Images:           []ImagePattern{{Glob: "globbityglob"}},
+					Authorities:      []Authority{{Static: &StaticRef{Action: "pass"}}},
+					InfraFailureMode: test.infraFailureMode,
+				},
+			}
+			err := policy.Validate(context.TODO())
+			validateError(t, test.errorString, "", err)
+		})
+	}
+}
+
 func TestAuthoritiesValidation(t *testing.T) {
 	tests := []struct {
 		name        string
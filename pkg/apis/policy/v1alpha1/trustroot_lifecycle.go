@@ -15,9 +15,15 @@
 package v1alpha1
 
 import (
+	corev1 "k8s.io/api/core/v1"
 	"knative.dev/pkg/apis"
 )
 
+const (
+	expiringReason           = "Expiring"
+	unsupportedTlogKeyReason = "UnsupportedTlogKeys"
+)
+
 var trCondSet = apis.NewLivingConditionSet(
 	TrustRootConditionKeysInlined,
 	TrustRootConditionCMUpdated,
@@ -72,3 +78,40 @@ func (ts *TrustRootStatus) MarkCMUpdateFailed(msg string) {
 func (ts *TrustRootStatus) MarkCMUpdatedOK() {
 	trCondSet.Manage(ts).MarkTrue(TrustRootConditionCMUpdated)
 }
+
+// MarkExpiring surfaces that parts of the TrustRoot expire soon, as a
+// Warning that does not affect its readiness.
+func (ts *TrustRootStatus) MarkExpiring(msg string) {
+	trCondSet.Manage(ts).SetCondition(apis.Condition{
+		Type:     TrustRootConditionNotExpiring,
+		Status:   corev1.ConditionFalse,
+		Reason:   expiringReason,
+		Message:  msg,
+		Severity: apis.ConditionSeverityWarning,
+	})
+}
+
+// MarkNotExpiring marks the status saying that no part of the TrustRoot
+// expires soon.
+func (ts *TrustRootStatus) MarkNotExpiring() {
+	trCondSet.Manage(ts).MarkTrue(TrustRootConditionNotExpiring)
+}
+
+// MarkTlogKeysUnsupported surfaces that some transparency log keys of the
+// TrustRoot are only used with signatureFormat bundle, as a Warning that
+// does not affect its readiness.
+func (ts *TrustRootStatus) MarkTlogKeysUnsupported(msg string) {
+	trCondSet.Manage(ts).SetCondition(apis.Condition{
+		Type:     TrustRootConditionTlogKeysSupported,
+		Status:   corev1.ConditionFalse,
+		Reason:   unsupportedTlogKeyReason,
+		Message:  msg,
+		Severity: apis.ConditionSeverityWarning,
+	})
+}
+
+// MarkTlogKeysSupported marks the status saying that all the transparency
+// log keys of the TrustRoot are used by every signature format.
+func (ts *TrustRootStatus) MarkTlogKeysSupported() {
+	trCondSet.Manage(ts).MarkTrue(TrustRootConditionTlogKeysSupported)
+}
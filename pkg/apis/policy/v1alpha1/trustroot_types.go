@@ -15,6 +15,7 @@
 package v1alpha1
 
 import (
+	corev1 "k8s.io/api/core/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/runtime/schema"
 	"knative.dev/pkg/apis"
@@ -64,6 +65,17 @@ const (
 	// TrustRootConditionCMUpdated is set to True when the inline representation
 	// has been successfully added to the ConfigMap holding all the TrustRoots.
 	TrustRootConditionCMUpdated apis.ConditionType = "ConfigMapUpdated"
+	// TrustRootConditionNotExpiring is set to False, with a Warning
+	// severity, when the TUF metadata, a certificate authority or a key of
+	// the TrustRoot expires within the expiry warning window. It does not
+	// affect the readiness of the TrustRoot.
+	TrustRootConditionNotExpiring apis.ConditionType = "NotExpiring"
+	// TrustRootConditionTlogKeysSupported is set to False, with a Warning
+	// severity, when some transparency log keys of the TrustRoot are not
+	// ECDSA keys. Those are only used by the authorities with
+	// signatureFormat bundle, the others ignore them. It does not affect the
+	// readiness of the TrustRoot.
+	TrustRootConditionTlogKeysSupported apis.ConditionType = "TlogKeysSupported"
 )
 
 // GetGroupVersionKind implements kmeta.OwnerRefable
@@ -84,6 +96,11 @@ type TrustRootSpec struct {
 	// +optional
 	Remote *Remote `json:"remote,omitempty"`
 
+	// OCI specifies initial root of trust & a TUF repository stored as an
+	// OCI artifact.
+	// +optional
+	OCI *OCIRepository `json:"oci,omitempty"`
+
 	// Repository contains the serialized TUF remote repository.
 	// +optional
 	Repository *Repository `json:"repository,omitempty"`
@@ -91,6 +108,40 @@ type TrustRootSpec struct {
 	// SigstoreKeys contains the serialized keys.
 	// +optional
 	SigstoreKeys *SigstoreKeys `json:"sigstoreKeys,omitempty"`
+
+	// TrustedRoot contains a Sigstore trusted root document
+	// (trusted_root.json), inline or referenced from a ConfigMap or a
+	// Secret.
+	// +optional
+	TrustedRoot *TrustedRoot `json:"trustedRoot,omitempty"`
+}
+
+// TrustedRoot is a Sigstore trusted root document, as published for example
+// in the TUF repository of a Sigstore instance. Only one of Data,
+// ConfigMapRef and SecretRef may be specified. The ConfigMap and Secret must
+// be in the same namespace as the policy-controller, and changes to them are
+// reflected into the TrustRoot.
+type TrustedRoot struct {
+	// Data is the trusted_root.json document.
+	// +optional
+	Data string `json:"data,omitempty"`
+	// ConfigMapRef references a ConfigMap holding the trusted_root.json
+	// document. If Key is not specified, `trusted_root.json` is used.
+	// +optional
+	ConfigMapRef *ConfigMapReference `json:"configMapRef,omitempty"`
+	// SecretRef references a Secret holding the trusted_root.json document.
+	// +optional
+	SecretRef *SecretKeyReference `json:"secretRef,omitempty"`
+}
+
+// SecretKeyReference references a key of a Secret.
+type SecretKeyReference struct {
+	// Name of the Secret.
+	Name string `json:"name"`
+	// Key of the Secret holding the data. If not specified,
+	// `trusted_root.json` is used.
+	// +optional
+	Key string `json:"key,omitempty"`
 }
 
 // Remote specifies the TUF with trusted initial root and remote mirror where
@@ -114,6 +165,36 @@ type Remote struct {
 	TrustedRootTarget string `json:"trustedRootTarget,omitempty"`
 }
 
+// OCIRepository specifies the TUF with trusted initial root and the OCI
+// artifact holding the repository to fetch updates from. Each layer of the
+// artifact is a metadata or target file of the repository, with its path
+// relative to the root of the repository (e.g. `timestamp.json` or
+// `targets/trusted_root.json`) in the `org.opencontainers.image.title`
+// annotation, as pushed by `oras push`.
+type OCIRepository struct {
+	// Root is the base64 encoded, json trusted initial root.
+	Root []byte `json:"root"`
+
+	// Image is the reference of the OCI artifact, for example:
+	// registry.example.com/sigstore/tuf:latest
+	Image string `json:"image"`
+
+	// Targets is where the targets live off of the root of the repository.
+	// If not specified 'targets' is defaulted.
+	// +optional
+	Targets string `json:"targets,omitempty"`
+
+	// TrustedRootTarget is the name of the target containing the JSON trusted
+	// root. If not specified, `trusted_root.json` is used.
+	// +optional
+	TrustedRootTarget string `json:"trustedRootTarget,omitempty"`
+
+	// PullSecrets is an optional list of references to secrets in the
+	// namespace of the policy-controller for pulling the artifact.
+	// +optional
+	PullSecrets []corev1.LocalObjectReference `json:"pullSecrets,omitempty"`
+}
+
 // Repository specifies an airgapped TUF. Specifies the trusted initial root as
 // well as a serialized repository.
 type Repository struct {
@@ -151,6 +232,11 @@ type TransparencyLogInstance struct {
 	HashAlgorithm string `json:"hashAlgorithm"`
 	// PEM encoded public key
 	PublicKey []byte `json:"publicKey"`
+	// ValidFor is the time range during which the log was valid, e.g. the
+	// entries integrated after its end are not trusted once the log has
+	// been rotated. If not specified, the log is always valid.
+	// +optional
+	ValidFor *TimeRange `json:"validFor,omitempty"`
 }
 
 type DistinguishedName struct {
@@ -167,12 +253,22 @@ type CertificateAuthority struct {
 	// The certificate chain for this CA in PEM format. Last entry in this
 	// chain is the Root certificate.
 	CertChain []byte `json:"certChain"`
+	// ValidFor is the time range during which the *entire* chain was valid.
+	// This is at max the longest interval when *all* certificates in the
+	// chain were valid, but it MAY be shorter, e.g. the certificates issued
+	// after its end are not trusted once the CA has been rotated. If not
+	// specified, the CA is always valid.
+	// +optional
+	ValidFor *TimeRange `json:"validFor,omitempty"`
+}
 
-	// TODO(vaikas): How to best represent this
-	// The time the *entire* chain was valid. This is at max the
-	// longest interval when *all* certificates in the chain where valid,
-	// but it MAY be shorter.
-	//       dev.sigstore.common.v1.TimeRange valid_for = 4;
+// TimeRange is a range of time, open ended if End is not specified.
+type TimeRange struct {
+	// Start is the beginning of the range.
+	Start metav1.Time `json:"start"`
+	// End is the end of the range.
+	// +optional
+	End *metav1.Time `json:"end,omitempty"`
 }
 
 // SigstoreKeys contains all the necessary Keys and Certificates for validating
@@ -205,6 +301,26 @@ type TrustRootStatus struct {
 	// * ObservedGeneration - the 'Generation' of the Broker that was last processed by the controller.
 	// * Conditions - the latest available observations of a resource's current state.
 	duckv1.Status `json:",inline"`
+
+	// LastRefreshTime is the last time the keys and certificates of the
+	// TrustRoot were successfully fetched and reflected into the ConfigMap.
+	// +optional
+	LastRefreshTime *metav1.Time `json:"lastRefreshTime,omitempty"`
+
+	// Expirations lists when the TUF metadata, the certificate chains and
+	// the validity windows of the TrustRoot expire, as of LastRefreshTime.
+	// +optional
+	Expirations []TrustRootExpiration `json:"expirations,omitempty"`
+}
+
+// TrustRootExpiration is the expiration time of a part of the TrustRoot.
+type TrustRootExpiration struct {
+	// Name of the part of the TrustRoot that expires, for example
+	// "tuf/timestamp", "certificateAuthorities[0].certChain" or
+	// "tLogs[1].validFor".
+	Name string `json:"name"`
+	// Expires is when it expires.
+	Expires metav1.Time `json:"expires"`
 }
 
 // GetStatus retrieves the status of the TrustRoot.
@@ -20,7 +20,9 @@ import (
 	"crypto/x509"
 	"encoding/json"
 
+	"github.com/google/go-containerregistry/pkg/name"
 	"github.com/sigstore/policy-controller/pkg/tuf"
+	"github.com/sigstore/sigstore-go/pkg/root"
 	"github.com/sigstore/sigstore/pkg/cryptoutils"
 
 	"knative.dev/pkg/apis"
@@ -41,26 +43,55 @@ func (tr *TrustRoot) Validate(ctx context.Context) *apis.FieldError {
 }
 
 func (spec *TrustRootSpec) Validate(ctx context.Context) (errors *apis.FieldError) {
-	if spec.Repository == nil && spec.Remote == nil && spec.SigstoreKeys == nil {
-		return apis.ErrMissingOneOf("repository", "remote", "sigstoreKeys")
-	}
-	if spec.Repository != nil {
-		if spec.Remote != nil || spec.SigstoreKeys != nil {
-			return apis.ErrMultipleOneOf("repository", "remote", "sigstoreKeys")
+	oneOf := []string{"repository", "remote", "oci", "sigstoreKeys", "trustedRoot"}
+	set := 0
+	for _, isSet := range []bool{spec.Repository != nil, spec.Remote != nil, spec.OCI != nil, spec.SigstoreKeys != nil, spec.TrustedRoot != nil} {
+		if isSet {
+			set++
 		}
+	}
+	switch {
+	case set == 0:
+		return apis.ErrMissingOneOf(oneOf...)
+	case set > 1:
+		return apis.ErrMultipleOneOf(oneOf...)
+	case spec.Repository != nil:
 		return spec.Repository.Validate(ctx).ViaField("repository")
+	case spec.Remote != nil:
+		return spec.Remote.Validate(ctx).ViaField("remote")
+	case spec.OCI != nil:
+		return spec.OCI.Validate(ctx).ViaField("oci")
+	case spec.SigstoreKeys != nil:
+		return spec.SigstoreKeys.Validate(ctx).ViaField("sigstoreKeys")
+	default:
+		return spec.TrustedRoot.Validate(ctx).ViaField("trustedRoot")
 	}
-	if spec.Remote != nil {
-		if spec.Repository != nil || spec.SigstoreKeys != nil {
-			return apis.ErrMultipleOneOf("repository", "remote", "sigstoreKeys")
+}
+
+func (trustedRoot *TrustedRoot) Validate(_ context.Context) (errors *apis.FieldError) {
+	set := 0
+	for _, isSet := range []bool{trustedRoot.Data != "", trustedRoot.ConfigMapRef != nil, trustedRoot.SecretRef != nil} {
+		if isSet {
+			set++
 		}
-		return spec.Remote.Validate(ctx).ViaField("remote")
 	}
-	if spec.SigstoreKeys != nil {
-		if spec.Remote != nil || spec.Repository != nil {
-			return apis.ErrMultipleOneOf("repository", "remote", "sigstoreKeys")
+	switch {
+	case set == 0:
+		return apis.ErrMissingOneOf("data", "configMapRef", "secretRef")
+	case set > 1:
+		return apis.ErrMultipleOneOf("data", "configMapRef", "secretRef")
+	case trustedRoot.Data != "":
+		if _, err := root.NewTrustedRootFromJSON([]byte(trustedRoot.Data)); err != nil {
+			errors = errors.Also(apis.ErrInvalidValue("invalid trusted root", "data", err.Error()))
+		}
+	case trustedRoot.ConfigMapRef != nil:
+		if trustedRoot.ConfigMapRef.Name == "" {
+			errors = errors.Also(apis.ErrMissingField("name").ViaField("configMapRef"))
+		}
+	default:
+		if trustedRoot.SecretRef.Name == "" {
+			errors = errors.Also(apis.ErrMissingField("name").ViaField("secretRef"))
 		}
-		return spec.SigstoreKeys.Validate(ctx).ViaField("sigstoreKeys")
 	}
 	return
 }
@@ -105,6 +136,21 @@ func (remote *Remote) Validate(ctx context.Context) (errors *apis.FieldError) {
 	return
 }
 
+func (oci *OCIRepository) Validate(ctx context.Context) (errors *apis.FieldError) {
+	if oci.Image == "" {
+		errors = errors.Also(apis.ErrMissingField("image"))
+	} else if _, err := name.ParseReference(oci.Image); err != nil {
+		errors = errors.Also(apis.ErrInvalidValue(oci.Image, "image", err.Error()))
+	}
+	for i, s := range oci.PullSecrets {
+		if s.Name == "" {
+			errors = errors.Also(apis.ErrMissingField("name").ViaFieldIndex("pullSecrets", i))
+		}
+	}
+	errors = errors.Also(ValidateRoot(ctx, oci.Root))
+	return
+}
+
 func (sigstoreKeys *SigstoreKeys) Validate(ctx context.Context) (errors *apis.FieldError) {
 	if len(sigstoreKeys.CertificateAuthorities) == 0 && len(sigstoreKeys.TimeStampAuthorities) == 0 {
 		errors = errors.Also(apis.ErrMissingOneOf("certificateAuthority", "timestampAuthorities"))
@@ -151,6 +197,7 @@ func ValidateCertificateAuthority(ctx context.Context, ca CertificateAuthority)
 	if len(ca.CertChain) == 0 {
 		errors = errors.Also(apis.ErrMissingField("certchain"))
 	}
+	errors = errors.Also(ValidateTimeRange(ctx, ca.ValidFor).ViaField("validFor"))
 	return
 }
 
@@ -169,6 +216,7 @@ func ValidateTimeStampAuthority(ctx context.Context, ca CertificateAuthority) (e
 	if len(leaves) > 1 {
 		errors = errors.Also(apis.ErrInvalidValue("certificate chain must contain at most one TSA certificate", "certChain"))
 	}
+	errors = errors.Also(ValidateTimeRange(ctx, ca.ValidFor).ViaField("validFor"))
 	return
 }
 
@@ -182,7 +230,7 @@ func ValidateDistinguishedName(_ context.Context, dn DistinguishedName) (errors
 	return
 }
 
-func ValidateTransparencyLogInstance(_ context.Context, tli TransparencyLogInstance) (errors *apis.FieldError) {
+func ValidateTransparencyLogInstance(ctx context.Context, tli TransparencyLogInstance) (errors *apis.FieldError) {
 	if tli.BaseURL.String() == "" {
 		errors = errors.Also(apis.ErrMissingField("baseURL"))
 	}
@@ -192,6 +240,20 @@ func ValidateTransparencyLogInstance(_ context.Context, tli TransparencyLogInsta
 	if len(tli.PublicKey) == 0 {
 		errors = errors.Also(apis.ErrMissingField("publicKey"))
 	}
+	errors = errors.Also(ValidateTimeRange(ctx, tli.ValidFor).ViaField("validFor"))
+	return
+}
+
+func ValidateTimeRange(_ context.Context, tr *TimeRange) (errors *apis.FieldError) {
+	if tr == nil {
+		return nil
+	}
+	if tr.Start.IsZero() {
+		errors = errors.Also(apis.ErrMissingField("start"))
+	}
+	if tr.End != nil && !tr.End.After(tr.Start.Time) {
+		errors = errors.Also(apis.ErrGeneric("must be after start", "end"))
+	}
 	return
 }
 
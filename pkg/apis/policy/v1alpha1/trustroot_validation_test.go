@@ -19,9 +19,12 @@ import (
 	"crypto/x509"
 	"encoding/base64"
 	"testing"
+	"time"
 
 	"github.com/sigstore/policy-controller/test"
 	"github.com/sigstore/sigstore/pkg/cryptoutils"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"knative.dev/pkg/apis"
 )
 
@@ -96,6 +99,79 @@ func TestTrustRootValidation(t *testing.T) {
 				},
 			},
 		},
+	}, {
+		name:        "Should fail with a missing oci.image",
+		errorString: "missing field(s): spec.oci.image",
+		trustroot: TrustRoot{
+			Spec: TrustRootSpec{
+				OCI: &OCIRepository{
+					Root: rootJSONDecoded,
+				},
+			},
+		},
+	}, {
+		name:        "Should fail with an invalid oci.image and pull secret",
+		errorString: "invalid value: registry.example.com/Invalid: spec.oci.image\ncould not parse reference: registry.example.com/Invalid\nmissing field(s): spec.oci.pullSecrets[0].name",
+		trustroot: TrustRoot{
+			Spec: TrustRootSpec{
+				OCI: &OCIRepository{
+					Root:        rootJSONDecoded,
+					Image:       "registry.example.com/Invalid",
+					PullSecrets: []corev1.LocalObjectReference{{}},
+				},
+			},
+		},
+	}, {
+		name: "Should work with a trustedRoot from a ConfigMap",
+		trustroot: TrustRoot{
+			Spec: TrustRootSpec{
+				TrustedRoot: &TrustedRoot{
+					ConfigMapRef: &ConfigMapReference{Name: "trusted-root"},
+				},
+			},
+		},
+	}, {
+		name:        "Should fail with a trustedRoot from a Secret without name",
+		errorString: "missing field(s): spec.trustedRoot.secretRef.name",
+		trustroot: TrustRoot{
+			Spec: TrustRootSpec{
+				TrustedRoot: &TrustedRoot{
+					SecretRef: &SecretKeyReference{Key: "trusted_root.json"},
+				},
+			},
+		},
+	}, {
+		name:        "Should fail with both trustedRoot data and secretRef",
+		errorString: "expected exactly one, got both: spec.trustedRoot.configMapRef, spec.trustedRoot.data, spec.trustedRoot.secretRef",
+		trustroot: TrustRoot{
+			Spec: TrustRootSpec{
+				TrustedRoot: &TrustedRoot{
+					Data:      "{}",
+					SecretRef: &SecretKeyReference{Name: "trusted-root"},
+				},
+			},
+		},
+	}, {
+		name:        "Should fail with invalid trustedRoot data",
+		errorString: "invalid value: invalid trusted root: spec.trustedRoot.data\nunsupported TrustedRoot media type: application/vnd.dev.sigstore.bundle+json;version=0.1",
+		trustroot: TrustRoot{
+			Spec: TrustRootSpec{
+				TrustedRoot: &TrustedRoot{
+					Data: `{"mediaType": "application/vnd.dev.sigstore.bundle+json;version=0.1"}`,
+				},
+			},
+		},
+	}, {
+		name:        "Should fail with both sigstoreKeys and trustedRoot",
+		errorString: "expected exactly one, got both: spec.oci, spec.remote, spec.repository, spec.sigstoreKeys, spec.trustedRoot",
+		trustroot: TrustRoot{
+			Spec: TrustRootSpec{
+				SigstoreKeys: &SigstoreKeys{},
+				TrustedRoot: &TrustedRoot{
+					ConfigMapRef: &ConfigMapReference{Name: "trusted-root"},
+				},
+			},
+		},
 	}, {
 		name:        "Should fail with an invalid repository.mirrorFS, not a gzip/tar file",
 		errorString: "invalid value: failed to construct a TUF client: spec.repository.mirrorFS\nfailed to uncompress: gzip: invalid header",
@@ -134,6 +210,8 @@ func TestTimeStampAuthorityValidation(t *testing.T) {
 	if err != nil {
 		t.Fatalf("unexpected error marshalling certificates to PEM: %v", err)
 	}
+	start := metav1.NewTime(time.Date(2023, 1, 1, 0, 0, 0, 0, time.UTC))
+	end := metav1.NewTime(time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC))
 
 	tests := []struct {
 		name        string
@@ -171,6 +249,41 @@ func TestTimeStampAuthorityValidation(t *testing.T) {
 			URI:       *apis.HTTPS("fulcio.example.com"),
 			CertChain: tooManyLeavesPem,
 		},
+	}, {
+		name: "Should work with a validFor",
+		tsa: CertificateAuthority{
+			Subject: DistinguishedName{
+				Organization: "fulcio-organization",
+				CommonName:   "fulcio-common-name",
+			},
+			URI:       *apis.HTTPS("fulcio.example.com"),
+			CertChain: pem,
+			ValidFor:  &TimeRange{Start: start, End: &end},
+		},
+	}, {
+		name:        "Should fail with a validFor ending before it starts",
+		errorString: "must be after start: validFor.end",
+		tsa: CertificateAuthority{
+			Subject: DistinguishedName{
+				Organization: "fulcio-organization",
+				CommonName:   "fulcio-common-name",
+			},
+			URI:       *apis.HTTPS("fulcio.example.com"),
+			CertChain: pem,
+			ValidFor:  &TimeRange{Start: end, End: &start},
+		},
+	}, {
+		name:        "Should fail with a validFor without start",
+		errorString: "missing field(s): validFor.start",
+		tsa: CertificateAuthority{
+			Subject: DistinguishedName{
+				Organization: "fulcio-organization",
+				CommonName:   "fulcio-common-name",
+			},
+			URI:       *apis.HTTPS("fulcio.example.com"),
+			CertChain: pem,
+			ValidFor:  &TimeRange{End: &end},
+		},
 	}}
 
 	for _, test := range tests {
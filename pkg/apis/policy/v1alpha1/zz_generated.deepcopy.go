@@ -87,7 +87,7 @@ func (in *Authority) DeepCopyInto(out *Authority) {
 	if in.RFC3161Timestamp != nil {
 		in, out := &in.RFC3161Timestamp, &out.RFC3161Timestamp
 		*out = new(RFC3161Timestamp)
-		**out = **in
+		(*in).DeepCopyInto(*out)
 	}
 	return
 }
@@ -112,6 +112,11 @@ func (in *CertificateAuthority) DeepCopyInto(out *CertificateAuthority) {
 		*out = make([]byte, len(*in))
 		copy(*out, *in)
 	}
+	if in.ValidFor != nil {
+		in, out := &in.ValidFor, &out.ValidFor
+		*out = new(TimeRange)
+		(*in).DeepCopyInto(*out)
+	}
 	return
 }
 
@@ -192,7 +197,9 @@ func (in *ClusterImagePolicySpec) DeepCopyInto(out *ClusterImagePolicySpec) {
 	if in.Images != nil {
 		in, out := &in.Images, &out.Images
 		*out = make([]ImagePattern, len(*in))
-		copy(*out, *in)
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
 	}
 	if in.Authorities != nil {
 		in, out := &in.Authorities, &out.Authorities
@@ -213,6 +220,11 @@ func (in *ClusterImagePolicySpec) DeepCopyInto(out *ClusterImagePolicySpec) {
 			(*in)[i].DeepCopyInto(&(*out)[i])
 		}
 	}
+	if in.AnnotateVerification != nil {
+		in, out := &in.AnnotateVerification, &out.AnnotateVerification
+		*out = new(bool)
+		**out = **in
+	}
 	return
 }
 
@@ -275,6 +287,22 @@ func (in *DistinguishedName) DeepCopy() *DistinguishedName {
 	return out
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *ExcludePattern) DeepCopyInto(out *ExcludePattern) {
+	*out = *in
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ExcludePattern.
+func (in *ExcludePattern) DeepCopy() *ExcludePattern {
+	if in == nil {
+		return nil
+	}
+	out := new(ExcludePattern)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *Identity) DeepCopyInto(out *Identity) {
 	*out = *in
@@ -294,6 +322,16 @@ func (in *Identity) DeepCopy() *Identity {
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *ImagePattern) DeepCopyInto(out *ImagePattern) {
 	*out = *in
+	if in.Digests != nil {
+		in, out := &in.Digests, &out.Digests
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	if in.Exclude != nil {
+		in, out := &in.Exclude, &out.Exclude
+		*out = make([]ExcludePattern, len(*in))
+		copy(*out, *in)
+	}
 	return
 }
 
@@ -346,6 +384,11 @@ func (in *KeylessRef) DeepCopyInto(out *KeylessRef) {
 		*out = new(KeyRef)
 		(*in).DeepCopyInto(*out)
 	}
+	if in.TrustRootRefs != nil {
+		in, out := &in.TrustRootRefs, &out.TrustRootRefs
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
 	if in.InsecureIgnoreSCT != nil {
 		in, out := &in.InsecureIgnoreSCT, &out.InsecureIgnoreSCT
 		*out = new(bool)
@@ -386,6 +429,32 @@ func (in *MatchResource) DeepCopy() *MatchResource {
 	return out
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *OCIRepository) DeepCopyInto(out *OCIRepository) {
+	*out = *in
+	if in.Root != nil {
+		in, out := &in.Root, &out.Root
+		*out = make([]byte, len(*in))
+		copy(*out, *in)
+	}
+	if in.PullSecrets != nil {
+		in, out := &in.PullSecrets, &out.PullSecrets
+		*out = make([]v1.LocalObjectReference, len(*in))
+		copy(*out, *in)
+	}
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new OCIRepository.
+func (in *OCIRepository) DeepCopy() *OCIRepository {
+	if in == nil {
+		return nil
+	}
+	out := new(OCIRepository)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *Policy) DeepCopyInto(out *Policy) {
 	*out = *in
@@ -435,6 +504,11 @@ func (in *Policy) DeepCopy() *Policy {
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *RFC3161Timestamp) DeepCopyInto(out *RFC3161Timestamp) {
 	*out = *in
+	if in.TrustRootRefs != nil {
+		in, out := &in.TrustRootRefs, &out.TrustRootRefs
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
 	return
 }
 
@@ -513,6 +587,22 @@ func (in *Repository) DeepCopy() *Repository {
 	return out
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *SecretKeyReference) DeepCopyInto(out *SecretKeyReference) {
+	*out = *in
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new SecretKeyReference.
+func (in *SecretKeyReference) DeepCopy() *SecretKeyReference {
+	if in == nil {
+		return nil
+	}
+	out := new(SecretKeyReference)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *SigstoreKeys) DeepCopyInto(out *SigstoreKeys) {
 	*out = *in
@@ -607,6 +697,11 @@ func (in *TLog) DeepCopyInto(out *TLog) {
 		*out = new(apis.URL)
 		(*in).DeepCopyInto(*out)
 	}
+	if in.TrustRootRefs != nil {
+		in, out := &in.TrustRootRefs, &out.TrustRootRefs
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
 	return
 }
 
@@ -620,6 +715,27 @@ func (in *TLog) DeepCopy() *TLog {
 	return out
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *TimeRange) DeepCopyInto(out *TimeRange) {
+	*out = *in
+	in.Start.DeepCopyInto(&out.Start)
+	if in.End != nil {
+		in, out := &in.End, &out.End
+		*out = (*in).DeepCopy()
+	}
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new TimeRange.
+func (in *TimeRange) DeepCopy() *TimeRange {
+	if in == nil {
+		return nil
+	}
+	out := new(TimeRange)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *TransparencyLogInstance) DeepCopyInto(out *TransparencyLogInstance) {
 	*out = *in
@@ -629,6 +745,11 @@ func (in *TransparencyLogInstance) DeepCopyInto(out *TransparencyLogInstance) {
 		*out = make([]byte, len(*in))
 		copy(*out, *in)
 	}
+	if in.ValidFor != nil {
+		in, out := &in.ValidFor, &out.ValidFor
+		*out = new(TimeRange)
+		(*in).DeepCopyInto(*out)
+	}
 	return
 }
 
@@ -670,6 +791,23 @@ func (in *TrustRoot) DeepCopyObject() runtime.Object {
 	return nil
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *TrustRootExpiration) DeepCopyInto(out *TrustRootExpiration) {
+	*out = *in
+	in.Expires.DeepCopyInto(&out.Expires)
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new TrustRootExpiration.
+func (in *TrustRootExpiration) DeepCopy() *TrustRootExpiration {
+	if in == nil {
+		return nil
+	}
+	out := new(TrustRootExpiration)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *TrustRootList) DeepCopyInto(out *TrustRootList) {
 	*out = *in
@@ -711,6 +849,11 @@ func (in *TrustRootSpec) DeepCopyInto(out *TrustRootSpec) {
 		*out = new(Remote)
 		(*in).DeepCopyInto(*out)
 	}
+	if in.OCI != nil {
+		in, out := &in.OCI, &out.OCI
+		*out = new(OCIRepository)
+		(*in).DeepCopyInto(*out)
+	}
 	if in.Repository != nil {
 		in, out := &in.Repository, &out.Repository
 		*out = new(Repository)
@@ -721,6 +864,11 @@ func (in *TrustRootSpec) DeepCopyInto(out *TrustRootSpec) {
 		*out = new(SigstoreKeys)
 		(*in).DeepCopyInto(*out)
 	}
+	if in.TrustedRoot != nil {
+		in, out := &in.TrustedRoot, &out.TrustedRoot
+		*out = new(TrustedRoot)
+		(*in).DeepCopyInto(*out)
+	}
 	return
 }
 
@@ -738,6 +886,17 @@ func (in *TrustRootSpec) DeepCopy() *TrustRootSpec {
 func (in *TrustRootStatus) DeepCopyInto(out *TrustRootStatus) {
 	*out = *in
 	in.Status.DeepCopyInto(&out.Status)
+	if in.LastRefreshTime != nil {
+		in, out := &in.LastRefreshTime, &out.LastRefreshTime
+		*out = (*in).DeepCopy()
+	}
+	if in.Expirations != nil {
+		in, out := &in.Expirations, &out.Expirations
+		*out = make([]TrustRootExpiration, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
 	return
 }
 
@@ -750,3 +909,29 @@ func (in *TrustRootStatus) DeepCopy() *TrustRootStatus {
 	in.DeepCopyInto(out)
 	return out
 }
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *TrustedRoot) DeepCopyInto(out *TrustedRoot) {
+	*out = *in
+	if in.ConfigMapRef != nil {
+		in, out := &in.ConfigMapRef, &out.ConfigMapRef
+		*out = new(ConfigMapReference)
+		**out = **in
+	}
+	if in.SecretRef != nil {
+		in, out := &in.SecretRef, &out.SecretRef
+		*out = new(SecretKeyReference)
+		**out = **in
+	}
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new TrustedRoot.
+func (in *TrustedRoot) DeepCopy() *TrustedRoot {
+	if in == nil {
+		return nil
+	}
+	out := new(TrustedRoot)
+	in.DeepCopyInto(out)
+	return out
+}
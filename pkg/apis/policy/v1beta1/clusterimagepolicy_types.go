@@ -94,17 +94,58 @@ type ClusterImagePolicySpec struct {
 	// warn - allow but warn
 	// +optional
 	Mode string `json:"mode,omitempty"`
+	// InfraFailureMode controls what happens when a policy can not be
+	// evaluated because of an infrastructure failure (e.g. the registry or
+	// the transparency log are unavailable, or a timeout), as opposed to a
+	// failed verification.
+	// enforce - Reject (default)
+	// warn - allow but warn (fail open)
+	// +optional
+	InfraFailureMode string `json:"infraFailureMode,omitempty"`
 	// Match allows selecting resources based on their properties.
 	// +optional
 	Match []MatchResource `json:"match,omitempty"`
+	// AnnotateVerification controls whether the admitted resources get an
+	// annotation recording, for each container and image volume, the
+	// policies that matched, the authorities that passed and the verified
+	// signers.
+	// +optional
+	AnnotateVerification *bool `json:"annotateVerification,omitempty"`
 }
 
 // ImagePattern defines a pattern and its associated authorties
 // If multiple patterns match a particular image, then ALL of
 // those authorities must be satisfied for the image to be admitted.
+// Exactly one of Glob or Regex must be specified.
 type ImagePattern struct {
 	// Glob defines a globbing pattern.
-	Glob string `json:"glob"`
+	// +optional
+	Glob string `json:"glob,omitempty"`
+	// Regex defines an RE2 regular expression that must match the whole
+	// image reference.
+	// +optional
+	Regex string `json:"regex,omitempty"`
+	// Digests restricts the pattern to images that resolve to one of the
+	// listed digests (e.g. sha256:abc...), so that a policy can pin the
+	// exact artifacts it applies to.
+	// +optional
+	Digests []string `json:"digests,omitempty"`
+	// Exclude defines a list of patterns for images that should not be
+	// matched by this ImagePattern even if they match the Glob.
+	// +optional
+	Exclude []ExcludePattern `json:"exclude,omitempty"`
+}
+
+// ExcludePattern defines an image pattern that is carved out of an
+// ImagePattern. Exactly one of Glob or Regex must be specified.
+type ExcludePattern struct {
+	// Glob defines a globbing pattern.
+	// +optional
+	Glob string `json:"glob,omitempty"`
+	// Regex defines a regular expression that must match the whole image
+	// reference.
+	// +optional
+	Regex string `json:"regex,omitempty"`
 }
 
 // The authorities block defines the rules for discovering and
@@ -203,6 +244,11 @@ type TLog struct {
 	// Use the Public Key from the referred TrustRoot.TLog
 	// +optional
 	TrustRootRef string `json:"trustRootRef,omitempty"`
+	// TrustRootRefs is a list of TrustRoots whose TLog Public Keys are all
+	// trusted, along with the one of TrustRootRef. This allows rotating
+	// from one TrustRoot to another without downtime.
+	// +optional
+	TrustRootRefs []string `json:"trustRootRefs,omitempty"`
 }
 
 // KeylessRef contains location of the validating certificate and the identities
@@ -220,6 +266,11 @@ type KeylessRef struct {
 	// Use the Certificate Chain from the referred TrustRoot.CertificateAuthorities and TrustRoot.CTLog
 	// +optional
 	TrustRootRef string `json:"trustRootRef,omitempty"`
+	// TrustRootRefs is a list of TrustRoots whose Certificate Chains and
+	// CTLogs are all trusted, along with the ones of TrustRootRef. This
+	// allows rotating from one TrustRoot to another without downtime.
+	// +optional
+	TrustRootRefs []string `json:"trustRootRefs,omitempty"`
 	// InsecureIgnoreSCT omits verifying if a certificate contains an embedded SCT
 	// +optional
 	InsecureIgnoreSCT *bool `json:"insecureIgnoreSCT,omitempty"`
@@ -341,6 +392,11 @@ type RFC3161Timestamp struct {
 	// Use the Certificate Chain from the referred TrustRoot.TimeStampAuthorities
 	// +optional
 	TrustRootRef string `json:"trustRootRef,omitempty"`
+	// TrustRootRefs is a list of TrustRoots whose TimeStampAuthorities are
+	// all trusted, along with the ones of TrustRootRef. This allows rotating
+	// from one TrustRoot to another without downtime.
+	// +optional
+	TrustRootRefs []string `json:"trustRootRefs,omitempty"`
 }
 
 // ClusterImagePolicyStatus represents the current state of a
@@ -21,6 +21,7 @@ import (
 	"path/filepath"
 	"regexp"
 
+	v1 "github.com/google/go-containerregistry/pkg/v1"
 	"github.com/sigstore/policy-controller/pkg/apis/glob"
 	"github.com/sigstore/policy-controller/pkg/apis/policy/common"
 	"github.com/sigstore/policy-controller/pkg/apis/signaturealgo"
@@ -60,6 +61,9 @@ func (spec *ClusterImagePolicySpec) Validate(ctx context.Context) (errors *apis.
 	if spec.Mode != "" && !common.ValidModes.Has(spec.Mode) {
 		errors = errors.Also(apis.ErrInvalidValue(spec.Mode, "mode", "unsupported mode"))
 	}
+	if spec.InfraFailureMode != "" && !common.ValidModes.Has(spec.InfraFailureMode) {
+		errors = errors.Also(apis.ErrInvalidValue(spec.InfraFailureMode, "infraFailureMode", "unsupported mode"))
+	}
 	for i, m := range spec.Match {
 		errors = errors.Also(m.Validate(ctx).ViaFieldIndex("match", i))
 	}
@@ -69,11 +73,40 @@ func (spec *ClusterImagePolicySpec) Validate(ctx context.Context) (errors *apis.
 	return
 }
 
-func (image *ImagePattern) Validate(_ context.Context) *apis.FieldError {
-	if image.Glob == "" {
-		return apis.ErrMissingField("glob")
+func (image *ImagePattern) Validate(ctx context.Context) *apis.FieldError {
+	var errs *apis.FieldError
+	switch {
+	case image.Glob == "" && image.Regex == "":
+		errs = errs.Also(apis.ErrMissingOneOf("glob", "regex"))
+	case image.Glob != "" && image.Regex != "":
+		errs = errs.Also(apis.ErrMultipleOneOf("glob", "regex"))
+	case image.Glob != "":
+		errs = errs.Also(ValidateGlob(image.Glob).ViaField("glob"))
+	default:
+		errs = errs.Also(ValidateRegex(image.Regex).ViaField("regex"))
+	}
+	for i, digest := range image.Digests {
+		if _, err := v1.NewHash(digest); err != nil {
+			errs = errs.Also(apis.ErrInvalidValue(digest, apis.CurrentField, fmt.Sprintf("digest is invalid: %v", err)).ViaFieldIndex("digests", i))
+		}
+	}
+	for i, exclude := range image.Exclude {
+		errs = errs.Also(exclude.Validate(ctx).ViaFieldIndex("exclude", i))
+	}
+	return errs
+}
+
+func (exclude *ExcludePattern) Validate(_ context.Context) *apis.FieldError {
+	switch {
+	case exclude.Glob == "" && exclude.Regex == "":
+		return apis.ErrMissingOneOf("glob", "regex")
+	case exclude.Glob != "" && exclude.Regex != "":
+		return apis.ErrMultipleOneOf("glob", "regex")
+	case exclude.Glob != "":
+		return ValidateGlob(exclude.Glob).ViaField("glob")
+	default:
+		return ValidateRegex(exclude.Regex).ViaField("regex")
 	}
-	return ValidateGlob(image.Glob).ViaField("glob")
 }
 
 func (matchResource *MatchResource) Validate(_ context.Context) *apis.FieldError {
@@ -112,6 +145,12 @@ func (authority *Authority) Validate(ctx context.Context) *apis.FieldError {
 	if authority.Keyless != nil {
 		errs = errs.Also(authority.Keyless.Validate(ctx).ViaField("keyless"))
 	}
+	if authority.CTLog != nil {
+		errs = errs.Also(validateTrustRootRefs(authority.CTLog.TrustRootRefs).ViaField("ctlog"))
+	}
+	if authority.RFC3161Timestamp != nil {
+		errs = errs.Also(validateTrustRootRefs(authority.RFC3161Timestamp.TrustRootRefs).ViaField("rfc3161timestamp"))
+	}
 	if authority.Static != nil {
 		errs = errs.Also(authority.Static.Validate(ctx).ViaField("static"))
 		// Attestations, Sources, RFC3161Timestamp, or CTLog do not make sense with static policy.
@@ -211,6 +250,19 @@ func (keyless *KeylessRef) Validate(ctx context.Context) *apis.FieldError {
 	for i, identity := range keyless.Identities {
 		errs = errs.Also(identity.Validate(ctx).ViaFieldIndex("identities", i))
 	}
+	errs = errs.Also(validateTrustRootRefs(keyless.TrustRootRefs))
+	return errs
+}
+
+// validateTrustRootRefs checks that the TrustRoots of trustRootRefs are
+// named.
+func validateTrustRootRefs(trustRootRefs []string) *apis.FieldError {
+	var errs *apis.FieldError
+	for i, ref := range trustRootRefs {
+		if ref == "" {
+			errs = errs.Also(apis.ErrMissingField(apis.CurrentField).ViaFieldIndex("trustRootRefs", i))
+		}
+	}
 	return errs
 }
 
@@ -87,7 +87,7 @@ func (in *Authority) DeepCopyInto(out *Authority) {
 	if in.RFC3161Timestamp != nil {
 		in, out := &in.RFC3161Timestamp, &out.RFC3161Timestamp
 		*out = new(RFC3161Timestamp)
-		**out = **in
+		(*in).DeepCopyInto(*out)
 	}
 	return
 }
@@ -169,7 +169,9 @@ func (in *ClusterImagePolicySpec) DeepCopyInto(out *ClusterImagePolicySpec) {
 	if in.Images != nil {
 		in, out := &in.Images, &out.Images
 		*out = make([]ImagePattern, len(*in))
-		copy(*out, *in)
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
 	}
 	if in.Authorities != nil {
 		in, out := &in.Authorities, &out.Authorities
@@ -190,6 +192,11 @@ func (in *ClusterImagePolicySpec) DeepCopyInto(out *ClusterImagePolicySpec) {
 			(*in)[i].DeepCopyInto(&(*out)[i])
 		}
 	}
+	if in.AnnotateVerification != nil {
+		in, out := &in.AnnotateVerification, &out.AnnotateVerification
+		*out = new(bool)
+		**out = **in
+	}
 	return
 }
 
@@ -236,6 +243,22 @@ func (in *ConfigMapReference) DeepCopy() *ConfigMapReference {
 	return out
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *ExcludePattern) DeepCopyInto(out *ExcludePattern) {
+	*out = *in
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ExcludePattern.
+func (in *ExcludePattern) DeepCopy() *ExcludePattern {
+	if in == nil {
+		return nil
+	}
+	out := new(ExcludePattern)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *Identity) DeepCopyInto(out *Identity) {
 	*out = *in
@@ -255,6 +278,16 @@ func (in *Identity) DeepCopy() *Identity {
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *ImagePattern) DeepCopyInto(out *ImagePattern) {
 	*out = *in
+	if in.Digests != nil {
+		in, out := &in.Digests, &out.Digests
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	if in.Exclude != nil {
+		in, out := &in.Exclude, &out.Exclude
+		*out = make([]ExcludePattern, len(*in))
+		copy(*out, *in)
+	}
 	return
 }
 
@@ -307,6 +340,11 @@ func (in *KeylessRef) DeepCopyInto(out *KeylessRef) {
 		*out = new(KeyRef)
 		(*in).DeepCopyInto(*out)
 	}
+	if in.TrustRootRefs != nil {
+		in, out := &in.TrustRootRefs, &out.TrustRootRefs
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
 	if in.InsecureIgnoreSCT != nil {
 		in, out := &in.InsecureIgnoreSCT, &out.InsecureIgnoreSCT
 		*out = new(bool)
@@ -396,6 +434,11 @@ func (in *Policy) DeepCopy() *Policy {
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *RFC3161Timestamp) DeepCopyInto(out *RFC3161Timestamp) {
 	*out = *in
+	if in.TrustRootRefs != nil {
+		in, out := &in.TrustRootRefs, &out.TrustRootRefs
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
 	return
 }
 
@@ -476,6 +519,11 @@ func (in *TLog) DeepCopyInto(out *TLog) {
 		*out = new(apis.URL)
 		(*in).DeepCopyInto(*out)
 	}
+	if in.TrustRootRefs != nil {
+		in, out := &in.TrustRootRefs, &out.TrustRootRefs
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
 	return
 }
 
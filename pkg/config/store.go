@@ -17,9 +17,15 @@ package config
 
 import (
 	"context"
+	"errors"
+	"fmt"
 	"strconv"
+	"strings"
+	"time"
 
+	"github.com/google/go-containerregistry/pkg/name"
 	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/util/validation"
 	"knative.dev/pkg/configmap"
 )
 
@@ -43,6 +49,40 @@ const (
 	NoMatchPolicyKey = "no-match-policy"
 
 	FailOnEmptyAuthorities = "fail-on-empty-authorities"
+
+	JSONDenialDetailsKey = "json-denial-details"
+
+	RegistryOperationTimeoutKey = "registry-operation-timeout"
+
+	AuthorityTimeoutKey = "authority-timeout"
+
+	PolicyTimeoutKey = "policy-timeout"
+
+	RegistryMaxRetriesKey = "registry-max-retries"
+
+	RegistryQPSKey = "registry-qps"
+
+	RegistryBurstKey = "registry-burst"
+
+	RegistryCircuitBreakerThresholdKey = "registry-circuit-breaker-threshold"
+
+	RegistryCircuitBreakerCooldownKey = "registry-circuit-breaker-cooldown"
+
+	OwnerTrustChainSecretKey = "owner-trust-chain-secret"
+
+	SkipUnchangedImagesKey = "skip-unchanged-images"
+
+	DefaultPullSecretsKey = "default-pull-secrets"
+
+	RegistryMirrorsKey = "registry-mirrors"
+
+	// DefaultRegistryMaxRetries is the default number of times a registry
+	// request is retried.
+	DefaultRegistryMaxRetries = 2
+
+	// DefaultRegistryCircuitBreakerCooldown is the default time a registry
+	// circuit breaker stays open.
+	DefaultRegistryCircuitBreakerCooldown = 30 * time.Second
 )
 
 // PolicyControllerConfig controls the behaviour of policy-controller that needs
@@ -56,10 +96,163 @@ type PolicyControllerConfig struct {
 	NoMatchPolicy string `json:"no-match-policy"`
 	// FailOnEmptyAuthorities configures the validating webhook to allow creating CIP without a list authorities
 	FailOnEmptyAuthorities bool `json:"fail-on-empty-authorities"`
+	// JSONDenialDetails configures the validating webhook to include a
+	// machine readable JSON description of why an image failed a policy in
+	// the details of the response.
+	JSONDenialDetails bool `json:"json-denial-details"`
+	// RegistryOperationTimeout is the time budget of a single request to a
+	// registry. Zero means no budget other than the ones of the authority,
+	// the policy and the admission request.
+	RegistryOperationTimeout time.Duration `json:"registry-operation-timeout"`
+	// AuthorityTimeout is the time budget for validating an authority of a
+	// policy. Zero means no budget other than the ones of the policy and
+	// the admission request.
+	AuthorityTimeout time.Duration `json:"authority-timeout"`
+	// PolicyTimeout is the time budget for validating an image against a
+	// policy. Zero means no budget other than the one of the admission
+	// request.
+	PolicyTimeout time.Duration `json:"policy-timeout"`
+	// RegistryMaxRetries is the number of times a registry read is retried
+	// when it fails with a retryable status (e.g. 429 or 503) or a network
+	// error.
+	RegistryMaxRetries int `json:"registry-max-retries"`
+	// RegistryQPS limits the requests per second made to each registry.
+	// Zero means no limit.
+	RegistryQPS float64 `json:"registry-qps"`
+	// RegistryBurst is the number of requests that can be made to a
+	// registry at once above RegistryQPS. Zero means RegistryQPS, rounded up.
+	RegistryBurst int `json:"registry-burst"`
+	// RegistryCircuitBreakerThreshold is the number of consecutive failed
+	// requests to a registry after which further requests fail without being
+	// made, for RegistryCircuitBreakerCooldown. Zero disables the circuit
+	// breaker.
+	RegistryCircuitBreakerThreshold int `json:"registry-circuit-breaker-threshold"`
+	// RegistryCircuitBreakerCooldown is the time a registry circuit breaker
+	// stays open before letting a request through to probe the registry.
+	RegistryCircuitBreakerCooldown time.Duration `json:"registry-circuit-breaker-cooldown"`
+	// OwnerTrustChainSecret is the name of a Secret in the policy-controller
+	// namespace holding the key used to stamp the pod templates of the
+	// resources that passed verification, so that the Pods created from them
+	// (e.g. the Pods of a Deployment) are not verified again. Empty disables
+	// the owner trust chain.
+	OwnerTrustChainSecret string `json:"owner-trust-chain-secret"`
+	// SkipUnchangedImages configures the validating webhook to not verify
+	// again, on UPDATE, the images that are unchanged from the old object,
+	// unless the policies that match them changed since it was admitted.
+	// The record of the verification on the old object is authenticated with
+	// the key of the OwnerTrustChainSecret, so this has no effect without it.
+	SkipUnchangedImages bool `json:"skip-unchanged-images"`
+	// DefaultPullSecrets are Secrets in the policy-controller namespace whose
+	// credentials are used for the registry operations when the ones of the
+	// workload (its pull secrets, or the signaturePullSecrets of the
+	// authority) do not apply.
+	DefaultPullSecrets []DefaultPullSecret `json:"default-pull-secrets"`
+	// RegistryMirrors rewrite the repositories from where the signatures,
+	// attestations and config files of the images are fetched. The policies
+	// still match the images by their names in the resources.
+	RegistryMirrors []RegistryMirror `json:"registry-mirrors"`
+}
+
+// DefaultPullSecret is one of the DefaultPullSecrets.
+type DefaultPullSecret struct {
+	// Registry limits the use of the Secret to the images of a registry host
+	// (e.g. "ghcr.io"). Empty means any registry.
+	Registry string `json:"registry,omitempty"`
+	// Name is the name of the Secret.
+	Name string `json:"name"`
+}
+
+// RegistryMirror is one of the RegistryMirrors.
+type RegistryMirror struct {
+	// Prefix is the canonical name of the registry host (e.g.
+	// "index.docker.io") or of the repository prefix (e.g.
+	// "ghcr.io/sigstore") that is mirrored.
+	Prefix string `json:"prefix"`
+	// Mirror is the registry host or repository prefix that replaces the
+	// Prefix (e.g. "mirror.corp/docker.io").
+	Mirror string `json:"mirror"`
+}
+
+// parseRegistryMirrors parses a comma separated list of
+// "<prefix>=<mirror>", where both are registry hosts or repository
+// prefixes.
+func parseRegistryMirrors(val string) ([]RegistryMirror, error) {
+	var ret []RegistryMirror
+	for _, entry := range strings.Split(val, ",") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+		prefix, mirror, ok := strings.Cut(entry, "=")
+		if !ok {
+			return nil, fmt.Errorf("invalid %s entry %q: want <prefix>=<mirror>", RegistryMirrorsKey, entry)
+		}
+		canonical, err := canonicalRepositoryPrefix(strings.TrimSpace(prefix))
+		if err != nil {
+			return nil, fmt.Errorf("invalid %s entry %q: %w", RegistryMirrorsKey, entry, err)
+		}
+		mirror = strings.TrimSpace(mirror)
+		if _, err := canonicalRepositoryPrefix(mirror); err != nil {
+			return nil, fmt.Errorf("invalid %s entry %q: %w", RegistryMirrorsKey, entry, err)
+		}
+		ret = append(ret, RegistryMirror{Prefix: canonical, Mirror: mirror})
+	}
+	return ret, nil
+}
+
+// canonicalRepositoryPrefix returns the canonical name of a registry host or
+// repository prefix, e.g. "index.docker.io" for "docker.io".
+func canonicalRepositoryPrefix(prefix string) (string, error) {
+	if prefix == "" {
+		return "", errors.New("empty registry")
+	}
+	if !strings.Contains(prefix, "/") {
+		registry, err := name.NewRegistry(prefix)
+		if err != nil {
+			return "", err
+		}
+		return registry.RegistryStr(), nil
+	}
+	repo, err := name.NewRepository(prefix)
+	if err != nil {
+		return "", err
+	}
+	return repo.Name(), nil
+}
+
+// parseDefaultPullSecrets parses a comma separated list of Secret names,
+// each optionally scoped to a registry host with "<registry>=<name>".
+func parseDefaultPullSecrets(val string) ([]DefaultPullSecret, error) {
+	var ret []DefaultPullSecret
+	for _, entry := range strings.Split(val, ",") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+		var dps DefaultPullSecret
+		if registry, secretName, ok := strings.Cut(entry, "="); ok {
+			dps.Registry, dps.Name = strings.TrimSpace(registry), strings.TrimSpace(secretName)
+			if dps.Registry == "" {
+				return nil, fmt.Errorf("invalid %s entry %q: empty registry", DefaultPullSecretsKey, entry)
+			}
+		} else {
+			dps.Name = entry
+		}
+		if errs := validation.IsDNS1123Subdomain(dps.Name); len(errs) > 0 {
+			return nil, fmt.Errorf("invalid %s entry %q: %s", DefaultPullSecretsKey, entry, strings.Join(errs, ", "))
+		}
+		ret = append(ret, dps)
+	}
+	return ret, nil
 }
 
 func NewPolicyControllerConfigFromMap(data map[string]string) (*PolicyControllerConfig, error) {
-	ret := &PolicyControllerConfig{NoMatchPolicy: "deny", FailOnEmptyAuthorities: true}
+	ret := &PolicyControllerConfig{
+		NoMatchPolicy:                  "deny",
+		FailOnEmptyAuthorities:         true,
+		RegistryMaxRetries:             DefaultRegistryMaxRetries,
+		RegistryCircuitBreakerCooldown: DefaultRegistryCircuitBreakerCooldown,
+	}
 	switch data[NoMatchPolicyKey] {
 	case DenyAll:
 		ret.NoMatchPolicy = DenyAll
@@ -70,6 +263,71 @@ func NewPolicyControllerConfigFromMap(data map[string]string) (*PolicyController
 	default:
 		ret.NoMatchPolicy = DenyAll
 	}
+	if val, ok := data[JSONDenialDetailsKey]; ok {
+		var err error
+		if ret.JSONDenialDetails, err = strconv.ParseBool(val); err != nil {
+			return ret, err
+		}
+	}
+	for key, d := range map[string]*time.Duration{
+		RegistryOperationTimeoutKey:       &ret.RegistryOperationTimeout,
+		AuthorityTimeoutKey:               &ret.AuthorityTimeout,
+		PolicyTimeoutKey:                  &ret.PolicyTimeout,
+		RegistryCircuitBreakerCooldownKey: &ret.RegistryCircuitBreakerCooldown,
+	} {
+		if val, ok := data[key]; ok && val != "" {
+			var err error
+			if *d, err = time.ParseDuration(val); err != nil {
+				return ret, fmt.Errorf("invalid %s: %w", key, err)
+			}
+			if *d < 0 {
+				return ret, fmt.Errorf("invalid %s: must not be negative", key)
+			}
+		}
+	}
+	for key, i := range map[string]*int{
+		RegistryMaxRetriesKey:              &ret.RegistryMaxRetries,
+		RegistryBurstKey:                   &ret.RegistryBurst,
+		RegistryCircuitBreakerThresholdKey: &ret.RegistryCircuitBreakerThreshold,
+	} {
+		if val, ok := data[key]; ok && val != "" {
+			var err error
+			if *i, err = strconv.Atoi(val); err != nil {
+				return ret, fmt.Errorf("invalid %s: %w", key, err)
+			}
+			if *i < 0 {
+				return ret, fmt.Errorf("invalid %s: must not be negative", key)
+			}
+		}
+	}
+	if val, ok := data[RegistryQPSKey]; ok && val != "" {
+		var err error
+		if ret.RegistryQPS, err = strconv.ParseFloat(val, 64); err != nil {
+			return ret, fmt.Errorf("invalid %s: %w", RegistryQPSKey, err)
+		}
+		if ret.RegistryQPS < 0 {
+			return ret, fmt.Errorf("invalid %s: must not be negative", RegistryQPSKey)
+		}
+	}
+	ret.OwnerTrustChainSecret = data[OwnerTrustChainSecretKey]
+	if val, ok := data[DefaultPullSecretsKey]; ok {
+		var err error
+		if ret.DefaultPullSecrets, err = parseDefaultPullSecrets(val); err != nil {
+			return ret, err
+		}
+	}
+	if val, ok := data[RegistryMirrorsKey]; ok {
+		var err error
+		if ret.RegistryMirrors, err = parseRegistryMirrors(val); err != nil {
+			return ret, err
+		}
+	}
+	if val, ok := data[SkipUnchangedImagesKey]; ok && val != "" {
+		var err error
+		if ret.SkipUnchangedImages, err = strconv.ParseBool(val); err != nil {
+			return ret, fmt.Errorf("invalid %s: %w", SkipUnchangedImagesKey, err)
+		}
+	}
 	if val, ok := data[FailOnEmptyAuthorities]; ok {
 		var err error
 		ret.FailOnEmptyAuthorities, err = strconv.ParseBool(val)
@@ -100,8 +358,10 @@ func FromContextOrDefaults(ctx context.Context) *PolicyControllerConfig {
 		return cfg
 	}
 	return &PolicyControllerConfig{
-		NoMatchPolicy:          DenyAll,
-		FailOnEmptyAuthorities: true,
+		NoMatchPolicy:                  DenyAll,
+		FailOnEmptyAuthorities:         true,
+		RegistryMaxRetries:             DefaultRegistryMaxRetries,
+		RegistryCircuitBreakerCooldown: DefaultRegistryCircuitBreakerCooldown,
 	}
 }
 
@@ -17,6 +17,7 @@ package config
 import (
 	"context"
 	"testing"
+	"time"
 
 	"github.com/google/go-cmp/cmp"
 	logtesting "knative.dev/pkg/logging/testing"
@@ -27,6 +28,11 @@ import (
 type testData struct {
 	noMatchPolicy          string
 	failOnEmptyAuthorities bool
+	jsonDenialDetails      bool
+	policyTimeout          time.Duration
+	registryQPS            float64
+	ownerTrustChainSecret  string
+	skipUnchangedImages    bool
 }
 
 var testfiles = map[string]testData{
@@ -35,6 +41,11 @@ var testfiles = map[string]testData{
 	"warn-all":                {noMatchPolicy: WarnAll, failOnEmptyAuthorities: true},
 	"deny-all-default":        {noMatchPolicy: DenyAll, failOnEmptyAuthorities: true},
 	"allow-empty-authorities": {noMatchPolicy: DenyAll, failOnEmptyAuthorities: false},
+	"json-denial-details":     {noMatchPolicy: DenyAll, failOnEmptyAuthorities: true, jsonDenialDetails: true},
+	"timeouts":                {noMatchPolicy: DenyAll, failOnEmptyAuthorities: true, policyTimeout: 15 * time.Second},
+	"registry":                {noMatchPolicy: DenyAll, failOnEmptyAuthorities: true, registryQPS: 20},
+	"owner-trust-chain":       {noMatchPolicy: DenyAll, failOnEmptyAuthorities: true, ownerTrustChainSecret: "owner-trust-chain"},
+	"skip-unchanged-images":   {noMatchPolicy: DenyAll, failOnEmptyAuthorities: true, skipUnchangedImages: true},
 }
 
 func TestStoreLoadWithContext(t *testing.T) {
@@ -55,6 +66,21 @@ func TestStoreLoadWithContext(t *testing.T) {
 			if diff := cmp.Diff(want.failOnEmptyAuthorities, expected.FailOnEmptyAuthorities); diff != "" {
 				t.Error("Unexpected defaults config (-want, +got):", diff)
 			}
+			if diff := cmp.Diff(want.jsonDenialDetails, expected.JSONDenialDetails); diff != "" {
+				t.Error("Unexpected defaults config (-want, +got):", diff)
+			}
+			if diff := cmp.Diff(want.policyTimeout, expected.PolicyTimeout); diff != "" {
+				t.Error("Unexpected defaults config (-want, +got):", diff)
+			}
+			if diff := cmp.Diff(want.registryQPS, expected.RegistryQPS); diff != "" {
+				t.Error("Unexpected defaults config (-want, +got):", diff)
+			}
+			if diff := cmp.Diff(want.ownerTrustChainSecret, expected.OwnerTrustChainSecret); diff != "" {
+				t.Error("Unexpected defaults config (-want, +got):", diff)
+			}
+			if diff := cmp.Diff(want.skipUnchangedImages, expected.SkipUnchangedImages); diff != "" {
+				t.Error("Unexpected defaults config (-want, +got):", diff)
+			}
 			if diff := cmp.Diff(expected, config); diff != "" {
 				t.Error("Unexpected defaults config (-want, +got):", diff)
 			}
@@ -80,3 +106,61 @@ func TestStoreLoadWithContextOrDefaults(t *testing.T) {
 		})
 	}
 }
+
+func TestInvalidTimeouts(t *testing.T) {
+	for _, key := range []string{RegistryOperationTimeoutKey, AuthorityTimeoutKey, PolicyTimeoutKey, RegistryCircuitBreakerCooldownKey} {
+		for _, val := range []string{"ten seconds", "-1s"} {
+			if _, err := NewPolicyControllerConfigFromMap(map[string]string{key: val}); err == nil {
+				t.Errorf("NewPolicyControllerConfigFromMap(%s: %s) did not fail", key, val)
+			}
+		}
+	}
+}
+
+func TestInvalidRegistrySettings(t *testing.T) {
+	for _, key := range []string{RegistryMaxRetriesKey, RegistryQPSKey, RegistryBurstKey, RegistryCircuitBreakerThresholdKey} {
+		for _, val := range []string{"many", "-1"} {
+			if _, err := NewPolicyControllerConfigFromMap(map[string]string{key: val}); err == nil {
+				t.Errorf("NewPolicyControllerConfigFromMap(%s: %s) did not fail", key, val)
+			}
+		}
+	}
+}
+
+func TestDefaultPullSecrets(t *testing.T) {
+	cfg, err := NewPolicyControllerConfigFromMap(map[string]string{
+		DefaultPullSecretsKey: "signatures, ghcr.io = ghcr-signatures,",
+	})
+	if err != nil {
+		t.Fatalf("NewPolicyControllerConfigFromMap() = %v", err)
+	}
+	want := []DefaultPullSecret{{Name: "signatures"}, {Registry: "ghcr.io", Name: "ghcr-signatures"}}
+	if diff := cmp.Diff(want, cfg.DefaultPullSecrets); diff != "" {
+		t.Error("Unexpected default pull secrets (-want, +got):", diff)
+	}
+
+	for _, val := range []string{"=signatures", "ghcr.io=", "Not_A_Secret"} {
+		if _, err := NewPolicyControllerConfigFromMap(map[string]string{DefaultPullSecretsKey: val}); err == nil {
+			t.Errorf("NewPolicyControllerConfigFromMap(%s: %s) did not fail", DefaultPullSecretsKey, val)
+		}
+	}
+}
+
+func TestRegistryMirrors(t *testing.T) {
+	cfg, err := NewPolicyControllerConfigFromMap(map[string]string{
+		RegistryMirrorsKey: "docker.io=mirror.corp/docker.io, ghcr.io/sigstore = mirror.corp,",
+	})
+	if err != nil {
+		t.Fatalf("NewPolicyControllerConfigFromMap() = %v", err)
+	}
+	want := []RegistryMirror{{Prefix: "index.docker.io", Mirror: "mirror.corp/docker.io"}, {Prefix: "ghcr.io/sigstore", Mirror: "mirror.corp"}}
+	if diff := cmp.Diff(want, cfg.RegistryMirrors); diff != "" {
+		t.Error("Unexpected registry mirrors (-want, +got):", diff)
+	}
+
+	for _, val := range []string{"mirror.corp", "=mirror.corp", "docker.io=", "docker.io=mirror.corp/Not_A_Repo"} {
+		if _, err := NewPolicyControllerConfigFromMap(map[string]string{RegistryMirrorsKey: val}); err == nil {
+			t.Errorf("NewPolicyControllerConfigFromMap(%s: %s) did not fail", RegistryMirrorsKey, val)
+		}
+	}
+}
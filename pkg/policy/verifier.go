@@ -18,6 +18,7 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"slices"
 
 	"github.com/google/go-containerregistry/pkg/authn"
 	"github.com/google/go-containerregistry/pkg/name"
@@ -42,6 +43,12 @@ type Verifier interface {
 	// For policies specifying `match:` criteria with label selectors, the
 	// ObjectMeta should be associated with `ctx` here using:
 	//    webhook.GetIncludeObjectMeta(ctx)
+	//
+	// The remote.Options for the registry operations can be passed with an
+	// ociremote.WithRemoteOptions, or associated with `ctx` here using:
+	//    webhook.WithRemoteOptions(ctx, ...)
+	// Either way, the context, keychain and transport of each stage of the
+	// verification take precedence over them.
 	Verify(context.Context, name.Reference, authn.Keychain, ...ociremote.Option) error
 }
 
@@ -74,9 +81,7 @@ func Compile(ctx context.Context, v Verification, ww WarningWriter) (Verifier, e
 
 func gather(ctx context.Context, v Verification, ww WarningWriter) (*config.ImagePolicyConfig, error) {
 	pol := *v.Policies
-	ipc := &config.ImagePolicyConfig{
-		Policies: make(map[string]webhookcip.ClusterImagePolicy, len(pol)),
-	}
+	policies := make(map[string]webhookcip.ClusterImagePolicy, len(pol))
 
 	for i, p := range pol {
 		content, err := p.fetch(ctx)
@@ -99,7 +104,7 @@ func gather(ctx context.Context, v Verification, ww WarningWriter) (*config.Imag
 
 		for _, cip := range l {
 			cip.SetDefaults(ctx)
-			if _, ok := ipc.Policies[cip.Name]; ok {
+			if _, ok := policies[cip.Name]; ok {
 				ww("duplicate policy named %q, skipping", cip.Name)
 				continue
 			}
@@ -113,11 +118,11 @@ func gather(ctx context.Context, v Verification, ww WarningWriter) (*config.Imag
 				ww("roundtripping policy %v", err)
 				continue
 			}
-			ipc.Policies[cip.Name] = compiled
+			policies[cip.Name] = compiled
 		}
 	}
 
-	return ipc, nil
+	return config.NewImagePolicyConfig(policies), nil
 }
 
 type impl struct {
@@ -134,7 +139,26 @@ var _ Verifier = (*impl)(nil)
 func (i *impl) Verify(ctx context.Context, ref name.Reference, kc authn.Keychain, opts ...ociremote.Option) error {
 	tm := getTypeMeta(ctx)
 	om := getObjectMeta(ctx)
-	matches, err := i.ipc.GetMatchingPolicies(ref.Name(), tm.Kind, tm.APIVersion, om.Labels)
+
+	// Add the remote.Options of ctx and the keychain to those of our
+	// (optional) list of options to resolve the digest.
+	resolveOpts := append(slices.Clip(opts), ociremote.WithRemoteOptions(
+		slices.Concat(webhook.RemoteOptions(opts...), webhook.GetRemoteOptions(ctx), []remote.Option{remote.WithAuthFromKeychain(kc)})...))
+
+	// Patterns with digests are matched against the resolved digest, while
+	// the rest of the patterns still see the image as it was referenced.
+	var matches map[string]webhookcip.ClusterImagePolicy
+	var err error
+	if _, ok := ref.(name.Digest); !ok && i.ipc.MatchesDigests() {
+		digest, rerr := ociremote.ResolveDigest(ref, resolveOpts...)
+		if rerr != nil {
+			return fmt.Errorf("resolving digest of %s: %w", ref, rerr)
+		}
+		matches, err = i.ipc.GetMatchingPoliciesResolved(ref.Name(), digest, tm.Kind, tm.APIVersion, om.Labels)
+		ref = digest
+	} else {
+		matches, err = i.ipc.GetMatchingPolicies(ref.Name(), tm.Kind, tm.APIVersion, om.Labels)
+	}
 	if err != nil {
 		return err
 	}
@@ -153,9 +177,6 @@ func (i *impl) Verify(ctx context.Context, ref name.Reference, kc authn.Keychain
 		}
 	}
 
-	// Add the keychain to our (optional) list of options.
-	opts = append(opts, ociremote.WithRemoteOptions(remote.WithAuthFromKeychain(kc)))
-
 	for _, p := range matches {
 		res, errs := webhook.ValidatePolicy(ctx, "" /* namespace */, ref, p, kc, opts...)
 		if res != nil { //nolint: revive
@@ -18,15 +18,18 @@ import (
 	"context"
 	"crypto"
 	"crypto/sha256"
+	"errors"
 	"fmt"
 	"io"
 	"net/http"
+	"slices"
 	"strings"
 
 	"github.com/sigstore/policy-controller/pkg/apis/config"
 	"github.com/sigstore/policy-controller/pkg/apis/policy/v1alpha1"
 	"github.com/sigstore/policy-controller/pkg/apis/signaturealgo"
 	clusterimagepolicyreconciler "github.com/sigstore/policy-controller/pkg/client/injection/reconciler/policy/v1alpha1/clusterimagepolicy"
+	policylisters "github.com/sigstore/policy-controller/pkg/client/listers/policy/v1alpha1"
 	"github.com/sigstore/policy-controller/pkg/reconciler/clusterimagepolicy/resources"
 	webhookcip "github.com/sigstore/policy-controller/pkg/webhook/clusterimagepolicy"
 	"github.com/sigstore/sigstore/pkg/cryptoutils"
@@ -38,6 +41,7 @@ import (
 	"k8s.io/client-go/kubernetes"
 	corev1listers "k8s.io/client-go/listers/core/v1"
 
+	"knative.dev/pkg/apis"
 	"knative.dev/pkg/logging"
 	"knative.dev/pkg/reconciler"
 	"knative.dev/pkg/system"
@@ -58,6 +62,7 @@ type Reconciler struct {
 	// keys.
 	secretlister    corev1listers.SecretLister
 	configmaplister corev1listers.ConfigMapLister
+	trustrootlister policylisters.TrustRootLister
 	kubeclient      kubernetes.Interface
 }
 
@@ -90,6 +95,15 @@ func (r *Reconciler) ReconcileKind(ctx context.Context, cip *v1alpha1.ClusterIma
 	}
 	cip.Status.MarkInlinePoliciesOk()
 
+	// The CIP is compiled into the ConfigMap even if its TrustRoots are not
+	// Ready, so that it keeps being enforced, e.g. with the other TrustRoots
+	// of an authority while one of them is being rotated.
+	if err := r.resolveAndTrackTrustRoots(ctx, cip); err != nil {
+		cip.Status.MarkTrustRootsFailed(err.Error())
+	} else {
+		cip.Status.MarkTrustRootsOk()
+	}
+
 	webhookCIP := webhookcip.ConvertClusterImagePolicyV1alpha1ToWebhook(cipCopy)
 
 	// See if the CM holding configs exists
@@ -167,6 +181,56 @@ func (r *Reconciler) handleCIPError(ctx context.Context, cipName string) {
 	}
 }
 
+// resolveAndTrackTrustRoots checks that the TrustRoots referenced by the
+// authorities of the CIP exist and are Ready. Additionally, we set up a
+// tracker so we will be notified if they are created or modified.
+func (r *Reconciler) resolveAndTrackTrustRoots(ctx context.Context, cip *v1alpha1.ClusterImagePolicy) error {
+	var trustRootRefs []string
+	for _, authority := range cip.Spec.Authorities {
+		if authority.Keyless != nil {
+			trustRootRefs = append(trustRootRefs, authority.Keyless.GetTrustRootRefs()...)
+		}
+		if authority.CTLog != nil {
+			trustRootRefs = append(trustRootRefs, authority.CTLog.GetTrustRootRefs()...)
+		}
+		if authority.RFC3161Timestamp != nil {
+			trustRootRefs = append(trustRootRefs, authority.RFC3161Timestamp.GetTrustRootRefs()...)
+		}
+	}
+	slices.Sort(trustRootRefs)
+	trustRootRefs = slices.Compact(trustRootRefs)
+
+	var failures []string
+	for _, trustRootRef := range trustRootRefs {
+		if err := r.tracker.TrackReference(tracker.Reference{
+			APIVersion: v1alpha1.SchemeGroupVersion.String(),
+			Kind:       "TrustRoot",
+			Name:       trustRootRef,
+		}, cip); err != nil {
+			return fmt.Errorf("failed to track changes to trustroot %q : %w", trustRootRef, err)
+		}
+		tr, err := r.trustrootlister.Get(trustRootRef)
+		if err != nil {
+			if !apierrs.IsNotFound(err) {
+				return err
+			}
+			failures = append(failures, fmt.Sprintf("trustroot %q not found", trustRootRef))
+			continue
+		}
+		if !tr.IsReady() {
+			msg := fmt.Sprintf("trustroot %q is not ready", trustRootRef)
+			if cond := tr.Status.GetCondition(apis.ConditionReady); cond != nil && cond.Message != "" {
+				msg = fmt.Sprintf("%s: %s", msg, cond.Message)
+			}
+			failures = append(failures, msg)
+		}
+	}
+	if len(failures) > 0 {
+		return errors.New(strings.Join(failures, ", "))
+	}
+	return nil
+}
+
 // inlinePublicKeys will go through the CIP and try to read the referenced
 // secrets, KMS keys and convert them into inlined data. Makes a copy of the CIP
 // before modifying it and returns the copy.
@@ -109,6 +109,9 @@ RCTfQ5s1kD+hGMSE1rH7s46hmXEeyhnlRnaGF8eMU/SBJE/2NKPnxE7WzQ==
 	// This is the patch for inlined secret with matching labels
 	inlinedSecretKeylessMatchLabelsPatch = `[{"op":"replace","path":"/data/test-cip-2","value":"{\"uid\":\"test-uid\",\"resourceVersion\":\"0123456789\",\"images\":[{\"glob\":\"ghcr.io/example/*\"}],\"authorities\":[{\"name\":\"authority-0\",\"keyless\":{\"identities\":[{\"issuerRegExp\":\"iss.*\",\"subjectRegExp\":\"sub.*\"}],\"ca-cert\":{\"data\":\"-----BEGIN PUBLIC KEY-----\\nMFkwEwYHKoZIzj0CAQYIKoZIzj0DAQcDQgAExB6+H6054/W1SJgs5JR6AJr6J35J\\nRCTfQ5s1kD+hGMSE1rH7s46hmXEeyhnlRnaGF8eMU/SBJE/2NKPnxE7WzQ==\\n-----END PUBLIC KEY-----\",\"hashAlgorithm\":\"sha256\"}}}],\"mode\":\"enforce\",\"match\":[{\"group\":\"apps\",\"version\":\"v1\",\"resource\":\"replicasets\",\"selector\":{\"matchLabels\":{\"match\":\"match\"}}}]}"}]`
 
+	// This is the patch for a keyless authority with multiple trust roots.
+	trustRootsKeylessPatch = `[{"op":"replace","path":"/data/test-cip-2","value":"{\"uid\":\"test-uid\",\"resourceVersion\":\"0123456789\",\"images\":[{\"glob\":\"ghcr.io/example/*\"}],\"authorities\":[{\"name\":\"authority-0\",\"keyless\":{\"url\":\"https://fulcio.sigstore.dev\",\"identities\":[{\"issuerRegExp\":\"iss.*\",\"subjectRegExp\":\"sub.*\"}],\"trustRootRef\":\"old-root\",\"trustRootRefs\":[\"new-root\"]}}],\"mode\":\"enforce\"}"}]`
+
 	replaceCIPKeySourcePatch = `[{"op":"replace","path":"/data/test-cip","value":"{\"uid\":\"test-uid\",\"resourceVersion\":\"0123456789\",\"images\":[{\"glob\":\"ghcr.io/example/*\"}],\"authorities\":[{\"name\":\"authority-0\",\"key\":{\"data\":\"-----BEGIN PUBLIC KEY-----\\nMFkwEwYHKoZIzj0CAQYIKoZIzj0DAQcDQgAExB6+H6054/W1SJgs5JR6AJr6J35J\\nRCTfQ5s1kD+hGMSE1rH7s46hmXEeyhnlRnaGF8eMU/SBJE/2NKPnxE7WzQ==\\n-----END PUBLIC KEY-----\",\"hashAlgorithm\":\"sha256\"},\"source\":[{\"oci\":\"example.com/alternative/signature\",\"signaturePullSecrets\":[{\"name\":\"signaturePullSecretName\"}]}]}],\"mode\":\"enforce\"}"}]`
 
 	replaceCIPKeySourceWithoutOCIPatch = `[{"op":"replace","path":"/data/test-cip","value":"{\"uid\":\"test-uid\",\"resourceVersion\":\"0123456789\",\"images\":[{\"glob\":\"ghcr.io/example/*\"}],\"authorities\":[{\"name\":\"authority-0\",\"key\":{\"data\":\"-----BEGIN PUBLIC KEY-----\\nMFkwEwYHKoZIzj0CAQYIKoZIzj0DAQcDQgAExB6+H6054/W1SJgs5JR6AJr6J35J\\nRCTfQ5s1kD+hGMSE1rH7s46hmXEeyhnlRnaGF8eMU/SBJE/2NKPnxE7WzQ==\\n-----END PUBLIC KEY-----\",\"hashAlgorithm\":\"sha256\"},\"source\":[{\"signaturePullSecrets\":[{\"name\":\"signaturePullSecretName\"}]}]}],\"mode\":\"enforce\"}"}]`
@@ -310,6 +313,7 @@ func TestReconcile(t *testing.T) {
 				WithObservedGeneration(1),
 				WithMarkInlineKeysOk,
 				WithMarkInlinePoliciesOk,
+				WithMarkTrustRootsOk,
 				WithMarkCMUpdateFailed("inducing failure for patch configmaps"),
 			),
 		}},
@@ -857,6 +861,102 @@ func TestReconcile(t *testing.T) {
 			PostConditions: []func(*testing.T, *TableRow){
 				AssertTrackingSecret(system.Namespace(), keylessSecretName),
 			},
+		}, {
+			Name: "Keyless with trust roots, trust roots ready",
+			Key:  testKey2,
+
+			SkipNamespaceValidation: true, // Cluster scoped
+			Objects: []runtime.Object{
+				NewClusterImagePolicy(cipName2,
+					WithUID(uid),
+					WithResourceVersion(resourceVersion),
+					WithFinalizer,
+					WithImagePattern(v1alpha1.ImagePattern{
+						Glob: glob,
+					}),
+					WithAuthority(v1alpha1.Authority{
+						Keyless: &v1alpha1.KeylessRef{
+							TrustRootRef:  "old-root",
+							TrustRootRefs: []string{"new-root"},
+							Identities:    placeholderIdentities,
+						}}),
+				),
+				makeConfigMapWithTwoEntries(),
+				NewTrustRoot("old-root", MarkReadyTrustRoot),
+				NewTrustRoot("new-root", MarkReadyTrustRoot),
+			},
+			WantPatches: []clientgotesting.PatchActionImpl{
+				makePatch(trustRootsKeylessPatch),
+			},
+			WantStatusUpdates: []clientgotesting.UpdateActionImpl{{
+				Object: NewClusterImagePolicy(cipName2,
+					WithUID(uid),
+					WithResourceVersion(resourceVersion),
+					WithFinalizer,
+					WithImagePattern(v1alpha1.ImagePattern{
+						Glob: glob,
+					}),
+					WithAuthority(v1alpha1.Authority{
+						Keyless: &v1alpha1.KeylessRef{
+							TrustRootRef:  "old-root",
+							TrustRootRefs: []string{"new-root"},
+							Identities:    placeholderIdentities,
+						}}),
+					MarkReady),
+			}},
+			PostConditions: []func(*testing.T, *TableRow){
+				AssertTrackingTrustRoot("old-root"),
+				AssertTrackingTrustRoot("new-root"),
+			},
+		}, {
+			Name: "Keyless with trust roots, one missing and one not ready, still added to cm",
+			Key:  testKey2,
+
+			SkipNamespaceValidation: true, // Cluster scoped
+			Objects: []runtime.Object{
+				NewClusterImagePolicy(cipName2,
+					WithUID(uid),
+					WithResourceVersion(resourceVersion),
+					WithFinalizer,
+					WithImagePattern(v1alpha1.ImagePattern{
+						Glob: glob,
+					}),
+					WithAuthority(v1alpha1.Authority{
+						Keyless: &v1alpha1.KeylessRef{
+							TrustRootRef:  "old-root",
+							TrustRootRefs: []string{"new-root"},
+							Identities:    placeholderIdentities,
+						}}),
+				),
+				makeConfigMapWithTwoEntries(),
+				NewTrustRoot("old-root",
+					WithInitConditionsTrustRoot,
+					WithMarkInlineKeysFailedTrustRoot("bad key")),
+			},
+			WantPatches: []clientgotesting.PatchActionImpl{
+				makePatch(trustRootsKeylessPatch),
+			},
+			WantStatusUpdates: []clientgotesting.UpdateActionImpl{{
+				Object: NewClusterImagePolicy(cipName2,
+					WithUID(uid),
+					WithResourceVersion(resourceVersion),
+					WithFinalizer,
+					WithImagePattern(v1alpha1.ImagePattern{
+						Glob: glob,
+					}),
+					WithAuthority(v1alpha1.Authority{
+						Keyless: &v1alpha1.KeylessRef{
+							TrustRootRef:  "old-root",
+							TrustRootRefs: []string{"new-root"},
+							Identities:    placeholderIdentities,
+						}}),
+					MarkReady,
+					WithMarkTrustRootsFailed(`trustroot "new-root" not found, trustroot "old-root" is not ready: bad key`)),
+			}},
+			PostConditions: []func(*testing.T, *TableRow){
+				AssertTrackingTrustRoot("old-root"),
+				AssertTrackingTrustRoot("new-root"),
+			},
 		}, {
 			Name: "ClusterImagePolicy with glob and KMS key, added the data after querying the fake signer",
 			Key:  cipKMSName,
@@ -1422,6 +1522,7 @@ malformed KMS format, should be prefixed by any of the supported providers: [aws
 		r := &Reconciler{
 			secretlister:    listers.GetSecretLister(),
 			configmaplister: listers.GetConfigMapLister(),
+			trustrootlister: listers.GetTrustRootLister(),
 			kubeclient:      fakekubeclient.Get(ctx),
 			tracker:         ctx.Value(TrackerKey).(tracker.Interface),
 		}
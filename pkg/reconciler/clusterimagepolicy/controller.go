@@ -33,7 +33,9 @@ import (
 	"knative.dev/pkg/system"
 
 	"github.com/sigstore/policy-controller/pkg/apis/config"
+	"github.com/sigstore/policy-controller/pkg/apis/policy/v1alpha1"
 	clusterimagepolicyinformer "github.com/sigstore/policy-controller/pkg/client/injection/informers/policy/v1alpha1/clusterimagepolicy"
+	trustrootinformer "github.com/sigstore/policy-controller/pkg/client/injection/informers/policy/v1alpha1/trustroot"
 	clusterimagepolicyreconciler "github.com/sigstore/policy-controller/pkg/client/injection/reconciler/policy/v1alpha1/clusterimagepolicy"
 	cminformer "knative.dev/pkg/injection/clients/namespacedkube/informers/core/v1/configmap"
 	secretinformer "knative.dev/pkg/injection/clients/namespacedkube/informers/core/v1/secret"
@@ -53,10 +55,12 @@ func NewController(
 	clusterimagepolicyInformer := clusterimagepolicyinformer.Get(ctx)
 	secretInformer := secretinformer.Get(ctx)
 	configMapInformer := cminformer.Get(ctx)
+	trustrootInformer := trustrootinformer.Get(ctx)
 
 	r := &Reconciler{
 		secretlister:    secretInformer.Lister(),
 		configmaplister: configMapInformer.Lister(),
+		trustrootlister: trustrootInformer.Lister(),
 		kubeclient:      kubeclient.Get(ctx),
 	}
 	impl := clusterimagepolicyreconciler.NewImpl(ctx, r, func(_ *controller.Impl) controller.Options {
@@ -92,6 +96,18 @@ func NewController(
 		logging.FromContext(ctx).Warnf("Failed configMapInformer AddEventHandler() %v", err)
 	}
 
+	if _, err := trustrootInformer.Informer().AddEventHandler(controller.HandleAll(
+		// Call the tracker's OnChanged method, but we've seen the objects
+		// coming through this path missing TypeMeta, so ensure it is properly
+		// populated.
+		controller.EnsureTypeMeta(
+			r.tracker.OnChanged,
+			v1alpha1.SchemeGroupVersion.WithKind("TrustRoot"),
+		),
+	)); err != nil {
+		logging.FromContext(ctx).Warnf("Failed trustrootInformer AddEventHandler() %v", err)
+	}
+
 	// When the underlying ConfigMap changes,perform a global resync on
 	// ClusterImagePolicies to make sure their state is correctly reflected
 	// in the ConfigMap. This is admittedly a bit heavy handed, but I don't
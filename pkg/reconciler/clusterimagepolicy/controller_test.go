@@ -24,6 +24,7 @@ import (
 
 	// Fake injection informers
 	_ "github.com/sigstore/policy-controller/pkg/client/injection/informers/policy/v1alpha1/clusterimagepolicy/fake"
+	_ "github.com/sigstore/policy-controller/pkg/client/injection/informers/policy/v1alpha1/trustroot/fake"
 	_ "knative.dev/pkg/client/injection/kube/informers/core/v1/configmap/fake"
 	_ "knative.dev/pkg/injection/clients/namespacedkube/informers/core/v1/configmap/fake"
 	_ "knative.dev/pkg/injection/clients/namespacedkube/informers/core/v1/secret/fake"
@@ -108,6 +108,7 @@ func MarkReady(cip *v1alpha1.ClusterImagePolicy) {
 	cip.Status.MarkInlineKeysOk()
 	cip.Status.MarkInlinePoliciesOk()
 	cip.Status.MarkCMUpdatedOK()
+	cip.Status.MarkTrustRootsOk()
 	cip.Status.ObservedGeneration = cip.Generation
 }
 
@@ -135,3 +136,13 @@ func WithMarkCMUpdateFailed(msg string) ClusterImagePolicyOption {
 		cip.Status.MarkCMUpdateFailed(msg)
 	}
 }
+
+func WithMarkTrustRootsOk(cip *v1alpha1.ClusterImagePolicy) {
+	cip.Status.MarkTrustRootsOk()
+}
+
+func WithMarkTrustRootsFailed(msg string) ClusterImagePolicyOption {
+	return func(cip *v1alpha1.ClusterImagePolicy) {
+		cip.Status.MarkTrustRootsFailed(msg)
+	}
+}
@@ -36,6 +36,7 @@ import (
 	ktesting "k8s.io/client-go/testing"
 	"knative.dev/pkg/controller"
 
+	"github.com/sigstore/policy-controller/pkg/apis/policy/v1alpha1"
 	fakecosignclient "github.com/sigstore/policy-controller/pkg/client/injection/client/fake"
 	fakekubeclient "knative.dev/pkg/client/injection/kube/client/fake"
 	fakedynamicclient "knative.dev/pkg/injection/clients/dynamicclient/fake"
@@ -179,6 +180,12 @@ func AssertTrackingConfigMap(namespace, name string) func(*testing.T, *reconcile
 	return AssertTrackingObject(gvk, namespace, name)
 }
 
+// AssertTrackingTrustRoot will ensure the provided TrustRoot is being tracked
+func AssertTrackingTrustRoot(name string) func(*testing.T, *reconcilertesting.TableRow) {
+	gvk := v1alpha1.SchemeGroupVersion.WithKind("TrustRoot")
+	return AssertTrackingObject(gvk, "", name)
+}
+
 // AssertTrackingObject will ensure the following objects are being tracked
 func AssertTrackingObject(gvk schema.GroupVersionKind, namespace, name string) func(*testing.T, *reconcilertesting.TableRow) {
 	apiVersion, kind := gvk.ToAPIVersionAndKind()
@@ -115,6 +115,14 @@ func WithRepository(targets string, root, repository []byte, trustedRootTarget s
 	}
 }
 
+// WithTrustedRoot constructs a TrustRootOption which is suitable
+// for reconciler table driven testing.
+func WithTrustedRoot(trustedRoot v1alpha1.TrustedRoot) TrustRootOption {
+	return func(tr *v1alpha1.TrustRoot) {
+		tr.Spec.TrustedRoot = &trustedRoot
+	}
+}
+
 func WithInitConditionsTrustRoot(tr *v1alpha1.TrustRoot) {
 	tr.Status.InitializeConditions()
 }
@@ -146,3 +154,29 @@ func WithMarkCMUpdateFailedTrustRoot(msg string) TrustRootOption {
 		tr.Status.MarkCMUpdateFailed(msg)
 	}
 }
+
+// WithTrustRootRefreshed sets the last refresh time and the expirations of
+// the TrustRoot, and marks it as not expiring.
+func WithTrustRootRefreshed(now time.Time, expirations ...v1alpha1.TrustRootExpiration) TrustRootOption {
+	return func(tr *v1alpha1.TrustRoot) {
+		tr.Status.LastRefreshTime = &metav1.Time{Time: now}
+		tr.Status.Expirations = expirations
+		tr.Status.MarkNotExpiring()
+	}
+}
+
+func WithMarkExpiringTrustRoot(msg string) TrustRootOption {
+	return func(tr *v1alpha1.TrustRoot) {
+		tr.Status.MarkExpiring(msg)
+	}
+}
+
+func WithMarkTlogKeysSupportedTrustRoot(tr *v1alpha1.TrustRoot) {
+	tr.Status.MarkTlogKeysSupported()
+}
+
+func WithMarkTlogKeysUnsupportedTrustRoot(msg string) TrustRootOption {
+	return func(tr *v1alpha1.TrustRoot) {
+		tr.Status.MarkTlogKeysUnsupported(msg)
+	}
+}
@@ -16,7 +16,10 @@ package trustroot
 
 import (
 	"context"
+	"time"
 
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/equality"
 	"k8s.io/client-go/tools/cache"
 	kubeclient "knative.dev/pkg/client/injection/kube/client"
 	"knative.dev/pkg/configmap"
@@ -27,10 +30,12 @@ import (
 	"knative.dev/pkg/system"
 
 	"github.com/sigstore/policy-controller/pkg/apis/config"
+	"github.com/sigstore/policy-controller/pkg/apis/policy/v1alpha1"
 	trustrootinformer "github.com/sigstore/policy-controller/pkg/client/injection/informers/policy/v1alpha1/trustroot"
 	trustrootreconciler "github.com/sigstore/policy-controller/pkg/client/injection/reconciler/policy/v1alpha1/trustroot"
 	"github.com/sigstore/policy-controller/pkg/tuf"
 	cminformer "knative.dev/pkg/injection/clients/namespacedkube/informers/core/v1/configmap"
+	secretinformer "knative.dev/pkg/injection/clients/namespacedkube/informers/core/v1/secret"
 )
 
 // This is what the default finalizer name is, but make it explicit so we can
@@ -43,20 +48,58 @@ func NewController(
 	_ configmap.Watcher,
 ) *controller.Impl {
 	trustrootInformer := trustrootinformer.Get(ctx)
+	secretInformer := secretinformer.Get(ctx)
 	configMapInformer := cminformer.Get(ctx)
 
 	r := &Reconciler{
+		secretlister:    secretInformer.Lister(),
 		configmaplister: configMapInformer.Lister(),
 		kubeclient:      kubeclient.Get(ctx),
+		now:             time.Now,
 	}
 	impl := trustrootreconciler.NewImpl(ctx, r, func(_ *controller.Impl) controller.Options {
 		return controller.Options{FinalizerName: FinalizerName}
 	})
+	r.tracker = impl.Tracker
 
-	if _, err := trustrootInformer.Informer().AddEventHandler(controller.HandleAll(impl.Enqueue)); err != nil {
+	// The status of the TrustRoots is updated on every reconcile (with the
+	// last refresh time), so skip the updates that only change it, or they
+	// would be reconciled again right away.
+	if _, err := trustrootInformer.Informer().AddEventHandler(cache.ResourceEventHandlerFuncs{
+		AddFunc: impl.Enqueue,
+		UpdateFunc: func(oldObj, newObj interface{}) {
+			if !statusOnlyUpdate(oldObj, newObj) {
+				impl.Enqueue(newObj)
+			}
+		},
+		DeleteFunc: impl.Enqueue,
+	}); err != nil {
 		logging.FromContext(ctx).Warnf("Failed trustrootInformer AddEventHandler() %v", err)
 	}
 
+	// Reconcile the TrustRoots holding a trusted root document from a Secret
+	// or a ConfigMap when it changes.
+	if _, err := secretInformer.Informer().AddEventHandler(controller.HandleAll(
+		// Call the tracker's OnChanged method, but we've seen the objects
+		// coming through this path missing TypeMeta, so ensure it is properly
+		// populated.
+		controller.EnsureTypeMeta(
+			r.tracker.OnChanged,
+			corev1.SchemeGroupVersion.WithKind("Secret"),
+		),
+	)); err != nil {
+		logging.FromContext(ctx).Warnf("Failed secretInformer AddEventHandler() %v", err)
+	}
+
+	if _, err := configMapInformer.Informer().AddEventHandler(controller.HandleAll(
+		controller.EnsureTypeMeta(
+			r.tracker.OnChanged,
+			corev1.SchemeGroupVersion.WithKind("ConfigMap"),
+		),
+	)); err != nil {
+		logging.FromContext(ctx).Warnf("Failed configMapInformer AddEventHandler() %v", err)
+	}
+
 	// When the underlying ConfigMap changes,perform a global resync on
 	// TrustRoot to make sure their state is correctly reflected
 	// in the ConfigMap. This is admittedly a bit heavy handed, but I don't
@@ -81,3 +124,19 @@ func NewController(
 	}
 	return impl
 }
+
+// statusOnlyUpdate returns true if the update of the TrustRoot only changed
+// its status.
+func statusOnlyUpdate(oldObj, newObj interface{}) bool {
+	oldTR, ok := oldObj.(*v1alpha1.TrustRoot)
+	if !ok {
+		return false
+	}
+	newTR, ok := newObj.(*v1alpha1.TrustRoot)
+	if !ok {
+		return false
+	}
+	return oldTR.Generation == newTR.Generation &&
+		equality.Semantic.DeepEqual(oldTR.DeletionTimestamp, newTR.DeletionTimestamp) &&
+		equality.Semantic.DeepEqual(oldTR.Finalizers, newTR.Finalizers)
+}
@@ -0,0 +1,124 @@
+// Copyright 2024 The Sigstore Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package trustroot
+
+import (
+	"context"
+	"crypto/x509"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/sigstore/policy-controller/pkg/apis/config"
+	"github.com/sigstore/policy-controller/pkg/apis/policy/v1alpha1"
+	"github.com/sigstore/policy-controller/pkg/tuf"
+	pbcommon "github.com/sigstore/protobuf-specs/gen/pb-go/common/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"knative.dev/pkg/controller"
+	"knative.dev/pkg/logging"
+)
+
+// markRefreshed records in the status of the TrustRoot that its keys were
+// just refreshed, along with when their parts expire. If any of them
+// expires within the expiry window, the TrustRoot is marked as expiring and
+// a Warning Event is raised. This is done on every reconcile, so at least
+// once per trustroot-resync-period.
+func (r *Reconciler) markRefreshed(ctx context.Context, trustroot *v1alpha1.TrustRoot, tufExpirations map[string]time.Time, sigstoreKeys *config.SigstoreKeys) {
+	now := r.now()
+	trustroot.Status.LastRefreshTime = &metav1.Time{Time: now}
+	trustroot.Status.Expirations = expirations(ctx, tufExpirations, sigstoreKeys)
+
+	deadline := now.Add(tuf.ExpiryWindowFromContextOrDefaults(ctx))
+	var expiring []string
+	for _, e := range trustroot.Status.Expirations {
+		switch {
+		case e.Expires.Time.Before(now):
+			expiring = append(expiring, fmt.Sprintf("%s expired at %s", e.Name, e.Expires.UTC().Format(time.RFC3339)))
+		case e.Expires.Time.Before(deadline):
+			expiring = append(expiring, fmt.Sprintf("%s expires at %s", e.Name, e.Expires.UTC().Format(time.RFC3339)))
+		}
+	}
+	if len(expiring) == 0 {
+		trustroot.Status.MarkNotExpiring()
+		return
+	}
+	msg := strings.Join(expiring, ", ")
+	trustroot.Status.MarkExpiring(msg)
+	controller.GetEventRecorder(ctx).Event(trustroot, corev1.EventTypeWarning, "Expiring", msg)
+}
+
+// expirations returns when the TUF metadata, the certificate chains and the
+// validity windows of the SigstoreKeys expire, sorted by time.
+func expirations(ctx context.Context, tufExpirations map[string]time.Time, sigstoreKeys *config.SigstoreKeys) []v1alpha1.TrustRootExpiration {
+	var ret []v1alpha1.TrustRootExpiration
+	add := func(name string, expires time.Time) {
+		ret = append(ret, v1alpha1.TrustRootExpiration{Name: name, Expires: metav1.Time{Time: expires}})
+	}
+	for role, expires := range tufExpirations {
+		add("tuf/"+role, expires)
+	}
+	for field, cas := range map[string][]*config.CertificateAuthority{
+		"certificateAuthorities": sigstoreKeys.GetCertificateAuthorities(),
+		"timestampAuthorities":   sigstoreKeys.GetTimestampAuthorities(),
+	} {
+		for i, ca := range cas {
+			name := fmt.Sprintf("%s[%d]", field, i)
+			if notAfter, ok := certChainNotAfter(ctx, name, ca.GetCertChain()); ok {
+				add(name+".certChain", notAfter)
+			}
+			if end := ca.GetValidFor().GetEnd(); end != nil {
+				add(name+".validFor", end.AsTime())
+			}
+		}
+	}
+	for field, tlogs := range map[string][]*config.TransparencyLogInstance{
+		"tLogs":  sigstoreKeys.GetTlogs(),
+		"ctLogs": sigstoreKeys.GetCtlogs(),
+	} {
+		for i, tlog := range tlogs {
+			if end := tlog.GetPublicKey().GetValidFor().GetEnd(); end != nil {
+				add(fmt.Sprintf("%s[%d].validFor", field, i), end.AsTime())
+			}
+		}
+	}
+	sort.Slice(ret, func(i, j int) bool {
+		if !ret[i].Expires.Equal(&ret[j].Expires) {
+			return ret[i].Expires.Before(&ret[j].Expires)
+		}
+		return ret[i].Name < ret[j].Name
+	})
+	return ret
+}
+
+// certChainNotAfter returns the earliest NotAfter of the certificates of
+// the chain.
+func certChainNotAfter(ctx context.Context, name string, certChain *pbcommon.X509CertificateChain) (time.Time, bool) {
+	var notAfter time.Time
+	for _, c := range certChain.GetCertificates() {
+		cert, err := x509.ParseCertificate(c.GetRawBytes())
+		if err != nil {
+			// The certificates were already parsed when converting the
+			// TrustRoot, so this should not happen.
+			logging.FromContext(ctx).Warnf("Failed to parse certificate of %s: %v", name, err)
+			continue
+		}
+		if notAfter.IsZero() || cert.NotAfter.Before(notAfter) {
+			notAfter = cert.NotAfter
+		}
+	}
+	return notAfter, !notAfter.IsZero()
+}
@@ -22,17 +22,24 @@ import (
 	"encoding/json"
 	"errors"
 	"fmt"
+	"strings"
+	"time"
 
+	"github.com/google/go-containerregistry/pkg/authn/k8schain"
+	kauth "github.com/google/go-containerregistry/pkg/authn/kubernetes"
+	"github.com/google/go-containerregistry/pkg/name"
+	"github.com/google/go-containerregistry/pkg/v1/remote"
 	"github.com/sigstore/cosign/v2/pkg/cosign"
 	"github.com/sigstore/policy-controller/pkg/apis/config"
 	"github.com/sigstore/policy-controller/pkg/apis/policy/v1alpha1"
 	trustrootreconciler "github.com/sigstore/policy-controller/pkg/client/injection/reconciler/policy/v1alpha1/trustroot"
 	"github.com/sigstore/policy-controller/pkg/reconciler/trustroot/resources"
 	"github.com/sigstore/policy-controller/pkg/tuf"
+	"github.com/sigstore/policy-controller/pkg/webhook/registryauth"
 	pbcommon "github.com/sigstore/protobuf-specs/gen/pb-go/common/v1"
+	"github.com/sigstore/sigstore-go/pkg/root"
 	"github.com/sigstore/sigstore/pkg/cryptoutils"
 	sigstoretuf "github.com/sigstore/sigstore/pkg/tuf"
-	"github.com/theupdateframework/go-tuf/client"
 	"google.golang.org/protobuf/encoding/protojson"
 	corev1 "k8s.io/api/core/v1"
 	apierrs "k8s.io/apimachinery/pkg/api/errors"
@@ -41,16 +48,26 @@ import (
 	"k8s.io/client-go/kubernetes"
 	corev1listers "k8s.io/client-go/listers/core/v1"
 
+	"knative.dev/pkg/controller"
 	"knative.dev/pkg/logging"
 	"knative.dev/pkg/reconciler"
 	"knative.dev/pkg/system"
+	"knative.dev/pkg/tracker"
 )
 
 // Reconciler implements ConfigMap reconciler.
 // TrustRoot resources.
 type Reconciler struct {
+	// Tracker builds an index of what resources are watching other resources
+	// so that we can immediately react to changes tracked resources.
+	tracker tracker.Interface
+	// We need to be able to read Secrets and ConfigMaps holding trusted
+	// root documents.
+	secretlister    corev1listers.SecretLister
 	configmaplister corev1listers.ConfigMapLister
 	kubeclient      kubernetes.Interface
+	// now returns the current time, replaced in tests.
+	now func() time.Time
 }
 
 // Check that our Reconciler implements Interface as well as finalizer
@@ -61,18 +78,25 @@ var _ trustrootreconciler.Finalizer = (*Reconciler)(nil)
 func (r *Reconciler) ReconcileKind(ctx context.Context, trustroot *v1alpha1.TrustRoot) reconciler.Event {
 	trustroot.Status.InitializeConditions()
 	var sigstoreKeys *config.SigstoreKeys
+	// tufExpirations are the expirations of the TUF metadata, if the keys
+	// come from a TUF repository.
+	var tufExpirations map[string]time.Time
 	var err error
 	switch {
 	case trustroot.Spec.Repository != nil:
-		sigstoreKeys, err = r.getSigstoreKeysFromMirrorFS(ctx, trustroot.Spec.Repository)
+		sigstoreKeys, tufExpirations, err = r.getSigstoreKeysFromMirrorFS(ctx, trustroot.Spec.Repository)
 	case trustroot.Spec.Remote != nil:
-		sigstoreKeys, err = r.getSigstoreKeysFromRemote(ctx, trustroot.Spec.Remote)
+		sigstoreKeys, tufExpirations, err = r.getSigstoreKeysFromRemote(ctx, trustroot.Spec.Remote)
+	case trustroot.Spec.OCI != nil:
+		sigstoreKeys, tufExpirations, err = r.getSigstoreKeysFromOCI(ctx, trustroot.Spec.OCI)
 	case trustroot.Spec.SigstoreKeys != nil:
 		sigstoreKeys, err = config.ConvertSigstoreKeys(ctx, trustroot.Spec.SigstoreKeys)
+	case trustroot.Spec.TrustedRoot != nil:
+		sigstoreKeys, err = r.getSigstoreKeysFromTrustedRoot(ctx, trustroot, trustroot.Spec.TrustedRoot)
 	default:
 		// This should not happen since the CRD has been validated.
-		err = fmt.Errorf("invalid TrustRoot entry: %s missing repository,remote, and sigstoreKeys", trustroot.Name)
-		logging.FromContext(ctx).Errorf("Invalid trustroot entry: %s missing repository,remote, and sigstoreKeys", trustroot.Name)
+		err = fmt.Errorf("invalid TrustRoot entry: %s missing repository, remote, oci, sigstoreKeys, and trustedRoot", trustroot.Name)
+		logging.FromContext(ctx).Errorf("Invalid trustroot entry: %s missing repository, remote, oci, sigstoreKeys, and trustedRoot", trustroot.Name)
 	}
 
 	if err != nil {
@@ -81,23 +105,23 @@ func (r *Reconciler) ReconcileKind(ctx context.Context, trustroot *v1alpha1.Trus
 		return err
 	}
 	trustroot.Status.MarkInlineKeysOk()
-	// LogIDs for Rekor get created from the PublicKey, so we need to construct
-	// them before serializing.
-	// Note this is identical to what we do with CTLog PublicKeys, but they
-	// are not restricted to being only ecdsa.PublicKey.
+	// LogIDs for Rekor and CTLog get created from the PublicKey, so we need to
+	// construct them before serializing. Any key type is accepted here: the
+	// cosign verification only uses the ECDSA ones, while the bundle
+	// verification supports ECDSA, Ed25519 and RSA keys.
+	var unsupportedTlogKeys []string
 	for i, tlog := range sigstoreKeys.Tlogs {
 		pk, logID, err := pemToKeyAndID(config.SerializePublicKey(tlog.PublicKey))
 		if err != nil {
 			return fmt.Errorf("invalid rekor public key %d: %w", i, err)
 		}
-		// This needs to be ecdsa instead of crypto.PublicKey
 		// https://github.com/sigstore/cosign/issues/2540
-		_, ok := pk.(*ecdsa.PublicKey)
-		if !ok {
-			return fmt.Errorf("public key %d is not ecdsa.PublicKey", i)
+		if _, ok := pk.(*ecdsa.PublicKey); !ok {
+			unsupportedTlogKeys = append(unsupportedTlogKeys, fmt.Sprintf("tLogs[%d] (%T)", i, pk))
 		}
 		sigstoreKeys.Tlogs[i].LogId = &config.LogID{KeyId: []byte(logID)}
 	}
+	r.markTlogKeys(ctx, trustroot, unsupportedTlogKeys)
 	for i, ctlog := range sigstoreKeys.Ctlogs {
 		_, logID, err := pemToKeyAndID(config.SerializePublicKey(ctlog.PublicKey))
 		if err != nil {
@@ -127,6 +151,7 @@ func (r *Reconciler) ReconcileKind(ctx context.Context, trustroot *v1alpha1.Trus
 			return err
 		}
 		trustroot.Status.MarkCMUpdatedOK()
+		r.markRefreshed(ctx, trustroot, tufExpirations, sigstoreKeys)
 		return nil
 	}
 
@@ -146,9 +171,26 @@ func (r *Reconciler) ReconcileKind(ctx context.Context, trustroot *v1alpha1.Trus
 		}
 	}
 	trustroot.Status.MarkCMUpdatedOK()
+	r.markRefreshed(ctx, trustroot, tufExpirations, sigstoreKeys)
 	return nil
 }
 
+// markTlogKeys records in the status of the TrustRoot whether some of its
+// transparency log keys are not ECDSA keys, which cosign does not support,
+// and raises a Warning Event if so, since the authorities without
+// signatureFormat bundle ignore them and fail to verify the entries they
+// signed.
+func (r *Reconciler) markTlogKeys(ctx context.Context, trustroot *v1alpha1.TrustRoot, unsupported []string) {
+	if len(unsupported) == 0 {
+		trustroot.Status.MarkTlogKeysSupported()
+		return
+	}
+	msg := fmt.Sprintf("the keys of %s are only used with signatureFormat bundle", strings.Join(unsupported, ", "))
+	logging.FromContext(ctx).Warnf("TrustRoot %s: %s", trustroot.Name, msg)
+	trustroot.Status.MarkTlogKeysUnsupported(msg)
+	controller.GetEventRecorder(ctx).Event(trustroot, corev1.EventTypeWarning, "UnsupportedTlogKeys", msg)
+}
+
 // FinalizeKind implements Interface.ReconcileKind.
 func (r *Reconciler) FinalizeKind(ctx context.Context, trustroot *v1alpha1.TrustRoot) reconciler.Event {
 	// See if the CM holding configs even exists
@@ -171,11 +213,12 @@ func (r *Reconciler) FinalizeKind(ctx context.Context, trustroot *v1alpha1.Trust
 }
 
 // getSigstoreKeys will take a TUF Repository specification, and fetch the
-// necessary Keys / Certificates from there for Fulcio, Rekor, and CTLog.
-func (r *Reconciler) getSigstoreKeysFromMirrorFS(ctx context.Context, repository *v1alpha1.Repository) (*config.SigstoreKeys, error) {
+// necessary Keys / Certificates from there for Fulcio, Rekor, and CTLog,
+// along with the expirations of the TUF metadata.
+func (r *Reconciler) getSigstoreKeysFromMirrorFS(ctx context.Context, repository *v1alpha1.Repository) (*config.SigstoreKeys, map[string]time.Time, error) {
 	tufClient, err := tuf.ClientFromSerializedMirror(ctx, repository.MirrorFS, repository.Root, repository.Targets, v1alpha1.DefaultTUFRepoPrefix)
 	if err != nil {
-		return nil, fmt.Errorf("failed to construct TUF client from mirror: %w", err)
+		return nil, nil, fmt.Errorf("failed to construct TUF client from mirror: %w", err)
 	}
 
 	trustedRootTarget := "trusted_root.json"
@@ -183,13 +226,13 @@ func (r *Reconciler) getSigstoreKeysFromMirrorFS(ctx context.Context, repository
 		trustedRootTarget = repository.TrustedRootTarget
 	}
 
-	return GetSigstoreKeysFromTuf(ctx, tufClient, trustedRootTarget)
+	return getSigstoreKeysAndExpirationsFromTuf(ctx, tufClient, trustedRootTarget)
 }
 
-func (r *Reconciler) getSigstoreKeysFromRemote(ctx context.Context, remote *v1alpha1.Remote) (*config.SigstoreKeys, error) {
+func (r *Reconciler) getSigstoreKeysFromRemote(ctx context.Context, remote *v1alpha1.Remote) (*config.SigstoreKeys, map[string]time.Time, error) {
 	tufClient, err := tuf.ClientFromRemote(ctx, remote.Mirror.String(), remote.Root, remote.Targets)
 	if err != nil {
-		return nil, fmt.Errorf("failed to construct TUF client from remote: %w", err)
+		return nil, nil, fmt.Errorf("failed to construct TUF client from remote: %w", err)
 	}
 
 	trustedRootTarget := "trusted_root.json"
@@ -197,7 +240,140 @@ func (r *Reconciler) getSigstoreKeysFromRemote(ctx context.Context, remote *v1al
 		trustedRootTarget = remote.TrustedRootTarget
 	}
 
-	return GetSigstoreKeysFromTuf(ctx, tufClient, trustedRootTarget)
+	return getSigstoreKeysAndExpirationsFromTuf(ctx, tufClient, trustedRootTarget)
+}
+
+// getSigstoreKeysFromOCI fetches the TUF repository stored as an OCI
+// artifact, with the same keychain as the images being verified (and the
+// pull secrets of the TrustRoot), and gets the keys from there.
+func (r *Reconciler) getSigstoreKeysFromOCI(ctx context.Context, oci *v1alpha1.OCIRepository) (*config.SigstoreKeys, map[string]time.Time, error) {
+	ref, err := name.ParseReference(oci.Image)
+	if err != nil {
+		return nil, nil, fmt.Errorf("invalid OCI image %q: %w", oci.Image, err)
+	}
+	pullSecrets := make([]string, 0, len(oci.PullSecrets))
+	for _, s := range oci.PullSecrets {
+		pullSecrets = append(pullSecrets, s.Name)
+	}
+	kc, err := registryauth.NewK8sKeychain(ctx, r.kubeclient, k8schain.Options{
+		Namespace:          system.Namespace(),
+		ServiceAccountName: kauth.NoServiceAccount,
+		ImagePullSecrets:   pullSecrets,
+	})
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to build the keychain: %w", err)
+	}
+	tufClient, err := tuf.ClientFromOCI(ctx, ref, oci.Root, oci.Targets, remote.WithAuthFromKeychain(kc))
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to construct TUF client from OCI: %w", err)
+	}
+
+	trustedRootTarget := "trusted_root.json"
+	if oci.TrustedRootTarget != "" {
+		trustedRootTarget = oci.TrustedRootTarget
+	}
+
+	return getSigstoreKeysAndExpirationsFromTuf(ctx, tufClient, trustedRootTarget)
+}
+
+// getSigstoreKeysFromTrustedRoot parses the trusted root document of the
+// TrustRoot, either inline or read from the referenced ConfigMap or Secret.
+func (r *Reconciler) getSigstoreKeysFromTrustedRoot(ctx context.Context, trustroot *v1alpha1.TrustRoot, trustedRoot *v1alpha1.TrustedRoot) (*config.SigstoreKeys, error) {
+	data := []byte(trustedRoot.Data)
+	var err error
+	switch {
+	case trustedRoot.ConfigMapRef != nil:
+		data, err = r.readAndTrackConfigMap(ctx, trustroot, trustedRoot.ConfigMapRef)
+	case trustedRoot.SecretRef != nil:
+		data, err = r.readAndTrackSecret(ctx, trustroot, trustedRoot.SecretRef)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return GetSigstoreKeysFromTrustedRoot(data)
+}
+
+// GetSigstoreKeysFromTrustedRoot returns the sigstore keys of a trusted root
+// JSON document, after making sure that it is a trusted root sigstore-go can
+// use for verifying bundles, and not just a valid protobuf message.
+func GetSigstoreKeysFromTrustedRoot(data []byte) (*config.SigstoreKeys, error) {
+	if _, err := root.NewTrustedRootFromJSON(data); err != nil {
+		return nil, fmt.Errorf("invalid trusted root: %w", err)
+	}
+	ret := &config.SigstoreKeys{}
+	if err := protojson.Unmarshal(data, ret); err != nil {
+		return nil, fmt.Errorf("parsing trusted root: %w", err)
+	}
+	return ret, nil
+}
+
+// readAndTrackConfigMap returns the trusted root document held by the
+// referenced ConfigMap. Additionally, we set up a tracker so we will be
+// notified if the ConfigMap is modified.
+func (r *Reconciler) readAndTrackConfigMap(ctx context.Context, trustroot *v1alpha1.TrustRoot, ref *v1alpha1.ConfigMapReference) ([]byte, error) {
+	keyName := trustedRootKey(ref.Key)
+	if err := r.tracker.TrackReference(tracker.Reference{
+		APIVersion: "v1",
+		Kind:       "ConfigMap",
+		Namespace:  system.Namespace(),
+		Name:       ref.Name,
+	}, trustroot); err != nil {
+		return nil, fmt.Errorf("failed to track changes to configmap %q : %w", ref.Name, err)
+	}
+	cm, err := r.configmaplister.ConfigMaps(system.Namespace()).Get(ref.Name)
+	if err != nil {
+		return nil, err
+	}
+	if cm.Data[keyName] == "" {
+		return nil, fmt.Errorf("configmap %q does not contain key %s", ref.Name, keyName)
+	}
+	logging.FromContext(ctx).Infof("reading trusted root from configmap %q key %q", ref.Name, keyName)
+	return []byte(cm.Data[keyName]), nil
+}
+
+// readAndTrackSecret returns the trusted root document held by the
+// referenced Secret. Additionally, we set up a tracker so we will be
+// notified if the Secret is modified.
+func (r *Reconciler) readAndTrackSecret(ctx context.Context, trustroot *v1alpha1.TrustRoot, ref *v1alpha1.SecretKeyReference) ([]byte, error) {
+	keyName := trustedRootKey(ref.Key)
+	if err := r.tracker.TrackReference(tracker.Reference{
+		APIVersion: "v1",
+		Kind:       "Secret",
+		Namespace:  system.Namespace(),
+		Name:       ref.Name,
+	}, trustroot); err != nil {
+		return nil, fmt.Errorf("failed to track changes to secret %q : %w", ref.Name, err)
+	}
+	secret, err := r.secretlister.Secrets(system.Namespace()).Get(ref.Name)
+	if err != nil {
+		return nil, err
+	}
+	if len(secret.Data[keyName]) == 0 {
+		return nil, fmt.Errorf("secret %q does not contain key %s", ref.Name, keyName)
+	}
+	logging.FromContext(ctx).Infof("reading trusted root from secret %q key %q", ref.Name, keyName)
+	return secret.Data[keyName], nil
+}
+
+// trustedRootKey returns the key holding the trusted root document,
+// defaulting to trusted_root.json.
+func trustedRootKey(key string) string {
+	if key == "" {
+		return "trusted_root.json"
+	}
+	return key
+}
+
+func getSigstoreKeysAndExpirationsFromTuf(ctx context.Context, tufClient *tuf.Client, trustedRootTarget string) (*config.SigstoreKeys, map[string]time.Time, error) {
+	sigstoreKeys, err := GetSigstoreKeysFromTuf(ctx, tufClient, trustedRootTarget)
+	if err != nil {
+		return nil, nil, err
+	}
+	tufExpirations, err := tufClient.MetadataExpirations()
+	if err != nil {
+		return nil, nil, err
+	}
+	return sigstoreKeys, tufExpirations, nil
 }
 
 // remoteTrustRootEntry removes a TrustRoot entry from a CM. If no entry exists, it's a nop.
@@ -243,7 +419,7 @@ type sigstoreCustomMetadata struct {
 // getSigstoreKeysFromTuf returns the sigstore keys from the TUF client. Note
 // that this should really be exposed from the sigstore/sigstore TUF pkg, but
 // is currently not.
-func GetSigstoreKeysFromTuf(ctx context.Context, tufClient *client.Client, trustedRootTarget string) (*config.SigstoreKeys, error) {
+func GetSigstoreKeysFromTuf(ctx context.Context, tufClient *tuf.Client, trustedRootTarget string) (*config.SigstoreKeys, error) {
 	targets, err := tufClient.Targets()
 	if err != nil {
 		return nil, fmt.Errorf("error getting targets: %w", err)
@@ -17,7 +17,9 @@ package trustroot
 import (
 	"bytes"
 	"context"
+	"crypto"
 	"crypto/ecdsa"
+	"crypto/ed25519"
 	"crypto/elliptic"
 	"crypto/rand"
 	"crypto/x509"
@@ -39,6 +41,7 @@ import (
 	fakecosignclient "github.com/sigstore/policy-controller/pkg/client/injection/client/fake"
 	"github.com/sigstore/policy-controller/pkg/client/injection/reconciler/policy/v1alpha1/trustroot"
 	pbcommon "github.com/sigstore/protobuf-specs/gen/pb-go/common/v1"
+	"github.com/sigstore/sigstore/pkg/cryptoutils"
 	corev1 "k8s.io/api/core/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/runtime"
@@ -47,10 +50,12 @@ import (
 	"knative.dev/pkg/configmap"
 	"knative.dev/pkg/controller"
 	"knative.dev/pkg/system"
+	"knative.dev/pkg/tracker"
 
 	. "github.com/sigstore/policy-controller/pkg/reconciler/testing/v1alpha1"
 	"github.com/sigstore/policy-controller/pkg/reconciler/trustroot/resources"
 	"github.com/sigstore/policy-controller/pkg/reconciler/trustroot/testdata"
+	"github.com/sigstore/policy-controller/pkg/tuf"
 	. "knative.dev/pkg/reconciler/testing"
 	_ "knative.dev/pkg/system/testing"
 )
@@ -157,6 +162,51 @@ var marshalledEntry = string(canonicalizeSigstoreKeys(testdata.Get("marshalledEn
 // this is the marshalled entry for when we construct from the repository.
 var marshalledEntryFromMirrorFS = string(canonicalizeSigstoreKeys(testdata.Get("marshalledEntryFromMirrorFS.json")))
 
+// testNow is the time of the reconciles.
+var testNow = time.Date(2024, time.December, 1, 0, 0, 0, 0, time.UTC)
+
+// testCertChainNotAfter is when the certificate chains of the test data expire,
+// and tufExpires when the metadata of the TUF repositories expire.
+// expiringMessage is the message of the TrustRoot with sigstoreKeys when
+// its certificate chains expire within the window.
+const expiringMessage = "certificateAuthorities[0].certChain expires at 2034-08-30T18:57:38Z, timestampAuthorities[0].certChain expires at 2034-08-30T18:57:38Z"
+
+var testCertChainNotAfter = metav1.NewTime(time.Date(2034, time.August, 30, 18, 57, 38, 0, time.UTC))
+var tufExpires = metav1.NewTime(time.Date(2025, time.March, 2, 19, 57, 39, 0, time.UTC))
+
+// sigstoreKeysExpirations are the expirations of the TrustRoot with
+// sigstoreKeys.
+var sigstoreKeysExpirations = []v1alpha1.TrustRootExpiration{
+	{Name: "certificateAuthorities[0].certChain", Expires: testCertChainNotAfter},
+	{Name: "timestampAuthorities[0].certChain", Expires: testCertChainNotAfter},
+}
+
+// mirrorFSExpirations are the expirations of the TrustRoot with
+// validRepository.
+var mirrorFSExpirations = []v1alpha1.TrustRootExpiration{
+	{Name: "tuf/root", Expires: tufExpires},
+	{Name: "tuf/snapshot", Expires: tufExpires},
+	{Name: "tuf/targets", Expires: tufExpires},
+	{Name: "tuf/timestamp", Expires: tufExpires},
+	{Name: "certificateAuthorities[0].certChain", Expires: testCertChainNotAfter},
+}
+
+// trustedRootJSONExpirations are the expirations of the TrustRoots with the
+// TUF repositories containing a trusted root.
+var trustedRootJSONExpirations = append(mirrorFSExpirations[:4:4], sigstoreKeysExpirations...)
+
+// ed25519SigstoreKeys are sigstoreKeys with an Ed25519 Rekor key, which
+// cosign does not support, and unsupportedTlogKeysMessage the message of the
+// TrustRoot with them.
+var ed25519SigstoreKeys = map[string]string{
+	"ctfe":   sigstoreKeys["ctfe"],
+	"fulcio": sigstoreKeys["fulcio"],
+	"rekor":  string(mustMarshalPublicKeyToPEM(ed25519.NewKeyFromSeed(bytes.Repeat([]byte{1}, ed25519.SeedSize)).Public())),
+	"tsa":    sigstoreKeys["tsa"],
+}
+
+const unsupportedTlogKeysMessage = "the keys of tLogs[0] (ed25519.PublicKey) are only used with signatureFormat bundle"
+
 var rekorLogID = string(testdata.Get("rekorLogID.txt"))
 var ctfeLogID = string(testdata.Get("ctfeLogID.txt"))
 
@@ -183,6 +233,23 @@ var validRepositoryWithCustomTrustedRootJSON = testdata.Get("tufRepoWithCustomTr
 // rootWithCustomTrustedRootJSON is a valid root.json for above TUF repository.
 var rootWithCustomTrustedRootJSON = testdata.Get("rootWithCustomTrustedRootJSON.json")
 
+// trustedRootJSON is the trusted_root.json target of
+// tufRepoWithTrustedRootJSON.tar, so it converts to marshalledEntry.
+var trustedRootJSON = testdata.Get("trustedRoot.json")
+
+const (
+	trustedRootConfigMapName = "trusted-root"
+	trustedRootSecretName    = "trusted-root"
+)
+
+var trustedRootFromConfigMap = v1alpha1.TrustedRoot{
+	ConfigMapRef: &v1alpha1.ConfigMapReference{Name: trustedRootConfigMapName},
+}
+
+var trustedRootFromSecret = v1alpha1.TrustedRoot{
+	SecretRef: &v1alpha1.SecretKeyReference{Name: trustedRootSecretName, Key: "root"},
+}
+
 func TestReconcile(t *testing.T) {
 	table := TableTest{{
 		Name: "bad workqueue key",
@@ -228,6 +295,36 @@ func TestReconcile(t *testing.T) {
 				WithTrustRootResourceVersion(resourceVersion),
 				WithSigstoreKeys(sigstoreKeys),
 				MarkReadyTrustRoot,
+				WithMarkTlogKeysSupportedTrustRoot,
+				WithTrustRootRefreshed(testNow, sigstoreKeysExpirations...),
+			)}},
+	}, {
+		Name: "TrustRoot with SigstoreKeys with an Ed25519 tlog key, cm created",
+		Key:  testKey,
+
+		SkipNamespaceValidation: true, // Cluster scoped
+		Objects: []runtime.Object{
+			NewTrustRoot(trName,
+				WithTrustRootUID(uid),
+				WithTrustRootResourceVersion(resourceVersion),
+				WithSigstoreKeys(ed25519SigstoreKeys),
+				WithTrustRootFinalizer,
+			)},
+		WantCreates: []runtime.Object{
+			makeConfigMapWithSigstoreKeysFrom(ed25519SigstoreKeys),
+		},
+		WantEvents: []string{
+			Eventf(corev1.EventTypeWarning, "UnsupportedTlogKeys", unsupportedTlogKeysMessage),
+		},
+		WantStatusUpdates: []clientgotesting.UpdateActionImpl{{
+			Object: NewTrustRoot(trName,
+				WithTrustRootUID(uid),
+				WithTrustRootResourceVersion(resourceVersion),
+				WithSigstoreKeys(ed25519SigstoreKeys),
+				WithTrustRootFinalizer,
+				MarkReadyTrustRoot,
+				WithMarkTlogKeysUnsupportedTrustRoot(unsupportedTlogKeysMessage),
+				WithTrustRootRefreshed(testNow, sigstoreKeysExpirations...),
 			)}},
 	}, {
 		Name: "TrustRoot with SigstoreKeys, cm exists with entry, no changes",
@@ -254,6 +351,37 @@ func TestReconcile(t *testing.T) {
 				WithTrustRootResourceVersion(resourceVersion),
 				WithSigstoreKeys(sigstoreKeys),
 				MarkReadyTrustRoot,
+				WithMarkTlogKeysSupportedTrustRoot,
+				WithTrustRootRefreshed(testNow, sigstoreKeysExpirations...),
+			)}},
+	}, {
+		Name: "TrustRoot with SigstoreKeys expiring within the window, cm exists with entry",
+		Key:  testKey,
+		Ctx:  tuf.ToContextWithExpiryWindow(context.Background(), 10*365*24*time.Hour),
+
+		SkipNamespaceValidation: true, // Cluster scoped
+		Objects: []runtime.Object{
+			NewTrustRoot(trName,
+				WithTrustRootUID(uid),
+				WithTrustRootResourceVersion(resourceVersion),
+				WithSigstoreKeys(sigstoreKeys),
+				WithTrustRootFinalizer,
+			),
+			makeConfigMapWithSigstoreKeys(),
+		},
+		WantEvents: []string{
+			Eventf(corev1.EventTypeWarning, "Expiring", expiringMessage),
+		},
+		WantStatusUpdates: []clientgotesting.UpdateActionImpl{{
+			Object: NewTrustRoot(trName,
+				WithTrustRootUID(uid),
+				WithTrustRootResourceVersion(resourceVersion),
+				WithSigstoreKeys(sigstoreKeys),
+				WithTrustRootFinalizer,
+				MarkReadyTrustRoot,
+				WithMarkTlogKeysSupportedTrustRoot,
+				WithTrustRootRefreshed(testNow, sigstoreKeysExpirations...),
+				WithMarkExpiringTrustRoot(expiringMessage),
 			)}},
 	}, {
 		Name: "TrustRoot with SigstoreKeys, cm exists with different, replace patched",
@@ -279,6 +407,8 @@ func TestReconcile(t *testing.T) {
 				WithSigstoreKeys(sigstoreKeys),
 				WithTrustRootFinalizer,
 				MarkReadyTrustRoot,
+				WithMarkTlogKeysSupportedTrustRoot,
+				WithTrustRootRefreshed(testNow, sigstoreKeysExpirations...),
 			)}},
 	}, {
 		Name: "TrustRoot with SigstoreKeys, cm exists with different, replace patched but fails",
@@ -313,6 +443,7 @@ func TestReconcile(t *testing.T) {
 				WithInitConditionsTrustRoot,
 				WithObservedGenerationTrustRoot(1),
 				WithMarkInlineKeysOkTrustRoot,
+				WithMarkTlogKeysSupportedTrustRoot,
 				WithMarkCMUpdateFailedTrustRoot("inducing failure for patch configmaps"),
 			)}},
 	}, {
@@ -343,6 +474,104 @@ func TestReconcile(t *testing.T) {
 		WantEvents: []string{
 			Eventf(corev1.EventTypeNormal, "FinalizerUpdate", `Updated "test-trustroot-2" finalizers`),
 		},
+	}, {
+		Name: "TrustedRoot from a ConfigMap, cm created",
+		Key:  testKey,
+
+		SkipNamespaceValidation: true, // Cluster scoped
+		Objects: []runtime.Object{
+			NewTrustRoot(trName,
+				WithTrustRootUID(uid),
+				WithTrustRootResourceVersion(resourceVersion),
+				WithTrustedRoot(trustedRootFromConfigMap),
+			),
+			makeTrustedRootConfigMap(),
+		},
+		WantCreates: []runtime.Object{
+			makeConfigMapWithMirrorFS(marshalledEntry),
+		},
+		WantPatches: []clientgotesting.PatchActionImpl{
+			patchFinalizers(system.Namespace(), trName),
+		},
+		WantEvents: []string{
+			Eventf(corev1.EventTypeNormal, "FinalizerUpdate", `Updated "test-trustroot" finalizers`),
+		},
+		WantStatusUpdates: []clientgotesting.UpdateActionImpl{{
+			Object: NewTrustRoot(trName,
+				WithTrustRootUID(uid),
+				WithTrustRootResourceVersion(resourceVersion),
+				WithTrustedRoot(trustedRootFromConfigMap),
+				MarkReadyTrustRoot,
+				WithMarkTlogKeysSupportedTrustRoot,
+				WithTrustRootRefreshed(testNow, sigstoreKeysExpirations...),
+			)}},
+		PostConditions: []func(*testing.T, *TableRow){
+			AssertTrackingConfigMap(system.Namespace(), trustedRootConfigMapName),
+		},
+	}, {
+		Name: "TrustedRoot from a Secret, secret does not exist",
+		Key:  testKey,
+
+		SkipNamespaceValidation: true, // Cluster scoped
+		Objects: []runtime.Object{
+			NewTrustRoot(trName,
+				WithTrustRootUID(uid),
+				WithTrustRootResourceVersion(resourceVersion),
+				WithTrustedRoot(trustedRootFromSecret),
+			),
+		},
+		WantErr: true,
+		WantPatches: []clientgotesting.PatchActionImpl{
+			patchFinalizers(system.Namespace(), trName),
+		},
+		WantEvents: []string{
+			Eventf(corev1.EventTypeNormal, "FinalizerUpdate", `Updated "test-trustroot" finalizers`),
+			Eventf(corev1.EventTypeWarning, "InternalError", `secret "trusted-root" not found`),
+		},
+		WantStatusUpdates: []clientgotesting.UpdateActionImpl{{
+			Object: NewTrustRoot(trName,
+				WithTrustRootUID(uid),
+				WithTrustRootResourceVersion(resourceVersion),
+				WithTrustedRoot(trustedRootFromSecret),
+				WithInitConditionsTrustRoot,
+				WithObservedGenerationTrustRoot(1),
+				WithMarkInlineKeysFailedTrustRoot(`secret "trusted-root" not found`),
+			)}},
+		PostConditions: []func(*testing.T, *TableRow){
+			AssertTrackingSecret(system.Namespace(), trustedRootSecretName),
+		},
+	}, {
+		Name: "TrustedRoot from a Secret, secret exists, cm exists with entry",
+		Key:  testKey,
+
+		SkipNamespaceValidation: true, // Cluster scoped
+		Objects: []runtime.Object{
+			NewTrustRoot(trName,
+				WithTrustRootUID(uid),
+				WithTrustRootResourceVersion(resourceVersion),
+				WithTrustedRoot(trustedRootFromSecret),
+			),
+			makeTrustedRootSecret(),
+			makeConfigMapWithMirrorFS(marshalledEntry),
+		},
+		WantPatches: []clientgotesting.PatchActionImpl{
+			patchFinalizers(system.Namespace(), trName),
+		},
+		WantEvents: []string{
+			Eventf(corev1.EventTypeNormal, "FinalizerUpdate", `Updated "test-trustroot" finalizers`),
+		},
+		WantStatusUpdates: []clientgotesting.UpdateActionImpl{{
+			Object: NewTrustRoot(trName,
+				WithTrustRootUID(uid),
+				WithTrustRootResourceVersion(resourceVersion),
+				WithTrustedRoot(trustedRootFromSecret),
+				MarkReadyTrustRoot,
+				WithMarkTlogKeysSupportedTrustRoot,
+				WithTrustRootRefreshed(testNow, sigstoreKeysExpirations...),
+			)}},
+		PostConditions: []func(*testing.T, *TableRow){
+			AssertTrackingSecret(system.Namespace(), trustedRootSecretName),
+		},
 	}, {
 		Name: "With repository",
 		Key:  testKey,
@@ -366,6 +595,8 @@ func TestReconcile(t *testing.T) {
 				WithRepository("targets", rootJSON, validRepository, ""),
 				WithTrustRootFinalizer,
 				MarkReadyTrustRoot,
+				WithMarkTlogKeysSupportedTrustRoot,
+				WithTrustRootRefreshed(testNow, mirrorFSExpirations...),
 			)}},
 	}, {
 		Name: "With repository containing trusted_root.json",
@@ -390,6 +621,8 @@ func TestReconcile(t *testing.T) {
 				WithRepository("targets", rootWithTrustedRootJSON, validRepositoryWithTrustedRootJSON, ""),
 				WithTrustRootFinalizer,
 				MarkReadyTrustRoot,
+				WithMarkTlogKeysSupportedTrustRoot,
+				WithTrustRootRefreshed(testNow, trustedRootJSONExpirations...),
 			)}},
 	}, {
 		Name: "With repository containing custom_trusted_root.json",
@@ -414,14 +647,19 @@ func TestReconcile(t *testing.T) {
 				WithRepository("targets", rootWithCustomTrustedRootJSON, validRepositoryWithCustomTrustedRootJSON, "custom_trusted_root.json"),
 				WithTrustRootFinalizer,
 				MarkReadyTrustRoot,
+				WithMarkTlogKeysSupportedTrustRoot,
+				WithTrustRootRefreshed(testNow, trustedRootJSONExpirations...),
 			)}},
 	}}
 
 	logger := logtesting.TestLogger(t)
 	table.Test(t, MakeFactory(func(ctx context.Context, listers *Listers, _ configmap.Watcher) controller.Reconciler {
 		r := &Reconciler{
+			tracker:         ctx.Value(TrackerKey).(tracker.Interface),
+			secretlister:    listers.GetSecretLister(),
 			configmaplister: listers.GetConfigMapLister(),
 			kubeclient:      fakekubeclient.Get(ctx),
+			now:             func() time.Time { return testNow },
 		}
 		return trustroot.NewReconciler(ctx, logger,
 			fakecosignclient.Get(ctx), listers.GetTrustRootLister(),
@@ -461,6 +699,42 @@ func makeConfigMapWithSigstoreKeys() *corev1.ConfigMap {
 	return ret
 }
 
+// makeConfigMapWithSigstoreKeysFrom is makeConfigMapWithSigstoreKeys for
+// other keys, whose log IDs are computed.
+func makeConfigMapWithSigstoreKeysFrom(sk map[string]string) *corev1.ConfigMap {
+	source := NewTrustRoot(trName, WithSigstoreKeys(sk))
+	c, err := config.ConvertSigstoreKeys(context.Background(), source.Spec.SigstoreKeys)
+	if err != nil {
+		panic("failed to convert test SigstoreKeys")
+	}
+	for _, tlog := range append(c.Tlogs, c.Ctlogs...) {
+		_, logID, err := pemToKeyAndID(config.SerializePublicKey(tlog.PublicKey))
+		if err != nil {
+			panic("failed to compute the log ID of test SigstoreKeys")
+		}
+		tlog.LogId = &config.LogID{KeyId: []byte(logID)}
+	}
+	marshalled, err := resources.Marshal(c)
+	if err != nil {
+		panic("failed to marshal test SigstoreKeys")
+	}
+	return &corev1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{
+			Namespace: system.Namespace(),
+			Name:      config.SigstoreKeysConfigName,
+		},
+		Data: map[string]string{trName: marshalled},
+	}
+}
+
+func mustMarshalPublicKeyToPEM(pk crypto.PublicKey) []byte {
+	pem, err := cryptoutils.MarshalPublicKeyToPEM(pk)
+	if err != nil {
+		panic(err)
+	}
+	return pem
+}
+
 func makeConfigMapWithMirrorFS(entry string) *corev1.ConfigMap {
 	return &corev1.ConfigMap{
 		ObjectMeta: metav1.ObjectMeta{
@@ -471,6 +745,26 @@ func makeConfigMapWithMirrorFS(entry string) *corev1.ConfigMap {
 	}
 }
 
+func makeTrustedRootConfigMap() *corev1.ConfigMap {
+	return &corev1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{
+			Namespace: system.Namespace(),
+			Name:      trustedRootConfigMapName,
+		},
+		Data: map[string]string{"trusted_root.json": string(trustedRootJSON)},
+	}
+}
+
+func makeTrustedRootSecret() *corev1.Secret {
+	return &corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{
+			Namespace: system.Namespace(),
+			Name:      trustedRootSecretName,
+		},
+		Data: map[string][]byte{"root": trustedRootJSON},
+	}
+}
+
 // Same as above, just forcing an update because the entry in the configMap
 // is not what we expect, it doesn't really matter what it is.
 func makeDifferentConfigMap() *corev1.ConfigMap {
@@ -0,0 +1,125 @@
+// Copyright 2024 The Sigstore Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package tuf
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/theupdateframework/go-tuf/client"
+	"github.com/theupdateframework/go-tuf/data"
+)
+
+// Client is a TUF client, along with the local store holding the metadata
+// it verified.
+type Client struct {
+	*client.Client
+	local client.LocalStore
+}
+
+func newClient(local client.LocalStore, remote client.RemoteStore) *Client {
+	return &Client{Client: client.NewClient(local, remote), local: local}
+}
+
+// initAndUpdate initializes the client with the trusted root, updates it
+// from the remote store and validates the resulting state.
+func (c *Client) initAndUpdate(rootJSON []byte) error {
+	if err := c.Init(rootJSON); err != nil {
+		return fmt.Errorf("failed to init TUF client: %w", err)
+	}
+	targetFiles, err := c.Update()
+	if err != nil {
+		return fmt.Errorf("failed to update TUF client: %w", err)
+	}
+	if len(targetFiles) == 0 {
+		return errors.New("there are no valid targetfiles in TUF repo")
+	}
+	return c.validate(time.Now())
+}
+
+// validate checks that the top-level metadata of the client are not expired
+// at now, and that the roles of the root have a usable signature threshold,
+// before its targets are trusted.
+func (c *Client) validate(now time.Time) error {
+	expirations, err := c.MetadataExpirations()
+	if err != nil {
+		return err
+	}
+	for _, role := range []string{"root", "targets", "snapshot", "timestamp"} {
+		expires, ok := expirations[role]
+		if !ok {
+			return fmt.Errorf("missing %s metadata", role)
+		}
+		if !expires.After(now) {
+			return fmt.Errorf("%s metadata expired at %s", role, expires.UTC().Format(time.RFC3339))
+		}
+	}
+
+	meta, err := c.local.GetMeta()
+	if err != nil {
+		return fmt.Errorf("getting TUF metadata: %w", err)
+	}
+	var signed data.Signed
+	if err := json.Unmarshal(meta["root.json"], &signed); err != nil {
+		return fmt.Errorf("parsing root metadata: %w", err)
+	}
+	var root data.Root
+	if err := json.Unmarshal(signed.Signed, &root); err != nil {
+		return fmt.Errorf("parsing root metadata: %w", err)
+	}
+	for _, name := range []string{"root", "targets", "snapshot", "timestamp"} {
+		role, ok := root.Roles[name]
+		if !ok {
+			return fmt.Errorf("root metadata is missing the %s role", name)
+		}
+		if role.Threshold < 1 {
+			return fmt.Errorf("%s role has an invalid threshold %d", name, role.Threshold)
+		}
+		if len(role.KeyIDs) < role.Threshold {
+			return fmt.Errorf("%s role has a threshold of %d but only %d keys", name, role.Threshold, len(role.KeyIDs))
+		}
+	}
+	return nil
+}
+
+// MetadataExpirations returns when the top-level TUF metadata (root,
+// targets, snapshot and timestamp) of the client expire, by role.
+func (c *Client) MetadataExpirations() (map[string]time.Time, error) {
+	meta, err := c.local.GetMeta()
+	if err != nil {
+		return nil, fmt.Errorf("getting TUF metadata: %w", err)
+	}
+	ret := make(map[string]time.Time, len(meta))
+	for _, role := range []string{"root", "targets", "snapshot", "timestamp"} {
+		raw, ok := meta[role+".json"]
+		if !ok {
+			continue
+		}
+		var signed data.Signed
+		if err := json.Unmarshal(raw, &signed); err != nil {
+			return nil, fmt.Errorf("parsing %s metadata: %w", role, err)
+		}
+		var expires struct {
+			Expires time.Time `json:"expires"`
+		}
+		if err := json.Unmarshal(signed.Signed, &expires); err != nil {
+			return nil, fmt.Errorf("parsing %s metadata: %w", role, err)
+		}
+		ret[role] = expires.Expires
+	}
+	return ret, nil
+}
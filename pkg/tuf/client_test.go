@@ -0,0 +1,101 @@
+// Copyright 2024 The Sigstore Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package tuf
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/theupdateframework/go-tuf/client"
+)
+
+func TestClientValidate(t *testing.T) {
+	now := time.Date(2024, time.December, 1, 0, 0, 0, 0, time.UTC)
+	const future, past = "2100-01-01T00:00:00Z", "2024-01-01T00:00:00Z"
+	role := func(threshold, keys int) string {
+		keyIDs := ""
+		for i := 0; i < keys; i++ {
+			if i > 0 {
+				keyIDs += ","
+			}
+			keyIDs += fmt.Sprintf(`"key-%d"`, i)
+		}
+		return fmt.Sprintf(`{"keyids":[%s],"threshold":%d}`, keyIDs, threshold)
+	}
+	root := func(expires, rootRole string) []byte {
+		return []byte(fmt.Sprintf(`{"signed":{"_type":"root","expires":%q,"roles":{"root":%s,"targets":%s,"snapshot":%s,"timestamp":%s}},"signatures":[]}`,
+			expires, rootRole, role(1, 1), role(1, 1), role(1, 1)))
+	}
+	meta := func(expires string) []byte {
+		return []byte(fmt.Sprintf(`{"signed":{"expires":%q},"signatures":[]}`, expires))
+	}
+
+	tests := []struct {
+		name      string
+		root      []byte
+		timestamp []byte
+		wantErr   string
+	}{{
+		name:      "valid",
+		root:      root(future, role(2, 3)),
+		timestamp: meta(future),
+	}, {
+		name:      "expired timestamp",
+		root:      root(future, role(1, 1)),
+		timestamp: meta(past),
+		wantErr:   "timestamp metadata expired at 2024-01-01T00:00:00Z",
+	}, {
+		name:    "missing timestamp",
+		root:    root(future, role(1, 1)),
+		wantErr: "missing timestamp metadata",
+	}, {
+		name:      "threshold higher than the keys",
+		root:      root(future, role(2, 1)),
+		timestamp: meta(future),
+		wantErr:   "root role has a threshold of 2 but only 1 keys",
+	}, {
+		name:      "zero threshold",
+		root:      root(future, role(0, 1)),
+		timestamp: meta(future),
+		wantErr:   "root role has an invalid threshold 0",
+	}}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			local := client.MemoryLocalStore()
+			files := map[string][]byte{
+				"root.json":      tc.root,
+				"targets.json":   meta(future),
+				"snapshot.json":  meta(future),
+				"timestamp.json": tc.timestamp,
+			}
+			for name, data := range files {
+				if data == nil {
+					continue
+				}
+				if err := local.SetMeta(name, data); err != nil {
+					t.Fatal(err)
+				}
+			}
+			err := newClient(local, nil).validate(now)
+			switch {
+			case tc.wantErr == "" && err != nil:
+				t.Errorf("validate() = %v", err)
+			case tc.wantErr != "" && (err == nil || err.Error() != tc.wantErr):
+				t.Errorf("validate() = %v, wanted %s", err, tc.wantErr)
+			}
+		})
+	}
+}
@@ -39,3 +39,25 @@ func FromContextOrDefaults(ctx context.Context) time.Duration {
 	}
 	return controller.DefaultResyncPeriod
 }
+
+type trustrootExpiryWindowKey struct{}
+
+// DefaultTrustRootExpiryWindow is how long before they expire the parts of a
+// TrustRoot are reported as expiring, by default.
+const DefaultTrustRootExpiryWindow = 7 * 24 * time.Hour
+
+// ToContextWithExpiryWindow returns a context that includes a key
+// trustrootExpiryWindow set to the included duration
+func ToContextWithExpiryWindow(ctx context.Context, duration time.Duration) context.Context {
+	return context.WithValue(ctx, trustrootExpiryWindowKey{}, duration)
+}
+
+// ExpiryWindowFromContextOrDefaults returns a stored trustrootExpiryWindow if
+// attached. If not found, it returns DefaultTrustRootExpiryWindow.
+func ExpiryWindowFromContextOrDefaults(ctx context.Context) time.Duration {
+	x, ok := ctx.Value(trustrootExpiryWindowKey{}).(time.Duration)
+	if ok {
+		return x
+	}
+	return DefaultTrustRootExpiryWindow
+}
@@ -40,3 +40,17 @@ func TestContextDuration(t *testing.T) {
 		t.Fatal("Expected the context to store the value and be retrievable")
 	}
 }
+
+func TestContextFetchOptions(t *testing.T) {
+	ctx, _ := rtesting.SetupFakeContext(t)
+
+	if actual := FetchOptionsFromContextOrDefaults(ctx); actual != DefaultFetchOptions {
+		t.Fatalf("Expected the default FetchOptions, got %+v", actual)
+	}
+
+	expected := FetchOptions{Timeout: time.Minute, Retries: 5, RetryDelay: time.Millisecond}
+	ctx = ToContextWithFetchOptions(ctx, expected)
+	if actual := FetchOptionsFromContextOrDefaults(ctx); actual != expected {
+		t.Fatal("Expected the context to store the value and be retrievable")
+	}
+}
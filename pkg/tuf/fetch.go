@@ -0,0 +1,150 @@
+// Copyright 2024 The Sigstore Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package tuf
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"io"
+	"net/http"
+	"net/url"
+	"time"
+)
+
+// FetchOptions configures how the TUF metadata and targets are fetched from
+// remote mirrors and registries.
+type FetchOptions struct {
+	// Timeout is the timeout of each attempt of a request, including reading
+	// the response body.
+	Timeout time.Duration
+	// Retries is how many times a request is retried when it fails or the
+	// server responds with a 5xx or 429 status.
+	Retries int
+	// RetryDelay is the delay before the first retry, doubled for each
+	// subsequent one.
+	RetryDelay time.Duration
+	// Proxy is the URL of the proxy to use. If nil, the proxy is taken from
+	// the environment (HTTPS_PROXY, NO_PROXY...).
+	Proxy *url.URL
+	// RootCAs are the CAs trusted for TLS. If nil, the system ones are used.
+	RootCAs *x509.CertPool
+}
+
+// DefaultFetchOptions are the FetchOptions used when none are attached to
+// the context.
+var DefaultFetchOptions = FetchOptions{
+	Timeout:    30 * time.Second,
+	Retries:    3,
+	RetryDelay: time.Second,
+}
+
+type fetchOptionsKey struct{}
+
+// ToContextWithFetchOptions returns a context that includes the FetchOptions
+// of the TUF clients.
+func ToContextWithFetchOptions(ctx context.Context, opts FetchOptions) context.Context {
+	return context.WithValue(ctx, fetchOptionsKey{}, opts)
+}
+
+// FetchOptionsFromContextOrDefaults returns the stored FetchOptions if
+// attached. If not found, it returns DefaultFetchOptions.
+func FetchOptionsFromContextOrDefaults(ctx context.Context) FetchOptions {
+	x, ok := ctx.Value(fetchOptionsKey{}).(FetchOptions)
+	if ok {
+		return x
+	}
+	return DefaultFetchOptions
+}
+
+// transport returns the http.RoundTripper for fetching with the options.
+// Since the TUF client methods don't take a context, the requests are bound
+// to ctx here, so that they are cancelled along with it.
+func (o FetchOptions) transport(ctx context.Context) http.RoundTripper {
+	base := http.DefaultTransport.(*http.Transport).Clone()
+	if o.Proxy != nil {
+		base.Proxy = http.ProxyURL(o.Proxy)
+	}
+	if o.RootCAs != nil {
+		base.TLSClientConfig = &tls.Config{
+			RootCAs:    o.RootCAs,
+			MinVersion: tls.VersionTLS12,
+		}
+	}
+	return &retryTransport{ctx: ctx, base: base, opts: o}
+}
+
+// retryTransport is an http.RoundTripper that binds the requests to a
+// context, times out each attempt and retries the failed ones.
+type retryTransport struct {
+	ctx  context.Context
+	base http.RoundTripper
+	opts FetchOptions
+}
+
+// RoundTrip implements http.RoundTripper.
+func (t *retryTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	delay := t.opts.RetryDelay
+	for attempt := 0; ; attempt++ {
+		res, err := t.roundTrip(req)
+		if attempt >= t.opts.Retries || !retryable(res, err) || t.ctx.Err() != nil {
+			return res, err
+		}
+		if res != nil {
+			res.Body.Close()
+		}
+		select {
+		case <-t.ctx.Done():
+			return nil, t.ctx.Err()
+		case <-time.After(delay):
+		}
+		delay *= 2
+	}
+}
+
+func (t *retryTransport) roundTrip(req *http.Request) (*http.Response, error) {
+	ctx, cancel := t.ctx, context.CancelFunc(func() {})
+	if t.opts.Timeout > 0 {
+		ctx, cancel = context.WithTimeout(ctx, t.opts.Timeout)
+	}
+	res, err := t.base.RoundTrip(req.Clone(ctx))
+	if err != nil {
+		cancel()
+		return nil, err
+	}
+	// The timeout covers reading the body too.
+	res.Body = &cancelOnClose{ReadCloser: res.Body, cancel: cancel}
+	return res, nil
+}
+
+// retryable returns true for the errors and the server side failures that
+// may succeed when retried.
+func retryable(res *http.Response, err error) bool {
+	if err != nil {
+		return true
+	}
+	return res.StatusCode == http.StatusTooManyRequests || res.StatusCode >= 500
+}
+
+// cancelOnClose cancels the context of a response when its body is closed.
+type cancelOnClose struct {
+	io.ReadCloser
+	cancel context.CancelFunc
+}
+
+func (c *cancelOnClose) Close() error {
+	defer c.cancel()
+	return c.ReadCloser.Close()
+}
@@ -0,0 +1,115 @@
+// Copyright 2024 The Sigstore Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package tuf
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestRetryTransport(t *testing.T) {
+	tests := []struct {
+		name string
+		// failures is how many requests fail before one succeeds.
+		failures int32
+		status   int
+		retries  int
+		timeout  time.Duration
+		wantErr  bool
+		want     int
+	}{{
+		name:     "succeeds after retries",
+		failures: 2,
+		status:   http.StatusServiceUnavailable,
+		retries:  2,
+		want:     http.StatusOK,
+	}, {
+		name:     "too many failures",
+		failures: 3,
+		status:   http.StatusTooManyRequests,
+		retries:  2,
+		want:     http.StatusTooManyRequests,
+	}, {
+		name:     "not found is not retried",
+		failures: 1,
+		status:   http.StatusNotFound,
+		retries:  2,
+		want:     http.StatusNotFound,
+	}, {
+		name:     "each attempt times out",
+		failures: 1,
+		retries:  0,
+		timeout:  10 * time.Millisecond,
+		wantErr:  true,
+	}}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			var requests atomic.Int32
+			ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				if requests.Add(1) > tc.failures {
+					io.WriteString(w, "ok")
+					return
+				}
+				if tc.status == 0 {
+					// Hang until the attempt times out.
+					<-r.Context().Done()
+					return
+				}
+				w.WriteHeader(tc.status)
+			}))
+			defer ts.Close()
+
+			opts := FetchOptions{Timeout: tc.timeout, Retries: tc.retries, RetryDelay: time.Millisecond}
+			client := &http.Client{Transport: opts.transport(context.Background())}
+			res, err := client.Get(ts.URL)
+			if tc.wantErr {
+				if err == nil {
+					res.Body.Close()
+					t.Fatal("Expected an error")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Get() = %v", err)
+			}
+			defer res.Body.Close()
+			if res.StatusCode != tc.want {
+				t.Errorf("StatusCode = %d, wanted %d", res.StatusCode, tc.want)
+			}
+		})
+	}
+}
+
+func TestRetryTransportContextCancelled(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		w.WriteHeader(http.StatusBadGateway)
+	}))
+	defer ts.Close()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	opts := FetchOptions{Retries: 100, RetryDelay: time.Hour}
+	client := &http.Client{Transport: opts.transport(ctx)}
+	time.AfterFunc(10*time.Millisecond, cancel)
+	_, err := client.Get(ts.URL)
+	if !errors.Is(err, context.Canceled) {
+		t.Errorf("Get() = %v, wanted %v", err, context.Canceled)
+	}
+}
@@ -0,0 +1,118 @@
+// Copyright 2024 The Sigstore Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package tuf
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"path"
+	"strings"
+
+	"github.com/google/go-containerregistry/pkg/name"
+	v1 "github.com/google/go-containerregistry/pkg/v1"
+	"github.com/google/go-containerregistry/pkg/v1/remote"
+	"github.com/theupdateframework/go-tuf/client"
+)
+
+// OCITitleAnnotation is the annotation of the layers of a TUF repository
+// stored as an OCI artifact that holds the path of the file they contain,
+// relative to the root of the repository (e.g. `timestamp.json` or
+// `targets/trusted_root.json`). This is what `oras push` sets.
+const OCITitleAnnotation = "org.opencontainers.image.title"
+
+// ClientFromOCI will construct a TUF client from a root, and a repository
+// stored as an OCI artifact, where each layer is a metadata or target file
+// of the repository (see OCITitleAnnotation). The options are used for
+// fetching the artifact, e.g. for the keychain, after those of the
+// FetchOptions in ctx. Will also Init/Update it.
+func ClientFromOCI(ctx context.Context, ref name.Reference, rootJSON []byte, targets string, opts ...remote.Option) (*Client, error) {
+	if targets == "" {
+		targets = "targets"
+	}
+	opts = append([]remote.Option{
+		remote.WithUserAgent(uaString),
+		remote.WithTransport(FetchOptionsFromContextOrDefaults(ctx).transport(ctx)),
+	}, opts...)
+	remoteStore, err := newOCIRemoteStore(ctx, ref, targets, opts...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create remote OCI store: %w", err)
+	}
+	local := client.MemoryLocalStore()
+	tufClient := newClient(local, remoteStore)
+	if err := tufClient.initAndUpdate(rootJSON); err != nil {
+		return nil, err
+	}
+	return tufClient, nil
+}
+
+// ociRemoteStore is a client.RemoteStore serving the files of a TUF
+// repository from the blobs of an OCI artifact.
+type ociRemoteStore struct {
+	// layers are the layers of the artifact, by file path.
+	layers  map[string]v1.Layer
+	targets string
+}
+
+func newOCIRemoteStore(ctx context.Context, ref name.Reference, targets string, opts ...remote.Option) (*ociRemoteStore, error) {
+	img, err := remote.Image(ref, append(opts, remote.WithContext(ctx))...)
+	if err != nil {
+		return nil, fmt.Errorf("fetching %s: %w", ref, err)
+	}
+	manifest, err := img.Manifest()
+	if err != nil {
+		return nil, fmt.Errorf("getting the manifest of %s: %w", ref, err)
+	}
+	layers := make(map[string]v1.Layer, len(manifest.Layers))
+	for _, desc := range manifest.Layers {
+		file, ok := desc.Annotations[OCITitleAnnotation]
+		if !ok {
+			continue
+		}
+		// The blobs are only fetched when read.
+		layer, err := img.LayerByDigest(desc.Digest)
+		if err != nil {
+			return nil, fmt.Errorf("getting the layer of %s: %w", file, err)
+		}
+		layers[path.Clean(file)] = layer
+	}
+	return &ociRemoteStore{layers: layers, targets: targets}, nil
+}
+
+// GetMeta implements client.RemoteStore.
+func (s *ociRemoteStore) GetMeta(name string) (io.ReadCloser, int64, error) {
+	return s.get(name)
+}
+
+// GetTarget implements client.RemoteStore.
+func (s *ociRemoteStore) GetTarget(target string) (io.ReadCloser, int64, error) {
+	return s.get(path.Join(s.targets, strings.TrimPrefix(target, "/")))
+}
+
+func (s *ociRemoteStore) get(file string) (io.ReadCloser, int64, error) {
+	layer, ok := s.layers[file]
+	if !ok {
+		return nil, 0, client.ErrNotFound{File: file}
+	}
+	size, err := layer.Size()
+	if err != nil {
+		return nil, 0, err
+	}
+	rc, err := layer.Compressed()
+	if err != nil {
+		return nil, 0, err
+	}
+	return rc, size, nil
+}
@@ -0,0 +1,118 @@
+// Copyright 2024 The Sigstore Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package tuf
+
+import (
+	"context"
+	"io"
+	"io/fs"
+	"log"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/google/go-containerregistry/pkg/name"
+	"github.com/google/go-containerregistry/pkg/registry"
+	"github.com/google/go-containerregistry/pkg/v1/empty"
+	"github.com/google/go-containerregistry/pkg/v1/mutate"
+	"github.com/google/go-containerregistry/pkg/v1/remote"
+	"github.com/google/go-containerregistry/pkg/v1/static"
+	"github.com/google/go-containerregistry/pkg/v1/types"
+)
+
+func TestClientFromOCI(t *testing.T) {
+	files := map[string][]byte{
+		"fulcio_v1.crt.pem": []byte(fulcioRootCert),
+		"ctfe.pub":          []byte(ctlogPublicKey),
+		"rekor.pub":         []byte(rekorPublicKey),
+	}
+	local, dir, err := createRepo(context.Background(), files)
+	if err != nil {
+		t.Fatalf("Failed to CreateRepo: %s", err)
+	}
+	defer os.RemoveAll(dir)
+	meta, err := local.GetMeta()
+	if err != nil {
+		t.Fatalf("getting meta: %v", err)
+	}
+	rootJSON, ok := meta["root.json"]
+	if !ok {
+		t.Fatalf("Getting root: %v", err)
+	}
+
+	// Push the repository as an artifact with a layer per file.
+	img := mutate.MediaType(empty.Image, types.OCIManifestSchema1)
+	serveDir := filepath.Join(dir, "repository")
+	err = filepath.WalkDir(serveDir, func(p string, d fs.DirEntry, err error) error {
+		if err != nil || d.IsDir() {
+			return err
+		}
+		data, err := os.ReadFile(p)
+		if err != nil {
+			return err
+		}
+		rel, err := filepath.Rel(serveDir, p)
+		if err != nil {
+			return err
+		}
+		img, err = mutate.Append(img, mutate.Addendum{
+			Layer:       static.NewLayer(data, "application/octet-stream"),
+			Annotations: map[string]string{OCITitleAnnotation: filepath.ToSlash(rel)},
+		})
+		return err
+	})
+	if err != nil {
+		t.Fatalf("Failed to build the artifact: %v", err)
+	}
+	ts := httptest.NewServer(registry.New(registry.Logger(log.New(io.Discard, "", 0))))
+	defer ts.Close()
+	u, err := url.Parse(ts.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ref, err := name.ParseReference(u.Host + "/tuf/repository:latest")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := remote.Write(ref, img); err != nil {
+		t.Fatalf("Failed to push the artifact: %v", err)
+	}
+
+	tufClient, err := ClientFromOCI(context.Background(), ref, rootJSON, "targets")
+	if err != nil {
+		t.Fatalf("Failed to get client from OCI: %v", err)
+	}
+	targets, err := tufClient.Targets()
+	if err != nil {
+		t.Errorf("failed to get Targets from tuf: %v", err)
+	}
+	if len(targets) != len(files) {
+		t.Errorf("Got %d targets from the TUF client, wanted %d", len(targets), len(files))
+	}
+
+	// An artifact without the TUF metadata fails.
+	emptyRef, err := name.ParseReference(u.Host + "/tuf/empty:latest")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := remote.Write(emptyRef, mutate.MediaType(empty.Image, types.OCIManifestSchema1)); err != nil {
+		t.Fatalf("Failed to push the artifact: %v", err)
+	}
+	if _, err := ClientFromOCI(context.Background(), emptyRef, rootJSON, "targets"); err == nil {
+		t.Error("ClientFromOCI() succeeded for an artifact without metadata")
+	}
+}
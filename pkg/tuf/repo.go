@@ -235,7 +235,7 @@ func UncompressMemFS(src io.Reader, stripPrefix string) (fs.FS, error) {
 // ClientFromSerializedMirror will construct a TUF client by
 // unzip/untar the repository and constructing an in-memory TUF
 // client for it. Will also Init/Update it.
-func ClientFromSerializedMirror(_ context.Context, repo, rootJSON []byte, targets, stripPrefix string) (*client.Client, error) {
+func ClientFromSerializedMirror(_ context.Context, repo, rootJSON []byte, targets, stripPrefix string) (*Client, error) {
 	// unzip/untar the repository.
 	tufFS, err := UncompressMemFS(bytes.NewReader(repo), stripPrefix)
 	if err != nil {
@@ -247,53 +247,31 @@ func ClientFromSerializedMirror(_ context.Context, repo, rootJSON []byte, target
 	}
 
 	local := client.MemoryLocalStore()
-	tufClient := client.NewClient(local, remote)
-
-	// TODO(vaikas): What should we do with above tufClient validation
-	// wise before just returning it?
-	err = tufClient.Init(rootJSON)
-	if err != nil {
-		return nil, fmt.Errorf("failed to init TUF client: %w", err)
-	}
-	targetFiles, err := tufClient.Update()
-	if err != nil {
-		return nil, fmt.Errorf("failed to update TUF client: %w", err)
-	}
-
-	if len(targetFiles) == 0 {
-		return nil, errors.New("there are no valid targetfiles in TUF repo")
+	tufClient := newClient(local, remote)
+	if err := tufClient.initAndUpdate(rootJSON); err != nil {
+		return nil, err
 	}
 	return tufClient, nil
 }
 
-// ClientFromRemote will construct a TUF client from a root, and mirror
-func ClientFromRemote(_ context.Context, mirror string, rootJSON []byte, targets string) (*client.Client, error) {
+// ClientFromRemote will construct a TUF client from a root, and mirror. The
+// fetches are bound to ctx, and configured by the FetchOptions in it.
+func ClientFromRemote(ctx context.Context, mirror string, rootJSON []byte, targets string) (*Client, error) {
 	opts := &client.HTTPRemoteOptions{
 		UserAgent:   uaString,
 		TargetsPath: targets,
-		Retries:     client.DefaultHTTPRetries,
+		// The retries are done by the transport.
 	}
-	// It's a bit unfortunate that the TUF client methods don't support context
-	// We could maybe plumb timeouts through from our context and set the
-	// timeouts based on that.
 	remote, err := client.HTTPRemoteStore(mirror, opts, &http.Client{
-		Timeout: 4 * time.Second})
+		Transport: FetchOptionsFromContextOrDefaults(ctx).transport(ctx),
+	})
 	if err != nil {
 		return nil, fmt.Errorf("failed to create remote HTTP store: %w", err)
 	}
 	local := client.MemoryLocalStore()
-	tufClient := client.NewClient(local, remote)
-	err = tufClient.Init(rootJSON)
-	if err != nil {
-		return nil, fmt.Errorf("failed to init TUF client: %w", err)
-	}
-	targetFiles, err := tufClient.Update()
-	if err != nil {
-		return nil, fmt.Errorf("failed to update TUF client: %w", err)
-	}
-
-	if len(targetFiles) == 0 {
-		return nil, errors.New("there are no valid targetfiles in TUF repo")
+	tufClient := newClient(local, remote)
+	if err := tufClient.initAndUpdate(rootJSON); err != nil {
+		return nil, err
 	}
 	return tufClient, nil
 }
@@ -304,8 +282,12 @@ var (
 	trustedRoot *root.TrustedRoot
 )
 
-// GetTrustedRoot returns the trusted root for the TUF repository.
+// GetTrustedRoot returns the trusted root for the TUF repository. It fails
+// while the TUF root is being initialized (see InitializePublicGood).
 func GetTrustedRoot(ctx context.Context) (*root.TrustedRoot, error) {
+	if err := PublicGoodError(); err != nil {
+		return nil, err
+	}
 	resyncPeriodDuration := FromContextOrDefaults(ctx)
 	now := time.Now().UTC()
 	// check if timestamp has never been set or if the current time
@@ -252,4 +252,13 @@ func TestClientFromRemoteMirror(t *testing.T) {
 	if len(targets) == 0 {
 		t.Errorf("Got no targets from the TUF client")
 	}
+	expirations, err := tufClient.MetadataExpirations()
+	if err != nil {
+		t.Fatalf("failed to get the metadata expirations: %v", err)
+	}
+	for _, role := range []string{"root", "targets", "snapshot", "timestamp"} {
+		if expires, ok := expirations[role]; !ok || !expires.After(time.Now()) {
+			t.Errorf("MetadataExpirations()[%s] = %v, wanted a time in the future", role, expires)
+		}
+	}
 }
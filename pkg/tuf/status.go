@@ -0,0 +1,167 @@
+//
+// Copyright 2024 The Sigstore Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package tuf
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/sigstore/sigstore/pkg/tuf"
+	"k8s.io/apimachinery/pkg/util/wait"
+	"knative.dev/pkg/logging"
+)
+
+// PublicGoodState is the state of the TUF root used by the authorities that
+// do not reference a TrustRoot (the public-good Sigstore instance, unless
+// the webhook was started with an alternate TUF mirror or root).
+type PublicGoodState string
+
+const (
+	// PublicGoodUnmanaged means that the TUF root is not initialized with
+	// InitializePublicGood, and is used as is.
+	PublicGoodUnmanaged PublicGoodState = ""
+	// PublicGoodInitializing means that the first initialization of the
+	// TUF root is in progress.
+	PublicGoodInitializing PublicGoodState = "Initializing"
+	// PublicGoodDegraded means that the initialization of the TUF root
+	// failed, and is being retried.
+	PublicGoodDegraded PublicGoodState = "Degraded"
+	// PublicGoodReady means that the TUF root is initialized.
+	PublicGoodReady PublicGoodState = "Ready"
+)
+
+// ErrPublicGoodUnavailable is returned (wrapped) by PublicGoodError while the
+// TUF root is being initialized.
+var ErrPublicGoodUnavailable = errors.New("the public-good TUF root is not available")
+
+var (
+	publicGoodMu    sync.RWMutex
+	publicGoodState PublicGoodState
+	publicGoodErr   error
+
+	// initializePublicGood is the function that initializes the TUF root,
+	// replaced in tests.
+	initializePublicGood = tuf.Initialize
+
+	// publicGoodBackoff is the backoff between the initialization attempts.
+	publicGoodBackoff = wait.Backoff{
+		Duration: time.Second,
+		Factor:   2,
+		Jitter:   0.1,
+		Steps:    32,
+		Cap:      5 * time.Minute,
+	}
+)
+
+func setPublicGood(state PublicGoodState, err error) {
+	publicGoodMu.Lock()
+	defer publicGoodMu.Unlock()
+	publicGoodState, publicGoodErr = state, err
+}
+
+// PublicGood returns the state of the TUF root, along with the error of the
+// last initialization attempt if it is Degraded.
+func PublicGood() (PublicGoodState, error) {
+	publicGoodMu.RLock()
+	defer publicGoodMu.RUnlock()
+	return publicGoodState, publicGoodErr
+}
+
+// PublicGoodError returns an error wrapping ErrPublicGoodUnavailable if the
+// TUF root is still being initialized, so that the authorities that depend
+// on it fail with a clear error in the meantime.
+func PublicGoodError() error {
+	switch state, err := PublicGood(); state {
+	case PublicGoodInitializing:
+		return fmt.Errorf("%w: initializing", ErrPublicGoodUnavailable)
+	case PublicGoodDegraded:
+		return fmt.Errorf("%w: %v", ErrPublicGoodUnavailable, err)
+	default:
+		return nil
+	}
+}
+
+// InitializePublicGood initializes the TUF root from the mirror (and the
+// root, if not empty) in the background, retrying with backoff until it
+// succeeds or the context is done. Until then, PublicGoodError returns an
+// error.
+func InitializePublicGood(ctx context.Context, mirror string, rootJSON []byte) {
+	setPublicGood(PublicGoodInitializing, nil)
+	go func() {
+		backoff := publicGoodBackoff
+		for {
+			err := initializePublicGood(ctx, mirror, rootJSON)
+			if err == nil {
+				setPublicGood(PublicGoodReady, nil)
+				logging.FromContext(ctx).Infof("Initialized TUF root from %s", mirror)
+				return
+			}
+			setPublicGood(PublicGoodDegraded, err)
+			delay := backoff.Step()
+			logging.FromContext(ctx).Warnf("Failed to initialize TUF root from %s, retrying in %v: %v", mirror, delay, err)
+			select {
+			case <-ctx.Done():
+				return
+			case <-time.After(delay):
+			}
+		}
+	}()
+}
+
+// StatusHandler reports the state of the TUF root, for monitoring (e.g. a
+// blackbox probe alerting on it). It responds with 503 Service Unavailable
+// while the TUF root is Initializing or Degraded, and 200 OK otherwise.
+func StatusHandler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		writeStatus(w)
+	})
+}
+
+// ReadinessHandler reports the readiness of the webhook, for its readiness
+// probe. It responds like StatusHandler while the TUF root is Initializing or
+// Degraded, and with the response of next (e.g. the probe of the webhook
+// server) otherwise. Since the TUF root stays Ready once it is initialized, a
+// webhook only becomes unready if it never initialized its TUF root, e.g. the
+// new replicas of a rollout while the mirror is unreachable.
+func ReadinessHandler(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if PublicGoodError() != nil {
+			writeStatus(w)
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+func writeStatus(w http.ResponseWriter) {
+	w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+	state, err := PublicGood()
+	switch state {
+	case PublicGoodInitializing, PublicGoodDegraded:
+		w.WriteHeader(http.StatusServiceUnavailable)
+	case PublicGoodUnmanaged:
+		state = "Unmanaged"
+	}
+	if err != nil {
+		fmt.Fprintf(w, "tuf: %s: %v\n", state, err)
+		return
+	}
+	fmt.Fprintf(w, "tuf: %s\n", state)
+}
@@ -0,0 +1,108 @@
+//
+// Copyright 2024 The Sigstore Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package tuf
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"k8s.io/apimachinery/pkg/util/wait"
+)
+
+func TestInitializePublicGood(t *testing.T) {
+	origInitialize, origBackoff := initializePublicGood, publicGoodBackoff
+	t.Cleanup(func() {
+		initializePublicGood, publicGoodBackoff = origInitialize, origBackoff
+		setPublicGood(PublicGoodUnmanaged, nil)
+	})
+
+	if err := PublicGoodError(); err != nil {
+		t.Fatalf("PublicGoodError() = %v before initialization, wanted nil", err)
+	}
+
+	attempts := make(chan struct{})
+	succeed := make(chan struct{})
+	initializePublicGood = func(context.Context, string, []byte) error {
+		attempts <- struct{}{}
+		select {
+		case <-succeed:
+			return nil
+		default:
+			return errors.New("expired root")
+		}
+	}
+	publicGoodBackoff = wait.Backoff{Duration: time.Millisecond, Factor: 1, Steps: 1}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	InitializePublicGood(ctx, "https://tuf.example.com", nil)
+	if state, _ := PublicGood(); state != PublicGoodInitializing {
+		t.Errorf("PublicGood() = %s, wanted %s", state, PublicGoodInitializing)
+	}
+
+	// Let the first attempt fail, and wait for the second one.
+	<-attempts
+	<-attempts
+	err := PublicGoodError()
+	if !errors.Is(err, ErrPublicGoodUnavailable) || !strings.Contains(err.Error(), "expired root") {
+		t.Errorf("PublicGoodError() = %v, wanted the last error wrapping ErrPublicGoodUnavailable", err)
+	}
+	rec := httptest.NewRecorder()
+	StatusHandler().ServeHTTP(rec, httptest.NewRequest("GET", "/tuf", nil))
+	if got := rec.Body.String(); rec.Code != http.StatusServiceUnavailable || !strings.HasPrefix(got, "tuf: Degraded: ") {
+		t.Errorf("StatusHandler() = %d %q, wanted 503 with the Degraded state", rec.Code, got)
+	}
+	webhookProbe := http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		w.WriteHeader(http.StatusNoContent)
+	})
+	rec = httptest.NewRecorder()
+	ReadinessHandler(webhookProbe).ServeHTTP(rec, httptest.NewRequest("GET", "/readyz", nil))
+	if got := rec.Body.String(); rec.Code != http.StatusServiceUnavailable || !strings.HasPrefix(got, "tuf: Degraded: ") {
+		t.Errorf("ReadinessHandler() = %d %q, wanted 503 with the Degraded state", rec.Code, got)
+	}
+
+	// The attempt in flight may fail or succeed, depending on when it sees
+	// this, so let through as many as it takes.
+	close(succeed)
+	if err := wait.PollUntilContextTimeout(ctx, time.Millisecond, 10*time.Second, true, func(context.Context) (bool, error) {
+		select {
+		case <-attempts:
+		default:
+		}
+		state, _ := PublicGood()
+		return state == PublicGoodReady, nil
+	}); err != nil {
+		t.Fatalf("the TUF root did not become ready: %v", err)
+	}
+	if err := PublicGoodError(); err != nil {
+		t.Errorf("PublicGoodError() = %v once ready, wanted nil", err)
+	}
+	rec = httptest.NewRecorder()
+	StatusHandler().ServeHTTP(rec, httptest.NewRequest("GET", "/tuf", nil))
+	if got := rec.Body.String(); rec.Code != http.StatusOK || got != "tuf: Ready\n" {
+		t.Errorf("StatusHandler() = %d %q, wanted 200 with the Ready state", rec.Code, got)
+	}
+	rec = httptest.NewRecorder()
+	ReadinessHandler(webhookProbe).ServeHTTP(rec, httptest.NewRequest("GET", "/readyz", nil))
+	if rec.Code != http.StatusNoContent {
+		t.Errorf("ReadinessHandler() = %d, wanted the response of the webhook probe", rec.Code)
+	}
+}
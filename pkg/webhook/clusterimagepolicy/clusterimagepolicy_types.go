@@ -20,6 +20,7 @@ import (
 	"encoding/json"
 	"fmt"
 
+	"github.com/google/go-containerregistry/pkg/authn"
 	"github.com/google/go-containerregistry/pkg/authn/k8schain"
 	"github.com/google/go-containerregistry/pkg/authn/kubernetes"
 	"github.com/google/go-containerregistry/pkg/name"
@@ -59,8 +60,15 @@ type ClusterImagePolicy struct {
 	// warn - allow but warn
 	// +optional
 	Mode string `json:"mode,omitempty"`
+	// InfraFailureMode controls whether infrastructure failures will be
+	// rejected (enforce, the default), or converted to Warnings (warn).
+	// +optional
+	InfraFailureMode string `json:"infraFailureMode,omitempty"`
 	// Match allows selecting resources based on their properties.
 	Match []v1alpha1.MatchResource `json:"match,omitempty"`
+	// AnnotateVerification controls whether the mutating webhook records
+	// the verification results as an annotation on the admitted resource.
+	AnnotateVerification bool `json:"annotateVerification,omitempty"`
 }
 
 type Authority struct {
@@ -119,6 +127,10 @@ type KeylessRef struct {
 	// Use the Certificate Chain from the referred TrustRoot.CertificateAuthorities and TrustRoot.CTLog
 	// +optional
 	TrustRootRef string `json:"trustRootRef,omitempty"`
+	// TrustRootRefs are more TrustRoots whose Certificate Chains and CTLogs
+	// are trusted.
+	// +optional
+	TrustRootRefs []string `json:"trustRootRefs,omitempty"`
 	// InsecureIgnoreSCT omits verifying if a certificate contains an embedded SCT
 	// +optional
 	InsecureIgnoreSCT *bool `json:"insecureIgnoreSCT,omitempty"`
@@ -167,6 +179,22 @@ type RFC3161Timestamp struct {
 	// Use the Certificate Chain from the referred TrustRoot.TimeStampAuthorities
 	// +optional
 	TrustRootRef string `json:"trustRootRef,omitempty"`
+	// TrustRootRefs are more TrustRoots whose TimeStampAuthorities are
+	// trusted.
+	// +optional
+	TrustRootRefs []string `json:"trustRootRefs,omitempty"`
+}
+
+// GetTrustRootRefs returns the TrustRoots referenced by TrustRootRef and
+// TrustRootRefs, without duplicates.
+func (k *KeylessRef) GetTrustRootRefs() []string {
+	return v1alpha1.MergeTrustRootRefs(k.TrustRootRef, k.TrustRootRefs)
+}
+
+// GetTrustRootRefs returns the TrustRoots referenced by TrustRootRef and
+// TrustRootRefs, without duplicates.
+func (r *RFC3161Timestamp) GetTrustRootRefs() []string {
+	return v1alpha1.MergeTrustRootRefs(r.TrustRootRef, r.TrustRootRefs)
 }
 
 // UnmarshalJSON populates the PublicKeys using Data because
@@ -239,8 +267,25 @@ func (a *Authority) UnmarshalJSON(data []byte) error {
 
 // SourceSignaturePullSecretsOpts creates the signaturePullSecrets remoteOpts
 // This is not stored in the Authority under RemoteOpts as the namespace can be different
-func (a *Authority) SourceSignaturePullSecretsOpts(ctx context.Context, namespace string) ([]ociremote.Option, error) {
-	var ret []ociremote.Option
+// The given remote options (e.g. the transport) are added to the ones
+// created. Like all the keychains of registryauth.NewK8sKeychain, the ones
+// of the signaturePullSecrets fall back to the default pull secrets.
+func (a *Authority) SourceSignaturePullSecretsOpts(ctx context.Context, namespace string, opts ...remote.Option) ([]ociremote.Option, error) {
+	kc, err := a.SourceSignaturePullSecretsKeychain(ctx, namespace)
+	if err != nil || kc == nil {
+		return nil, err
+	}
+	return []ociremote.Option{ociremote.WithRemoteOptions(append([]remote.Option{
+		remote.WithContext(ctx),
+		remote.WithAuthFromKeychain(kc),
+	}, opts...)...)}, nil
+}
+
+// SourceSignaturePullSecretsKeychain returns the keychain of the
+// signaturePullSecrets of the (last) source that has them, or nil if none
+// does.
+func (a *Authority) SourceSignaturePullSecretsKeychain(ctx context.Context, namespace string) (authn.Keychain, error) {
+	var kc authn.Keychain
 	for _, source := range a.Sources {
 		if len(source.SignaturePullSecrets) > 0 {
 			signaturePullSecrets := make([]string, 0, len(source.SignaturePullSecrets))
@@ -255,20 +300,16 @@ func (a *Authority) SourceSignaturePullSecretsOpts(ctx context.Context, namespac
 				ImagePullSecrets:   signaturePullSecrets,
 			}
 
-			kc, err := registryauth.NewK8sKeychain(ctx, kubeclient.Get(ctx), opt)
+			var err error
+			kc, err = registryauth.NewK8sKeychain(ctx, kubeclient.Get(ctx), opt)
 			if err != nil {
 				logging.FromContext(ctx).Errorf("failed creating keychain: %+v", err)
 				return nil, err
 			}
-
-			ret = append(ret, ociremote.WithRemoteOptions(
-				remote.WithContext(ctx),
-				remote.WithAuthFromKeychain(kc),
-			))
 		}
 	}
 
-	return ret, nil
+	return kc, nil
 }
 
 func ConvertClusterImagePolicyV1alpha1ToWebhook(in *v1alpha1.ClusterImagePolicy) *ClusterImagePolicy {
@@ -301,13 +342,15 @@ func ConvertClusterImagePolicyV1alpha1ToWebhook(in *v1alpha1.ClusterImagePolicy)
 		}
 	}
 	return &ClusterImagePolicy{
-		UID:             copyIn.UID,
-		ResourceVersion: copyIn.ResourceVersion,
-		Images:          copyIn.Spec.Images,
-		Authorities:     outAuthorities,
-		Policy:          cipAttestationPolicy,
-		Mode:            in.Spec.Mode,
-		Match:           in.Spec.Match,
+		UID:                  copyIn.UID,
+		ResourceVersion:      copyIn.ResourceVersion,
+		Images:               copyIn.Spec.Images,
+		Authorities:          outAuthorities,
+		Policy:               cipAttestationPolicy,
+		Mode:                 in.Spec.Mode,
+		InfraFailureMode:     in.Spec.InfraFailureMode,
+		Match:                in.Spec.Match,
+		AnnotateVerification: in.Spec.AnnotateVerification != nil && *in.Spec.AnnotateVerification,
 	}
 }
 
@@ -337,7 +380,8 @@ func convertRFC3161TimestampV1Alpha1ToWebhook(in *v1alpha1.RFC3161Timestamp) *RF
 	}
 
 	return &RFC3161Timestamp{
-		TrustRootRef: in.TrustRootRef,
+		TrustRootRef:  in.TrustRootRef,
+		TrustRootRefs: in.TrustRootRefs,
 	}
 }
 
@@ -401,6 +445,7 @@ func convertKeylessRefV1Alpha1ToWebhook(in *v1alpha1.KeylessRef) *KeylessRef {
 		Identities:        in.Identities,
 		CACert:            CACertRef,
 		TrustRootRef:      in.TrustRootRef,
+		TrustRootRefs:     in.TrustRootRefs,
 		InsecureIgnoreSCT: in.InsecureIgnoreSCT,
 	}
 }
@@ -0,0 +1,60 @@
+// Copyright 2022 The Sigstore Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package webhook
+
+import (
+	"context"
+	"strconv"
+
+	"go.opencensus.io/stats"
+	"go.opencensus.io/stats/view"
+	"go.opencensus.io/tag"
+	"knative.dev/pkg/metrics"
+)
+
+const policyVerificationsName = "policy_verifications"
+
+var (
+	policyVerificationsM = stats.Int64(
+		policyVerificationsName,
+		"The number of validations of an image against a policy, tagged with whether they were coalesced with an identical one in flight",
+		stats.UnitDimensionless)
+
+	// coalescedKey tells whether a validation shared the result of an
+	// identical one in flight, rather than running. The ratio of coalesced
+	// validations to all of them is the coalescing ratio.
+	coalescedKey = tag.MustNewKey("coalesced")
+)
+
+func init() {
+	if err := view.Register(&view.View{
+		Description: policyVerificationsM.Description(),
+		Measure:     policyVerificationsM,
+		Aggregation: view.Count(),
+		TagKeys:     []tag.Key{coalescedKey},
+	}); err != nil {
+		panic(err)
+	}
+}
+
+// recordPolicyVerification records a validation of an image against a
+// policy.
+func recordPolicyVerification(ctx context.Context, coalesced bool) {
+	ctx, err := tag.New(ctx, tag.Upsert(coalescedKey, strconv.FormatBool(coalesced)))
+	if err != nil {
+		return
+	}
+	metrics.Record(ctx, policyVerificationsM.M(1))
+}
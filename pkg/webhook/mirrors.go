@@ -0,0 +1,85 @@
+// Copyright 2022 The Sigstore Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package webhook
+
+import (
+	"context"
+	"strings"
+
+	"github.com/google/go-containerregistry/pkg/name"
+	ociremote "github.com/sigstore/cosign/v2/pkg/oci/remote"
+	policycontrollerconfig "github.com/sigstore/policy-controller/pkg/config"
+	webhookcip "github.com/sigstore/policy-controller/pkg/webhook/clusterimagepolicy"
+	"knative.dev/pkg/logging"
+)
+
+// mirrorRepository returns the repository from where to fetch the
+// signatures, attestations and config files of the images of repo, as per
+// PolicyControllerConfig.RegistryMirrors (the longest matching prefix
+// wins), or repo if no mirror applies.
+func mirrorRepository(ctx context.Context, repo name.Repository) name.Repository {
+	repoName := repo.Name()
+	mirrors := policycontrollerconfig.FromContextOrDefaults(ctx).RegistryMirrors
+	var match *policycontrollerconfig.RegistryMirror
+	for i, mirror := range mirrors {
+		if repoName != mirror.Prefix && !strings.HasPrefix(repoName, mirror.Prefix+"/") {
+			continue
+		}
+		if match == nil || len(mirror.Prefix) > len(match.Prefix) {
+			match = &mirrors[i]
+		}
+	}
+	if match == nil {
+		return repo
+	}
+	mirrored, err := name.NewRepository(match.Mirror + strings.TrimPrefix(repoName, match.Prefix))
+	if err != nil {
+		logging.FromContext(ctx).Warnf("Unable to mirror %s to %s: %v", repoName, match.Mirror, err)
+		return repo
+	}
+	return mirrored
+}
+
+// mirrorReference returns ref in the repository given by mirrorRepository.
+func mirrorReference(ctx context.Context, ref name.Reference) name.Reference {
+	repo := mirrorRepository(ctx, ref.Context())
+	if repo == ref.Context() {
+		return ref
+	}
+	if _, ok := ref.(name.Digest); ok {
+		return repo.Digest(ref.Identifier())
+	}
+	return repo.Tag(ref.Identifier())
+}
+
+// mirrorSourceOpts returns the options that fetch the signatures and
+// attestations of the authority from the mirrors of its sources, if any.
+// They override the ones of Authority.RemoteOpts.
+func mirrorSourceOpts(ctx context.Context, authority webhookcip.Authority) []ociremote.Option {
+	var opts []ociremote.Option
+	for _, source := range authority.Sources {
+		if source.OCI == "" {
+			continue
+		}
+		repo, err := name.NewRepository(source.OCI)
+		if err != nil {
+			continue
+		}
+		if mirrored := mirrorRepository(ctx, repo); mirrored != repo {
+			opts = append(opts, ociremote.WithTargetRepository(mirrored))
+		}
+	}
+	return opts
+}
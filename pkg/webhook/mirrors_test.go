@@ -0,0 +1,70 @@
+// Copyright 2022 The Sigstore Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package webhook
+
+import (
+	"context"
+	"testing"
+
+	"github.com/google/go-containerregistry/pkg/name"
+	"github.com/sigstore/policy-controller/pkg/apis/policy/v1alpha1"
+	policycontrollerconfig "github.com/sigstore/policy-controller/pkg/config"
+	webhookcip "github.com/sigstore/policy-controller/pkg/webhook/clusterimagepolicy"
+)
+
+func TestMirrorReference(t *testing.T) {
+	cfg, err := policycontrollerconfig.NewPolicyControllerConfigFromMap(map[string]string{
+		policycontrollerconfig.RegistryMirrorsKey: "docker.io=mirror.corp/docker.io,ghcr.io/sigstore=mirror.corp/sigstore,ghcr.io=ghcr.mirror.corp,mirror.corp/upstream=quay.io",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx := policycontrollerconfig.ToContext(context.Background(), cfg)
+
+	digest := "@sha256:5e3c0a8ab6b0c0fa5ee8f7ab1d3c0e3e4b0e0ca0f5bd5da6c9a2df8fb0f5b0b1"
+	tests := []struct {
+		ref  string
+		want string
+	}{
+		{"nginx" + digest, "mirror.corp/docker.io/library/nginx" + digest},
+		{"docker.io/team/app:v1", "mirror.corp/docker.io/team/app:v1"},
+		{"ghcr.io/sigstore/policy-controller" + digest, "mirror.corp/sigstore/policy-controller" + digest},
+		{"ghcr.io/sigstorefoo/app" + digest, "ghcr.mirror.corp/sigstorefoo/app" + digest},
+		{"mirror.corp/upstream/team/app" + digest, "quay.io/team/app" + digest},
+		{"gcr.io/team/app" + digest, "gcr.io/team/app" + digest},
+	}
+	for _, test := range tests {
+		ref, err := name.ParseReference(test.ref)
+		if err != nil {
+			t.Fatal(err)
+		}
+		want, err := name.ParseReference(test.want)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := mirrorReference(ctx, ref); got.String() != want.String() {
+			t.Errorf("mirrorReference(%s) = %s, wanted %s", test.ref, got, want)
+		}
+	}
+
+	authority := webhookcip.Authority{Sources: []v1alpha1.Source{{OCI: "ghcr.io/team/signatures"}}}
+	if got := len(mirrorSourceOpts(ctx, authority)); got != 1 {
+		t.Errorf("mirrorSourceOpts() = %d options, wanted 1", got)
+	}
+	authority = webhookcip.Authority{Sources: []v1alpha1.Source{{OCI: "gcr.io/team/signatures"}}}
+	if got := len(mirrorSourceOpts(ctx, authority)); got != 0 {
+		t.Errorf("mirrorSourceOpts() = %d options, wanted 0", got)
+	}
+}
@@ -0,0 +1,275 @@
+// Copyright 2022 The Sigstore Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package webhook
+
+import (
+	"context"
+	"encoding/json"
+	"sort"
+	"sync"
+
+	"github.com/google/go-containerregistry/pkg/authn/k8schain"
+	"github.com/google/go-containerregistry/pkg/name"
+	"github.com/sigstore/policy-controller/pkg/apis/config"
+	webhookcip "github.com/sigstore/policy-controller/pkg/webhook/clusterimagepolicy"
+	"github.com/sigstore/policy-controller/pkg/webhook/registryauth"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"knative.dev/pkg/apis"
+	kubeclient "knative.dev/pkg/client/injection/kube/client"
+	"knative.dev/pkg/logging"
+)
+
+// VerificationAnnotation is the annotation that the mutating webhook adds to
+// admitted resources when a matching ClusterImagePolicy has
+// annotateVerification set. Its value is the JSON encoding of a
+// map from container name to ContainerVerification. The images of image
+// volumes are keyed by the name of the volume, prefixed with "volume:".
+const VerificationAnnotation = "policy.sigstore.dev/verification"
+
+// ContainerVerification records how the image of a container (or of an image
+// volume) was verified.
+type ContainerVerification struct {
+	// Image is the image reference that was verified.
+	Image string `json:"image"`
+	// Policies has an entry for each ClusterImagePolicy that the image
+	// satisfied, keyed by its name.
+	Policies map[string]PolicyVerification `json:"policies"`
+}
+
+// PolicyVerification records the authorities that passed for a
+// ClusterImagePolicy.
+type PolicyVerification struct {
+	// Authorities has an entry for each authority that passed, keyed by
+	// its name.
+	Authorities map[string]AuthorityVerification `json:"authorities"`
+}
+
+// AuthorityVerification records who signed the image (or its attestations)
+// for an authority that passed.
+type AuthorityVerification struct {
+	// Static is true if the authority passed due to a static action.
+	Static bool `json:"static,omitempty"`
+	// Signers are the verified signers.
+	Signers []Signer `json:"signers,omitempty"`
+}
+
+// Signer identifies a verified signer, either with the Subject and Issuer of
+// its certificate, or with the KeyID of its public key.
+type Signer struct {
+	Subject string `json:"subject,omitempty"`
+	Issuer  string `json:"issuer,omitempty"`
+	KeyID   string `json:"keyId,omitempty"`
+}
+
+// verificationFromPolicyResults summarizes the PolicyResults of an image.
+func verificationFromPolicyResults(image string, results map[string]*PolicyResult) ContainerVerification {
+	ret := ContainerVerification{
+		Image:    image,
+		Policies: make(map[string]PolicyVerification, len(results)),
+	}
+	for cipName, result := range results {
+		pv := PolicyVerification{
+			Authorities: make(map[string]AuthorityVerification, len(result.AuthorityMatches)),
+		}
+		for authorityName, am := range result.AuthorityMatches {
+			pv.Authorities[authorityName] = AuthorityVerification{
+				Static:  am.Static,
+				Signers: signersFromAuthorityMatch(am),
+			}
+		}
+		ret.Policies[cipName] = pv
+	}
+	return ret
+}
+
+// signersFromAuthorityMatch returns the distinct signers of the signatures
+// and attestations of an AuthorityMatch, in a stable order.
+func signersFromAuthorityMatch(am AuthorityMatch) []Signer {
+	seen := make(map[Signer]struct{})
+	add := func(ps PolicySignature) {
+		s := Signer{Subject: ps.Subject, Issuer: ps.Issuer, KeyID: ps.KeyID}
+		if s != (Signer{}) {
+			seen[s] = struct{}{}
+		}
+	}
+	for _, sig := range am.Signatures {
+		add(sig)
+	}
+	for _, atts := range am.Attestations {
+		for _, att := range atts {
+			add(att.PolicySignature)
+		}
+	}
+	ret := make([]Signer, 0, len(seen))
+	for s := range seen {
+		ret = append(ret, s)
+	}
+	sort.Slice(ret, func(i, j int) bool {
+		if ret[i].Subject != ret[j].Subject {
+			return ret[i].Subject < ret[j].Subject
+		}
+		if ret[i].Issuer != ret[j].Issuer {
+			return ret[i].Issuer < ret[j].Issuer
+		}
+		return ret[i].KeyID < ret[j].KeyID
+	})
+	return ret
+}
+
+// annotatingPolicies returns the policies that have AnnotateVerification set.
+func annotatingPolicies(policies map[string]webhookcip.ClusterImagePolicy) map[string]webhookcip.ClusterImagePolicy {
+	ret := make(map[string]webhookcip.ClusterImagePolicy)
+	for k, cip := range policies {
+		if cip.AnnotateVerification {
+			ret[k] = cip
+		}
+	}
+	return ret
+}
+
+// This is attached to the context of the mutating webhook, so that the
+// verifications of an image against a policy that it runs for the same
+// admission (e.g. to stamp the pod template and to annotate the resource)
+// share a single result.
+type verificationResultsKey struct{}
+
+// verificationResults holds the results of the verifications of an
+// admission, keyed like the policyFlights.
+type verificationResults struct {
+	mu      sync.Mutex
+	results map[string]*CacheResult
+}
+
+// withVerificationResults returns a context whose verifications share their
+// results.
+func withVerificationResults(ctx context.Context) context.Context {
+	return context.WithValue(ctx, verificationResultsKey{}, &verificationResults{
+		results: make(map[string]*CacheResult),
+	})
+}
+
+// verificationResultsFromContext returns the verificationResults of ctx, or
+// nil if its verifications do not share their results.
+func verificationResultsFromContext(ctx context.Context) *verificationResults {
+	vr, _ := ctx.Value(verificationResultsKey{}).(*verificationResults)
+	return vr
+}
+
+func (vr *verificationResults) get(key string) (*CacheResult, bool) {
+	if vr == nil {
+		return nil, false
+	}
+	vr.mu.Lock()
+	defer vr.mu.Unlock()
+	r, ok := vr.results[key]
+	return r, ok
+}
+
+func (vr *verificationResults) set(key string, r *CacheResult) {
+	if vr == nil {
+		return
+	}
+	vr.mu.Lock()
+	defer vr.mu.Unlock()
+	vr.results[key] = r
+}
+
+// annotateVerification records in the VerificationAnnotation of the resource
+// how its (already resolved) images were verified against the matching
+// policies that have AnnotateVerification set. Images that are not digests
+// or that fail verification are not recorded, since it's up to the
+// validating webhook to reject them. Only the webhook writes the annotation,
+// so any value supplied with the resource is dropped.
+func (v *Validator) annotateVerification(ctx context.Context, meta *metav1.ObjectMeta, kind, apiVersion string, ps *corev1.PodSpec, opt k8schain.Options) {
+	if !apis.IsInCreate(ctx) && !apis.IsInUpdate(ctx) {
+		return
+	}
+	delete(meta.Annotations, VerificationAnnotation)
+
+	cfg := config.FromContext(ctx)
+	if cfg == nil || cfg.ImagePolicyConfig == nil || len(annotatingPolicies(cfg.ImagePolicyConfig.Policies)) == 0 {
+		return
+	}
+
+	kc, err := registryauth.NewK8sKeychain(ctx, kubeclient.Get(ctx), opt)
+	if err != nil {
+		logging.FromContext(ctx).Warnf("Unable to build k8schain: %v", err)
+		return
+	}
+	ctx = withKeychainInputs(ctx, opt)
+
+	type annotatedImage struct {
+		image  string
+		volume bool
+	}
+	images := make(map[string]annotatedImage, len(ps.InitContainers)+len(ps.Containers)+len(ps.EphemeralContainers)+len(ps.Volumes))
+	for _, c := range ps.InitContainers {
+		images[c.Name] = annotatedImage{image: c.Image}
+	}
+	for _, c := range ps.Containers {
+		images[c.Name] = annotatedImage{image: c.Image}
+	}
+	for _, c := range ps.EphemeralContainers {
+		images[c.Name] = annotatedImage{image: c.Image}
+	}
+	for _, vol := range ps.Volumes {
+		if vol.Image != nil {
+			images["volume:"+vol.Name] = annotatedImage{image: vol.Image.Reference, volume: true}
+		}
+	}
+
+	verifications := make(map[string]ContainerVerification, len(images))
+	for key, ai := range images {
+		ref, err := name.ParseReference(ai.image)
+		if err != nil {
+			continue
+		}
+		if _, ok := ref.(name.Digest); !ok {
+			continue
+		}
+		policies, err := cfg.ImagePolicyConfig.GetMatchingPolicies(ref.Name(), kind, apiVersion, meta.Labels)
+		if err != nil {
+			logging.FromContext(ctx).Debugf("Unable to get matching policies for %s: %v", ai.image, err)
+			continue
+		}
+		policies = annotatingPolicies(policies)
+		if len(policies) == 0 {
+			continue
+		}
+		imageCtx := ctx
+		if ai.volume {
+			imageCtx = withImageVolume(ctx)
+		}
+		results, _ := validatePolicies(imageCtx, opt.Namespace, ref, policies, kc)
+		if len(results) == 0 {
+			continue
+		}
+		verifications[key] = verificationFromPolicyResults(ai.image, results)
+	}
+
+	if len(verifications) == 0 {
+		return
+	}
+	b, err := json.Marshal(verifications)
+	if err != nil {
+		logging.FromContext(ctx).Warnf("Unable to marshal verification results: %v", err)
+		return
+	}
+	if meta.Annotations == nil {
+		meta.Annotations = make(map[string]string, 1)
+	}
+	meta.Annotations[VerificationAnnotation] = string(b)
+}
@@ -0,0 +1,412 @@
+//
+// Copyright 2024 The Sigstore Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package registryauth
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"net/url"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/google/go-containerregistry/pkg/authn"
+	"golang.org/x/sync/singleflight"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"knative.dev/pkg/logging"
+	"sigs.k8s.io/yaml"
+)
+
+/*
+This file implements the kubelet credential provider plugins, see
+https://kubernetes.io/docs/tasks/administer-cluster/kubelet-credential-provider/
+
+The types below mirror the ones of k8s.io/kubelet/config/v1 and
+k8s.io/kubelet/pkg/apis/credentialprovider/v1, so that the same
+CredentialProviderConfig and plugins as the ones of the nodes can be used.
+*/
+
+const (
+	credentialProviderConfigKind   = "CredentialProviderConfig"
+	credentialProviderRequestKind  = "CredentialProviderRequest"
+	credentialProviderResponseKind = "CredentialProviderResponse"
+
+	// credentialProviderExecTimeout bounds each invocation of a plugin.
+	credentialProviderExecTimeout = time.Minute
+)
+
+// Cache key types of a CredentialProviderResponse.
+const (
+	cacheKeyTypeImage    = "Image"
+	cacheKeyTypeRegistry = "Registry"
+	cacheKeyTypeGlobal   = "Global"
+)
+
+// CredentialProviderConfig is the configuration of the kubelet credential
+// provider plugins.
+type CredentialProviderConfig struct {
+	metav1.TypeMeta `json:",inline"`
+
+	Providers []CredentialProvider `json:"providers"`
+}
+
+// CredentialProvider is the configuration of a credential provider plugin.
+type CredentialProvider struct {
+	// Name is the name of the plugin binary, in the plugin directory.
+	Name string `json:"name"`
+	// MatchImages are the patterns of the images the plugin provides
+	// credentials for, e.g. "*.dkr.ecr.*.amazonaws.com" or
+	// "registry.example.com:5000/team".
+	MatchImages []string `json:"matchImages"`
+	// DefaultCacheDuration is how long the credentials are cached when the
+	// plugin does not say.
+	DefaultCacheDuration *metav1.Duration `json:"defaultCacheDuration"`
+	// APIVersion is the version of the CredentialProviderRequest and
+	// CredentialProviderResponse spoken by the plugin.
+	APIVersion string `json:"apiVersion"`
+	// Args are the arguments passed to the plugin.
+	Args []string `json:"args,omitempty"`
+	// Env are the environment variables set for the plugin, in addition to
+	// the ones of the webhook.
+	Env []ExecEnvVar `json:"env,omitempty"`
+}
+
+// ExecEnvVar is an environment variable set for a credential provider plugin.
+type ExecEnvVar struct {
+	Name  string `json:"name"`
+	Value string `json:"value"`
+}
+
+// credentialProviderRequest is sent to a plugin on its stdin.
+type credentialProviderRequest struct {
+	metav1.TypeMeta `json:",inline"`
+
+	Image string `json:"image"`
+}
+
+// credentialProviderResponse is read from the stdout of a plugin.
+type credentialProviderResponse struct {
+	metav1.TypeMeta `json:",inline"`
+
+	CacheKeyType  string                        `json:"cacheKeyType"`
+	CacheDuration *metav1.Duration              `json:"cacheDuration,omitempty"`
+	Auth          map[string]providerAuthConfig `json:"auth,omitempty"`
+}
+
+type providerAuthConfig struct {
+	Username string `json:"username"`
+	Password string `json:"password"`
+}
+
+// credentialProviderKeychain is an authn.Keychain that gets the credentials
+// of the images from the credential provider plugins that match them.
+type credentialProviderKeychain struct {
+	plugins []*credentialProviderPlugin
+}
+
+// credentialProvidersKeychain is the keychain added by NewK8sKeychain for
+// the plugins configured with LoadCredentialProviders, if any.
+var credentialProvidersKeychain authn.Keychain
+
+// LoadCredentialProviders configures the keychains returned by
+// NewK8sKeychain to get credentials from the kubelet credential provider
+// plugins of the CredentialProviderConfig at configPath, whose binaries are
+// in binDir.
+func LoadCredentialProviders(configPath, binDir string) error {
+	kc, err := NewCredentialProviderKeychain(configPath, binDir)
+	if err != nil {
+		return err
+	}
+	credentialProvidersKeychain = kc
+	return nil
+}
+
+// NewCredentialProviderKeychain returns a keychain that gets credentials from
+// the kubelet credential provider plugins of the CredentialProviderConfig at
+// configPath, whose binaries are in binDir. The credentials are cached as
+// long as the plugins say.
+func NewCredentialProviderKeychain(configPath, binDir string) (authn.Keychain, error) {
+	b, err := os.ReadFile(configPath)
+	if err != nil {
+		return nil, fmt.Errorf("reading credential provider config: %w", err)
+	}
+	// The fields of newer kubelets are ignored rather than rejected, so that
+	// the config of upgraded nodes still works. The ones used are validated
+	// below.
+	var config CredentialProviderConfig
+	if err := yaml.Unmarshal(b, &config); err != nil {
+		return nil, fmt.Errorf("parsing credential provider config %s: %w", configPath, err)
+	}
+	if config.Kind != credentialProviderConfigKind {
+		return nil, fmt.Errorf("credential provider config %s has kind %q, wanted %q", configPath, config.Kind, credentialProviderConfigKind)
+	}
+
+	kc := &credentialProviderKeychain{}
+	for i, provider := range config.Providers {
+		if err := validateCredentialProvider(provider); err != nil {
+			return nil, fmt.Errorf("invalid providers[%d]: %w", i, err)
+		}
+		path := filepath.Join(binDir, provider.Name)
+		if _, err := os.Stat(path); err != nil {
+			return nil, fmt.Errorf("invalid providers[%d]: %w", i, err)
+		}
+		kc.plugins = append(kc.plugins, &credentialProviderPlugin{
+			provider: provider,
+			path:     path,
+			cache:    make(map[string]*cachedCredentials),
+		})
+	}
+	return kc, nil
+}
+
+func validateCredentialProvider(provider CredentialProvider) error {
+	switch {
+	case provider.Name == "":
+		return errors.New("name is required")
+	case strings.ContainsAny(provider.Name, `/\`) || provider.Name == "." || provider.Name == "..":
+		return fmt.Errorf("name %q must not be a path", provider.Name)
+	case len(provider.MatchImages) == 0:
+		return errors.New("matchImages is required")
+	case provider.DefaultCacheDuration == nil:
+		return errors.New("defaultCacheDuration is required")
+	case provider.DefaultCacheDuration.Duration < 0:
+		return errors.New("defaultCacheDuration must not be negative")
+	case provider.APIVersion == "":
+		return errors.New("apiVersion is required")
+	}
+	for _, pattern := range provider.MatchImages {
+		if _, err := parseImageURL(pattern); err != nil {
+			return fmt.Errorf("invalid matchImages %q: %w", pattern, err)
+		}
+	}
+	return nil
+}
+
+// Resolve implements authn.Keychain.
+func (kc *credentialProviderKeychain) Resolve(target authn.Resource) (authn.Authenticator, error) {
+	return kc.ResolveContext(context.Background(), target)
+}
+
+// ResolveContext implements authn.ContextKeychain.
+func (kc *credentialProviderKeychain) ResolveContext(ctx context.Context, target authn.Resource) (authn.Authenticator, error) {
+	image := target.String()
+	for _, plugin := range kc.plugins {
+		if !plugin.matches(image) {
+			continue
+		}
+		auth, err := plugin.credentials(ctx, image)
+		if err != nil {
+			// Like the kubelet, carry on with the other credentials.
+			logging.FromContext(ctx).Warnf("Unable to get credentials for %s: %v", image, err)
+			continue
+		}
+		if auth != nil {
+			return authn.FromConfig(authn.AuthConfig{Username: auth.Username, Password: auth.Password}), nil
+		}
+	}
+	return authn.Anonymous, nil
+}
+
+// credentialProviderPlugin runs a plugin and caches the credentials it
+// returns.
+type credentialProviderPlugin struct {
+	provider CredentialProvider
+	path     string
+
+	flights singleflight.Group
+	m       sync.Mutex
+	cache   map[string]*cachedCredentials
+}
+
+// cachedCredentials are the credentials returned by a plugin for a cache
+// key, until they expire.
+type cachedCredentials struct {
+	auth    map[string]providerAuthConfig
+	expires time.Time
+}
+
+func (p *credentialProviderPlugin) matches(image string) bool {
+	for _, pattern := range p.provider.MatchImages {
+		if imageMatches(pattern, image) {
+			return true
+		}
+	}
+	return false
+}
+
+// credentials returns the credentials for the image, from the cache or by
+// running the plugin, or nil if the plugin has none.
+func (p *credentialProviderPlugin) credentials(ctx context.Context, image string) (*providerAuthConfig, error) {
+	registry := strings.SplitN(image, "/", 2)[0]
+	for _, key := range []string{cacheKeyTypeImage + ":" + image, cacheKeyTypeRegistry + ":" + registry, cacheKeyTypeGlobal} {
+		if auth, ok := p.cached(key); ok {
+			return matchingAuth(auth, image), nil
+		}
+	}
+
+	// Concurrent lookups of the same image share a single run of the
+	// plugin.
+	v, err, _ := p.flights.Do(image, func() (interface{}, error) {
+		return p.exec(ctx, image)
+	})
+	if err != nil {
+		return nil, err
+	}
+	resp := v.(*credentialProviderResponse)
+
+	duration := p.provider.DefaultCacheDuration.Duration
+	if resp.CacheDuration != nil {
+		duration = resp.CacheDuration.Duration
+	}
+	if duration > 0 {
+		var key string
+		switch resp.CacheKeyType {
+		case cacheKeyTypeImage:
+			key = cacheKeyTypeImage + ":" + image
+		case cacheKeyTypeRegistry:
+			key = cacheKeyTypeRegistry + ":" + registry
+		case cacheKeyTypeGlobal:
+			key = cacheKeyTypeGlobal
+		}
+		p.m.Lock()
+		p.cache[key] = &cachedCredentials{auth: resp.Auth, expires: time.Now().Add(duration)}
+		p.m.Unlock()
+	}
+	return matchingAuth(resp.Auth, image), nil
+}
+
+// cached returns the unexpired credentials cached for key, evicting them if
+// they expired.
+func (p *credentialProviderPlugin) cached(key string) (map[string]providerAuthConfig, bool) {
+	p.m.Lock()
+	defer p.m.Unlock()
+	c, ok := p.cache[key]
+	if !ok {
+		return nil, false
+	}
+	if time.Now().After(c.expires) {
+		delete(p.cache, key)
+		return nil, false
+	}
+	return c.auth, true
+}
+
+// exec runs the plugin for the image.
+func (p *credentialProviderPlugin) exec(ctx context.Context, image string) (*credentialProviderResponse, error) {
+	req, err := json.Marshal(credentialProviderRequest{
+		TypeMeta: metav1.TypeMeta{Kind: credentialProviderRequestKind, APIVersion: p.provider.APIVersion},
+		Image:    image,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, credentialProviderExecTimeout)
+	defer cancel()
+	cmd := exec.CommandContext(ctx, p.path, p.provider.Args...) //nolint: gosec
+	cmd.Env = os.Environ()
+	for _, env := range p.provider.Env {
+		cmd.Env = append(cmd.Env, env.Name+"="+env.Value)
+	}
+	var stdout, stderr bytes.Buffer
+	cmd.Stdin = bytes.NewReader(req)
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		return nil, fmt.Errorf("running credential provider %s for %s: %w: %s", p.provider.Name, image, err, strings.TrimSpace(stderr.String()))
+	}
+
+	var resp credentialProviderResponse
+	if err := json.Unmarshal(stdout.Bytes(), &resp); err != nil {
+		return nil, fmt.Errorf("parsing the response of credential provider %s: %w", p.provider.Name, err)
+	}
+	if resp.Kind != credentialProviderResponseKind || resp.APIVersion != p.provider.APIVersion {
+		return nil, fmt.Errorf("credential provider %s returned a %s %s, wanted a %s %s", p.provider.Name, resp.APIVersion, resp.Kind, p.provider.APIVersion, credentialProviderResponseKind)
+	}
+	switch resp.CacheKeyType {
+	case cacheKeyTypeImage, cacheKeyTypeRegistry, cacheKeyTypeGlobal:
+	default:
+		return nil, fmt.Errorf("credential provider %s returned an invalid cacheKeyType %q", p.provider.Name, resp.CacheKeyType)
+	}
+	return &resp, nil
+}
+
+// matchingAuth returns the credentials of auth whose key matches the image,
+// preferring the most specific key.
+func matchingAuth(auth map[string]providerAuthConfig, image string) *providerAuthConfig {
+	var best string
+	var ret *providerAuthConfig
+	for pattern, a := range auth {
+		if imageMatches(pattern, image) && (ret == nil || len(pattern) > len(best)) {
+			a := a
+			best, ret = pattern, &a
+		}
+	}
+	return ret
+}
+
+// imageMatches returns true if the image matches the pattern, as the kubelet
+// does for the matchImages of the providers and the auth of the responses:
+// each label of the host may be a glob, the ports must be equal and the path
+// of the pattern must be a prefix of the path of the image.
+func imageMatches(pattern, image string) bool {
+	p, err := parseImageURL(pattern)
+	if err != nil {
+		return false
+	}
+	i, err := parseImageURL(image)
+	if err != nil {
+		return false
+	}
+	pHost, pPort := splitHostPort(p.Host)
+	iHost, iPort := splitHostPort(i.Host)
+	if pPort != iPort {
+		return false
+	}
+	pLabels, iLabels := strings.Split(pHost, "."), strings.Split(iHost, ".")
+	if len(pLabels) != len(iLabels) {
+		return false
+	}
+	for n := range pLabels {
+		if ok, err := filepath.Match(pLabels[n], iLabels[n]); err != nil || !ok {
+			return false
+		}
+	}
+	return strings.HasPrefix(i.Path, p.Path)
+}
+
+// parseImageURL parses an image or a pattern, which have no scheme.
+func parseImageURL(s string) (*url.URL, error) {
+	if strings.Contains(s, "://") {
+		return nil, errors.New("must not have a scheme")
+	}
+	return url.Parse("https://" + s)
+}
+
+func splitHostPort(hostport string) (string, string) {
+	host, port, err := net.SplitHostPort(hostport)
+	if err != nil {
+		return hostport, ""
+	}
+	return host, port
+}
@@ -0,0 +1,208 @@
+//
+// Copyright 2024 The Sigstore Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package registryauth
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/google/go-containerregistry/pkg/authn"
+	"github.com/google/go-containerregistry/pkg/name"
+)
+
+func TestImageMatches(t *testing.T) {
+	tests := []struct {
+		pattern string
+		image   string
+		want    bool
+	}{
+		{"registry.example.com", "registry.example.com/team/app", true},
+		{"*.example.com", "registry.example.com/team/app", true},
+		{"*.example.com", "example.com/team/app", false},
+		{"*.dkr.ecr.*.amazonaws.com", "123456789012.dkr.ecr.us-east-1.amazonaws.com/app", true},
+		{"registry.example.com/team", "registry.example.com/team/app", true},
+		{"registry.example.com/team", "registry.example.com/other/app", false},
+		{"registry.example.com:5000", "registry.example.com:5000/app", true},
+		{"registry.example.com:5000", "registry.example.com/app", false},
+		{"registry.example.com", "registry.example.com:5000/app", false},
+	}
+	for _, test := range tests {
+		if got := imageMatches(test.pattern, test.image); got != test.want {
+			t.Errorf("imageMatches(%q, %q) = %t, wanted %t", test.pattern, test.image, got, test.want)
+		}
+	}
+}
+
+// writeCredentialProvider writes a plugin that appends a line to calls each
+// time it runs, and returns the response.
+func writeCredentialProvider(t *testing.T, dir, calls, response string) {
+	t.Helper()
+	script := fmt.Sprintf("#!/bin/sh\ncat > /dev/null\necho run >> %s\ncat <<'EOF'\n%s\nEOF\n", calls, response)
+	if err := os.WriteFile(filepath.Join(dir, "test-provider"), []byte(script), 0o755); err != nil { //nolint: gosec
+		t.Fatal(err)
+	}
+}
+
+func countCalls(t *testing.T, calls string) int {
+	t.Helper()
+	b, err := os.ReadFile(calls)
+	if os.IsNotExist(err) {
+		return 0
+	} else if err != nil {
+		t.Fatal(err)
+	}
+	return strings.Count(string(b), "run")
+}
+
+func TestCredentialProviderKeychain(t *testing.T) {
+	tests := []struct {
+		name      string
+		response  string
+		wantAuth  *authn.AuthConfig
+		wantCalls int
+	}{{
+		name: "cached per registry",
+		response: `{"kind": "CredentialProviderResponse", "apiVersion": "credentialprovider.kubelet.k8s.io/v1",
+"cacheKeyType": "Registry", "cacheDuration": "1h",
+"auth": {"registry.example.com": {"username": "user", "password": "pass"}}}`,
+		wantAuth:  &authn.AuthConfig{Username: "user", Password: "pass"},
+		wantCalls: 1,
+	}, {
+		name: "not cached",
+		response: `{"kind": "CredentialProviderResponse", "apiVersion": "credentialprovider.kubelet.k8s.io/v1",
+"cacheKeyType": "Image", "cacheDuration": "0s",
+"auth": {"registry.example.com/team": {"username": "user", "password": "pass"}}}`,
+		wantAuth:  &authn.AuthConfig{Username: "user", Password: "pass"},
+		wantCalls: 2,
+	}, {
+		name: "no matching auth",
+		response: `{"kind": "CredentialProviderResponse", "apiVersion": "credentialprovider.kubelet.k8s.io/v1",
+"cacheKeyType": "Global",
+"auth": {"other.example.com": {"username": "user", "password": "pass"}}}`,
+		wantCalls: 1,
+	}, {
+		name:      "wrong kind",
+		response:  `{"kind": "Something", "apiVersion": "credentialprovider.kubelet.k8s.io/v1", "cacheKeyType": "Global"}`,
+		wantCalls: 2,
+	}}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			dir := t.TempDir()
+			calls := filepath.Join(dir, "calls")
+			writeCredentialProvider(t, dir, calls, test.response)
+			config := filepath.Join(dir, "config.yaml")
+			// The fields of newer kubelets are ignored.
+			if err := os.WriteFile(config, []byte(`apiVersion: kubelet.config.k8s.io/v1
+kind: CredentialProviderConfig
+futureSetting: true
+providers:
+- name: test-provider
+  matchImages:
+  - "*.example.com"
+  defaultCacheDuration: 10m
+  apiVersion: credentialprovider.kubelet.k8s.io/v1
+  futureProviderSetting:
+    enabled: true
+`), 0o600); err != nil {
+				t.Fatal(err)
+			}
+
+			kc, err := NewCredentialProviderKeychain(config, dir)
+			if err != nil {
+				t.Fatalf("NewCredentialProviderKeychain() = %v", err)
+			}
+			for _, image := range []string{"registry.example.com/team/app", "registry.example.com/team/app", "gcr.io/team/app"} {
+				repo, err := name.NewRepository(image)
+				if err != nil {
+					t.Fatal(err)
+				}
+				auth, err := kc.Resolve(repo)
+				if err != nil {
+					t.Fatalf("Resolve(%s) = %v", image, err)
+				}
+				got, err := auth.Authorization()
+				if err != nil {
+					t.Fatal(err)
+				}
+				want := &authn.AuthConfig{}
+				if test.wantAuth != nil && strings.HasPrefix(image, "registry.example.com") {
+					want = test.wantAuth
+				}
+				if *got != *want {
+					t.Errorf("Resolve(%s) = %+v, wanted %+v", image, got, want)
+				}
+			}
+			if got := countCalls(t, calls); got != test.wantCalls {
+				t.Errorf("the plugin ran %d times, wanted %d", got, test.wantCalls)
+			}
+		})
+	}
+}
+
+func TestNewCredentialProviderKeychainErrors(t *testing.T) {
+	dir := t.TempDir()
+	writeCredentialProvider(t, dir, filepath.Join(dir, "calls"), "")
+	tests := map[string]string{
+		"wrong kind": `apiVersion: kubelet.config.k8s.io/v1
+kind: KubeletConfiguration
+`,
+		"missing plugin": `apiVersion: kubelet.config.k8s.io/v1
+kind: CredentialProviderConfig
+providers:
+- name: missing-provider
+  matchImages: ["*.example.com"]
+  defaultCacheDuration: 10m
+  apiVersion: credentialprovider.kubelet.k8s.io/v1
+`,
+		"path as name": `apiVersion: kubelet.config.k8s.io/v1
+kind: CredentialProviderConfig
+providers:
+- name: ../test-provider
+  matchImages: ["*.example.com"]
+  defaultCacheDuration: 10m
+  apiVersion: credentialprovider.kubelet.k8s.io/v1
+`,
+		"invalid defaultCacheDuration": `apiVersion: kubelet.config.k8s.io/v1
+kind: CredentialProviderConfig
+providers:
+- name: test-provider
+  matchImages: ["*.example.com"]
+  defaultCacheDuration: soon
+  apiVersion: credentialprovider.kubelet.k8s.io/v1
+`,
+		"missing defaultCacheDuration": `apiVersion: kubelet.config.k8s.io/v1
+kind: CredentialProviderConfig
+providers:
+- name: test-provider
+  matchImages: ["*.example.com"]
+  apiVersion: credentialprovider.kubelet.k8s.io/v1
+`,
+	}
+	for name, config := range tests {
+		t.Run(name, func(t *testing.T) {
+			path := filepath.Join(dir, "config.yaml")
+			if err := os.WriteFile(path, []byte(config), 0o600); err != nil {
+				t.Fatal(err)
+			}
+			if _, err := NewCredentialProviderKeychain(path, dir); err == nil {
+				t.Error("NewCredentialProviderKeychain() succeeded, wanted an error")
+			}
+		})
+	}
+}
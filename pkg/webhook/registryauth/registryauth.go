@@ -17,15 +17,25 @@ package registryauth
 
 import (
 	"context"
+	"fmt"
 	"io"
 
 	ecr "github.com/awslabs/amazon-ecr-credential-helper/ecr-login"
 	"github.com/google/go-containerregistry/pkg/authn"
 	"github.com/google/go-containerregistry/pkg/authn/k8schain"
 	kauth "github.com/google/go-containerregistry/pkg/authn/kubernetes"
+	"github.com/google/go-containerregistry/pkg/name"
 	"github.com/google/go-containerregistry/pkg/v1/google"
+	policycontrollerconfig "github.com/sigstore/policy-controller/pkg/config"
 	"github.com/sigstore/policy-controller/pkg/webhook/registryauth/azure"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	corev1informers "k8s.io/client-go/informers/core/v1"
 	"k8s.io/client-go/kubernetes"
+	corev1listers "k8s.io/client-go/listers/core/v1"
+	secretinformer "knative.dev/pkg/injection/clients/namespacedkube/informers/core/v1/secret"
+	"knative.dev/pkg/logging"
+	"knative.dev/pkg/system"
 )
 
 /*
@@ -44,17 +54,104 @@ with ACR and AKS clusters.
 */
 var amazonKeychain authn.Keychain = authn.NewKeychainFromHelper(ecr.NewECRHelper(ecr.WithLogger(io.Discard)))
 
+// NewK8sKeychain returns the keychain for the registry operations done on
+// behalf of a workload. It has the credentials of the pull secrets of opt,
+// then those of the DefaultPullSecrets of the PolicyControllerConfig in ctx,
+// of the credential provider plugins (see LoadCredentialProviders) and of the
+// environment.
 func NewK8sKeychain(ctx context.Context, client kubernetes.Interface, opt k8schain.Options) (authn.Keychain, error) {
 	k8s, err := kauth.New(ctx, client, opt)
 	if err != nil {
 		return nil, err
 	}
 
-	return authn.NewMultiKeychain(
-		k8s,
+	keychains := []authn.Keychain{k8s}
+	if secrets := policycontrollerconfig.FromContextOrDefaults(ctx).DefaultPullSecrets; len(secrets) > 0 {
+		defaults, err := newDefaultPullSecretsKeychain(ctx, client, secrets)
+		if err != nil {
+			return nil, err
+		}
+		keychains = append(keychains, defaults)
+	}
+	if credentialProvidersKeychain != nil {
+		keychains = append(keychains, credentialProvidersKeychain)
+	}
+	return authn.NewMultiKeychain(append(keychains,
 		authn.DefaultKeychain,
 		google.Keychain,
 		amazonKeychain,
 		authn.NewKeychainFromHelper(azure.NewACRHelper()),
-	), nil
+	)...), nil
+}
+
+// newDefaultPullSecretsKeychain returns a keychain with the credentials of the
+// default pull secrets, which live in the policy-controller namespace. Each
+// Secret scoped to a registry is only used for the images of that registry.
+// The Secrets are read from the informer of the policy-controller namespace
+// in ctx, if any, rather than from the API server.
+func newDefaultPullSecretsKeychain(ctx context.Context, client kubernetes.Interface, secrets []policycontrollerconfig.DefaultPullSecret) (authn.Keychain, error) {
+	var lister corev1listers.SecretNamespaceLister
+	if informer, ok := ctx.Value(secretinformer.Key{}).(corev1informers.SecretInformer); ok {
+		lister = informer.Lister().Secrets(system.Namespace())
+	}
+	keychains := make([]authn.Keychain, 0, len(secrets))
+	for _, s := range secrets {
+		var kc authn.Keychain
+		var err error
+		if lister != nil {
+			kc, err = pullSecretKeychain(ctx, lister, s.Name)
+		} else {
+			kc, err = kauth.New(ctx, client, k8schain.Options{
+				Namespace:          system.Namespace(),
+				ServiceAccountName: kauth.NoServiceAccount,
+				ImagePullSecrets:   []string{s.Name},
+			})
+		}
+		if err != nil {
+			return nil, err
+		}
+		if s.Registry != "" {
+			// Normalize the registry like the targets, e.g. docker.io.
+			registry, err := name.NewRegistry(s.Registry)
+			if err != nil {
+				return nil, fmt.Errorf("invalid registry %q for default pull secret %s: %w", s.Registry, s.Name, err)
+			}
+			kc = &registryScopedKeychain{registry: registry.RegistryStr(), keychain: kc}
+		}
+		keychains = append(keychains, kc)
+	}
+	return authn.NewMultiKeychain(keychains...), nil
+}
+
+// pullSecretKeychain returns a keychain with the credentials of the pull
+// secret from the lister. Like kauth.New, a missing Secret has no
+// credentials.
+func pullSecretKeychain(ctx context.Context, lister corev1listers.SecretNamespaceLister, secretName string) (authn.Keychain, error) {
+	secret, err := lister.Get(secretName)
+	if apierrors.IsNotFound(err) {
+		logging.FromContext(ctx).Warnf("Default pull secret %s/%s not found; ignoring", system.Namespace(), secretName)
+		return authn.NewMultiKeychain(), nil
+	} else if err != nil {
+		return nil, err
+	}
+	return kauth.NewFromPullSecrets(ctx, []corev1.Secret{*secret})
+}
+
+// registryScopedKeychain only resolves the resources of a registry.
+type registryScopedKeychain struct {
+	registry string
+	keychain authn.Keychain
+}
+
+// Resolve implements authn.Keychain.
+func (kc *registryScopedKeychain) Resolve(target authn.Resource) (authn.Authenticator, error) {
+	return kc.ResolveContext(context.Background(), target)
+}
+
+// ResolveContext implements authn.ContextKeychain.
+func (kc *registryScopedKeychain) ResolveContext(ctx context.Context, target authn.Resource) (authn.Authenticator, error) {
+	if target.RegistryStr() != kc.registry {
+		return authn.Anonymous, nil
+	}
+	return authn.Resolve(ctx, kc.keychain, target)
 }
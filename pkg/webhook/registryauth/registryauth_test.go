@@ -0,0 +1,177 @@
+//
+// Copyright 2024 The Sigstore Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package registryauth
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/google/go-containerregistry/pkg/authn"
+	"github.com/google/go-containerregistry/pkg/authn/k8schain"
+	kauth "github.com/google/go-containerregistry/pkg/authn/kubernetes"
+	"github.com/google/go-containerregistry/pkg/name"
+	policycontrollerconfig "github.com/sigstore/policy-controller/pkg/config"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/fake"
+	fakesecret "knative.dev/pkg/injection/clients/namespacedkube/informers/core/v1/secret/fake"
+	rtesting "knative.dev/pkg/reconciler/testing"
+	"knative.dev/pkg/system"
+
+	_ "knative.dev/pkg/system/testing"
+)
+
+func dockerConfigSecret(namespace, secretName, username string, registries ...string) *corev1.Secret {
+	auths := make([]string, 0, len(registries))
+	for _, registry := range registries {
+		auths = append(auths, `"`+registry+`":{"username":"`+username+`","password":"password"}`)
+	}
+	return &corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      secretName,
+			Namespace: namespace,
+		},
+		Type: corev1.SecretTypeDockerConfigJson,
+		Data: map[string][]byte{
+			corev1.DockerConfigJsonKey: []byte(`{"auths":{` + strings.Join(auths, ",") + `}}`),
+		},
+	}
+}
+
+func TestNewK8sKeychainDefaultPullSecrets(t *testing.T) {
+	client := fake.NewSimpleClientset(
+		dockerConfigSecret("default", "workload", "workload", "registry.example.com"),
+		dockerConfigSecret(system.Namespace(), "signatures", "signatures", "registry.example.com"),
+		dockerConfigSecret(system.Namespace(), "other-signatures", "other-signatures", "other.example.com"),
+		dockerConfigSecret(system.Namespace(), "scoped-signatures", "scoped-signatures", "scoped.example.com", "elsewhere.example.com"),
+	)
+	ctx := policycontrollerconfig.ToContext(context.Background(), &policycontrollerconfig.PolicyControllerConfig{
+		DefaultPullSecrets: []policycontrollerconfig.DefaultPullSecret{
+			{Name: "signatures"},
+			{Name: "other-signatures"},
+			{Registry: "scoped.example.com", Name: "scoped-signatures"},
+		},
+	})
+
+	tests := []struct {
+		name    string
+		secrets []string
+		image   string
+		want    string
+	}{{
+		name:    "workload secret first",
+		secrets: []string{"workload"},
+		image:   "registry.example.com/app",
+		want:    "workload",
+	}, {
+		name:  "default secret as fallback",
+		image: "registry.example.com/app",
+		want:  "signatures",
+	}, {
+		name:  "second default secret",
+		image: "other.example.com/app",
+		want:  "other-signatures",
+	}, {
+		name:  "scoped default secret",
+		image: "scoped.example.com/app",
+		want:  "scoped-signatures",
+	}, {
+		name:  "scoped default secret not used for other registries",
+		image: "elsewhere.example.com/app",
+	}}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			kc, err := NewK8sKeychain(ctx, client, k8schain.Options{
+				Namespace:        "default",
+				ImagePullSecrets: test.secrets,
+			})
+			if err != nil {
+				t.Fatalf("NewK8sKeychain() = %v", err)
+			}
+			repo, err := name.NewRepository(test.image)
+			if err != nil {
+				t.Fatal(err)
+			}
+			auth, err := authn.Resolve(ctx, kc, repo)
+			if err != nil {
+				t.Fatalf("Resolve() = %v", err)
+			}
+			got, err := auth.Authorization()
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got.Username != test.want {
+				t.Errorf("Resolve() = %q, wanted the credentials of %q", got.Username, test.want)
+			}
+		})
+	}
+}
+
+func TestNewK8sKeychainDefaultPullSecretsFromInformer(t *testing.T) {
+	// The default pull secrets are only in the informer, so reading them
+	// from the API server would not find them.
+	client := fake.NewSimpleClientset()
+	ctx, _ := rtesting.SetupFakeContext(t)
+	for _, secret := range []*corev1.Secret{
+		dockerConfigSecret(system.Namespace(), "signatures", "signatures", "registry.example.com"),
+		dockerConfigSecret(system.Namespace(), "scoped-signatures", "scoped-signatures", "scoped.example.com"),
+	} {
+		if err := fakesecret.Get(ctx).Informer().GetIndexer().Add(secret); err != nil {
+			t.Fatal(err)
+		}
+	}
+	ctx = policycontrollerconfig.ToContext(ctx, &policycontrollerconfig.PolicyControllerConfig{
+		DefaultPullSecrets: []policycontrollerconfig.DefaultPullSecret{
+			{Name: "signatures"},
+			{Name: "missing"},
+			{Registry: "scoped.example.com", Name: "scoped-signatures"},
+		},
+	})
+
+	kc, err := NewK8sKeychain(ctx, client, k8schain.Options{
+		Namespace:          "default",
+		ServiceAccountName: kauth.NoServiceAccount,
+	})
+	if err != nil {
+		t.Fatalf("NewK8sKeychain() = %v", err)
+	}
+	for image, want := range map[string]string{
+		"registry.example.com/app": "signatures",
+		"scoped.example.com/app":   "scoped-signatures",
+	} {
+		repo, err := name.NewRepository(image)
+		if err != nil {
+			t.Fatal(err)
+		}
+		auth, err := authn.Resolve(ctx, kc, repo)
+		if err != nil {
+			t.Fatalf("Resolve() = %v", err)
+		}
+		got, err := auth.Authorization()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got.Username != want {
+			t.Errorf("Resolve(%s) = %q, wanted the credentials of %q", image, got.Username, want)
+		}
+	}
+	for _, action := range client.Actions() {
+		if action.GetNamespace() == system.Namespace() {
+			t.Errorf("NewK8sKeychain() made the request %v, wanted it to use the informer", action)
+		}
+	}
+}
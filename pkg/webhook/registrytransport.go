@@ -0,0 +1,259 @@
+// Copyright 2022 The Sigstore Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package webhook
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"math"
+	"math/rand"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	policycontrollerconfig "github.com/sigstore/policy-controller/pkg/config"
+	"golang.org/x/time/rate"
+)
+
+const (
+	// registryRetryBaseDelay is the delay before the first retry of a
+	// registry request, doubled for each further retry.
+	registryRetryBaseDelay = 250 * time.Millisecond
+	// registryRetryMaxDelay caps the delay before a retry, including the one
+	// requested by the registry with Retry-After.
+	registryRetryMaxDelay = 10 * time.Second
+)
+
+// retryableStatusCodes are the statuses for which a registry read is
+// retried. They are also the ones that count as failures for the circuit
+// breaker.
+var retryableStatusCodes = map[int]bool{
+	http.StatusRequestTimeout:      true,
+	http.StatusTooManyRequests:     true,
+	http.StatusInternalServerError: true,
+	http.StatusBadGateway:          true,
+	http.StatusServiceUnavailable:  true,
+	http.StatusGatewayTimeout:      true,
+}
+
+// registryHosts holds the rate limiter and circuit breaker of each registry,
+// shared by all the registry operations of the webhook.
+var registryHosts = &registryHostStates{hosts: make(map[string]*registryHostState)}
+
+type registryHostStates struct {
+	m     sync.Mutex
+	hosts map[string]*registryHostState
+}
+
+// registryHostState is the state shared by the requests to a registry.
+type registryHostState struct {
+	limiter *rate.Limiter
+	breaker circuitBreaker
+}
+
+// get returns the state of the registry host, with its rate limiter
+// updated to the configured limits.
+func (s *registryHostStates) get(host string, cfg *policycontrollerconfig.PolicyControllerConfig) *registryHostState {
+	limit, burst := rate.Inf, 0
+	if cfg.RegistryQPS > 0 {
+		limit, burst = rate.Limit(cfg.RegistryQPS), cfg.RegistryBurst
+		if burst == 0 {
+			burst = int(math.Ceil(cfg.RegistryQPS))
+		}
+	}
+
+	s.m.Lock()
+	defer s.m.Unlock()
+	state, ok := s.hosts[host]
+	if !ok {
+		state = &registryHostState{limiter: rate.NewLimiter(limit, burst)}
+		s.hosts[host] = state
+	}
+	if state.limiter.Limit() != limit || state.limiter.Burst() != burst {
+		state.limiter.SetLimit(limit)
+		state.limiter.SetBurst(burst)
+	}
+	return state
+}
+
+// circuitOpenError is returned for the requests to a registry whose circuit
+// breaker is open.
+type circuitOpenError struct {
+	host     string
+	failures int
+	until    time.Time
+}
+
+func (e *circuitOpenError) Error() string {
+	return fmt.Sprintf("circuit breaker is open for registry %s after %d consecutive failures, retrying after %s", e.host, e.failures, e.until.Format(time.RFC3339))
+}
+
+// circuitBreaker stops the requests to a registry once too many of them
+// failed in a row, until a cooldown has elapsed. Then a single request is let
+// through, which closes the circuit if it succeeds or opens it again if it
+// fails.
+type circuitBreaker struct {
+	m         sync.Mutex
+	failures  int
+	openUntil time.Time
+	probing   bool
+}
+
+// allow returns an error if the request can not be made.
+func (cb *circuitBreaker) allow(host string, threshold int) error {
+	if threshold <= 0 {
+		return nil
+	}
+	cb.m.Lock()
+	defer cb.m.Unlock()
+	if cb.failures < threshold {
+		return nil
+	}
+	if time.Now().Before(cb.openUntil) || cb.probing {
+		return &circuitOpenError{host: host, failures: cb.failures, until: cb.openUntil}
+	}
+	cb.probing = true
+	return nil
+}
+
+// release lets another request probe the registry, when the one that was
+// allowed to do it was not made.
+func (cb *circuitBreaker) release() {
+	cb.m.Lock()
+	defer cb.m.Unlock()
+	cb.probing = false
+}
+
+// record records the outcome of a request.
+func (cb *circuitBreaker) record(failed bool, threshold int, cooldown time.Duration) {
+	cb.m.Lock()
+	defer cb.m.Unlock()
+	cb.probing = false
+	if !failed {
+		cb.failures = 0
+		return
+	}
+	cb.failures++
+	if threshold > 0 && cb.failures >= threshold {
+		cb.openUntil = time.Now().Add(cooldown)
+	}
+}
+
+// resilientTransport retries the registry reads that fail with a retryable
+// status or a network error, honoring Retry-After, and applies the rate
+// limit and circuit breaker of the registry to each attempt.
+type resilientTransport struct {
+	inner http.RoundTripper
+	cfg   *policycontrollerconfig.PolicyControllerConfig
+	hosts *registryHostStates
+}
+
+func (t *resilientTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	ctx := req.Context()
+	host := req.URL.Host
+	state := t.hosts.get(host, t.cfg)
+	// Only reads are retried, since the request body can not be replayed.
+	retries := t.cfg.RegistryMaxRetries
+	if req.Method != http.MethodGet && req.Method != http.MethodHead {
+		retries = 0
+	}
+
+	for attempt := 0; ; attempt++ {
+		if err := state.breaker.allow(host, t.cfg.RegistryCircuitBreakerThreshold); err != nil {
+			return nil, err
+		}
+		if err := state.limiter.Wait(ctx); err != nil {
+			state.breaker.release()
+			if ctx.Err() == nil {
+				// The limiter fails early when the wait would exceed the
+				// deadline of ctx.
+				err = fmt.Errorf("%w: %w", context.DeadlineExceeded, err)
+			}
+			return nil, fmt.Errorf("waiting for the rate limit of registry %s: %w", host, err)
+		}
+
+		resp, err := t.inner.RoundTrip(req)
+		if err != nil && ctx.Err() != nil {
+			// A request canceled by the caller says nothing about the
+			// registry.
+			state.breaker.release()
+			return resp, err
+		}
+		failed := err != nil || retryableStatusCodes[resp.StatusCode]
+		state.breaker.record(failed, t.cfg.RegistryCircuitBreakerThreshold, t.cfg.RegistryCircuitBreakerCooldown)
+		if !failed || attempt >= retries {
+			return resp, err
+		}
+
+		delay := retryDelay(attempt, resp)
+		if deadline, ok := ctx.Deadline(); ok && time.Until(deadline) < delay {
+			// There is no time left to retry.
+			return resp, err
+		}
+		if resp != nil {
+			// Drain the body so that the connection can be reused.
+			_, _ = io.Copy(io.Discard, io.LimitReader(resp.Body, 4096))
+			resp.Body.Close()
+		}
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case <-time.After(delay):
+		}
+	}
+}
+
+// retryDelay returns how long to wait before retrying a request after the
+// given attempt, which is what the registry asked for with Retry-After if
+// any, or an exponential backoff with jitter.
+func retryDelay(attempt int, resp *http.Response) time.Duration {
+	if resp != nil {
+		if d, ok := parseRetryAfter(resp.Header.Get("Retry-After")); ok {
+			return min(d, registryRetryMaxDelay)
+		}
+	}
+	d := registryRetryBaseDelay << attempt
+	d += time.Duration(rand.Int63n(int64(d) / 2)) //nolint: gosec
+	return min(d, registryRetryMaxDelay)
+}
+
+// parseRetryAfter parses the value of a Retry-After header, which is either a
+// number of seconds or an HTTP date.
+func parseRetryAfter(val string) (time.Duration, bool) {
+	if val == "" {
+		return 0, false
+	}
+	if secs, err := strconv.Atoi(val); err == nil && secs >= 0 {
+		return time.Duration(secs) * time.Second, true
+	}
+	if t, err := http.ParseTime(val); err == nil {
+		return max(time.Until(t), 0), true
+	}
+	return 0, false
+}
+
+// newRegistryTransport returns the http.RoundTripper for registry
+// operations, configured from the PolicyControllerConfig in ctx. The rate
+// limits and circuit breakers are shared by all the transports.
+func newRegistryTransport(ctx context.Context) http.RoundTripper {
+	cfg := policycontrollerconfig.FromContextOrDefaults(ctx)
+	var rt http.RoundTripper = &noncompliantRegistryTransport{}
+	if cfg.RegistryOperationTimeout > 0 {
+		rt = &timeoutTransport{inner: rt, timeout: cfg.RegistryOperationTimeout}
+	}
+	return &resilientTransport{inner: rt, cfg: cfg, hosts: registryHosts}
+}
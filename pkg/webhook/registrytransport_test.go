@@ -0,0 +1,195 @@
+// Copyright 2022 The Sigstore Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package webhook
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	policycontrollerconfig "github.com/sigstore/policy-controller/pkg/config"
+)
+
+// newTestResilientTransport returns a resilientTransport with its own
+// registry states, so that tests do not share circuit breakers.
+func newTestResilientTransport(cfg *policycontrollerconfig.PolicyControllerConfig) *resilientTransport {
+	return &resilientTransport{
+		inner: http.DefaultTransport,
+		cfg:   cfg,
+		hosts: &registryHostStates{hosts: make(map[string]*registryHostState)},
+	}
+}
+
+// flakyServer fails the first failures requests with status, asking to
+// retry right away, and counts the requests it gets.
+func flakyServer(failures int32, status int) (*httptest.Server, *atomic.Int32) {
+	var requests atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if requests.Add(1) <= failures {
+			w.Header().Set("Retry-After", "0")
+			w.WriteHeader(status)
+			return
+		}
+		w.WriteHeader(http.StatusOK)
+	}))
+	return server, &requests
+}
+
+func TestResilientTransportRetries(t *testing.T) {
+	for _, tc := range []struct {
+		name         string
+		method       string
+		failures     int32
+		status       int
+		maxRetries   int
+		wantStatus   int
+		wantRequests int32
+	}{{
+		name:         "retries a throttled read",
+		method:       http.MethodGet,
+		failures:     2,
+		status:       http.StatusTooManyRequests,
+		maxRetries:   2,
+		wantStatus:   http.StatusOK,
+		wantRequests: 3,
+	}, {
+		name:         "gives up after the max retries",
+		method:       http.MethodGet,
+		failures:     5,
+		status:       http.StatusServiceUnavailable,
+		maxRetries:   2,
+		wantStatus:   http.StatusServiceUnavailable,
+		wantRequests: 3,
+	}, {
+		name:         "does not retry other statuses",
+		method:       http.MethodGet,
+		failures:     1,
+		status:       http.StatusNotFound,
+		maxRetries:   2,
+		wantStatus:   http.StatusNotFound,
+		wantRequests: 1,
+	}, {
+		name:         "does not retry writes",
+		method:       http.MethodPost,
+		failures:     1,
+		status:       http.StatusServiceUnavailable,
+		maxRetries:   2,
+		wantStatus:   http.StatusServiceUnavailable,
+		wantRequests: 1,
+	}} {
+		t.Run(tc.name, func(t *testing.T) {
+			server, requests := flakyServer(tc.failures, tc.status)
+			defer server.Close()
+			rt := newTestResilientTransport(&policycontrollerconfig.PolicyControllerConfig{RegistryMaxRetries: tc.maxRetries})
+
+			req, _ := http.NewRequest(tc.method, server.URL, nil)
+			resp, err := rt.RoundTrip(req)
+			if err != nil {
+				t.Fatalf("RoundTrip() = %v", err)
+			}
+			resp.Body.Close()
+			if resp.StatusCode != tc.wantStatus {
+				t.Errorf("status = %d, wanted %d", resp.StatusCode, tc.wantStatus)
+			}
+			if got := requests.Load(); got != tc.wantRequests {
+				t.Errorf("requests = %d, wanted %d", got, tc.wantRequests)
+			}
+		})
+	}
+}
+
+func TestResilientTransportCircuitBreaker(t *testing.T) {
+	server, requests := flakyServer(2, http.StatusBadGateway)
+	defer server.Close()
+	rt := newTestResilientTransport(&policycontrollerconfig.PolicyControllerConfig{
+		RegistryCircuitBreakerThreshold: 2,
+		RegistryCircuitBreakerCooldown:  100 * time.Millisecond,
+	})
+	get := func() (*http.Response, error) {
+		req, _ := http.NewRequest(http.MethodGet, server.URL, nil)
+		resp, err := rt.RoundTrip(req)
+		if resp != nil {
+			resp.Body.Close()
+		}
+		return resp, err
+	}
+
+	// Two failures open the circuit.
+	for i := 0; i < 2; i++ {
+		if resp, err := get(); err != nil || resp.StatusCode != http.StatusBadGateway {
+			t.Fatalf("get() = %v, %v, wanted %d", resp, err, http.StatusBadGateway)
+		}
+	}
+	_, err := get()
+	var circuitErr *circuitOpenError
+	if !errors.As(err, &circuitErr) {
+		t.Fatalf("get() = %v, wanted a circuitOpenError", err)
+	}
+	if classifyError(err) != ReasonInfrastructure {
+		t.Errorf("classifyError(%v) = %s, wanted %s", err, classifyError(err), ReasonInfrastructure)
+	}
+	if got := requests.Load(); got != 2 {
+		t.Errorf("requests = %d, wanted 2", got)
+	}
+
+	// After the cooldown, a successful probe closes it.
+	time.Sleep(150 * time.Millisecond)
+	for i := 0; i < 2; i++ {
+		if resp, err := get(); err != nil || resp.StatusCode != http.StatusOK {
+			t.Fatalf("get() = %v, %v, wanted %d", resp, err, http.StatusOK)
+		}
+	}
+}
+
+func TestResilientTransportRateLimit(t *testing.T) {
+	server, _ := flakyServer(0, http.StatusOK)
+	defer server.Close()
+	rt := newTestResilientTransport(&policycontrollerconfig.PolicyControllerConfig{RegistryQPS: 10, RegistryBurst: 1})
+
+	start := time.Now()
+	for i := 0; i < 3; i++ {
+		req, _ := http.NewRequest(http.MethodGet, server.URL, nil)
+		resp, err := rt.RoundTrip(req)
+		if err != nil {
+			t.Fatalf("RoundTrip() = %v", err)
+		}
+		resp.Body.Close()
+	}
+	if elapsed := time.Since(start); elapsed < 150*time.Millisecond {
+		t.Errorf("3 requests at 10 QPS took %s, wanted at least 150ms", elapsed)
+	}
+}
+
+func TestParseRetryAfter(t *testing.T) {
+	for _, tc := range []struct {
+		val    string
+		want   time.Duration
+		wantOK bool
+	}{
+		{val: "", wantOK: false},
+		{val: "3", want: 3 * time.Second, wantOK: true},
+		{val: "-1", wantOK: false},
+		{val: "soon", wantOK: false},
+		{val: "Wed, 21 Oct 2015 07:28:00 GMT", want: 0, wantOK: true},
+	} {
+		got, ok := parseRetryAfter(tc.val)
+		if got != tc.want || ok != tc.wantOK {
+			t.Errorf("parseRetryAfter(%q) = %s, %t, wanted %s, %t", tc.val, got, ok, tc.want, tc.wantOK)
+		}
+	}
+}
@@ -0,0 +1,198 @@
+// Copyright 2022 The Sigstore Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package webhook
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"reflect"
+	"slices"
+	"time"
+
+	"github.com/google/go-containerregistry/pkg/authn"
+	"github.com/google/go-containerregistry/pkg/v1/remote"
+	ociremote "github.com/sigstore/cosign/v2/pkg/oci/remote"
+	"knative.dev/pkg/apis"
+)
+
+// admissionResponseReserve is the part of the deadline of each stage that is
+// not handed to the stages it encloses, so that it still has time to gather
+// their errors and respond before its own deadline.
+const admissionResponseReserve = 500 * time.Millisecond
+
+// stageTimeoutError is the cause of the cancellation of the context of a
+// stage of the validation (the admission request, a policy, an authority or a
+// registry operation) that ran out of time. It unwraps to
+// context.DeadlineExceeded.
+type stageTimeoutError struct {
+	stage   string
+	timeout time.Duration
+}
+
+func (e *stageTimeoutError) Error() string {
+	return fmt.Sprintf("%s timed out after %s", e.stage, e.timeout)
+}
+
+func (e *stageTimeoutError) Unwrap() error {
+	return context.DeadlineExceeded
+}
+
+// withStageTimeout returns a context for a stage of the validation that is
+// canceled after timeout, or when the deadline of ctx (less
+// admissionResponseReserve) is reached, whichever comes first. A zero timeout
+// only carves the stage out of the deadline of ctx, if any.
+func withStageTimeout(ctx context.Context, stage string, timeout time.Duration) (context.Context, context.CancelFunc) {
+	if deadline, ok := ctx.Deadline(); ok {
+		remaining := time.Until(deadline) - admissionResponseReserve
+		if remaining > 0 && (timeout <= 0 || remaining < timeout) {
+			timeout = remaining
+		}
+	}
+	if timeout <= 0 {
+		return context.WithCancel(ctx)
+	}
+	return context.WithTimeoutCause(ctx, timeout, &stageTimeoutError{stage: stage, timeout: timeout})
+}
+
+// withAdmissionTimeout bounds ctx by the timeout that the API server sets on
+// the admission request, unless it already has a deadline.
+func withAdmissionTimeout(ctx context.Context) (context.Context, context.CancelFunc) {
+	if _, ok := ctx.Deadline(); !ok {
+		if req := apis.GetHTTPRequest(ctx); req != nil {
+			if timeout, err := time.ParseDuration(req.URL.Query().Get("timeout")); err == nil && timeout > admissionResponseReserve {
+				return withStageTimeout(ctx, "admission request", timeout-admissionResponseReserve)
+			}
+		}
+	}
+	return context.WithCancel(ctx)
+}
+
+// stageError adds the stage that timed out to err, if ctx was canceled
+// because of a stage timeout and err does not name one already.
+func stageError(ctx context.Context, err error) error {
+	if err == nil || ctx.Err() == nil {
+		return err
+	}
+	var ste *stageTimeoutError
+	if errors.As(err, &ste) {
+		return err
+	}
+	if cause := context.Cause(ctx); errors.As(cause, &ste) {
+		return fmt.Errorf("%w: %w", ste, err)
+	}
+	return err
+}
+
+// canceledError returns the error for a stage that did not complete because
+// ctx was canceled, naming the stage that timed out if any.
+func canceledError(ctx context.Context) error {
+	var ste *stageTimeoutError
+	if cause := context.Cause(ctx); errors.As(cause, &ste) {
+		return fmt.Errorf("%w before validation completed", ste)
+	}
+	return fmt.Errorf("%w before validation completed", ctx.Err())
+}
+
+// canceledMessage is like canceledError, for the stages that report plain
+// messages.
+func canceledMessage(ctx context.Context) string {
+	var ste *stageTimeoutError
+	if cause := context.Cause(ctx); errors.As(cause, &ste) {
+		return ste.Error() + " before validation completed"
+	}
+	return "context was canceled before validation completed"
+}
+
+// This is attached to the contexts of the validations by WithRemoteOptions.
+type remoteOptionsKey struct{}
+
+// WithRemoteOptions returns a context whose validations make their registry
+// operations with opts, in addition to the context, keychain and transport
+// of the stage that makes them.
+func WithRemoteOptions(ctx context.Context, opts ...remote.Option) context.Context {
+	return context.WithValue(ctx, remoteOptionsKey{}, slices.Clip(slices.Concat(GetRemoteOptions(ctx), opts)))
+}
+
+// GetRemoteOptions returns the remote.Options of the validations with ctx
+// (see WithRemoteOptions).
+func GetRemoteOptions(ctx context.Context) []remote.Option {
+	opts, _ := ctx.Value(remoteOptionsKey{}).([]remote.Option)
+	return opts
+}
+
+// RemoteOptions returns the remote.Options set by opts (e.g. with
+// ociremote.WithRemoteOptions), so that they can be merged with others rather
+// than be replaced by them. ociremote does not export its options, so they
+// are read by applying opts to an empty set of them.
+func RemoteOptions(opts ...ociremote.Option) []remote.Option {
+	if len(opts) == 0 {
+		return nil
+	}
+	o := reflect.New(reflect.TypeOf(ociremote.Option(nil)).In(0).Elem())
+	for _, opt := range opts {
+		if opt != nil {
+			reflect.ValueOf(opt).Call([]reflect.Value{o})
+		}
+	}
+	ropts, _ := o.Elem().FieldByName("ROpt").Interface().([]remote.Option)
+	return ropts
+}
+
+// registryRemoteOptions returns the remote.Options for the registry
+// operations done on behalf of a stage with ctx: those of WithRemoteOptions,
+// followed by the context, keychain and transport of the stage.
+func registryRemoteOptions(ctx context.Context, kc authn.Keychain) []remote.Option {
+	return append(GetRemoteOptions(ctx),
+		remote.WithContext(ctx),
+		remote.WithAuthFromKeychain(kc),
+		remote.WithTransport(newRegistryTransport(ctx)),
+		// The retries on statuses are done by newRegistryTransport.
+		remote.WithRetryStatusCodes(),
+	)
+}
+
+// timeoutTransport bounds each registry request, including reading its
+// response body, by a timeout.
+type timeoutTransport struct {
+	inner   http.RoundTripper
+	timeout time.Duration
+}
+
+func (t *timeoutTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	ctx, cancel := withStageTimeout(req.Context(), fmt.Sprintf("registry operation %s %s", req.Method, req.URL.Redacted()), t.timeout)
+	resp, err := t.inner.RoundTrip(req.WithContext(ctx))
+	if err != nil {
+		err = stageError(ctx, err)
+		cancel()
+		return nil, err
+	}
+	resp.Body = &cancelOnClose{ReadCloser: resp.Body, cancel: cancel}
+	return resp, nil
+}
+
+// cancelOnClose cancels the context of a request once its response body is
+// closed.
+type cancelOnClose struct {
+	io.ReadCloser
+	cancel context.CancelFunc
+}
+
+func (c *cancelOnClose) Close() error {
+	defer c.cancel()
+	return c.ReadCloser.Close()
+}
@@ -0,0 +1,192 @@
+// Copyright 2022 The Sigstore Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package webhook
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"sort"
+	"strings"
+
+	"github.com/google/go-containerregistry/pkg/authn/k8schain"
+	"github.com/google/go-containerregistry/pkg/name"
+	"github.com/sigstore/policy-controller/pkg/apis/config"
+	policycontrollerconfig "github.com/sigstore/policy-controller/pkg/config"
+	webhookcip "github.com/sigstore/policy-controller/pkg/webhook/clusterimagepolicy"
+	corev1 "k8s.io/api/core/v1"
+	"knative.dev/pkg/apis"
+	"knative.dev/pkg/logging"
+)
+
+// VerificationStampAnnotation is the annotation that the mutating webhook
+// adds to the pod template of a resource that passed verification, when the
+// owner trust chain is enabled (see
+// PolicyControllerConfig.OwnerTrustChainSecret). Its value is an HMAC over
+// the namespace, the image digests of the pod template and the digests of
+// the policies that match them for a Pod created from the template (see
+// policyDigests), so that such Pods (e.g. those of a Deployment) are admitted
+// without being verified again, as long as the policies and their TrustRoots
+// did not change.
+const VerificationStampAnnotation = "policy.sigstore.dev/verification-stamp"
+
+const (
+	// ownerTrustChainSecretKey is the key of the Secret data that holds the
+	// HMAC key.
+	ownerTrustChainSecretKey = "key"
+
+	// The stamp is computed for the Pods created from the pod template,
+	// whatever the kind of the resource that was verified.
+	stampKind       = "Pod"
+	stampAPIVersion = "v1"
+)
+
+// ownerTrustChainKey returns the HMAC key of the owner trust chain, or nil if
+// it is disabled or the key can not be read.
+func (v *Validator) ownerTrustChainKey(ctx context.Context) []byte {
+	secretName := policycontrollerconfig.FromContextOrDefaults(ctx).OwnerTrustChainSecret
+	if secretName == "" {
+		return nil
+	}
+	secret, err := v.secretLister.Get(secretName)
+	if err != nil {
+		logging.FromContext(ctx).Warnf("Unable to get the owner trust chain secret %s: %v", secretName, err)
+		return nil
+	}
+	key := secret.Data[ownerTrustChainSecretKey]
+	if len(key) == 0 {
+		logging.FromContext(ctx).Warnf("The owner trust chain secret %s has no %q", secretName, ownerTrustChainSecretKey)
+		return nil
+	}
+	return key
+}
+
+// stampPayload returns what the VerificationStampAnnotation is computed over:
+// the namespace and each image of the pod spec, along with the digests of
+// the policies that match it for a Pod with the labels. It returns false if
+// the pod spec can not be stamped, because one of its images is not a digest
+// or matches no policy, or because one of the policies depends on the
+// resource itself.
+func stampPayload(ctx context.Context, namespace string, labels map[string]string, ps *corev1.PodSpec) (string, bool) {
+	cfg := config.FromContext(ctx)
+	if cfg == nil || cfg.ImagePolicyConfig == nil {
+		return "", false
+	}
+
+	images := make(map[string]struct{})
+	podSpecImages(ps, func(image string, _ bool) {
+		images[image] = struct{}{}
+	})
+	if len(images) == 0 {
+		return "", false
+	}
+
+	lines := make([]string, 0, len(images))
+	for image := range images {
+		ref, err := name.ParseReference(image)
+		if err != nil {
+			return "", false
+		}
+		if _, ok := ref.(name.Digest); !ok {
+			return "", false
+		}
+		policies, err := cfg.ImagePolicyConfig.GetMatchingPolicies(ref.Name(), stampKind, stampAPIVersion, labels)
+		if err != nil || len(policies) == 0 {
+			return "", false
+		}
+		for _, cip := range policies {
+			if dependsOnResource(cip) {
+				return "", false
+			}
+		}
+		digests, err := policyDigests(ctx, policies)
+		if err != nil {
+			logging.FromContext(ctx).Warnf("Unable to digest the policies of %s: %v", image, err)
+			return "", false
+		}
+		lines = append(lines, ref.Name()+"="+strings.Join(digests, ","))
+	}
+	sort.Strings(lines)
+	return "namespace=" + namespace + "\n" + strings.Join(lines, "\n"), true
+}
+
+// dependsOnResource returns true if the CIP level policy evaluates the
+// resource being admitted, so that its result for a parent does not carry
+// over to its children.
+func dependsOnResource(cip webhookcip.ClusterImagePolicy) bool {
+	if cip.Policy == nil {
+		return false
+	}
+	isSet := func(b *bool) bool { return b != nil && *b }
+	return isSet(cip.Policy.IncludeSpec) || isSet(cip.Policy.IncludeObjectMeta) || isSet(cip.Policy.IncludeTypeMeta)
+}
+
+// computeStamp returns the HMAC of the payload with the key.
+func computeStamp(key []byte, payload string) string {
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(payload))
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// hasValidStamp returns true if the annotations carry a
+// VerificationStampAnnotation for the pod spec and the current digests of
+// its policies.
+func (v *Validator) hasValidStamp(ctx context.Context, annotations map[string]string, namespace string, labels map[string]string, ps *corev1.PodSpec) bool {
+	stamp, ok := annotations[VerificationStampAnnotation]
+	if !ok {
+		return false
+	}
+	key := v.ownerTrustChainKey(ctx)
+	if key == nil {
+		return false
+	}
+	payload, ok := stampPayload(ctx, namespace, labels, ps)
+	if !ok {
+		return false
+	}
+	return hmac.Equal([]byte(stamp), []byte(computeStamp(key, payload)))
+}
+
+// stampVerification verifies the (already resolved) pod template as a Pod
+// and, if it passes all its policies, stamps it with the
+// VerificationStampAnnotation. A stale or invalid stamp is removed.
+func (v *Validator) stampVerification(ctx context.Context, template *corev1.PodTemplateSpec, opt k8schain.Options) {
+	if !apis.IsInCreate(ctx) && !apis.IsInUpdate(ctx) {
+		return
+	}
+	key := v.ownerTrustChainKey(ctx)
+	if key == nil {
+		return
+	}
+	if v.hasValidStamp(ctx, template.Annotations, opt.Namespace, template.Labels, &template.Spec) {
+		return
+	}
+	delete(template.Annotations, VerificationStampAnnotation)
+
+	payload, ok := stampPayload(ctx, opt.Namespace, template.Labels, &template.Spec)
+	if !ok {
+		return
+	}
+	// Only stamp templates that pass without even a warning, since the Pods
+	// admitted on the stamp do not report any.
+	if errs := v.validatePodSpec(ctx, opt.Namespace, stampKind, stampAPIVersion, template.Labels, &template.Spec, opt); errs != nil {
+		return
+	}
+	if template.Annotations == nil {
+		template.Annotations = make(map[string]string, 1)
+	}
+	template.Annotations[VerificationStampAnnotation] = computeStamp(key, payload)
+}
@@ -0,0 +1,125 @@
+// Copyright 2022 The Sigstore Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package webhook
+
+import (
+	"context"
+	"crypto/sha256"
+	"fmt"
+	"sync"
+
+	"github.com/sigstore/policy-controller/pkg/apis/config"
+	pbcommon "github.com/sigstore/protobuf-specs/gen/pb-go/common/v1"
+	sgroot "github.com/sigstore/sigstore-go/pkg/root"
+	"google.golang.org/protobuf/proto"
+)
+
+// trustedMaterials caches the TrustedMaterial built from the SigstoreKeys of
+// each TrustRoot, so that it is only built again when the TrustRoot
+// reconciler writes new SigstoreKeys for it (i.e. for a new generation of
+// the TrustRoot, or new TUF metadata).
+var trustedMaterials = &trustedMaterialCache{entries: make(map[string]trustedMaterialEntry)}
+
+type trustedMaterialCache struct {
+	mu      sync.Mutex
+	entries map[string]trustedMaterialEntry
+}
+
+type trustedMaterialEntry struct {
+	sigstoreKeys *config.SigstoreKeys
+	material     sgroot.TrustedMaterial
+}
+
+// get returns the TrustedMaterial of the TrustRoot, building it if its
+// SigstoreKeys changed.
+func (c *trustedMaterialCache) get(trustRootRef string, sk *config.SigstoreKeys) (sgroot.TrustedMaterial, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if entry, ok := c.entries[trustRootRef]; ok {
+		// The SigstoreKeys are parsed again whenever the ConfigMap holding
+		// them changes, even if this TrustRoot did not.
+		if entry.sigstoreKeys == sk || proto.Equal(entry.sigstoreKeys, sk) {
+			c.entries[trustRootRef] = trustedMaterialEntry{sigstoreKeys: sk, material: entry.material}
+			return entry.material, nil
+		}
+	}
+	material, err := newTrustedMaterial(sk)
+	if err != nil {
+		return nil, err
+	}
+	c.entries[trustRootRef] = trustedMaterialEntry{sigstoreKeys: sk, material: material}
+	return material, nil
+}
+
+// trustedMaterialFromTrustRoot returns the TrustedMaterial (Fulcio CAs,
+// Rekor and CT logs, TSAs) of the TrustRoot named trustRootRef.
+func trustedMaterialFromTrustRoot(ctx context.Context, trustRootRef string) (sgroot.TrustedMaterial, error) {
+	sk, err := sigstoreKeysForTrustRoot(ctx, trustRootRef)
+	if err != nil {
+		return nil, err
+	}
+	material, err := trustedMaterials.get(trustRootRef, sk)
+	if err != nil {
+		return nil, fmt.Errorf("trustRootRef %s: %w", trustRootRef, err)
+	}
+	return material, nil
+}
+
+// trustedMaterialFromTrustRoots returns the TrustedMaterial of the
+// TrustRoots named trustRootRefs. With more than one, the material of any of
+// them is trusted, e.g. while rotating from one TrustRoot to another.
+func trustedMaterialFromTrustRoots(ctx context.Context, trustRootRefs []string) (sgroot.TrustedMaterial, error) {
+	if len(trustRootRefs) == 1 {
+		return trustedMaterialFromTrustRoot(ctx, trustRootRefs[0])
+	}
+	ret := make(sgroot.TrustedMaterialCollection, 0, len(trustRootRefs))
+	for _, trustRootRef := range trustRootRefs {
+		material, err := trustedMaterialFromTrustRoot(ctx, trustRootRef)
+		if err != nil {
+			return nil, err
+		}
+		ret = append(ret, material)
+	}
+	return ret, nil
+}
+
+// newTrustedMaterial builds the TrustedMaterial of SigstoreKeys. These are
+// not quite a trusted root as sigstore-go expects it: the log IDs are set
+// by the TrustRoot reconciler to the hex encoding of the key IDs, and the
+// media type and hash algorithm are missing for the TrustRoots built from
+// TUF custom metadata, so they are filled in on a copy first.
+func newTrustedMaterial(sk *config.SigstoreKeys) (sgroot.TrustedMaterial, error) {
+	sk = proto.Clone(sk).(*config.SigstoreKeys)
+	if sk.MediaType == "" {
+		sk.MediaType = sgroot.TrustedRootMediaType01
+	}
+	for _, tlogs := range [][]*config.TransparencyLogInstance{sk.Tlogs, sk.Ctlogs} {
+		for _, tlog := range tlogs {
+			if tlog.GetPublicKey() == nil {
+				continue
+			}
+			if tlog.HashAlgorithm == pbcommon.HashAlgorithm_HASH_ALGORITHM_UNSPECIFIED {
+				tlog.HashAlgorithm = pbcommon.HashAlgorithm_SHA2_256
+			}
+			keyID := sha256.Sum256(tlog.PublicKey.RawBytes)
+			tlog.LogId = &config.LogID{KeyId: keyID[:]}
+		}
+	}
+	trustedRoot, err := sgroot.NewTrustedRootFromProtobuf(sk)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse trusted root from protobuf: %w", err)
+	}
+	return trustedRoot, nil
+}
@@ -0,0 +1,270 @@
+// Copyright 2022 The Sigstore Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package webhook
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"slices"
+	"sort"
+	"strings"
+
+	"github.com/google/go-containerregistry/pkg/name"
+	"github.com/sigstore/policy-controller/pkg/apis/config"
+	policycontrollerconfig "github.com/sigstore/policy-controller/pkg/config"
+	webhookcip "github.com/sigstore/policy-controller/pkg/webhook/clusterimagepolicy"
+	"google.golang.org/protobuf/proto"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"knative.dev/pkg/apis"
+	"knative.dev/pkg/logging"
+)
+
+// VerifiedPoliciesAnnotation is the annotation that the mutating webhook adds
+// to resources when PolicyControllerConfig.SkipUnchangedImages is set, along
+// with the OwnerTrustChainSecret. Its value is the JSON encoding of a map
+// from each image of the resource to an HMAC, with the key of the owner trust
+// chain, over the namespace, the image and the fingerprint of the policies
+// that match it (see policiesFingerprint). On UPDATE, the images that are
+// unchanged from the old object are not verified again if the HMAC recorded
+// on the old object is still current.
+const VerifiedPoliciesAnnotation = "policy.sigstore.dev/verified-policies"
+
+// policyDigests returns the sorted digests ("name:digest") of the policies,
+// as they are compiled (e.g. with their keys inlined) and along with the
+// material of the TrustRoots they reference, so that a change to either
+// changes them, even if the resourceVersion of the policy does not.
+func policyDigests(ctx context.Context, policies map[string]webhookcip.ClusterImagePolicy) ([]string, error) {
+	var sigstoreKeys map[string]*config.SigstoreKeys
+	if cfg := config.FromContext(ctx); cfg != nil && cfg.SigstoreKeysConfig != nil {
+		sigstoreKeys = cfg.SigstoreKeysConfig.SigstoreKeys
+	}
+	digests := make([]string, 0, len(policies))
+	for cipName, cip := range policies {
+		h := sha256.New()
+		b, err := json.Marshal(cip)
+		if err != nil {
+			return nil, fmt.Errorf("marshaling policy %s: %w", cipName, err)
+		}
+		h.Write(b)
+		for _, ref := range policyTrustRootRefs(cip) {
+			h.Write([]byte("\n" + ref + "="))
+			keys, ok := sigstoreKeys[ref]
+			if !ok {
+				continue
+			}
+			b, err := proto.MarshalOptions{Deterministic: true}.Marshal(keys)
+			if err != nil {
+				return nil, fmt.Errorf("marshaling trustRootRef %s of policy %s: %w", ref, cipName, err)
+			}
+			h.Write(b)
+		}
+		digests = append(digests, cipName+":"+hex.EncodeToString(h.Sum(nil)))
+	}
+	sort.Strings(digests)
+	return digests, nil
+}
+
+// policyTrustRootRefs returns the sorted TrustRoots referenced by the
+// authorities of the policy.
+func policyTrustRootRefs(cip webhookcip.ClusterImagePolicy) []string {
+	var refs []string
+	for _, authority := range cip.Authorities {
+		if authority.Keyless != nil {
+			refs = append(refs, authority.Keyless.GetTrustRootRefs()...)
+		}
+		if authority.CTLog != nil {
+			refs = append(refs, authority.CTLog.GetTrustRootRefs()...)
+		}
+		if authority.RFC3161Timestamp != nil {
+			refs = append(refs, authority.RFC3161Timestamp.GetTrustRootRefs()...)
+		}
+	}
+	sort.Strings(refs)
+	return slices.Compact(refs)
+}
+
+// policiesFingerprint returns the fingerprint of the digests of the policies
+// that match an image (see policyDigests). It returns false if the admission
+// of a resource with the image under these policies does not mean that the
+// image was verified, i.e. if no policy matches, or if one of them only warns
+// on failures, or if it depends on the resource itself.
+func policiesFingerprint(ctx context.Context, policies map[string]webhookcip.ClusterImagePolicy) (string, bool) {
+	if len(policies) == 0 {
+		return "", false
+	}
+	for _, cip := range policies {
+		if cip.Mode == "warn" || cip.InfraFailureMode == "warn" || dependsOnResource(cip) {
+			return "", false
+		}
+	}
+	digests, err := policyDigests(ctx, policies)
+	if err != nil {
+		logging.FromContext(ctx).Warnf("Unable to digest policies: %v", err)
+		return "", false
+	}
+	sum := sha256.Sum256([]byte(strings.Join(digests, ",")))
+	return hex.EncodeToString(sum[:]), true
+}
+
+// podSpecImages calls f with each image of the pod spec, and whether it is
+// the image of an image volume.
+func podSpecImages(ps *corev1.PodSpec, f func(image string, volume bool)) {
+	for _, c := range ps.InitContainers {
+		f(c.Image, false)
+	}
+	for _, c := range ps.Containers {
+		f(c.Image, false)
+	}
+	for _, c := range ps.EphemeralContainers {
+		f(c.Image, false)
+	}
+	for _, vol := range ps.Volumes {
+		if vol.Image != nil {
+			f(vol.Image.Reference, true)
+		}
+	}
+}
+
+// unchangedImageKey returns the key of an image in the
+// VerifiedPoliciesAnnotation. The images of image volumes are kept apart from
+// those of containers, since the policies may treat them differently.
+func unchangedImageKey(image string, volume bool) string {
+	if volume {
+		return "volume:" + image
+	}
+	return image
+}
+
+// verifiedPolicies returns the HMACs with the key of the fingerprints of the
+// policies that match the images of the pod spec in the namespace, keyed by
+// unchangedImageKey. Images that are not digests are left out.
+func verifiedPolicies(ctx context.Context, key []byte, namespace, kind, apiVersion string, labels map[string]string, ps *corev1.PodSpec) map[string]string {
+	cfg := config.FromContext(ctx)
+	if cfg == nil || cfg.ImagePolicyConfig == nil {
+		return nil
+	}
+	ret := make(map[string]string)
+	podSpecImages(ps, func(image string, volume bool) {
+		ref, err := name.ParseReference(image)
+		if err != nil {
+			return
+		}
+		if _, ok := ref.(name.Digest); !ok {
+			return
+		}
+		policies, err := cfg.ImagePolicyConfig.GetMatchingPolicies(ref.Name(), kind, apiVersion, labels)
+		if err != nil {
+			logging.FromContext(ctx).Debugf("Unable to get matching policies for %s: %v", image, err)
+			return
+		}
+		if fingerprint, ok := policiesFingerprint(ctx, policies); ok {
+			imageKey := unchangedImageKey(image, volume)
+			ret[imageKey] = computeStamp(key, "namespace="+namespace+"\n"+imageKey+"="+fingerprint)
+		}
+	})
+	return ret
+}
+
+// unchangedImagesKey returns the key that authenticates the
+// VerifiedPoliciesAnnotation, or nil if
+// PolicyControllerConfig.SkipUnchangedImages is not set or the owner trust
+// chain is disabled.
+func (v *Validator) unchangedImagesKey(ctx context.Context) []byte {
+	if !policycontrollerconfig.FromContextOrDefaults(ctx).SkipUnchangedImages {
+		return nil
+	}
+	return v.ownerTrustChainKey(ctx)
+}
+
+// recordVerifiedPolicies records in the VerifiedPoliciesAnnotation of the
+// resource the policies that match its (already resolved) images, or removes
+// it if they can not be recorded (see unchangedImagesKey).
+func (v *Validator) recordVerifiedPolicies(ctx context.Context, meta *metav1.ObjectMeta, namespace, kind, apiVersion string, ps *corev1.PodSpec) {
+	if !apis.IsInCreate(ctx) && !apis.IsInUpdate(ctx) {
+		return
+	}
+	var fingerprints map[string]string
+	if key := v.unchangedImagesKey(ctx); key != nil {
+		fingerprints = verifiedPolicies(ctx, key, namespace, kind, apiVersion, meta.Labels, ps)
+	}
+	if len(fingerprints) == 0 {
+		delete(meta.Annotations, VerifiedPoliciesAnnotation)
+		return
+	}
+	b, err := json.Marshal(fingerprints)
+	if err != nil {
+		logging.FromContext(ctx).Warnf("Unable to marshal verified policies: %v", err)
+		return
+	}
+	if meta.Annotations == nil {
+		meta.Annotations = make(map[string]string, 1)
+	}
+	meta.Annotations[VerifiedPoliciesAnnotation] = string(b)
+}
+
+// This is attached to the contexts passed to validatePodSpec on UPDATE, with
+// the images that do not need to be verified again.
+type unchangedImagesKey struct{}
+
+// withUnchangedImages returns a context for validating the pod spec of a
+// resource being updated from the old object in the namespace, so that the
+// images that are unchanged from it are not verified again, if the policies
+// that match them did not change since the old object was admitted.
+func (v *Validator) withUnchangedImages(ctx context.Context, namespace, kind, apiVersion string, labels map[string]string, ps *corev1.PodSpec, oldAnnotations map[string]string, oldPS *corev1.PodSpec) context.Context {
+	if !apis.IsInUpdate(ctx) {
+		return ctx
+	}
+	key := v.unchangedImagesKey(ctx)
+	if key == nil {
+		return ctx
+	}
+	val, ok := oldAnnotations[VerifiedPoliciesAnnotation]
+	if !ok {
+		return ctx
+	}
+	var old map[string]string
+	if err := json.Unmarshal([]byte(val), &old); err != nil {
+		logging.FromContext(ctx).Debugf("Unable to unmarshal verified policies %q: %v", val, err)
+		return ctx
+	}
+	oldImages := make(map[string]struct{})
+	podSpecImages(oldPS, func(image string, volume bool) {
+		oldImages[unchangedImageKey(image, volume)] = struct{}{}
+	})
+
+	unchanged := make(map[string]struct{})
+	for imageKey, fingerprint := range verifiedPolicies(ctx, key, namespace, kind, apiVersion, labels, ps) {
+		if _, ok := oldImages[imageKey]; ok && hmac.Equal([]byte(old[imageKey]), []byte(fingerprint)) {
+			unchanged[imageKey] = struct{}{}
+		}
+	}
+	if len(unchanged) == 0 {
+		return ctx
+	}
+	return context.WithValue(ctx, unchangedImagesKey{}, unchanged)
+}
+
+// isUnchangedImage returns true if the image does not need to be verified
+// again (see withUnchangedImages).
+func isUnchangedImage(ctx context.Context, image string, volume bool) bool {
+	unchanged, _ := ctx.Value(unchangedImagesKey{}).(map[string]struct{})
+	_, ok := unchanged[unchangedImageKey(image, volume)]
+	return ok
+}
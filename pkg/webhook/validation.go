@@ -18,12 +18,17 @@ package webhook
 import (
 	"context"
 	"crypto"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/hex"
 	"encoding/pem"
 
 	"github.com/google/go-containerregistry/pkg/name"
+	v1 "github.com/google/go-containerregistry/pkg/v1"
 	"knative.dev/pkg/logging"
 
 	"github.com/sigstore/cosign/v2/pkg/cosign"
+	"github.com/sigstore/sigstore/pkg/cryptoutils"
 	"github.com/sigstore/sigstore/pkg/signature"
 )
 
@@ -47,12 +52,58 @@ func valid(ctx context.Context, ref name.Reference, keys []crypto.PublicKey, has
 			lastErr = err
 			continue
 		}
-		return sps, nil
+		return withKeyID(sps, k), nil
 	}
 	logging.FromContext(ctx).Debug("No valid signatures were found.")
 	return nil, lastErr
 }
 
+// keyedSignature is a Signature that was verified with a public key, and
+// remembers which one so that it can be reported in the PolicySignature.
+type keyedSignature struct {
+	sig   Signature
+	keyID string
+}
+
+func (ks keyedSignature) Digest() (v1.Hash, error)         { return ks.sig.Digest() }
+func (ks keyedSignature) Payload() ([]byte, error)         { return ks.sig.Payload() }
+func (ks keyedSignature) Signature() ([]byte, error)       { return ks.sig.Signature() }
+func (ks keyedSignature) Cert() (*x509.Certificate, error) { return ks.sig.Cert() }
+
+// keyID returns the hex encoded SHA256 of the DER encoded public key.
+func keyID(k crypto.PublicKey) (string, error) {
+	der, err := cryptoutils.MarshalPublicKeyToDER(k)
+	if err != nil {
+		return "", err
+	}
+	sum := sha256.Sum256(der)
+	return hex.EncodeToString(sum[:]), nil
+}
+
+// withKeyID wraps the signatures verified with the given key so that they
+// carry its key ID. The signatures are returned as is if the key can not be
+// marshaled.
+func withKeyID(sigs []Signature, k crypto.PublicKey) []Signature {
+	id, err := keyID(k)
+	if err != nil {
+		return sigs
+	}
+	ret := make([]Signature, 0, len(sigs))
+	for _, sig := range sigs {
+		ret = append(ret, keyedSignature{sig: sig, keyID: id})
+	}
+	return ret
+}
+
+// signatureKeyID returns the ID of the key that verified the signature, or
+// "" if it was not verified with a key.
+func signatureKeyID(sig Signature) string {
+	if ks, ok := sig.(keyedSignature); ok {
+		return ks.keyID
+	}
+	return ""
+}
+
 // For testing
 var cosignVerifySignatures = cosign.VerifyImageSignatures
 var cosignVerifyAttestations = cosign.VerifyImageAttestations
@@ -25,8 +25,11 @@ import (
 	"encoding/json"
 	"errors"
 	"fmt"
+	"maps"
+	"slices"
 	"strings"
 	"sync"
+	"time"
 
 	"github.com/google/go-containerregistry/pkg/authn"
 	"github.com/google/go-containerregistry/pkg/authn/k8schain"
@@ -49,14 +52,18 @@ import (
 	"github.com/sigstore/sigstore/pkg/fulcioroots"
 	"github.com/sigstore/sigstore/pkg/signature"
 	"github.com/sigstore/sigstore/pkg/tuf"
+	"golang.org/x/sync/singleflight"
 	admissionv1 "k8s.io/api/admission/v1"
 	corev1 "k8s.io/api/core/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	corev1listers "k8s.io/client-go/listers/core/v1"
 	"knative.dev/pkg/apis"
 	duckv1 "knative.dev/pkg/apis/duck/v1"
 
 	kubeclient "knative.dev/pkg/client/injection/kube/client"
+	secretinformer "knative.dev/pkg/injection/clients/namespacedkube/informers/core/v1/secret"
 	"knative.dev/pkg/logging"
+	"knative.dev/pkg/system"
 
 	sgroot "github.com/sigstore/sigstore-go/pkg/root"
 	"github.com/sigstore/sigstore-go/pkg/verify"
@@ -73,10 +80,16 @@ type Signature interface {
 // policy.AttestationToPayloadJSON)
 var _ policy.PayloadProvider = (Signature)(nil)
 
-type Validator struct{}
+type Validator struct {
+	// secretLister reads the Secrets of the system namespace, such as the
+	// PolicyControllerConfig.OwnerTrustChainSecret.
+	secretLister corev1listers.SecretNamespaceLister
+}
 
-func NewValidator(_ context.Context) *Validator {
-	return &Validator{}
+func NewValidator(ctx context.Context) *Validator {
+	return &Validator{
+		secretLister: secretinformer.Get(ctx).Lister().Secrets(system.Namespace()),
+	}
 }
 
 // isDeletedOrStatusUpdate returns true if the resource in question is being
@@ -154,6 +167,42 @@ func GetIncludeTypeMeta(ctx context.Context) interface{} {
 	return ctx.Value(includeTypeMetaKey{})
 }
 
+// This is attached to the contexts used to validate the image of an image
+// volume, so that it is reported as such in the PolicyResult.
+type imageVolumeKey struct{}
+
+// withImageVolume marks ctx as validating the image of an image volume.
+func withImageVolume(ctx context.Context) context.Context {
+	return context.WithValue(ctx, imageVolumeKey{}, true)
+}
+
+// isImageVolume returns true if ctx is validating the image of an image
+// volume.
+func isImageVolume(ctx context.Context) bool {
+	b, _ := ctx.Value(imageVolumeKey{}).(bool)
+	return b
+}
+
+// resultCacheImage returns the image under which the results of the
+// validation of image are cached and coalesced. The results for image volumes
+// are kept apart from those for containers, since their PolicyResults
+// differ.
+func resultCacheImage(ctx context.Context, image string) string {
+	if isImageVolume(ctx) {
+		return "volume:" + image
+	}
+	return image
+}
+
+// imageField returns the field that holds the image reference of the entries
+// of field, a list of the pod spec (e.g. containers or volumes).
+func imageField(field string) string {
+	if field == "volumes" {
+		return "image.reference"
+	}
+	return "image"
+}
+
 // ValidatePodScalable implements policyduckv1beta1.PodScalableValidator
 // It is very similar to ValidatePodSpecable, but allows for spec.replicas
 // to be decremented. This allows for scaling down pods with non-compliant
@@ -187,6 +236,9 @@ func (v *Validator) ValidatePodScalable(ctx context.Context, ps *policyduckv1bet
 		ImagePullSecrets:   imagePullSecrets,
 	}
 
+	if old, ok := apis.GetBaseline(ctx).(*policyduckv1beta1.PodScalable); ok && old != nil {
+		ctx = v.withUnchangedImages(ctx, ns, ps.Kind, ps.APIVersion, ps.ObjectMeta.Labels, &ps.Spec.Template.Spec, old.ObjectMeta.Annotations, &old.Spec.Template.Spec)
+	}
 	return v.validatePodSpec(ctx, ns, ps.Kind, ps.APIVersion, ps.ObjectMeta.Labels, &ps.Spec.Template.Spec, opt).ViaField("spec.template.spec")
 }
 
@@ -213,6 +265,9 @@ func (v *Validator) ValidatePodSpecable(ctx context.Context, wp *duckv1.WithPod)
 		ServiceAccountName: wp.Spec.Template.Spec.ServiceAccountName,
 		ImagePullSecrets:   imagePullSecrets,
 	}
+	if old, ok := apis.GetBaseline(ctx).(*duckv1.WithPod); ok && old != nil {
+		ctx = v.withUnchangedImages(ctx, ns, wp.Kind, wp.APIVersion, wp.ObjectMeta.Labels, &wp.Spec.Template.Spec, old.ObjectMeta.Annotations, &old.Spec.Template.Spec)
+	}
 	return v.validatePodSpec(ctx, ns, wp.Kind, wp.APIVersion, wp.ObjectMeta.Labels, &wp.Spec.Template.Spec, opt).ViaField("spec.template.spec")
 }
 
@@ -238,6 +293,13 @@ func (v *Validator) ValidatePod(ctx context.Context, p *duckv1.Pod) *apis.FieldE
 		ServiceAccountName: p.Spec.ServiceAccountName,
 		ImagePullSecrets:   imagePullSecrets,
 	}
+	if v.hasValidStamp(ctx, p.ObjectMeta.Annotations, ns, p.ObjectMeta.Labels, &p.Spec) {
+		logging.FromContext(ctx).Debugf("Skipping validations of %s/%s due to a valid verification stamp", ns, p.ObjectMeta.Name)
+		return nil
+	}
+	if old, ok := apis.GetBaseline(ctx).(*duckv1.Pod); ok && old != nil {
+		ctx = v.withUnchangedImages(ctx, ns, p.Kind, p.APIVersion, p.ObjectMeta.Labels, &p.Spec, old.ObjectMeta.Annotations, &old.Spec)
+	}
 	return v.validatePodSpec(ctx, ns, p.Kind, p.APIVersion, p.ObjectMeta.Labels, &p.Spec, opt).ViaField("spec")
 }
 
@@ -265,15 +327,22 @@ func (v *Validator) ValidateCronJob(ctx context.Context, c *duckv1.CronJob) *api
 		ImagePullSecrets:   imagePullSecrets,
 	}
 
+	if old, ok := apis.GetBaseline(ctx).(*duckv1.CronJob); ok && old != nil {
+		ctx = v.withUnchangedImages(ctx, ns, c.Kind, c.APIVersion, c.ObjectMeta.Labels, &c.Spec.JobTemplate.Spec.Template.Spec, old.ObjectMeta.Annotations, &old.Spec.JobTemplate.Spec.Template.Spec)
+	}
 	return v.validatePodSpec(ctx, ns, c.Kind, c.APIVersion, c.ObjectMeta.Labels, &c.Spec.JobTemplate.Spec.Template.Spec, opt).ViaField("spec.jobTemplate.spec.template.spec")
 }
 
 func (v *Validator) validatePodSpec(ctx context.Context, namespace, kind, apiVersion string, labels map[string]string, ps *corev1.PodSpec, opt k8schain.Options) (errs *apis.FieldError) {
+	ctx, cancel := withAdmissionTimeout(ctx)
+	defer cancel()
+
 	kc, err := registryauth.NewK8sKeychain(ctx, kubeclient.Get(ctx), opt)
 	if err != nil {
 		logging.FromContext(ctx).Warnf("Unable to build k8schain: %v", err)
 		return apis.ErrGeneric(err.Error(), apis.CurrentField)
 	}
+	ctx = withKeychainInputs(ctx, opt)
 
 	type containerCheckResult struct {
 		index                int
@@ -296,18 +365,20 @@ func (v *Validator) validatePodSpec(ctx context.Context, namespace, kind, apiVer
 					results <- containerCheckResult{index: i, containerCheckResult: fe}
 					return
 				}
+				if isUnchangedImage(ctx, c.Image, false) {
+					logging.FromContext(ctx).Debugf("Skipping validation of unchanged image %s", c.Image)
+					results <- containerCheckResult{index: i}
+					return
+				}
 
-				containerErrors := v.validateContainerImage(ctx, c.Image, namespace, field, i, kind, apiVersion, labels, kc, ociremote.WithRemoteOptions(
-					remote.WithContext(ctx),
-					remote.WithAuthFromKeychain(kc),
-				))
+				containerErrors := v.validateContainerImage(ctx, c.Image, namespace, field, i, kind, apiVersion, labels, kc)
 				results <- containerCheckResult{index: i, containerCheckResult: containerErrors}
 			}()
 		}
 		for i := 0; i < len(cs); i++ {
 			select {
 			case <-ctx.Done():
-				errs = errs.Also(apis.ErrGeneric("context was canceled before validation completed"))
+				errs = errs.Also(apis.ErrGeneric(canceledMessage(ctx)))
 			case result, ok := <-results:
 				if !ok {
 					errs = errs.Also(apis.ErrGeneric("results channel failed to produce a result"))
@@ -336,18 +407,67 @@ func (v *Validator) validatePodSpec(ctx context.Context, namespace, kind, apiVer
 					results <- containerCheckResult{index: i, containerCheckResult: fe}
 					return
 				}
+				if isUnchangedImage(ctx, c.Image, false) {
+					logging.FromContext(ctx).Debugf("Skipping validation of unchanged image %s", c.Image)
+					results <- containerCheckResult{index: i}
+					return
+				}
 
-				containerErrors := v.validateContainerImage(ctx, c.Image, namespace, field, i, kind, apiVersion, labels, kc, ociremote.WithRemoteOptions(
-					remote.WithContext(ctx),
-					remote.WithAuthFromKeychain(kc),
-				))
+				containerErrors := v.validateContainerImage(ctx, c.Image, namespace, field, i, kind, apiVersion, labels, kc)
 				results <- containerCheckResult{index: i, containerCheckResult: containerErrors}
 			}()
 		}
 		for i := 0; i < len(cs); i++ {
 			select {
 			case <-ctx.Done():
-				errs = errs.Also(apis.ErrGeneric("context was canceled before validation completed"))
+				errs = errs.Also(apis.ErrGeneric(canceledMessage(ctx)))
+			case result, ok := <-results:
+				if !ok {
+					errs = errs.Also(apis.ErrGeneric("results channel failed to produce a result"))
+				} else {
+					errs = errs.Also(result.containerCheckResult)
+				}
+			}
+		}
+		wg.Wait()
+	}
+
+	checkImageVolumes := func(vs []corev1.Volume, field string) {
+		results := make(chan containerCheckResult, len(vs))
+		wg := new(sync.WaitGroup)
+		count := 0
+		for i, vol := range vs {
+			if vol.Image == nil {
+				continue
+			}
+			i := i
+			image := vol.Image.Reference
+			count++
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+
+				// Require digests, otherwise the validation is meaningless
+				// since the tag can move.
+				fe := refOrFieldError(image, field, i)
+				if fe != nil {
+					results <- containerCheckResult{index: i, containerCheckResult: fe}
+					return
+				}
+				if isUnchangedImage(ctx, image, true) {
+					logging.FromContext(ctx).Debugf("Skipping validation of unchanged image %s", image)
+					results <- containerCheckResult{index: i}
+					return
+				}
+
+				volumeErrors := v.validateContainerImage(withImageVolume(ctx), image, namespace, field, i, kind, apiVersion, labels, kc)
+				results <- containerCheckResult{index: i, containerCheckResult: volumeErrors}
+			}()
+		}
+		for i := 0; i < count; i++ {
+			select {
+			case <-ctx.Done():
+				errs = errs.Also(apis.ErrGeneric(canceledMessage(ctx)))
 			case result, ok := <-results:
 				if !ok {
 					errs = errs.Also(apis.ErrGeneric("results channel failed to produce a result"))
@@ -362,6 +482,7 @@ func (v *Validator) validatePodSpec(ctx context.Context, namespace, kind, apiVer
 	checkContainers(ps.InitContainers, "initContainers")
 	checkContainers(ps.Containers, "containers")
 	checkEphemeralContainers(ps.EphemeralContainers, "ephemeralContainers")
+	checkImageVolumes(ps.Volumes, "volumes")
 
 	return errs
 }
@@ -373,7 +494,7 @@ func setNoMatchingPoliciesError(ctx context.Context, image, field string, index
 	// Check what the configuration is and act accordingly.
 	pcConfig := policycontrollerconfig.FromContextOrDefaults(ctx)
 
-	noMatchingPolicyError := apis.ErrGeneric("no matching policies", "image").ViaFieldIndex(field, index)
+	noMatchingPolicyError := apis.ErrGeneric("no matching policies", imageField(field)).ViaFieldIndex(field, index)
 	noMatchingPolicyError.Details = image
 	if pcConfig == nil {
 		// This should not happen, but handle it as fail close
@@ -393,6 +514,57 @@ func setNoMatchingPoliciesError(ctx context.Context, image, field string, index
 	}
 }
 
+// policyFlights coalesces the validations of an image against a policy that
+// are in flight at the same time. The waiters get the result of the
+// validation that runs, including its errors if it runs out of time.
+var policyFlights singleflight.Group
+
+// For testing
+var joinPolicyFlight = policyFlights.DoChan
+
+// maxPolicyFlightDuration bounds the validations in flight that are started
+// by an admission without a deadline. It is the longest timeout that the API
+// server gives an admission webhook.
+// For testing
+var maxPolicyFlightDuration = 30 * time.Second
+
+// withPolicyFlightDeadline returns a context for the validation in flight
+// started with ctx. It is detached from ctx, so that the admissions waiting on
+// it are not failed when the one that started it is canceled, but keeps its
+// deadline (or maxPolicyFlightDuration, if it has none), so that a hung
+// validation does not hold on to its policyFlights key forever.
+func withPolicyFlightDeadline(ctx context.Context) (context.Context, context.CancelFunc) {
+	deadline, ok := ctx.Deadline()
+	if !ok {
+		deadline = time.Now().Add(maxPolicyFlightDuration)
+	}
+	return context.WithDeadlineCause(context.WithoutCancel(ctx), deadline,
+		&stageTimeoutError{stage: "validation in flight", timeout: time.Until(deadline)})
+}
+
+// This is attached to the contexts of the validations by withKeychainInputs.
+type keychainInputsKey struct{}
+
+// withKeychainInputs returns a context whose validations authenticate with
+// the keychain built from opt, so that they only share their results (see
+// policyFlights) with those that use the same pull credentials.
+func withKeychainInputs(ctx context.Context, opt k8schain.Options) context.Context {
+	b, err := json.Marshal(opt)
+	if err != nil {
+		// This should never happen, but do not share the results then.
+		b = []byte(err.Error())
+	}
+	sum := sha256.Sum256(b)
+	return context.WithValue(ctx, keychainInputsKey{}, hex.EncodeToString(sum[:]))
+}
+
+// keychainInputs returns the digest of the inputs of the keychain of the
+// validations with ctx (see withKeychainInputs), if any.
+func keychainInputs(ctx context.Context) string {
+	digest, _ := ctx.Value(keychainInputsKey{}).(string)
+	return digest
+}
+
 // validatePolicies will go through all the matching Policies and their
 // Authorities for a given image. Returns the map of policy=>Validated
 // signatures. From the map you can see the number of matched policies along
@@ -429,12 +601,65 @@ func validatePolicies(ctx context.Context, namespace string, ref name.Reference,
 			defer wg.Done()
 			result := retChannelType{name: cipName}
 
-			result.policyResult, result.errors = ValidatePolicy(ctx, namespace, ref, cip, kc, remoteOpts...)
-			// Cache the result.
-			FromContext(ctx).Set(ctx, ref.Name(), cipName, string(cip.UID), cip.ResourceVersion, &CacheResult{
-				PolicyResult: result.policyResult,
-				Errors:       result.errors,
+			validate := func(ctx context.Context) *CacheResult {
+				policyCtx, cancel := withStageTimeout(ctx, "policy "+cipName, policycontrollerconfig.FromContextOrDefaults(ctx).PolicyTimeout)
+				defer cancel()
+				policyResult, errs := ValidatePolicy(policyCtx, namespace, ref, cip, kc, remoteOpts...)
+				// Cache the result, unless it is due to a (hopefully
+				// transient) infrastructure failure.
+				if !hasInfrastructureError(errs) {
+					FromContext(ctx).Set(ctx, resultCacheImage(ctx, ref.Name()), cipName, string(cip.UID), cip.ResourceVersion, &CacheResult{
+						PolicyResult: policyResult,
+						Errors:       errs,
+					})
+				}
+				return &CacheResult{PolicyResult: policyResult, Errors: errs}
+			}
+
+			// A policy that evaluates the resource itself can not share its
+			// result with the admissions of other resources.
+			if dependsOnResource(cip) {
+				shared := validate(ctx)
+				recordPolicyVerification(ctx, false)
+				result.policyResult, result.errors = shared.PolicyResult, shared.Errors
+				results <- result
+				return
+			}
+
+			// Identical validations that are in flight (e.g. for the pods of
+			// a rollout) share a single result. The namespace and the inputs
+			// of the keychain are part of the key, since they determine the
+			// pull credentials.
+			key := strings.Join([]string{namespace, keychainInputs(ctx), resultCacheImage(ctx, ref.String()), cipName, string(cip.UID), cip.ResourceVersion}, "|")
+
+			// The verifications of the same admission share their results
+			// (see withVerificationResults).
+			memo := verificationResultsFromContext(ctx)
+			if shared, ok := memo.get(key); ok {
+				result.policyResult, result.errors = shared.PolicyResult, shared.Errors
+				results <- result
+				return
+			}
+
+			// The flight runs detached from the admission that starts it, so
+			// that the admissions waiting on it are not failed when that one
+			// is canceled; each of them gives up on its own deadline instead.
+			ran := false
+			flight := joinPolicyFlight(key, func() (interface{}, error) {
+				ran = true
+				flightCtx, cancel := withPolicyFlightDeadline(ctx)
+				defer cancel()
+				return validate(flightCtx), nil
 			})
+			select {
+			case <-ctx.Done():
+				result.errors = []error{errors.New(canceledMessage(ctx))}
+			case r := <-flight:
+				recordPolicyVerification(ctx, !ran)
+				shared := r.Val.(*CacheResult)
+				result.policyResult, result.errors = shared.PolicyResult, shared.Errors
+				memo.set(key, shared)
+			}
 			results <- result
 		}()
 	}
@@ -447,7 +672,7 @@ func validatePolicies(ctx context.Context, namespace string, ref name.Reference,
 	for i := 0; i < len(policies); i++ {
 		select {
 		case <-ctx.Done():
-			ret["internalerror"] = append(ret["internalerror"], fmt.Errorf("context was canceled before validation completed"))
+			ret["internalerror"] = append(ret["internalerror"], errors.New(canceledMessage(ctx)))
 		case result, ok := <-results:
 			if !ok {
 				ret["internalerror"] = append(ret["internalerror"], fmt.Errorf("results channel failed to produce a result"))
@@ -487,14 +712,24 @@ func asFieldError(warn bool, err error) *apis.FieldError {
 // In any case returns all errors encountered if none of the authorities
 // passed.
 // kc is the Keychain to use for fetching ConfigFile that's independent of the
-// signatures / attestations.
+// signatures / attestations. Each authority is validated with its own time
+// budget (see PolicyControllerConfig.AuthorityTimeout), so the registry
+// operations for the signatures / attestations are bound to that budget
+// and authenticated with kc. They are made with the remote.Options set by
+// remoteOpts and those of ctx (see WithRemoteOptions), followed by the
+// context, keychain and transport of the authority, and against the mirror of
+// ref as per PolicyControllerConfig.RegistryMirrors.
 func ValidatePolicy(ctx context.Context, namespace string, ref name.Reference, cip webhookcip.ClusterImagePolicy, kc authn.Keychain, remoteOpts ...ociremote.Option) (*PolicyResult, []error) {
 	// Check the cache and return if hit, otherwise, check the policy
-	cacheResult := FromContext(ctx).Get(ctx, ref.String(), string(cip.UID), cip.ResourceVersion)
+	cacheResult := FromContext(ctx).Get(ctx, resultCacheImage(ctx, ref.String()), string(cip.UID), cip.ResourceVersion)
 	if cacheResult != nil {
 		return cacheResult.PolicyResult, cacheResult.Errors
 	}
 
+	// The signatures, attestations and config files are fetched from the
+	// mirror of the image, if any. The policies matched it by its name.
+	fetchRef := mirrorReference(ctx, ref)
+
 	// Each gofunc creates and puts one of these into a results channel.
 	// Once each gofunc finishes, we go through the channel and pull out
 	// the results.
@@ -516,17 +751,26 @@ func ValidatePolicy(ctx context.Context, namespace string, ref name.Reference, c
 		go func() {
 			defer wg.Done()
 			result := retChannelType{name: authority.Name}
-			// Assignment for appendAssign lint error
-			authorityRemoteOpts := remoteOpts
-			authorityRemoteOpts = append(authorityRemoteOpts, authority.RemoteOpts...)
 
-			signaturePullSecretsOpts, err := authority.SourceSignaturePullSecretsOpts(ctx, namespace)
+			ctx, cancel := withStageTimeout(ctx, "authority "+authority.Name, policycontrollerconfig.FromContextOrDefaults(ctx).AuthorityTimeout)
+			defer cancel()
+			authorityRemoteOpts := slices.Concat(remoteOpts, authority.RemoteOpts, mirrorSourceOpts(ctx, authority))
+			// The remote.Options set by the caller and by the authority are
+			// merged with those of the stage, which come last so that they
+			// are not overridden.
+			ctx = WithRemoteOptions(ctx, slices.Concat(RemoteOptions(remoteOpts...), RemoteOptions(authority.RemoteOpts...))...)
+
+			signaturePullSecretsKC, err := authority.SourceSignaturePullSecretsKeychain(ctx, namespace)
 			if err != nil {
-				result.err = err
+				result.err = stageError(ctx, err)
 				results <- result
 				return
 			}
-			authorityRemoteOpts = append(authorityRemoteOpts, signaturePullSecretsOpts...)
+			authorityKC := kc
+			if signaturePullSecretsKC != nil {
+				authorityKC = signaturePullSecretsKC
+			}
+			authorityRemoteOpts = append(authorityRemoteOpts, ociremote.WithRemoteOptions(registryRemoteOptions(ctx, authorityKC)...))
 
 			switch {
 			case authority.Static != nil:
@@ -539,15 +783,16 @@ func ValidatePolicy(ctx context.Context, namespace string, ref name.Reference, c
 
 			case len(authority.Attestations) > 0:
 				if authority.SignatureFormat == "bundle" {
-					result.attestations, result.err = ValidatePolicyAttestationsForAuthorityWithBundle(ctx, ref, authority, kc)
+					result.attestations, result.err = ValidatePolicyAttestationsForAuthorityWithBundle(ctx, fetchRef, authority, kc)
 				} else {
 					// We're doing the verify-attestations path, so validate (.att)
-					result.attestations, result.err = ValidatePolicyAttestationsForAuthority(ctx, ref, authority, authorityRemoteOpts...)
+					result.attestations, result.err = ValidatePolicyAttestationsForAuthority(ctx, fetchRef, authority, authorityRemoteOpts...)
 				}
 
 			default:
-				result.signatures, result.err = ValidatePolicySignaturesForAuthority(ctx, ref, authority, authorityRemoteOpts...)
+				result.signatures, result.err = ValidatePolicySignaturesForAuthority(ctx, fetchRef, authority, authorityRemoteOpts...)
 			}
+			result.err = stageError(ctx, result.err)
 			results <- result
 		}()
 	}
@@ -560,11 +805,12 @@ func ValidatePolicy(ctx context.Context, namespace string, ref name.Reference, c
 	// return it.
 	policyResult := &PolicyResult{
 		AuthorityMatches: make(map[string]AuthorityMatch, len(cip.Authorities)),
+		ImageVolume:      isImageVolume(ctx),
 	}
 	for range cip.Authorities {
 		select {
 		case <-ctx.Done():
-			authorityErrors = append(authorityErrors, fmt.Errorf("%w before validation completed", ctx.Err()))
+			authorityErrors = append(authorityErrors, newPolicyError(cip, "", canceledError(ctx)))
 
 		case result, ok := <-results:
 			if !ok {
@@ -576,7 +822,11 @@ func ValidatePolicy(ctx context.Context, namespace string, ref name.Reference, c
 				// We only wrap actual policy failures as FieldErrors with the
 				// possibly Warn level. Other things imho should be still
 				// be considered errors.
-				authorityErrors = append(authorityErrors, asFieldError(cip.Mode == "warn", result.err))
+				pe := newPolicyError(cip, result.name, result.err)
+				if pe.FailedOpen {
+					logging.FromContext(ctx).Warnf("Failing open for policy %s authority %s on %s: %v", cip.UID, result.name, ref.Name(), result.err)
+				}
+				authorityErrors = append(authorityErrors, pe)
 
 			case len(result.signatures) > 0:
 				policyResult.AuthorityMatches[result.name] = AuthorityMatch{Signatures: result.signatures}
@@ -615,14 +865,10 @@ func ValidatePolicy(ctx context.Context, namespace string, ref name.Reference, c
 			// would be nice if we could just unwrap/generate the ggcr remote
 			// options from the oci remote options, but for now this is how
 			// we're rolling.
-			rOpts := []remote.Option{
-				remote.WithContext(ctx),
-				remote.WithAuthFromKeychain(kc),
-			}
-			configFiles, errs := getConfigs(ctx, ref, rOpts...)
+			configFiles, errs := getConfigs(ctx, fetchRef, registryRemoteOptions(ctx, kc)...)
 			if len(errs) > 0 {
 				for _, e := range errs {
-					authorityErrors = append(authorityErrors, asFieldError(cip.Mode == "warn", e))
+					authorityErrors = append(authorityErrors, newPolicyError(cip, "", e))
 				}
 				return nil, authorityErrors
 			}
@@ -647,17 +893,17 @@ func ValidatePolicy(ctx context.Context, namespace string, ref name.Reference, c
 		warn, err := policy.EvaluatePolicyAgainstJSON(ctx, "ClusterImagePolicy", cip.Policy.Type, cip.Policy.Data, policyJSON)
 		if err != nil {
 			logging.FromContext(ctx).Warnf("Failed to validate CIP level policy; err: %w; against %s", err, string(policyJSON))
-			return nil, append(authorityErrors, asFieldError(cip.Mode == "warn", err))
+			return nil, append(authorityErrors, newPolicyErrorWithReason(cip.Mode == "warn", "", ReasonPolicyEvaluation, err))
 		}
 		if warn != nil {
 			logging.FromContext(ctx).Warnf("Failed to validate CIP level policy; warn: %w; against %s", warn, string(policyJSON))
-			return nil, append(authorityErrors, asFieldError(cip.Mode == "warn", warn))
+			return nil, append(authorityErrors, newPolicyErrorWithReason(cip.Mode == "warn", "", ReasonPolicyEvaluation, warn))
 		}
 	}
 	return policyResult, authorityErrors
 }
 
-func ociSignatureToPolicySignature(ctx context.Context, sigs []Signature) []PolicySignature {
+func ociSignatureToPolicySignature(ctx context.Context, authority webhookcip.Authority, sigs []Signature) []PolicySignature {
 	ret := make([]PolicySignature, 0, len(sigs))
 	for _, ociSig := range sigs {
 		logging.FromContext(ctx).Debugf("Converting signature %+v", ociSig)
@@ -677,9 +923,10 @@ func ociSignatureToPolicySignature(ctx context.Context, sigs []Signature) []Poli
 				sub = sans[0]
 			}
 			ret = append(ret, PolicySignature{
-				ID:      sigID,
-				Subject: sub,
-				Issuer:  ce.GetIssuer(),
+				ID:           sigID,
+				Subject:      sub,
+				Issuer:       ce.GetIssuer(),
+				TrustRootRef: signatureTrustRootRef(ctx, authority, ociSig),
 				GithubExtensions: GithubExtensions{
 					WorkflowTrigger: ce.GetCertExtensionGithubWorkflowTrigger(),
 					WorkflowSHA:     ce.GetExtensionGithubWorkflowSha(),
@@ -690,8 +937,9 @@ func ociSignatureToPolicySignature(ctx context.Context, sigs []Signature) []Poli
 			})
 		} else {
 			ret = append(ret, PolicySignature{
-				ID: sigID,
-				// TODO(mattmoor): Is there anything we should encode for key-based?
+				ID:           sigID,
+				KeyID:        signatureKeyID(ociSig),
+				TrustRootRef: signatureTrustRootRef(ctx, authority, ociSig),
 			})
 		}
 	}
@@ -738,7 +986,7 @@ type attestation struct {
 	Digest        string
 }
 
-func attestationToPolicyAttestations(ctx context.Context, atts []attestation) []PolicyAttestation {
+func attestationToPolicyAttestations(ctx context.Context, authority webhookcip.Authority, atts []attestation) []PolicyAttestation {
 	ret := make([]PolicyAttestation, 0, len(atts))
 	for _, att := range atts {
 		logging.FromContext(ctx).Debugf("Converting attestation with digest %s\n", att.Digest)
@@ -759,9 +1007,10 @@ func attestationToPolicyAttestations(ctx context.Context, atts []attestation) []
 			}
 			ret = append(ret, PolicyAttestation{
 				PolicySignature: PolicySignature{
-					ID:      sigID,
-					Subject: sub,
-					Issuer:  ce.GetIssuer(),
+					ID:           sigID,
+					Subject:      sub,
+					Issuer:       ce.GetIssuer(),
+					TrustRootRef: signatureTrustRootRef(ctx, authority, att.Signature),
 					GithubExtensions: GithubExtensions{
 						WorkflowTrigger: ce.GetCertExtensionGithubWorkflowTrigger(),
 						WorkflowSHA:     ce.GetExtensionGithubWorkflowSha(),
@@ -777,8 +1026,9 @@ func attestationToPolicyAttestations(ctx context.Context, atts []attestation) []
 		} else {
 			ret = append(ret, PolicyAttestation{
 				PolicySignature: PolicySignature{
-					ID: sigID,
-					// TODO(mattmoor): Is there anything we should encode for key-based?
+					ID:           sigID,
+					KeyID:        signatureKeyID(att.Signature),
+					TrustRootRef: signatureTrustRootRef(ctx, authority, att.Signature),
 				},
 				PredicateType: att.PredicateType,
 				Payload:       att.Payload,
@@ -812,8 +1062,11 @@ func ValidatePolicySignaturesForAuthority(ctx context.Context, ref name.Referenc
 		if err != nil {
 			return nil, fmt.Errorf("signature key validation failed for authority %s for %s: %w", name, ref.Name(), err)
 		}
+		if sps, err = filterValidFor(ctx, authority, sps); err != nil {
+			return nil, fmt.Errorf("signature key validation failed for authority %s for %s: %w", name, ref.Name(), err)
+		}
 		logging.FromContext(ctx).Debugf("validated signature for %s for authority %s got %d signatures", ref.Name(), authority.Name, len(sps))
-		return ociSignatureToPolicySignature(ctx, sps), nil
+		return ociSignatureToPolicySignature(ctx, authority, sps), nil
 
 	case authority.Keyless != nil:
 		if authority.Keyless.URL != nil {
@@ -822,8 +1075,11 @@ func ValidatePolicySignaturesForAuthority(ctx context.Context, ref name.Referenc
 				logging.FromContext(ctx).Errorf("failed validSignatures for authority %s with fulcio for %s: %v", name, ref.Name(), err)
 				return nil, fmt.Errorf("signature keyless validation failed for authority %s for %s: %w", name, ref.Name(), err)
 			}
+			if sps, err = filterValidFor(ctx, authority, sps); err != nil {
+				return nil, fmt.Errorf("signature keyless validation failed for authority %s for %s: %w", name, ref.Name(), err)
+			}
 			logging.FromContext(ctx).Debugf("validated signature for %s, got %d signatures", ref.Name(), len(sps))
-			return ociSignatureToPolicySignature(ctx, sps), nil
+			return ociSignatureToPolicySignature(ctx, authority, sps), nil
 		}
 		return nil, fmt.Errorf("no Keyless URL specified")
 	case authority.RFC3161Timestamp != nil:
@@ -832,8 +1088,11 @@ func ValidatePolicySignaturesForAuthority(ctx context.Context, ref name.Referenc
 			logging.FromContext(ctx).Errorf("failed validSignatures for authority %s with fulcio for %s: %v", name, ref.Name(), err)
 			return nil, fmt.Errorf("signature TSA validation failed for authority %s for %s: %w", name, ref.Name(), err)
 		}
+		if sps, err = filterValidFor(ctx, authority, sps); err != nil {
+			return nil, fmt.Errorf("signature TSA validation failed for authority %s for %s: %w", name, ref.Name(), err)
+		}
 		logging.FromContext(ctx).Debugf("validated TSA signature for %s, got %d signatures", ref.Name(), len(sps))
-		return ociSignatureToPolicySignature(ctx, sps), nil
+		return ociSignatureToPolicySignature(ctx, authority, sps), nil
 	}
 
 	// This should never happen because authority has to have been validated to
@@ -866,7 +1125,7 @@ func ValidatePolicyAttestationsForAuthority(ctx context.Context, ref name.Refere
 				logging.FromContext(ctx).Errorf("error validating attestations: %v", err)
 				return nil, fmt.Errorf("attestation key validation failed for authority %s for %s: %w", name, ref.Name(), err)
 			}
-			verifiedAttestations = append(verifiedAttestations, va...)
+			verifiedAttestations = append(verifiedAttestations, withKeyID(va, k)...)
 		}
 
 	case authority.Keyless != nil:
@@ -888,6 +1147,11 @@ func ValidatePolicyAttestationsForAuthority(ctx context.Context, ref name.Refere
 		verifiedAttestations = append(verifiedAttestations, va...)
 	}
 
+	verifiedAttestations, err = filterValidFor(ctx, authority, verifiedAttestations)
+	if err != nil {
+		return nil, fmt.Errorf("attestation validation failed for authority %s for %s: %w", name, ref.Name(), err)
+	}
+
 	// If we didn't get any verified attestations either from the Key or Keyless
 	// path, then error out
 	if len(verifiedAttestations) == 0 {
@@ -982,17 +1246,14 @@ func checkPredicates(ctx context.Context, authority webhookcip.Authority, verifi
 			}
 			return nil, fmt.Errorf("%s with type %s, checked the following predicateTypes: %q", cosign.ErrNoMatchingAttestationsMessage, wantedAttestation.PredicateType, strings.Join(cpt, ","))
 		}
-		ret[wantedAttestation.Name] = attestationToPolicyAttestations(ctx, checkedAttestations)
+		ret[wantedAttestation.Name] = attestationToPolicyAttestations(ctx, authority, checkedAttestations)
 	}
 	return ret, nil
 }
 
 func ValidatePolicyAttestationsForAuthorityWithBundle(ctx context.Context, ref name.Reference, authority webhookcip.Authority, kc authn.Keychain) (map[string][]PolicyAttestation, error) {
 	// TODO: Apply authority.Source options (Tag prefix, alternative registry, and signature pull secrets)
-	remoteOpts := []remote.Option{
-		remote.WithContext(ctx),
-		remote.WithAuthFromKeychain(kc),
-	}
+	remoteOpts := registryRemoteOptions(ctx, kc)
 
 	trustedMaterial, err := trustedMaterialFromAuthority(ctx, authority)
 	if err != nil {
@@ -1037,32 +1298,44 @@ func ValidatePolicyAttestationsForAuthorityWithBundle(ctx context.Context, ref n
 	return checkPredicates(ctx, authority, verifiedBundles)
 }
 
+// trustedMaterialFromAuthority returns the material of the TrustRoots
+// referenced by the keyless authority, its CTLog and its RFC3161Timestamp.
+// Without a keyless TrustRoot, the Fulcio CAs (and the rest) come from the
+// embedded or cached TUF root.
 func trustedMaterialFromAuthority(ctx context.Context, authority webhookcip.Authority) (sgroot.TrustedMaterial, error) {
-	var trustedMaterial sgroot.TrustedMaterial
-	if authority.Keyless != nil {
-		if authority.Keyless.TrustRootRef != "" {
-			trustRoot, err := sigstoreKeysFromContext(ctx, authority.Keyless.TrustRootRef)
-			if err != nil {
-				return nil, fmt.Errorf("failed to get trusted root from context: %w", err)
-			}
-			if pbTrustedRoot, ok := trustRoot.SigstoreKeys[authority.Keyless.TrustRootRef]; ok {
-				trustedMaterial, err = sgroot.NewTrustedRootFromProtobuf(pbTrustedRoot)
-				if err != nil {
-					return nil, fmt.Errorf("failed to parse trusted root from protobuf: %w", err)
-				}
-				return trustedMaterial, nil
-			} else {
-				return nil, fmt.Errorf("trusted root \"%s\" does not exist", authority.Keyless.TrustRootRef)
-			}
-		}
-		trustedMaterial, err := pctuf.GetTrustedRoot(ctx)
-		if err != nil {
-			return nil, fmt.Errorf("failed to parse trusted root from protobuf: %w", err)
+	if authority.Keyless == nil {
+		return nil, errors.New("no trusted material specified") // TODO: better error message
+	}
+	trustRootRefs := authority.Keyless.GetTrustRootRefs()
+	keylessTrustRoots := len(trustRootRefs) > 0
+	var otherRefs []string
+	if authority.CTLog != nil {
+		otherRefs = append(otherRefs, authority.CTLog.GetTrustRootRefs()...)
+	}
+	if authority.RFC3161Timestamp != nil {
+		otherRefs = append(otherRefs, authority.RFC3161Timestamp.GetTrustRootRefs()...)
+	}
+	for _, ref := range otherRefs {
+		if !slices.Contains(trustRootRefs, ref) {
+			trustRootRefs = append(trustRootRefs, ref)
 		}
+	}
+	if keylessTrustRoots {
+		return trustedMaterialFromTrustRoots(ctx, trustRootRefs)
+	}
 
+	trustedMaterial, err := pctuf.GetTrustedRoot(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse trusted root from protobuf: %w", err)
+	}
+	if len(trustRootRefs) == 0 {
 		return trustedMaterial, nil
 	}
-	return nil, errors.New("no trusted material specified") // TODO: better error message
+	material, err := trustedMaterialFromTrustRoots(ctx, trustRootRefs)
+	if err != nil {
+		return nil, err
+	}
+	return sgroot.TrustedMaterialCollection{trustedMaterial, material}, nil
 }
 
 // ResolvePodScalable implements policyduckv1beta1.PodScalableValidator
@@ -1078,6 +1351,10 @@ func (v *Validator) ResolvePodScalable(ctx context.Context, ps *policyduckv1beta
 		return
 	}
 
+	// The verifications to stamp and to annotate the resource share their
+	// results.
+	ctx = withVerificationResults(ctx)
+
 	imagePullSecrets := make([]string, 0, len(ps.Spec.Template.Spec.ImagePullSecrets))
 	for _, s := range ps.Spec.Template.Spec.ImagePullSecrets {
 		imagePullSecrets = append(imagePullSecrets, s.Name)
@@ -1088,6 +1365,13 @@ func (v *Validator) ResolvePodScalable(ctx context.Context, ps *policyduckv1beta
 		ImagePullSecrets:   imagePullSecrets,
 	}
 	v.resolvePodSpec(ctx, &ps.Spec.Template.Spec, opt)
+	v.stampVerification(ctx, &ps.Spec.Template, opt)
+
+	ctx = IncludeSpec(ctx, ps.Spec)
+	ctx = IncludeObjectMeta(ctx, ps.ObjectMeta)
+	ctx = IncludeTypeMeta(ctx, ps.TypeMeta)
+	v.annotateVerification(ctx, &ps.ObjectMeta, ps.Kind, ps.APIVersion, &ps.Spec.Template.Spec, opt)
+	v.recordVerifiedPolicies(ctx, &ps.ObjectMeta, opt.Namespace, ps.Kind, ps.APIVersion, &ps.Spec.Template.Spec)
 }
 
 // ResolvePodSpecable implements duckv1.PodSpecValidator
@@ -1098,6 +1382,10 @@ func (v *Validator) ResolvePodSpecable(ctx context.Context, wp *duckv1.WithPod)
 		return
 	}
 
+	// The verifications to stamp and to annotate the resource share their
+	// results.
+	ctx = withVerificationResults(ctx)
+
 	imagePullSecrets := make([]string, 0, len(wp.Spec.Template.Spec.ImagePullSecrets))
 	for _, s := range wp.Spec.Template.Spec.ImagePullSecrets {
 		imagePullSecrets = append(imagePullSecrets, s.Name)
@@ -1108,6 +1396,13 @@ func (v *Validator) ResolvePodSpecable(ctx context.Context, wp *duckv1.WithPod)
 		ImagePullSecrets:   imagePullSecrets,
 	}
 	v.resolvePodSpec(ctx, &wp.Spec.Template.Spec, opt)
+	v.stampVerification(ctx, (*corev1.PodTemplateSpec)(&wp.Spec.Template), opt)
+
+	ctx = IncludeSpec(ctx, wp.Spec)
+	ctx = IncludeObjectMeta(ctx, wp.ObjectMeta)
+	ctx = IncludeTypeMeta(ctx, wp.TypeMeta)
+	v.annotateVerification(ctx, &wp.ObjectMeta, wp.Kind, wp.APIVersion, &wp.Spec.Template.Spec, opt)
+	v.recordVerifiedPolicies(ctx, &wp.ObjectMeta, opt.Namespace, wp.Kind, wp.APIVersion, &wp.Spec.Template.Spec)
 }
 
 // ResolvePod implements duckv1.PodValidator
@@ -1127,6 +1422,11 @@ func (v *Validator) ResolvePod(ctx context.Context, p *duckv1.Pod) {
 		ImagePullSecrets:   imagePullSecrets,
 	}
 	v.resolvePodSpec(ctx, &p.Spec, opt)
+
+	ctx = IncludeSpec(ctx, p.Spec)
+	ctx = IncludeObjectMeta(ctx, p.ObjectMeta)
+	v.annotateVerification(ctx, &p.ObjectMeta, p.Kind, p.APIVersion, &p.Spec, opt)
+	v.recordVerifiedPolicies(ctx, &p.ObjectMeta, opt.Namespace, p.Kind, p.APIVersion, &p.Spec)
 }
 
 // ResolveCronJob implements duckv1.CronJobValidator
@@ -1137,6 +1437,10 @@ func (v *Validator) ResolveCronJob(ctx context.Context, c *duckv1.CronJob) {
 		return
 	}
 
+	// The verifications to stamp and to annotate the resource share their
+	// results.
+	ctx = withVerificationResults(ctx)
+
 	imagePullSecrets := make([]string, 0, len(c.Spec.JobTemplate.Spec.Template.Spec.ImagePullSecrets))
 	for _, s := range c.Spec.JobTemplate.Spec.Template.Spec.ImagePullSecrets {
 		imagePullSecrets = append(imagePullSecrets, s.Name)
@@ -1147,6 +1451,13 @@ func (v *Validator) ResolveCronJob(ctx context.Context, c *duckv1.CronJob) {
 		ImagePullSecrets:   imagePullSecrets,
 	}
 	v.resolvePodSpec(ctx, &c.Spec.JobTemplate.Spec.Template.Spec, opt)
+	v.stampVerification(ctx, &c.Spec.JobTemplate.Spec.Template, opt)
+
+	ctx = IncludeSpec(ctx, c.Spec)
+	ctx = IncludeObjectMeta(ctx, c.ObjectMeta)
+	ctx = IncludeTypeMeta(ctx, c.TypeMeta)
+	v.annotateVerification(ctx, &c.ObjectMeta, c.Kind, c.APIVersion, &c.Spec.JobTemplate.Spec.Template.Spec, opt)
+	v.recordVerifiedPolicies(ctx, &c.ObjectMeta, opt.Namespace, c.Kind, c.APIVersion, &c.Spec.JobTemplate.Spec.Template.Spec)
 }
 
 // For testing
@@ -1170,10 +1481,7 @@ func (v *Validator) resolvePodSpec(ctx context.Context, ps *corev1.PodSpec, opt
 			// If we are in the context of a mutating webhook, then resolve the tag to a digest.
 			switch {
 			case apis.IsInCreate(ctx), apis.IsInUpdate(ctx):
-				digest, err := remoteResolveDigest(ref, ociremote.WithRemoteOptions(
-					remote.WithContext(ctx),
-					remote.WithAuthFromKeychain(kc),
-				))
+				digest, err := remoteResolveDigest(ref, ociremote.WithRemoteOptions(registryRemoteOptions(ctx, kc)...))
 				if err != nil {
 					logging.FromContext(ctx).Debugf("Unable to resolve digest %q: %v", ref.String(), err)
 					continue
@@ -1194,10 +1502,7 @@ func (v *Validator) resolvePodSpec(ctx context.Context, ps *corev1.PodSpec, opt
 			// If we are in the context of a mutating webhook, then resolve the tag to a digest.
 			switch {
 			case apis.IsInCreate(ctx), apis.IsInUpdate(ctx):
-				digest, err := remoteResolveDigest(ref, ociremote.WithRemoteOptions(
-					remote.WithContext(ctx),
-					remote.WithAuthFromKeychain(kc),
-				))
+				digest, err := remoteResolveDigest(ref, ociremote.WithRemoteOptions(registryRemoteOptions(ctx, kc)...))
 				if err != nil {
 					logging.FromContext(ctx).Debugf("Unable to resolve digest %q: %v", ref.String(), err)
 					continue
@@ -1207,9 +1512,34 @@ func (v *Validator) resolvePodSpec(ctx context.Context, ps *corev1.PodSpec, opt
 		}
 	}
 
+	resolveImageVolumes := func(vs []corev1.Volume) {
+		for i, vol := range vs {
+			if vol.Image == nil {
+				continue
+			}
+			ref, err := name.ParseReference(vol.Image.Reference)
+			if err != nil {
+				logging.FromContext(ctx).Debugf("Unable to parse reference: %v", err)
+				continue
+			}
+
+			// If we are in the context of a mutating webhook, then resolve the tag to a digest.
+			switch {
+			case apis.IsInCreate(ctx), apis.IsInUpdate(ctx):
+				digest, err := remoteResolveDigest(ref, ociremote.WithRemoteOptions(registryRemoteOptions(ctx, kc)...))
+				if err != nil {
+					logging.FromContext(ctx).Debugf("Unable to resolve digest %q: %v", ref.String(), err)
+					continue
+				}
+				vs[i].Image.Reference = digest.String()
+			}
+		}
+	}
+
 	resolveContainers(ps.InitContainers)
 	resolveContainers(ps.Containers)
 	resolveEphemeralContainers(ps.EphemeralContainers)
+	resolveImageVolumes(ps.Volumes)
 }
 
 // getNamespace tries to extract the namespace from the HTTPRequest
@@ -1241,17 +1571,17 @@ func getNamespace(ctx context.Context, namespace string) string {
 // All the matched policies were validated, or
 // no matching policies were found, but the PolicyControllerConfig has been
 // configured to allow images not matching any policies.
-func (v *Validator) validateContainerImage(ctx context.Context, containerImage string, namespace, field string, index int, kind, apiVersion string, labels map[string]string, kc authn.Keychain, ociRemoteOpts ...ociremote.Option) *apis.FieldError {
+func (v *Validator) validateContainerImage(ctx context.Context, containerImage string, namespace, field string, index int, kind, apiVersion string, labels map[string]string, kc authn.Keychain) *apis.FieldError {
 	ref, err := name.ParseReference(containerImage)
 	if err != nil {
-		return apis.ErrGeneric(err.Error(), "image").ViaFieldIndex(field, index)
+		return apis.ErrGeneric(err.Error(), imageField(field)).ViaFieldIndex(field, index)
 	}
 	config := config.FromContext(ctx)
 
 	if config != nil {
 		policies, err := config.ImagePolicyConfig.GetMatchingPolicies(ref.Name(), kind, apiVersion, labels)
 		if err != nil {
-			errorField := apis.ErrGeneric(err.Error(), "image").ViaFieldIndex(field, index)
+			errorField := apis.ErrGeneric(err.Error(), imageField(field)).ViaFieldIndex(field, index)
 			errorField.Details = containerImage
 			return errorField
 		}
@@ -1259,13 +1589,13 @@ func (v *Validator) validateContainerImage(ctx context.Context, containerImage s
 		// If there is at least one policy that matches, that means it
 		// has to be satisfied.
 		if len(policies) > 0 {
-			signatures, fieldErrors := validatePolicies(ctx, namespace, ref, policies, kc, ociRemoteOpts...)
+			signatures, fieldErrors := validatePolicies(ctx, namespace, ref, policies, kc)
 			if len(signatures) != len(policies) {
 				logging.FromContext(ctx).Warnf("Failed to validate at least one policy for %s wanted %d policies, only validated %d", ref.Name(), len(policies), len(signatures))
 			} else {
 				logging.FromContext(ctx).Infof("Validated %d policies for image %s", len(signatures), containerImage)
 			}
-			return errorsToFieldErrors(containerImage, field, index, fieldErrors)
+			return errorsToFieldErrors(ctx, containerImage, field, index, fieldErrors)
 		}
 		// Container matched no policies, so return based on the configured
 		// NoMatchPolicy.
@@ -1274,40 +1604,52 @@ func (v *Validator) validateContainerImage(ctx context.Context, containerImage s
 	return nil
 }
 
-func errorsToFieldErrors(image, field string, index int, fieldErrors map[string][]error) (errs *apis.FieldError) {
+func errorsToFieldErrors(ctx context.Context, image, field string, index int, fieldErrors map[string][]error) (errs *apis.FieldError) {
 	// Do we really want to add all the error details here?
 	// Seems like we can just say which policy failed, so
 	// doing that for now.
 	// Split the errors and warnings to their own
 	// error levels.
-	hasWarnings := false
-	hasErrors := false
+	jsonDetails := policycontrollerconfig.FromContextOrDefaults(ctx).JSONDenialDetails
 	for failingPolicy, policyErrs := range fieldErrors {
+		hasWarnings := false
+		hasErrors := false
 		errDetails := image
 		warnDetails := image
+		var errFailures, warnFailures []DenialFailure
 		for _, policyErr := range policyErrs {
 			var fe *apis.FieldError
 			if errors.As(policyErr, &fe) {
 				if fe.Filter(apis.WarningLevel) != nil {
 					warnDetails = warnDetails + " " + fe.Message
+					warnFailures = append(warnFailures, newDenialFailure(policyErr))
 					hasWarnings = true
 				} else {
 					errDetails = errDetails + " " + fe.Message
+					errFailures = append(errFailures, newDenialFailure(policyErr))
 					hasErrors = true
 				}
 			} else {
 				// Just a regular error.
 				errDetails = errDetails + " " + policyErr.Error()
+				errFailures = append(errFailures, newDenialFailure(policyErr))
+				hasErrors = true
 			}
 		}
 		if hasWarnings {
-			warnField := apis.ErrGeneric(fmt.Sprintf("failed policy: %s", failingPolicy), "image").ViaFieldIndex(field, index)
+			warnField := apis.ErrGeneric(fmt.Sprintf("failed policy: %s: %s", failingPolicy, denialSummary(warnFailures)), imageField(field)).ViaFieldIndex(field, index)
 			warnField.Details = warnDetails
-			errs = errs.Also(warnField).At(apis.WarningLevel)
+			if jsonDetails {
+				warnField.Details += "\n" + denialDetailJSON(image, failingPolicy, warnFailures)
+			}
+			errs = errs.Also(warnField.At(apis.WarningLevel))
 		}
 		if hasErrors {
-			errorField := apis.ErrGeneric(fmt.Sprintf("failed policy: %s", failingPolicy), "image").ViaFieldIndex(field, index)
+			errorField := apis.ErrGeneric(fmt.Sprintf("failed policy: %s: %s", failingPolicy, denialSummary(errFailures)), imageField(field)).ViaFieldIndex(field, index)
 			errorField.Details = errDetails
+			if jsonDetails {
+				errorField.Details += "\n" + denialDetailJSON(image, failingPolicy, errFailures)
+			}
 			errs = errs.Also(errorField)
 		}
 	}
@@ -1320,12 +1662,12 @@ func errorsToFieldErrors(image, field string, index int, fieldErrors map[string]
 func refOrFieldError(image, field string, index int) *apis.FieldError {
 	ref, err := name.ParseReference(image)
 	if err != nil {
-		return apis.ErrGeneric(err.Error(), "image").ViaFieldIndex(field, index)
+		return apis.ErrGeneric(err.Error(), imageField(field)).ViaFieldIndex(field, index)
 	}
 	if _, ok := ref.(name.Digest); !ok {
 		return apis.ErrInvalidValue(
 			fmt.Sprintf("%s must be an image digest", image),
-			"image",
+			imageField(field),
 		).ViaFieldIndex(field, index)
 	}
 	return nil
@@ -1481,33 +1823,39 @@ func checkOptsFromAuthority(ctx context.Context, authority webhookcip.Authority,
 		}
 	}
 
-	if authority.RFC3161Timestamp != nil && authority.RFC3161Timestamp.TrustRootRef != "" {
+	if authority.RFC3161Timestamp != nil && len(authority.RFC3161Timestamp.GetTrustRootRefs()) > 0 {
 		logging.FromContext(ctx).Debug("Using RFC3161Timestamp...")
 		// TODO: By default, we disable any tlog verification when using the RFC3161Timestamp validation.
 		// There are use cases when the validation is only handled by TSA, and there isn't any TLog involved.
 		ret.IgnoreTlog = true
 
-		sigstoreKeys, err := sigstoreKeysFromContext(ctx, authority.RFC3161Timestamp.TrustRootRef)
-		if err != nil {
-			return nil, err
-		}
-		sk, ok := sigstoreKeys.SigstoreKeys[authority.RFC3161Timestamp.TrustRootRef]
-		if !ok {
-			return nil, fmt.Errorf("trustRootRef %s not found", authority.RFC3161Timestamp.TrustRootRef)
-		}
-		for _, timestampAuthority := range sk.TimestampAuthorities {
-			leaves, intermediates, roots, err := splitPEMCertificateChain(config.SerializeCertChain(timestampAuthority.CertChain)) // TODO: this is less efficient than it could be
+		var tsaCertificates []*x509.Certificate
+		for _, trustRootRef := range authority.RFC3161Timestamp.GetTrustRootRefs() {
+			sigstoreKeys, err := sigstoreKeysFromContext(ctx, trustRootRef)
 			if err != nil {
-				return nil, fmt.Errorf("error splitting certificates: %w", err)
+				return nil, err
 			}
-			if len(leaves) > 1 {
-				return nil, fmt.Errorf("certificate chain must contain at most one TSA certificate")
+			sk, ok := sigstoreKeys.SigstoreKeys[trustRootRef]
+			if !ok {
+				return nil, fmt.Errorf("trustRootRef %s not found", trustRootRef)
 			}
-			if len(leaves) == 1 {
-				ret.TSACertificate = leaves[0]
+			for _, timestampAuthority := range sk.TimestampAuthorities {
+				leaves, intermediates, roots, err := splitPEMCertificateChain(config.SerializeCertChain(timestampAuthority.CertChain)) // TODO: this is less efficient than it could be
+				if err != nil {
+					return nil, fmt.Errorf("error splitting certificates: %w", err)
+				}
+				if len(leaves) > 1 {
+					return nil, fmt.Errorf("certificate chain must contain at most one TSA certificate")
+				}
+				tsaCertificates = append(tsaCertificates, leaves...)
+				ret.TSAIntermediateCertificates = append(ret.TSAIntermediateCertificates, intermediates...)
+				ret.TSARootCertificates = append(ret.TSARootCertificates, roots...)
 			}
-			ret.TSAIntermediateCertificates = intermediates
-			ret.TSARootCertificates = roots
+		}
+		// With the TSAs of several TrustRoots, the TSA certificate is taken
+		// from the timestamps themselves, and verified against the roots.
+		if len(tsaCertificates) == 1 {
+			ret.TSACertificate = tsaCertificates[0]
 		}
 	}
 	return ret, nil
@@ -1529,12 +1877,16 @@ func sigstoreKeysFromContext(ctx context.Context, trustRootRef string) (*config.
 // fulcioCertsFromAuthority gets the necessary Fulcio certificates, this is
 // rootPool and an optional intermediatePool. Additionally fetches the CTLog
 // public keys.
-// Preference is given to TrustRoot if specified, from which the certificates
-// are fetched and returned. If there's no TrustRoot, the certificates are
-// fetched from embedded or cached TUF root.
+// Preference is given to the TrustRoots if specified, from which the
+// certificates are fetched and returned. If there's no TrustRoot, the
+// certificates are fetched from embedded or cached TUF root.
 func fulcioCertsFromAuthority(ctx context.Context, keylessRef *webhookcip.KeylessRef) (*x509.CertPool, *x509.CertPool, *cosign.TrustedTransparencyLogPubKeys, error) {
 	// If this is not Keyless, there's no Fulcio, so just return
-	if keylessRef.TrustRootRef == "" {
+	trustRootRefs := keylessRef.GetTrustRootRefs()
+	if len(trustRootRefs) == 0 {
+		if err := pctuf.PublicGoodError(); err != nil {
+			return nil, nil, nil, err
+		}
 		roots, err := fulcioroots.Get()
 		if err != nil {
 			return nil, nil, nil, fmt.Errorf("failed to fetch Fulcio roots: %w", err)
@@ -1550,45 +1902,42 @@ func fulcioCertsFromAuthority(ctx context.Context, keylessRef *webhookcip.Keyles
 		return roots, intermediates, ctPubs, nil
 	}
 
-	// There's TrustRootRef, so fetch it
-	trustRootRef := keylessRef.TrustRootRef
-	sigstoreKeys, err := sigstoreKeysFromContext(ctx, trustRootRef)
-	if err != nil {
-		return nil, nil, nil, fmt.Errorf("getting SigstoreKeys: %w", err)
-	}
+	// There are TrustRootRefs, so fetch them and trust the certificates and
+	// CTLogs of all of them.
 	rootCertsPool := x509.NewCertPool()
 	intermediateCertsPool := x509.NewCertPool()
-
-	sk, ok := sigstoreKeys.SigstoreKeys[trustRootRef]
-	if !ok {
-		return nil, nil, nil, fmt.Errorf("trustRootRef %s not found", trustRootRef)
+	ctlogKeys := &cosign.TrustedTransparencyLogPubKeys{
+		Keys: make(map[string]cosign.TransparencyLogPubKey),
 	}
-	for _, ca := range sk.CertificateAuthorities {
-		certs, err := cryptoutils.UnmarshalCertificatesFromPEM(config.SerializeCertChain(ca.CertChain)) // TODO: this is less efficient than it could be
+	for _, trustRootRef := range trustRootRefs {
+		sk, err := sigstoreKeysForTrustRoot(ctx, trustRootRef)
 		if err != nil {
-			return nil, nil, nil, fmt.Errorf("error unmarshalling certificates: %w", err)
+			return nil, nil, nil, err
 		}
-		for _, cert := range certs {
-			// root certificates are self-signed
-			if bytes.Equal(cert.RawSubject, cert.RawIssuer) {
-				rootCertsPool.AddCert(cert)
-			} else {
-				intermediateCertsPool.AddCert(cert)
+		for _, ca := range sk.CertificateAuthorities {
+			certs, err := cryptoutils.UnmarshalCertificatesFromPEM(config.SerializeCertChain(ca.CertChain)) // TODO: this is less efficient than it could be
+			if err != nil {
+				return nil, nil, nil, fmt.Errorf("error unmarshalling certificates: %w", err)
+			}
+			for _, cert := range certs {
+				// root certificates are self-signed
+				if bytes.Equal(cert.RawSubject, cert.RawIssuer) {
+					rootCertsPool.AddCert(cert)
+				} else {
+					intermediateCertsPool.AddCert(cert)
+				}
 			}
 		}
-	}
 
-	ctlogKeys := &cosign.TrustedTransparencyLogPubKeys{
-		Keys: make(map[string]cosign.TransparencyLogPubKey, len(sk.Ctlogs)),
-	}
-	for i, ctlog := range sk.Ctlogs {
-		pk, err := cryptoutils.UnmarshalPEMToPublicKey(config.SerializePublicKey(ctlog.PublicKey)) // TODO: this is less efficient than it could be
-		if err != nil {
-			return nil, nil, nil, fmt.Errorf("unmarshaling public key %d failed: %w", i, err)
-		}
-		ctlogKeys.Keys[string(ctlog.LogId.KeyId)] = cosign.TransparencyLogPubKey{
-			PubKey: pk,
-			Status: tuf.Active,
+		for i, ctlog := range sk.Ctlogs {
+			pk, err := cryptoutils.UnmarshalPEMToPublicKey(config.SerializePublicKey(ctlog.PublicKey)) // TODO: this is less efficient than it could be
+			if err != nil {
+				return nil, nil, nil, fmt.Errorf("unmarshaling public key %d failed: %w", i, err)
+			}
+			ctlogKeys.Keys[string(ctlog.LogId.KeyId)] = cosign.TransparencyLogPubKey{
+				PubKey: pk,
+				Status: tuf.Active,
+			}
 		}
 	}
 	if len(ctlogKeys.Keys) == 0 {
@@ -1603,12 +1952,15 @@ func fulcioCertsFromAuthority(ctx context.Context, keylessRef *webhookcip.Keyles
 // and public keys to go with it.
 // Note that if Rekor is not specified, it's not an error and nil will be
 // returned for it.
-// Preference is given to TrustRoot if specified, from which the URL and public
-// keys are fetched and returned. If there's no TrustRoot but a URL, then
+// Preference is given to the TrustRoots if specified, from which the URL and
+// public keys are fetched and returned. If there's no TrustRoot but a URL, then
 // a Rekor client is returned and the keys from the embedded or cached TUF root.
 func rekorClientAndKeysFromAuthority(ctx context.Context, authority webhookcip.Authority) (*client.Rekor, *cosign.TrustedTransparencyLogPubKeys, error) {
 	// In keyless, if no TrustRoot was defined and CTLog is nil, then default to rekor pub keys as done in cosign
-	if authority.Keyless != nil && authority.Keyless.TrustRootRef == "" && authority.CTLog == nil {
+	if authority.Keyless != nil && len(authority.Keyless.GetTrustRootRefs()) == 0 && authority.CTLog == nil {
+		if err := pctuf.PublicGoodError(); err != nil {
+			return nil, nil, err
+		}
 		rekorPubKeys, err := cosign.GetRekorPubs(ctx)
 		if err != nil {
 			logging.FromContext(ctx).Errorf("failed getting rekor public keys: %v", err)
@@ -1620,11 +1972,31 @@ func rekorClientAndKeysFromAuthority(ctx context.Context, authority webhookcip.A
 	if tlog == nil {
 		return nil, nil, nil
 	}
-	if tlog.TrustRootRef != "" {
-		trustRootRef := tlog.TrustRootRef
-		rekorPubKeys, rekorURL, err := rekorKeysFromTrustRef(ctx, trustRootRef)
-		if err != nil {
-			return nil, nil, fmt.Errorf("fetching keys for trustRootRef: %w", err)
+	if trustRootRefs := tlog.GetTrustRootRefs(); len(trustRootRefs) > 0 {
+		// Trust the keys of all the TrustRoots, and use the URL of the first
+		// one that has one.
+		rekorPubKeys := &cosign.TrustedTransparencyLogPubKeys{
+			Keys: make(map[string]cosign.TransparencyLogPubKey),
+		}
+		rekorURL := ""
+		var noECDSAErr error
+		for _, trustRootRef := range trustRootRefs {
+			keys, keysURL, err := rekorKeysFromTrustRef(ctx, trustRootRef)
+			if errors.Is(err, errNoECDSARekorKeys) {
+				// The keys of the other TrustRoots may do.
+				noECDSAErr = err
+				continue
+			}
+			if err != nil {
+				return nil, nil, fmt.Errorf("fetching keys for trustRootRef: %w", err)
+			}
+			maps.Copy(rekorPubKeys.Keys, keys.Keys)
+			if rekorURL == "" {
+				rekorURL = keysURL
+			}
+		}
+		if len(rekorPubKeys.Keys) == 0 && noECDSAErr != nil {
+			return nil, nil, fmt.Errorf("fetching keys for trustRootRef: %w", noECDSAErr)
 		}
 		if rekorURL == "" && tlog.URL != nil {
 			// Pull this from the tlog entry in this case.
@@ -1648,6 +2020,9 @@ func rekorClientAndKeysFromAuthority(ctx context.Context, authority webhookcip.A
 		logging.FromContext(ctx).Errorf("failed creating rekor client: %v", err)
 		return nil, nil, fmt.Errorf("creating Rekor client: %w", err)
 	}
+	if err := pctuf.PublicGoodError(); err != nil {
+		return nil, nil, err
+	}
 	rekorPubKeys, err := cosign.GetRekorPubs(ctx)
 	if err != nil {
 		logging.FromContext(ctx).Errorf("failed getting rekor public keys: %v", err)
@@ -1656,6 +2031,10 @@ func rekorClientAndKeysFromAuthority(ctx context.Context, authority webhookcip.A
 	return rekorClient, rekorPubKeys, nil
 }
 
+// errNoECDSARekorKeys is returned (wrapped) by rekorKeysFromTrustRef for the
+// TrustRoots whose Rekor keys are all unsupported by cosign.
+var errNoECDSARekorKeys = errors.New("no ecdsa rekor public keys")
+
 func rekorKeysFromTrustRef(ctx context.Context, trustRootRef string) (*cosign.TrustedTransparencyLogPubKeys, string, error) {
 	sigstoreKeys, err := sigstoreKeysFromContext(ctx, trustRootRef)
 	if err != nil {
@@ -1672,17 +2051,23 @@ func rekorKeysFromTrustRef(ctx context.Context, trustRootRef string) (*cosign.Tr
 			if err != nil {
 				return nil, "", fmt.Errorf("unmarshaling public key %d failed: %w", i, err)
 			}
-			// This needs to be ecdsa instead of crypto.PublicKey
+			rekorURL = tlog.BaseUrl
+			// cosign only verifies the Rekor entries with ecdsa keys, and
+			// fails if any other key is trusted, so leave them to the
+			// bundle verification.
 			// https://github.com/sigstore/cosign/issues/2540
 			pkecdsa, ok := pk.(*ecdsa.PublicKey)
 			if !ok {
-				return nil, "", fmt.Errorf("public key %d is not ecdsa.PublicKey", i)
+				logging.FromContext(ctx).Warnf("skipping the %T rekor public key %d of trustRootRef %s, only ecdsa keys are supported without signatureFormat bundle", pk, i, trustRootRef)
+				continue
 			}
 			retKeys.Keys[string(tlog.LogId.KeyId)] = cosign.TransparencyLogPubKey{
 				PubKey: pkecdsa,
 				Status: tuf.Active,
 			}
-			rekorURL = tlog.BaseUrl
+		}
+		if len(retKeys.Keys) == 0 && len(sk.Tlogs) > 0 {
+			return nil, "", fmt.Errorf("trustRootRef %s has %w, which are required unless signatureFormat is bundle", trustRootRef, errNoECDSARekorKeys)
 		}
 		return retKeys, rekorURL, nil
 	}
@@ -0,0 +1,329 @@
+// Copyright 2022 The Sigstore Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package webhook
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"sort"
+	"strings"
+
+	"github.com/google/go-containerregistry/pkg/v1/remote/transport"
+	"github.com/sigstore/cosign/v2/pkg/cosign"
+	"github.com/sigstore/cosign/v2/pkg/policy"
+	webhookcip "github.com/sigstore/policy-controller/pkg/webhook/clusterimagepolicy"
+	"knative.dev/pkg/apis"
+)
+
+// Reason classifies why an image did not satisfy a ClusterImagePolicy.
+type Reason string
+
+const (
+	// ReasonMissingSignature means that no signatures or attestations were
+	// found for the image.
+	ReasonMissingSignature Reason = "MissingSignature"
+	// ReasonWrongIdentity means that signatures were found, but none of them
+	// was made by the expected identity or key.
+	ReasonWrongIdentity Reason = "WrongIdentity"
+	// ReasonUntrustedRoot means that the certificates, transparency log
+	// entries or timestamps could not be verified against the trusted roots.
+	ReasonUntrustedRoot Reason = "UntrustedRoot"
+	// ReasonRegistryAuth means that the registry denied access to the image
+	// or its signatures.
+	ReasonRegistryAuth Reason = "RegistryAuth"
+	// ReasonPolicyEvaluation means that an attestation policy, the CIP level
+	// policy or a static authority rejected the image.
+	ReasonPolicyEvaluation Reason = "PolicyEvaluation"
+	// ReasonInfrastructure means that the image could not be verified
+	// because of an infrastructure failure, e.g. the registry or the
+	// transparency log being unavailable, or a timeout. Unlike the other
+	// reasons, this says nothing about the image itself.
+	ReasonInfrastructure Reason = "Infrastructure"
+	// ReasonUnknown is used for errors that do not fall in any of the above.
+	ReasonUnknown Reason = "Unknown"
+)
+
+// summary returns a short human readable description of the Reason.
+func (r Reason) summary() string {
+	switch r {
+	case ReasonMissingSignature:
+		return "missing signature"
+	case ReasonWrongIdentity:
+		return "wrong identity"
+	case ReasonUntrustedRoot:
+		return "untrusted root"
+	case ReasonRegistryAuth:
+		return "registry authentication failed"
+	case ReasonPolicyEvaluation:
+		return "policy evaluation failed"
+	case ReasonInfrastructure:
+		return "infrastructure failure"
+	default:
+		return "verification failed"
+	}
+}
+
+// PolicyError is an error encountered while validating an image against a
+// ClusterImagePolicy, along with its classification. It wraps the
+// FieldError that carries its level (error or warning), as well as the
+// original error.
+type PolicyError struct {
+	// Authority is the name of the authority that failed, or empty if the
+	// error is not specific to an authority (e.g. the CIP level policy).
+	Authority string
+	// Reason is the classification of the error.
+	Reason Reason
+	// FailedOpen is true if the error is an infrastructure failure that was
+	// converted to a warning because the policy has InfraFailureMode warn.
+	FailedOpen bool
+
+	fe  *apis.FieldError
+	err error
+}
+
+// newPolicyError classifies err and wraps it as a PolicyError, converted to a
+// warning if the cip is in warn mode, or if it is an infrastructure failure
+// and the cip fails open on those.
+func newPolicyError(cip webhookcip.ClusterImagePolicy, authority string, err error) *PolicyError {
+	reason := classifyError(err)
+	// Only the infrastructure failures that are known by their type fail
+	// open, so that no error can be let through by its message alone.
+	failOpen := reason == ReasonInfrastructure && isInfrastructureError(err) && cip.InfraFailureMode == "warn" && cip.Mode != "warn"
+	pe := newPolicyErrorWithReason(cip.Mode == "warn" || failOpen, authority, reason, err)
+	pe.FailedOpen = failOpen
+	return pe
+}
+
+func newPolicyErrorWithReason(warn bool, authority string, reason Reason, err error) *PolicyError {
+	return &PolicyError{
+		Authority: authority,
+		Reason:    reason,
+		fe:        asFieldError(warn, err),
+		err:       err,
+	}
+}
+
+func (e *PolicyError) Error() string {
+	return e.fe.Error()
+}
+
+func (e *PolicyError) Unwrap() []error {
+	return []error{e.fe, e.err}
+}
+
+var (
+	// untrustedRootMessages are fragments of the errors returned when
+	// the trust material does not verify the signatures.
+	untrustedRootMessages = []string{
+		"certificate signed by unknown authority",
+		"cert verification failed",
+		"unable to verify SET",
+		"no valid tlog entries found",
+		"rekor log public key not found",
+		"ctfe public key not found",
+		"error verifying SCT",
+		"error verifying embedded SCT",
+		"certificate does not include required embedded SCT",
+		"unable to verify RFC3161 timestamp",
+		"certificate expired before signatures were entered in log",
+		"certificate was issued after signatures were entered in log",
+		"expected a signed timestamp to verify an expired certificate",
+	}
+	// infrastructureMessages are fragments of the errors returned when a
+	// remote service can not be reached or fails, for the cases where the
+	// error is not wrapped and so can not be checked by type. They are only
+	// used to report the reason, never to fail open.
+	infrastructureMessages = []string{
+		"context deadline exceeded",
+		"context canceled",
+		"i/o timeout",
+		"connection refused",
+		"connection reset by peer",
+		"no such host",
+		"TLS handshake timeout",
+		"server misbehaving",
+		"500 Internal Server Error",
+		"502 Bad Gateway",
+		"503 Service Unavailable",
+		"504 Gateway Timeout",
+		"circuit breaker is open for registry",
+		"waiting for the rate limit of registry",
+	}
+	// wrongIdentityMessages are fragments of the errors returned when
+	// signatures are found but not from the expected signer.
+	wrongIdentityMessages = []string{
+		"none of the expected identities matched",
+		"no matching CertificateIdentity",
+		"expected GitHub Workflow",
+		"invalid signature when validating ASN.1 encoded signature",
+		"failed to verify signature",
+	}
+	// missingSignatureMessages are fragments of the errors returned when
+	// there is nothing to verify.
+	missingSignatureMessages = []string{
+		"no signatures found",
+		"no valid attestations found",
+		"no verified bundles found",
+		cosign.ErrNoMatchingAttestationsMessage,
+	}
+)
+
+// classifyError returns the Reason for an error returned while validating an
+// authority. cosign does not type most of its verification errors, so some
+// of them are classified by their message. Verification failures take
+// precedence over infrastructure failures, so that an image that is known to
+// be bad is never let through by a policy that fails open.
+func classifyError(err error) Reason {
+	var terr *transport.Error
+	if errors.As(err, &terr) {
+		if terr.StatusCode == http.StatusUnauthorized || terr.StatusCode == http.StatusForbidden {
+			return ReasonRegistryAuth
+		}
+		for _, d := range terr.Errors {
+			if d.Code == transport.UnauthorizedErrorCode || d.Code == transport.DeniedErrorCode {
+				return ReasonRegistryAuth
+			}
+		}
+	}
+	var evalErr *policy.EvaluationFailure
+	var staticErr *cosign.VerificationError
+	if errors.As(err, &evalErr) || errors.As(err, &staticErr) {
+		return ReasonPolicyEvaluation
+	}
+
+	msg := err.Error()
+	switch {
+	case containsAny(msg, untrustedRootMessages):
+		return ReasonUntrustedRoot
+	case containsAny(msg, wrongIdentityMessages):
+		return ReasonWrongIdentity
+	case isInfrastructureError(err), containsAny(msg, infrastructureMessages):
+		return ReasonInfrastructure
+	}
+
+	var noSigs *cosign.ErrNoSignaturesFound
+	var noTag *cosign.ErrImageTagNotFound
+	var noMatch *cosign.ErrNoMatchingSignatures
+	switch {
+	case errors.As(err, &noSigs), errors.As(err, &noTag), containsAny(msg, missingSignatureMessages):
+		return ReasonMissingSignature
+	case errors.As(err, &noMatch):
+		// There were signatures, but none of them verified.
+		return ReasonWrongIdentity
+	}
+	return ReasonUnknown
+}
+
+// isInfrastructureError returns true if err is known by its type to be a
+// failure to reach or use a remote service, rather than a verification
+// failure.
+func isInfrastructureError(err error) bool {
+	if errors.Is(err, context.DeadlineExceeded) || errors.Is(err, context.Canceled) {
+		return true
+	}
+	var terr *transport.Error
+	if errors.As(err, &terr) && (terr.StatusCode >= http.StatusInternalServerError || terr.StatusCode == http.StatusTooManyRequests) {
+		return true
+	}
+	var netErr net.Error
+	var circuitErr *circuitOpenError
+	return errors.As(err, &netErr) || errors.As(err, &circuitErr)
+}
+
+// hasInfrastructureError returns true if any of the errors is a PolicyError
+// caused by an infrastructure failure.
+func hasInfrastructureError(errs []error) bool {
+	for _, err := range errs {
+		var pe *PolicyError
+		if errors.As(err, &pe) && pe.Reason == ReasonInfrastructure {
+			return true
+		}
+	}
+	return false
+}
+
+func containsAny(s string, fragments []string) bool {
+	for _, f := range fragments {
+		if strings.Contains(s, f) {
+			return true
+		}
+	}
+	return false
+}
+
+// DenialDetail is the machine readable description of why an image failed a
+// ClusterImagePolicy. It is included in the response when the
+// PolicyControllerConfig has JSONDenialDetails set.
+type DenialDetail struct {
+	Image    string          `json:"image"`
+	Policy   string          `json:"policy"`
+	Failures []DenialFailure `json:"failures"`
+}
+
+// DenialFailure describes one of the failures of a DenialDetail.
+type DenialFailure struct {
+	Authority  string `json:"authority,omitempty"`
+	Reason     Reason `json:"reason"`
+	FailedOpen bool   `json:"failedOpen,omitempty"`
+	Message    string `json:"message"`
+}
+
+// newDenialFailure returns the DenialFailure for an error. Errors that are
+// not PolicyErrors are classified on the fly.
+func newDenialFailure(err error) DenialFailure {
+	var pe *PolicyError
+	if errors.As(err, &pe) {
+		return DenialFailure{Authority: pe.Authority, Reason: pe.Reason, FailedOpen: pe.FailedOpen, Message: pe.err.Error()}
+	}
+	return DenialFailure{Reason: classifyError(err), Message: err.Error()}
+}
+
+// denialSummary returns a concise description of the failures, naming the
+// failing authorities, e.g. "authority-0: missing signature, authority-1:
+// untrusted root".
+func denialSummary(failures []DenialFailure) string {
+	seen := make(map[string]struct{}, len(failures))
+	parts := make([]string, 0, len(failures))
+	for _, f := range failures {
+		part := f.Reason.summary()
+		if f.FailedOpen {
+			part += " (failed open)"
+		}
+		if f.Authority != "" {
+			part = fmt.Sprintf("%s: %s", f.Authority, part)
+		}
+		if _, ok := seen[part]; ok {
+			continue
+		}
+		seen[part] = struct{}{}
+		parts = append(parts, part)
+	}
+	sort.Strings(parts)
+	return strings.Join(parts, ", ")
+}
+
+// denialDetailJSON returns the JSON encoding of the DenialDetail, or "" if it
+// can not be encoded.
+func denialDetailJSON(image, policyName string, failures []DenialFailure) string {
+	b, err := json.Marshal(DenialDetail{Image: image, Policy: policyName, Failures: failures})
+	if err != nil {
+		return ""
+	}
+	return string(b)
+}
@@ -69,6 +69,12 @@ type PolicyResult struct {
 	// This field is only available for evaluation if
 	// CIP.Spec.Policy.IncludeTypeMeta is set to true.
 	TypeMeta interface{} `json:"typemeta,omitempty"`
+
+	// ImageVolume is true if the image was evaluated as the reference of an
+	// image volume (volumes[].image.reference) rather than as the image of a
+	// container, so that the policy can treat the OCI artifacts mounted into
+	// pods differently.
+	ImageVolume bool `json:"imageVolume,omitempty"`
 }
 
 // AuthorityMatch returns either Signatures (if there are no Attestations
@@ -99,6 +105,15 @@ type PolicySignature struct {
 	Subject string `json:"subject,omitempty"`
 	// Issure that was found to match on the Cert.
 	Issuer string `json:"issuer,omitempty"`
+	// KeyID identifies the public key that verified the signature, for
+	// key-based authorities. It is the hex encoded SHA256 of the DER encoded
+	// public key.
+	KeyID string `json:"keyId,omitempty"`
+	// TrustRootRef is the TrustRoot of the authority that satisfied the
+	// match, when the authority refers to TrustRoots: the one whose CA
+	// issued the Cert, or else the one whose transparency log integrated the
+	// signature.
+	TrustRootRef string `json:"trustRootRef,omitempty"`
 
 	// GithubExtensions holds the Github-related OID extensions.
 	// See also: https://github.com/sigstore/fulcio/blob/main/docs/oid-info.md
@@ -20,35 +20,49 @@ import (
 	"context"
 	"crypto"
 	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/rand"
 	"crypto/x509"
 	"encoding/base64"
 	"encoding/json"
 	"errors"
 	"fmt"
+	"net"
 	"net/http"
 	"net/http/httptest"
+	"net/url"
 	"os"
+	"sort"
 	"strings"
+	"sync"
+	"sync/atomic"
 	"testing"
 	"time"
 
 	"github.com/google/go-cmp/cmp"
 	"github.com/google/go-containerregistry/pkg/authn/k8schain"
 	"github.com/google/go-containerregistry/pkg/name"
+	ggcrremote "github.com/google/go-containerregistry/pkg/v1/remote"
+	"github.com/google/go-containerregistry/pkg/v1/remote/transport"
 	"github.com/sigstore/cosign/v2/pkg/cosign"
 	"github.com/sigstore/cosign/v2/pkg/cosign/bundle"
 	"github.com/sigstore/cosign/v2/pkg/oci"
 	"github.com/sigstore/cosign/v2/pkg/oci/remote"
 	"github.com/sigstore/cosign/v2/pkg/oci/static"
+	"github.com/sigstore/cosign/v2/pkg/policy"
 	"github.com/sigstore/policy-controller/pkg/apis/config"
 	policyduckv1beta1 "github.com/sigstore/policy-controller/pkg/apis/duck/v1beta1"
 	"github.com/sigstore/policy-controller/pkg/apis/policy/v1alpha1"
 	"github.com/sigstore/policy-controller/pkg/apis/signaturealgo"
 	policycontrollerconfig "github.com/sigstore/policy-controller/pkg/config"
 	webhookcip "github.com/sigstore/policy-controller/pkg/webhook/clusterimagepolicy"
+	"github.com/sigstore/policy-controller/test"
 	"github.com/sigstore/sigstore/pkg/cryptoutils"
 	"github.com/sigstore/sigstore/pkg/fulcioroots"
 	"github.com/sigstore/sigstore/pkg/tuf"
+	"go.opencensus.io/stats/view"
+	"golang.org/x/sync/singleflight"
+	"google.golang.org/protobuf/proto"
 	admissionv1 "k8s.io/api/admission/v1"
 	batchv1 "k8s.io/api/batch/v1"
 	corev1 "k8s.io/api/core/v1"
@@ -59,6 +73,8 @@ import (
 	duckv1 "knative.dev/pkg/apis/duck/v1"
 	kubeclient "knative.dev/pkg/client/injection/kube/client"
 	fakekube "knative.dev/pkg/client/injection/kube/client/fake"
+	fakesecret "knative.dev/pkg/injection/clients/namespacedkube/informers/core/v1/secret/fake"
+	"knative.dev/pkg/metrics"
 	"knative.dev/pkg/ptr"
 	rtesting "knative.dev/pkg/reconciler/testing"
 	"knative.dev/pkg/system"
@@ -74,6 +90,9 @@ MFkwEwYHKoZIzj0CAQYIKoZIzj0DAQcDQgAENAyijLvRu5QpCPp2uOj8C79ZW1VJ
 SID/4H61ZiRzN4nqONzp+ZF22qQTk3MFO3D0/ZKmWHAosIf2pf2GHH7myA==
 -----END PUBLIC KEY-----`
 
+	// authorityKeyCosignPubID is the key ID of authorityKeyCosignPubString.
+	authorityKeyCosignPubID = "f990fa0dfed04862fe6dd3f67fcd62c8b8f9fefd47ad25d234f6d1d9d72ee54e"
+
 	certChain = `-----BEGIN CERTIFICATE-----
 MIIBzDCCAXKgAwIBAgIUfyGKDoFa7y6s/W1p1CiTmBRs1eAwCgYIKoZIzj0EAwIw
 MDEOMAwGA1UEChMFbG9jYWwxHjAcBgNVBAMTFVRlc3QgVFNBIEludGVybWVkaWF0
@@ -363,10 +382,10 @@ func TestValidatePodSpec(t *testing.T) {
 		),
 		want: func() *apis.FieldError {
 			var errs *apis.FieldError
-			fe := apis.ErrGeneric("failed policy: cluster-image-policy-keyless", "image").ViaFieldIndex("containers", 0)
+			fe := apis.ErrGeneric("failed policy: cluster-image-policy-keyless: verification failed", "image").ViaFieldIndex("containers", 0)
 			fe.Details = fmt.Sprintf("%s %s", digest.String(), `signature keyless validation failed for authority  for gcr.io/distroless/static@sha256:be5d77c62dbe7fedfb0a4e5ec2f91078080800ab1f18358e5f31fcc8faa023c4: bad signature`)
 			errs = errs.Also(fe)
-			fe2 := apis.ErrGeneric("failed policy: cluster-image-policy-keyless", "image").ViaFieldIndex("initContainers", 0)
+			fe2 := apis.ErrGeneric("failed policy: cluster-image-policy-keyless: verification failed", "image").ViaFieldIndex("initContainers", 0)
 			fe2.Details = fe.Details
 			errs = errs.Also(fe2)
 			return errs
@@ -406,10 +425,10 @@ func TestValidatePodSpec(t *testing.T) {
 		),
 		want: func() *apis.FieldError {
 			var errs *apis.FieldError
-			fe := apis.ErrGeneric("failed policy: cluster-image-policy-keyless", "image").ViaFieldIndex("initContainers", 0)
+			fe := apis.ErrGeneric("failed policy: cluster-image-policy-keyless: verification failed", "image").ViaFieldIndex("initContainers", 0)
 			fe.Details = fmt.Sprintf("%s signature keyless validation failed for authority  for %s: bad signature", digest.String(), digest.Name())
 			errs = errs.Also(fe)
-			fe2 := apis.ErrGeneric("failed policy: cluster-image-policy-keyless", "image").ViaFieldIndex("containers", 0)
+			fe2 := apis.ErrGeneric("failed policy: cluster-image-policy-keyless: verification failed", "image").ViaFieldIndex("containers", 0)
 			fe2.Details = fmt.Sprintf("%s signature keyless validation failed for authority  for %s: bad signature", digest.String(), digest.Name())
 			errs = errs.Also(fe2)
 			return errs
@@ -454,10 +473,10 @@ func TestValidatePodSpec(t *testing.T) {
 		),
 		want: func() *apis.FieldError {
 			var errs *apis.FieldError
-			fe := apis.ErrGeneric("failed policy: cluster-image-policy-keyless-bad-cip", "image").ViaFieldIndex("initContainers", 0)
+			fe := apis.ErrGeneric("failed policy: cluster-image-policy-keyless-bad-cip: policy evaluation failed", "image").ViaFieldIndex("initContainers", 0)
 			fe.Details = fmt.Sprintf("%s failed evaluating cue policy for ClusterImagePolicy: failed to compile the cue policy with error: string literal not terminated", digest.String())
 			errs = errs.Also(fe)
-			fe2 := apis.ErrGeneric("failed policy: cluster-image-policy-keyless-bad-cip", "image").ViaFieldIndex("containers", 0)
+			fe2 := apis.ErrGeneric("failed policy: cluster-image-policy-keyless-bad-cip: policy evaluation failed", "image").ViaFieldIndex("containers", 0)
 			fe2.Details = fmt.Sprintf("%s failed evaluating cue policy for ClusterImagePolicy: failed to compile the cue policy with error: string literal not terminated", digest.String())
 			errs = errs.Also(fe2)
 			return errs
@@ -533,10 +552,10 @@ func TestValidatePodSpec(t *testing.T) {
 		),
 		want: func() *apis.FieldError {
 			var errs *apis.FieldError
-			fe := apis.ErrGeneric("failed policy: cluster-image-policy-keyless", "image").ViaFieldIndex("initContainers", 0)
+			fe := apis.ErrGeneric("failed policy: cluster-image-policy-keyless: verification failed", "image").ViaFieldIndex("initContainers", 0)
 			fe.Details = fmt.Sprintf("%s signature keyless validation failed for authority  for %s: bad signature", digest.String(), digest.Name())
 			errs = errs.Also(fe)
-			fe2 := apis.ErrGeneric("failed policy: cluster-image-policy-keyless", "image").ViaFieldIndex("containers", 0)
+			fe2 := apis.ErrGeneric("failed policy: cluster-image-policy-keyless: verification failed", "image").ViaFieldIndex("containers", 0)
 			fe2.Details = fmt.Sprintf("%s signature keyless validation failed for authority  for %s: bad signature", digest.String(), digest.Name())
 			errs = errs.Also(fe2)
 			return errs
@@ -582,13 +601,13 @@ func TestValidatePodSpec(t *testing.T) {
 		),
 		want: func() *apis.FieldError {
 			var errs *apis.FieldError
-			fe := apis.ErrGeneric("failed policy: cluster-image-policy-keyless", "image").ViaFieldIndex("initContainers", 0)
+			fe := apis.ErrGeneric("failed policy: cluster-image-policy-keyless: verification failed", "image").ViaFieldIndex("initContainers", 0)
 			fe.Details = fmt.Sprintf("%s signature keyless validation failed for authority  for %s: bad signature", digest.String(), digest.Name())
 			errs = errs.Also(fe)
-			fe2 := apis.ErrGeneric("failed policy: cluster-image-policy-keyless", "image").ViaFieldIndex("containers", 0)
+			fe2 := apis.ErrGeneric("failed policy: cluster-image-policy-keyless: verification failed", "image").ViaFieldIndex("containers", 0)
 			fe2.Details = fmt.Sprintf("%s signature keyless validation failed for authority  for %s: bad signature", digest.String(), digest.Name())
 			errs = errs.Also(fe2)
-			fe3 := apis.ErrGeneric("failed policy: cluster-image-policy-keyless", "image").ViaFieldIndex("containers", 1)
+			fe3 := apis.ErrGeneric("failed policy: cluster-image-policy-keyless: verification failed", "image").ViaFieldIndex("containers", 1)
 			fe3.Details = fmt.Sprintf("%s signature keyless validation failed for authority  for %s: bad signature", digestNewer.String(), digestNewer.Name())
 			errs = errs.Also(fe3)
 			return errs
@@ -1107,6 +1126,44 @@ func TestResolvePodSpec(t *testing.T) {
 		},
 		wc:  apis.WithinCreate,
 		rrd: resolve,
+	}, {
+		name: "image volume digests resolve (in create)",
+		ps: &corev1.PodSpec{
+			Containers: []corev1.Container{{
+				Name:  "user-container",
+				Image: digest.String(),
+			}},
+			Volumes: []corev1.Volume{{
+				Name: "config",
+				VolumeSource: corev1.VolumeSource{
+					ConfigMap: &corev1.ConfigMapVolumeSource{},
+				},
+			}, {
+				Name: "artifact",
+				VolumeSource: corev1.VolumeSource{
+					Image: &corev1.ImageVolumeSource{Reference: tag.String()},
+				},
+			}},
+		},
+		want: &corev1.PodSpec{
+			Containers: []corev1.Container{{
+				Name:  "user-container",
+				Image: digest.String(),
+			}},
+			Volumes: []corev1.Volume{{
+				Name: "config",
+				VolumeSource: corev1.VolumeSource{
+					ConfigMap: &corev1.ConfigMapVolumeSource{},
+				},
+			}, {
+				Name: "artifact",
+				VolumeSource: corev1.VolumeSource{
+					Image: &corev1.ImageVolumeSource{Reference: digest.String()},
+				},
+			}},
+		},
+		wc:  apis.WithinCreate,
+		rrd: resolve,
 	}}
 
 	for _, test := range tests {
@@ -1505,265 +1562,948 @@ func TestResolveCronJob(t *testing.T) {
 	}
 }
 
-func TestValidatePolicy(t *testing.T) {
+func TestResolvePodAnnotateVerification(t *testing.T) {
 	// Resolved via crane digest on 2021/09/25
 	digest := name.MustParseReference("gcr.io/distroless/static:nonroot@sha256:be5d77c62dbe7fedfb0a4e5ec2f91078080800ab1f18358e5f31fcc8faa023c4")
 
-	// Non-existent URL for testing complete failure
-	badURL := apis.HTTP("http://example.com/")
-	t.Logf("badURL: %s", badURL.String())
-
-	// Spin up a Fulcio that responds with a Root Cert
-	fulcioServer := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, _ *http.Request) {
-		rw.Write([]byte(fulcioRootCert))
-	}))
-	t.Cleanup(fulcioServer.Close)
-
-	fulcioURL, err := apis.ParseURL("https://fulcio.sigstore.dev")
-	if err != nil {
-		t.Fatalf("Failed to parse fake Fulcio URL")
-	}
-
-	rekorServer := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, _ *http.Request) {
-		rw.Write([]byte(rekorResponse))
-	}))
-	t.Cleanup(rekorServer.Close)
-	rekorURL, err := apis.ParseURL(rekorServer.URL)
-	if err != nil {
-		t.Fatalf("Failed to parse fake Rekor URL")
-	}
-	t.Logf("rekorURL: %s", rekorURL.String())
-	var authorityKeyCosignPub *ecdsa.PublicKey
+	ctx, _ := rtesting.SetupFakeContext(t)
+	kc := fakekube.Get(ctx)
+	kc.CoreV1().ServiceAccounts("default").Create(ctx, &corev1.ServiceAccount{
+		ObjectMeta: metav1.ObjectMeta{
+			Name: "default",
+		},
+	}, metav1.CreateOptions{})
 
+	var authorityKeyCosignPub crypto.PublicKey
 	pems := parsePems([]byte(authorityKeyCosignPubString))
 	if len(pems) > 0 {
-		key, _ := x509.ParsePKIXPublicKey(pems[0].Bytes)
-		authorityKeyCosignPub = key.(*ecdsa.PublicKey)
+		authorityKeyCosignPub, _ = x509.ParsePKIXPublicKey(pems[0].Bytes)
 	} else {
-		t.Errorf("Error parsing authority key from string")
+		t.Fatal("Error parsing authority key from string")
 	}
 
+	v := NewValidator(ctx)
+
+	rrd := remoteResolveDigest
 	cvs := cosignVerifySignatures
 	defer func() {
+		remoteResolveDigest = rrd
 		cosignVerifySignatures = cvs
 	}()
-	// Let's just say that everything is verified.
-	pass := func(_ context.Context, _ name.Reference, _ *cosign.CheckOpts) (checkedSignatures []oci.Signature, bundleVerified bool, err error) {
-		sig, err := static.NewSignature(nil, "")
-		if err != nil {
-			return nil, false, err
-		}
-		return []oci.Signature{sig}, true, nil
+	remoteResolveDigest = func(_ name.Reference, _ ...remote.Option) (name.Digest, error) {
+		return digest.(name.Digest), nil
 	}
 	// Let's just say that everything is verified.
-	passKeyless := func(_ context.Context, _ name.Reference, _ *cosign.CheckOpts) (checkedSignatures []oci.Signature, bundleVerified bool, err error) {
-		// This is from 2022/07/29
-		// ghcr.io/distroless/static@sha256:a1e82f6a5f6dfc735165d3442e7cc5a615f72abac3db19452481f5f3c90fbfa8
-		payload := []byte(`{"payloadType":"application/vnd.in-toto+json","payload":"eyJfdHlwZSI6Imh0dHBzOi8vaW4tdG90by5pby9TdGF0ZW1lbnQvdjAuMSIsInByZWRpY2F0ZVR5cGUiOiJodHRwczovL2Nvc2lnbi5zaWdzdG9yZS5kZXYvYXR0ZXN0YXRpb24vdnVsbi92MSIsInN1YmplY3QiOlt7Im5hbWUiOiJnaGNyLmlvL2Rpc3Ryb2xlc3Mvc3RhdGljIiwiZGlnZXN0Ijp7InNoYTI1NiI6ImExZTgyZjZhNWY2ZGZjNzM1MTY1ZDM0NDJlN2NjNWE2MTVmNzJhYmFjM2RiMTk0NTI0ODFmNWYzYzkwZmJmYTgifX1dLCJwcmVkaWNhdGUiOnsiaW52b2NhdGlvbiI6eyJwYXJhbWV0ZXJzIjpudWxsLCJ1cmkiOiJodHRwczovL2dpdGh1Yi5jb20vZGlzdHJvbGVzcy9zdGF0aWMvYWN0aW9ucy9ydW5zLzI3NTc5NTMxMzkiLCJldmVudF9pZCI6IjI3NTc5NTMxMzkiLCJidWlsZGVyLmlkIjoiQ3JlYXRlIFJlbGVhc2UifSwic2Nhbm5lciI6eyJ1cmkiOiJodHRwczovL2dpdGh1Yi5jb20vYXF1YXNlY3VyaXR5L3RyaXZ5IiwidmVyc2lvbiI6IjAuMjkuMiIsImRiIjp7InVyaSI6IiIsInZlcnNpb24iOiIifSwicmVzdWx0Ijp7IiRzY2hlbWEiOiJodHRwczovL2pzb24uc2NoZW1hc3RvcmUub3JnL3NhcmlmLTIuMS4wLXJ0bS41Lmpzb24iLCJydW5zIjpbeyJjb2x1bW5LaW5kIjoidXRmMTZDb2RlVW5pdHMiLCJvcmlnaW5hbFVyaUJhc2VJZHMiOnsiUk9PVFBBVEgiOnsidXJpIjoiZmlsZTovLy8ifX0sInJlc3VsdHMiOltdLCJ0b29sIjp7ImRyaXZlciI6eyJmdWxsTmFtZSI6IlRyaXZ5IFZ1bG5lcmFiaWxpdHkgU2Nhbm5lciIsImluZm9ybWF0aW9uVXJpIjoiaHR0cHM6Ly9naXRodWIuY29tL2FxdWFzZWN1cml0eS90cml2eSIsIm5hbWUiOiJUcml2eSIsInJ1bGVzIjpbXSwidmVyc2lvbiI6IjAuMjkuMiJ9fX1dLCJ2ZXJzaW9uIjoiMi4xLjAifX0sIm1ldGFkYXRhIjp7InNjYW5TdGFydGVkT24iOiIyMDIyLTA3LTI5VDAyOjI4OjQyWiIsInNjYW5GaW5pc2hlZE9uIjoiMjAyMi0wNy0yOVQwMjoyODo0OFoifX19","signatures":[{"keyid":"","sig":"MEYCIQDeQXMMojIpNvxEDLDXUC5aAwCbPPr/0uckP8TCcdTLjgIhAJG6M00kY40bz/C90W0FeUc2YcWY+txD4BPXhzd8E+tP"}]}`)
-		set, err := base64.StdEncoding.DecodeString("MEQCIDBYWwwDW+nH+1vFoTOqHS4jAtVm4Yezq2nAy7vjcV8zAiBkznmgMrz9em4NuB/hl5X/umubhLgwoXgUAY2NJJwu5A==")
-		if err != nil {
-			return nil, false, err
-		}
-		sig, err := static.NewSignature(payload, "", static.WithCertChain(
-			[]byte("-----BEGIN CERTIFICATE-----\nMIIDnDCCAyOgAwIBAgIUVGZ4TQgYi4VCLLFghYMU/taKrD8wCgYIKoZIzj0EAwMw\nNzEVMBMGA1UEChMMc2lnc3RvcmUuZGV2MR4wHAYDVQQDExVzaWdzdG9yZS1pbnRl\ncm1lZGlhdGUwHhcNMjIwNzI5MDIyODQ4WhcNMjIwNzI5MDIzODQ4WjAAMFkwEwYH\nKoZIzj0CAQYIKoZIzj0DAQcDQgAEhiVvK5Tqk1+HnXSstf/8byA1RDpZu+Jvn9X6\nZoaCL/IjSJ7fBakvKAQ0BlzFg/JEtDreg/TFNiX2wnlMBlMV16OCAkIwggI+MA4G\nA1UdDwEB/wQEAwIHgDATBgNVHSUEDDAKBggrBgEFBQcDAzAdBgNVHQ4EFgQUiMn3\nza+9v+99n385GpkXzZxZiBIwHwYDVR0jBBgwFoAU39Ppz1YkEZb5qNjpKFWixi4Y\nZD8wYQYDVR0RAQH/BFcwVYZTaHR0cHM6Ly9naXRodWIuY29tL2Rpc3Ryb2xlc3Mv\nc3RhdGljLy5naXRodWIvd29ya2Zsb3dzL3JlbGVhc2UueWFtbEByZWZzL2hlYWRz\nL21haW4wOQYKKwYBBAGDvzABAQQraHR0cHM6Ly90b2tlbi5hY3Rpb25zLmdpdGh1\nYnVzZXJjb250ZW50LmNvbTAWBgorBgEEAYO/MAECBAhzY2hlZHVsZTA2BgorBgEE\nAYO/MAEDBCg3ZTc1NzJlNTc4ZGU3YzUxYTJmMWExNzkxZjAyNWNmMzE1NTAzYWEy\nMBwGCisGAQQBg78wAQQEDkNyZWF0ZSBSZWxlYXNlMB8GCisGAQQBg78wAQUEEWRp\nc3Ryb2xlc3Mvc3RhdGljMB0GCisGAQQBg78wAQYED3JlZnMvaGVhZHMvbWFpbjCB\nigYKKwYBBAHWeQIEAgR8BHoAeAB2AAhgkvAoUv9oRdHRayeEnEVnGKwWPcM40m3m\nvCIGNm9yAAABgkfI9c8AAAQDAEcwRQIgPm4AoftGQF2abbFxMLvtzTjXy+sxwxTp\nCh5ZsoesBDMCIQCNlwmLpuu1KiqjY74l5527AffSd4kOapDMfpHAlMrpCTAKBggq\nhkjOPQQDAwNnADBkAjAe7jfVc1OJNhbaZF8BJRJ9nQOAcY6kwFYMav1XfQsJPE0x\naYpNg/oXVA5UrFcSBLkCMFa4124w3qUzrXSTGq99nlALKQ8HFR8ri17wM5/ZiWxi\nrtABq5eub32TXpAnfqGSmw==\n-----END CERTIFICATE-----\n"),
-			[]byte("-----BEGIN CERTIFICATE-----\nMIICGjCCAaGgAwIBAgIUALnViVfnU0brJasmRkHrn/UnfaQwCgYIKoZIzj0EAwMw\nKjEVMBMGA1UEChMMc2lnc3RvcmUuZGV2MREwDwYDVQQDEwhzaWdzdG9yZTAeFw0y\nMjA0MTMyMDA2MTVaFw0zMTEwMDUxMzU2NThaMDcxFTATBgNVBAoTDHNpZ3N0b3Jl\nLmRldjEeMBwGA1UEAxMVc2lnc3RvcmUtaW50ZXJtZWRpYXRlMHYwEAYHKoZIzj0C\nAQYFK4EEACIDYgAE8RVS/ysH+NOvuDZyPIZtilgUF9NlarYpAd9HP1vBBH1U5CV7\n7LSS7s0ZiH4nE7Hv7ptS6LvvR/STk798LVgMzLlJ4HeIfF3tHSaexLcYpSASr1kS\n0N/RgBJz/9jWCiXno3sweTAOBgNVHQ8BAf8EBAMCAQYwEwYDVR0lBAwwCgYIKwYB\nBQUHAwMwEgYDVR0TAQH/BAgwBgEB/wIBADAdBgNVHQ4EFgQU39Ppz1YkEZb5qNjp\nKFWixi4YZD8wHwYDVR0jBBgwFoAUWMAeX5FFpWapesyQoZMi0CrFxfowCgYIKoZI\nzj0EAwMDZwAwZAIwPCsQK4DYiZYDPIaDi5HFKnfxXx6ASSVmERfsynYBiX2X6SJR\nnZU84/9DZdnFvvxmAjBOt6QpBlc4J/0DxvkTCqpclvziL6BCCPnjdlIB3Pu3BxsP\nmygUY7Ii2zbdCdliiow=\n-----END CERTIFICATE-----\n-----BEGIN CERTIFICATE-----\nMIIB9zCCAXygAwIBAgIUALZNAPFdxHPwjeDloDwyYChAO/4wCgYIKoZIzj0EAwMw\nKjEVMBMGA1UEChMMc2lnc3RvcmUuZGV2MREwDwYDVQQDEwhzaWdzdG9yZTAeFw0y\nMTEwMDcxMzU2NTlaFw0zMTEwMDUxMzU2NThaMCoxFTATBgNVBAoTDHNpZ3N0b3Jl\nLmRldjERMA8GA1UEAxMIc2lnc3RvcmUwdjAQBgcqhkjOPQIBBgUrgQQAIgNiAAT7\nXeFT4rb3PQGwS4IajtLk3/OlnpgangaBclYpsYBr5i+4ynB07ceb3LP0OIOZdxex\nX69c5iVuyJRQ+Hz05yi+UF3uBWAlHpiS5sh0+H2GHE7SXrk1EC5m1Tr19L9gg92j\nYzBhMA4GA1UdDwEB/wQEAwIBBjAPBgNVHRMBAf8EBTADAQH/MB0GA1UdDgQWBBRY\nwB5fkUWlZql6zJChkyLQKsXF+jAfBgNVHSMEGDAWgBRYwB5fkUWlZql6zJChkyLQ\nKsXF+jAKBggqhkjOPQQDAwNpADBmAjEAj1nHeXZp+13NWBNa+EDsDP8G1WWg1tCM\nWP/WHPqpaVo0jhsweNFZgSs0eE7wYI4qAjEA2WB9ot98sIkoF3vZYdd3/VtWB5b9\nTNMea7Ix/stJ5TfcLLeABLE4BNJOsQ4vnBHJ\n-----END CERTIFICATE-----"),
-		), static.WithBundle(&bundle.RekorBundle{
-			SignedEntryTimestamp: set,
-			Payload: bundle.RekorPayload{
-				Body:           "eyJhcGlWZXJzaW9uIjoiMC4wLjEiLCJraW5kIjoiaW50b3RvIiwic3BlYyI6eyJjb250ZW50Ijp7Imhhc2giOnsiYWxnb3JpdGhtIjoic2hhMjU2IiwidmFsdWUiOiIyYjY1Y2JmMGU3OTAxYmEzMWQ1NWIxMmQzMTliY2EzOTQyMGFmNDM4OGQzZTU3MTRkMTZmMjAxOWQ3NGUzYWI3In0sInBheWxvYWRIYXNoIjp7ImFsZ29yaXRobSI6InNoYTI1NiIsInZhbHVlIjoiYzFiNWYwZjRiOGVjZDU1ZWRhMjUwY2Q4NDk2NGQwYzFmYjVkN2E4YTM0OGY0YjdmZmI3ZGFhMmUwNmM0ODM3MyJ9fSwicHVibGljS2V5IjoiTFMwdExTMUNSVWRKVGlCRFJWSlVTVVpKUTBGVVJTMHRMUzB0Q2sxSlNVUnVSRU5EUVhsUFowRjNTVUpCWjBsVlZrZGFORlJSWjFscE5GWkRURXhHWjJoWlRWVXZkR0ZMY2tRNGQwTm5XVWxMYjFwSmVtb3dSVUYzVFhjS1RucEZWazFDVFVkQk1WVkZRMmhOVFdNeWJHNWpNMUoyWTIxVmRWcEhWakpOVWpSM1NFRlpSRlpSVVVSRmVGWjZZVmRrZW1SSE9YbGFVekZ3WW01U2JBcGpiVEZzV2tkc2FHUkhWWGRJYUdOT1RXcEpkMDU2U1RWTlJFbDVUMFJSTkZkb1kwNU5ha2wzVG5wSk5VMUVTWHBQUkZFMFYycEJRVTFHYTNkRmQxbElDa3R2V2tsNmFqQkRRVkZaU1V0dldrbDZhakJFUVZGalJGRm5RVVZvYVZaMlN6VlVjV3N4SzBodVdGTnpkR1l2T0dKNVFURlNSSEJhZFN0S2RtNDVXRFlLV205aFEwd3ZTV3BUU2pkbVFtRnJka3RCVVRCQ2JIcEdaeTlLUlhSRWNtVm5MMVJHVG1sWU1uZHViRTFDYkUxV01UWlBRMEZyU1hkblowa3JUVUUwUndwQk1WVmtSSGRGUWk5M1VVVkJkMGxJWjBSQlZFSm5UbFpJVTFWRlJFUkJTMEpuWjNKQ1owVkdRbEZqUkVGNlFXUkNaMDVXU0ZFMFJVWm5VVlZwVFc0ekNucGhLemwyS3prNWJqTTROVWR3YTFoNlduaGFhVUpKZDBoM1dVUldVakJxUWtKbmQwWnZRVlV6T1ZCd2VqRlphMFZhWWpWeFRtcHdTMFpYYVhocE5Ga0tXa1E0ZDFsUldVUldVakJTUVZGSUwwSkdZM2RXV1ZwVVlVaFNNR05JVFRaTWVUbHVZVmhTYjJSWFNYVlpNamwwVERKU2NHTXpVbmxpTW5oc1l6Tk5kZ3BqTTFKb1pFZHNha3g1Tlc1aFdGSnZaRmRKZG1ReU9YbGhNbHB6WWpOa2Vrd3pTbXhpUjFab1l6SlZkV1ZYUm5SaVJVSjVXbGRhZWt3eWFHeFpWMUo2Q2t3eU1XaGhWelIzVDFGWlMwdDNXVUpDUVVkRWRucEJRa0ZSVVhKaFNGSXdZMGhOTmt4NU9UQmlNblJzWW1rMWFGa3pVbkJpTWpWNlRHMWtjR1JIYURFS1dXNVdlbHBZU21waU1qVXdXbGMxTUV4dFRuWmlWRUZYUW1kdmNrSm5SVVZCV1U4dlRVRkZRMEpCYUhwWk1taHNXa2hXYzFwVVFUSkNaMjl5UW1kRlJRcEJXVTh2VFVGRlJFSkRaek5hVkdNeFRucEtiRTVVWXpSYVIxVXpXWHBWZUZsVVNtMU5WMFY0VG5wcmVGcHFRWGxPVjA1dFRYcEZNVTVVUVhwWlYwVjVDazFDZDBkRGFYTkhRVkZSUW1jM09IZEJVVkZGUkd0T2VWcFhSakJhVTBKVFdsZDRiRmxZVG14TlFqaEhRMmx6UjBGUlVVSm5OemgzUVZGVlJVVlhVbkFLWXpOU2VXSXllR3hqTTAxMll6TlNhR1JIYkdwTlFqQkhRMmx6UjBGUlVVSm5OemgzUVZGWlJVUXpTbXhhYmsxMllVZFdhRnBJVFhaaVYwWndZbXBEUWdwcFoxbExTM2RaUWtKQlNGZGxVVWxGUVdkU09FSkliMEZsUVVJeVFVRm9aMnQyUVc5VmRqbHZVbVJJVW1GNVpVVnVSVlp1UjB0M1YxQmpUVFF3YlROdENuWkRTVWRPYlRsNVFVRkJRbWRyWmtrNVl6aEJRVUZSUkVGRlkzZFNVVWxuVUcwMFFXOW1kRWRSUmpKaFltSkdlRTFNZG5SNlZHcFllU3R6ZUhkNFZIQUtRMmcxV25OdlpYTkNSRTFEU1ZGRFRteDNiVXh3ZFhVeFMybHhhbGszTkd3MU5USTNRV1ptVTJRMGEwOWhjRVJOWm5CSVFXeE5jbkJEVkVGTFFtZG5jUXBvYTJwUFVGRlJSRUYzVG01QlJFSnJRV3BCWlRkcVpsWmpNVTlLVG1oaVlWcEdPRUpLVWtvNWJsRlBRV05aTm10M1JsbE5ZWFl4V0daUmMwcFFSVEI0Q21GWmNFNW5MMjlZVmtFMVZYSkdZMU5DVEd0RFRVWmhOREV5TkhjemNWVjZjbGhUVkVkeE9UbHViRUZNUzFFNFNFWlNPSEpwTVRkM1RUVXZXbWxYZUdrS2NuUkJRbkUxWlhWaU16SlVXSEJCYm1aeFIxTnRkejA5Q2kwdExTMHRSVTVFSUVORlVsUkpSa2xEUVZSRkxTMHRMUzBLIn19",
-				IntegratedTime: 1659061729,
-				LogIndex:       3059470,
-				LogID:          "c0d23d6ad406973f9559f3ba2d1ca01f84147d8ffc5b8445c224f98b9591801d",
-			},
-		}))
+	cosignVerifySignatures = func(_ context.Context, _ name.Reference, _ *cosign.CheckOpts) (checkedSignatures []oci.Signature, bundleVerified bool, err error) {
+		sig, err := static.NewSignature(nil, "")
 		if err != nil {
 			return nil, false, err
 		}
 		return []oci.Signature{sig}, true, nil
 	}
-	// Let's just say that everything is not verified.
-	fail := func(_ context.Context, _ name.Reference, _ *cosign.CheckOpts) (checkedSignatures []oci.Signature, bundleVerified bool, err error) {
-		return nil, false, errors.New("bad signature")
-	}
-
-	// Let's say it is verified if it is the expected Public Key
-	authorityPublicKeyCVS := func(ctx context.Context, signedImgRef name.Reference, co *cosign.CheckOpts) (checkedSignatures []oci.Signature, bundleVerified bool, err error) {
-		// Because we use this below and it gets called for both key / keyless
-		// in the keyless case there's no SigVerifier, so fail it.
-		if co.SigVerifier == nil {
-			return nil, false, errors.New("Keyless used for key")
-		}
-		actualPublicKey, _ := co.SigVerifier.PublicKey()
-		actualECDSAPubkey := actualPublicKey.(*ecdsa.PublicKey)
-
-		actualPubKey, err := actualECDSAPubkey.ECDH()
-		if err != nil {
-			return nil, false, errors.New("failed to get edch pub key")
-		}
-
-		authorityKeyPubKey, err := authorityKeyCosignPub.ECDH()
-		if err != nil {
-			return nil, false, errors.New("failed to get edch pub key")
-		}
-
-		if bytes.Equal(actualPubKey.Bytes(), authorityKeyPubKey.Bytes()) {
-			return pass(ctx, signedImgRef, co)
-		}
-
-		return fail(ctx, signedImgRef, co)
-	}
 
-	tests := []struct {
-		name          string
-		policy        webhookcip.ClusterImagePolicy
-		want          *PolicyResult
-		wantErrs      []string
-		cva           func(context.Context, name.Reference, *cosign.CheckOpts) ([]oci.Signature, bool, error)
-		cvs           func(context.Context, name.Reference, *cosign.CheckOpts) ([]oci.Signature, bool, error)
-		customContext context.Context
-	}{{
-		name: "fail with no public key",
-		policy: webhookcip.ClusterImagePolicy{
-			Authorities: []webhookcip.Authority{{
-				Name: "authority-0",
-				Key:  &webhookcip.KeyRef{},
+	policies := map[string]webhookcip.ClusterImagePolicy{
+		"annotated": {
+			Images: []v1alpha1.ImagePattern{{
+				Glob: "gcr.io/*/*",
 			}},
-		},
-		wantErrs: []string{"there are no public keys for authority authority-0"},
-	}, {
-		name: "simple, public key, no matches",
-		policy: webhookcip.ClusterImagePolicy{
 			Authorities: []webhookcip.Authority{{
-				Name: "authority-0",
-				Key: &webhookcip.KeyRef{
-					PublicKeys:        []crypto.PublicKey{authorityKeyCosignPub},
-					HashAlgorithm:     signaturealgo.DefaultSignatureAlgorithm,
-					HashAlgorithmCode: crypto.SHA256,
+				Name: "static",
+				Static: &webhookcip.StaticRef{
+					Action: "pass",
 				},
-			}},
-		},
-		wantErrs: []string{"key validation failed for authority authority-0 for gcr.io/distroless/static@sha256:be5d77c62dbe7fedfb0a4e5ec2f91078080800ab1f18358e5f31fcc8faa023c4: bad signature"},
-		cvs:      fail,
-	}, {
-		name: "simple, public key, works",
-		policy: webhookcip.ClusterImagePolicy{
-			Authorities: []webhookcip.Authority{{
-				Name: "authority-0",
+			}, {
+				Name: "key",
 				Key: &webhookcip.KeyRef{
 					PublicKeys:        []crypto.PublicKey{authorityKeyCosignPub},
 					HashAlgorithm:     signaturealgo.DefaultSignatureAlgorithm,
 					HashAlgorithmCode: crypto.SHA256,
 				},
 			}},
+			AnnotateVerification: true,
 		},
-		want: &PolicyResult{
-			AuthorityMatches: map[string]AuthorityMatch{
-				"authority-0": {
-					Signatures: []PolicySignature{{
-						ID: "e3b0c44298fc1c149afbf4c8996fb92427ae41e4649b934ca495991b7852b855",
-						// TODO(mattmoor): Is there anything we should encode for key-based?
-					}},
-				}},
-		},
-		cvs: pass,
-	}, {
-		name: "simple, public key and keyless, one works, one doesn't",
-		policy: webhookcip.ClusterImagePolicy{
-			Authorities: []webhookcip.Authority{{
-				Name: "authority-0",
-				Key: &webhookcip.KeyRef{
-					PublicKeys:        []crypto.PublicKey{authorityKeyCosignPub},
-					HashAlgorithm:     signaturealgo.DefaultSignatureAlgorithm,
-					HashAlgorithmCode: crypto.SHA256,
-				},
-			}, {
-				Name: "authority-1",
-				Keyless: &webhookcip.KeylessRef{
-					URL: badURL,
-				},
+		"not-annotated": {
+			Images: []v1alpha1.ImagePattern{{
+				Glob: "gcr.io/*/*",
 			}},
-		},
-		want: &PolicyResult{
-			AuthorityMatches: map[string]AuthorityMatch{
-				"authority-0": {
-					Signatures: []PolicySignature{{
-						ID: "e3b0c44298fc1c149afbf4c8996fb92427ae41e4649b934ca495991b7852b855",
-						// TODO(mattmoor): Is there anything we should encode for key-based?
-					}},
-				}},
-		},
-		wantErrs: []string{`signature keyless validation failed for authority authority-1 for gcr.io/distroless/static@sha256:be5d77c62dbe7fedfb0a4e5ec2f91078080800ab1f18358e5f31fcc8faa023c4: Keyless used for key`},
-		cvs:      authorityPublicKeyCVS,
-	}, {
-		name: "simple, static set to pass",
-		policy: webhookcip.ClusterImagePolicy{
 			Authorities: []webhookcip.Authority{{
-				Name: "authority-0",
+				Name: "static",
 				Static: &webhookcip.StaticRef{
 					Action: "pass",
 				},
 			}},
 		},
-		want: &PolicyResult{
-			AuthorityMatches: map[string]AuthorityMatch{
-				"authority-0": {
-					Static: true,
-				},
-			},
-		},
-	}, {
-		name: "simple, static set to fail",
-		policy: webhookcip.ClusterImagePolicy{
+		"failing": {
+			Images: []v1alpha1.ImagePattern{{
+				Glob: "gcr.io/*/*",
+			}},
 			Authorities: []webhookcip.Authority{{
-				Name: "authority-0",
+				Name: "static",
 				Static: &webhookcip.StaticRef{
 					Action: "fail",
 				},
 			}},
+			AnnotateVerification: true,
 		},
-		wantErrs: []string{"disallowed by static policy"},
-	}, {
-		name: "simple, static set to fail with custom message",
-		policy: webhookcip.ClusterImagePolicy{
-			Authorities: []webhookcip.Authority{{
-				Name: "authority-0",
-				Static: &webhookcip.StaticRef{
-					Action:  "fail",
-					Message: "test custom message here",
+	}
+	annotated := ContainerVerification{
+		Image: digest.String(),
+		Policies: map[string]PolicyVerification{
+			"annotated": {
+				Authorities: map[string]AuthorityVerification{
+					"static": {Static: true},
+					"key": {Signers: []Signer{{
+						KeyID: authorityKeyCosignPubID,
+					}}},
 				},
-			}},
+			},
 		},
-		wantErrs: []string{"disallowed by static policy: test custom message here"},
-	}, {
-		name: "simple, public key, no error",
-		policy: webhookcip.ClusterImagePolicy{
-			Authorities: []webhookcip.Authority{{
-				Name: "authority-0",
-				Key: &webhookcip.KeyRef{
-					PublicKeys:        []crypto.PublicKey{authorityKeyCosignPub},
-					HashAlgorithm:     signaturealgo.DefaultSignatureAlgorithm,
-					HashAlgorithmCode: crypto.SHA256,
+	}
+	want := map[string]ContainerVerification{
+		"user-container": annotated,
+		"volume:data":    annotated,
+	}
+
+	newPod := func() *duckv1.Pod {
+		return &duckv1.Pod{
+			ObjectMeta: metav1.ObjectMeta{
+				Annotations: map[string]string{
+					VerificationAnnotation: "stale",
 				},
-			}},
-		},
-		want: &PolicyResult{
-			AuthorityMatches: map[string]AuthorityMatch{
-				"authority-0": {
-					Signatures: []PolicySignature{{
-						ID: "e3b0c44298fc1c149afbf4c8996fb92427ae41e4649b934ca495991b7852b855",
-						// TODO(mattmoor): Is there anything we should encode for key-based?
-					}},
+			},
+			Spec: corev1.PodSpec{
+				Containers: []corev1.Container{{
+					Name:  "user-container",
+					Image: "gcr.io/distroless/static:nonroot",
 				}},
-		},
-		cvs: authorityPublicKeyCVS,
-	}, {
-		name: "simple, keyless attestation, works",
-		policy: webhookcip.ClusterImagePolicy{
-			Authorities: []webhookcip.Authority{{
-				Name: "authority-0",
-				Keyless: &webhookcip.KeylessRef{
-					URL: fulcioURL,
-				},
+				Volumes: []corev1.Volume{{
+					Name: "data",
+					VolumeSource: corev1.VolumeSource{
+						Image: &corev1.ImageVolumeSource{
+							Reference: "gcr.io/distroless/static:nonroot",
+						},
+					},
+				}},
+			},
+		}
+	}
+
+	baseCtx := context.WithValue(context.Background(), kubeclient.Key{}, kc)
+	testCtx := config.ToContext(baseCtx, &config.Config{
+		ImagePolicyConfig: &config.ImagePolicyConfig{Policies: policies},
+	})
+
+	// The verification is recorded for the policies that ask for it.
+	pod := newPod()
+	v.ResolvePod(apis.WithinCreate(testCtx), pod)
+	var got map[string]ContainerVerification
+	if err := json.Unmarshal([]byte(pod.Annotations[VerificationAnnotation]), &got); err != nil {
+		t.Fatalf("Failed to unmarshal %q: %v", pod.Annotations[VerificationAnnotation], err)
+	}
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Errorf("unexpected verification (-want +got): %s", diff)
+	}
+
+	// Nothing is recorded outside of create / update.
+	pod = newPod()
+	v.ResolvePod(testCtx, pod)
+	if got := pod.Annotations[VerificationAnnotation]; got != "stale" {
+		t.Errorf("ResolvePod() changed the annotation to %q", got)
+	}
+
+	// Stale results are removed when no policy asks for them.
+	delete(policies, "annotated")
+	pod = newPod()
+	v.ResolvePod(apis.WithinUpdate(testCtx, newPod()), pod)
+	if got, ok := pod.Annotations[VerificationAnnotation]; ok {
+		t.Errorf("ResolvePod() left the annotation %q", got)
+	}
+
+	// Nor can it be forged when there are no policies at all.
+	pod = newPod()
+	v.ResolvePod(apis.WithinCreate(config.ToContext(baseCtx, &config.Config{
+		ImagePolicyConfig: &config.ImagePolicyConfig{},
+	})), pod)
+	if got, ok := pod.Annotations[VerificationAnnotation]; ok {
+		t.Errorf("ResolvePod() left the annotation %q", got)
+	}
+}
+
+func TestOwnerTrustChain(t *testing.T) {
+	// Resolved via crane digest on 2021/09/25
+	digest := name.MustParseReference("gcr.io/distroless/static:nonroot@sha256:be5d77c62dbe7fedfb0a4e5ec2f91078080800ab1f18358e5f31fcc8faa023c4")
+
+	ctx, _ := rtesting.SetupFakeContext(t)
+	kc := fakekube.Get(ctx)
+	kc.CoreV1().ServiceAccounts("default").Create(ctx, &corev1.ServiceAccount{
+		ObjectMeta: metav1.ObjectMeta{
+			Name: "default",
+		},
+	}, metav1.CreateOptions{})
+	fakesecret.Get(ctx).Informer().GetIndexer().Add(&corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "trust-chain",
+			Namespace: system.Namespace(),
+		},
+		Data: map[string][]byte{
+			"key": []byte("not-so-secret"),
+		},
+	})
+
+	var authorityKeyCosignPub crypto.PublicKey
+	pems := parsePems([]byte(authorityKeyCosignPubString))
+	if len(pems) > 0 {
+		authorityKeyCosignPub, _ = x509.ParsePKIXPublicKey(pems[0].Bytes)
+	} else {
+		t.Fatal("Error parsing authority key from string")
+	}
+
+	v := NewValidator(ctx)
+
+	cvs := cosignVerifySignatures
+	defer func() {
+		cosignVerifySignatures = cvs
+	}()
+	verified := true
+	var verifications atomic.Int32
+	cosignVerifySignatures = func(_ context.Context, _ name.Reference, _ *cosign.CheckOpts) (checkedSignatures []oci.Signature, bundleVerified bool, err error) {
+		verifications.Add(1)
+		if !verified {
+			return nil, false, errors.New("bad signature")
+		}
+		sig, err := static.NewSignature(nil, "")
+		if err != nil {
+			return nil, false, err
+		}
+		return []oci.Signature{sig}, true, nil
+	}
+
+	policies := map[string]webhookcip.ClusterImagePolicy{
+		"cluster-image-policy": {
+			UID:             "7fa8b0d2-cb46-4dd2-8b4b-2b4f4b5b0e1a",
+			ResourceVersion: "1",
+			Images: []v1alpha1.ImagePattern{{
+				Glob: "gcr.io/*/*",
+			}},
+			Authorities: []webhookcip.Authority{{
+				Name: "key",
+				Key: &webhookcip.KeyRef{
+					PublicKeys:        []crypto.PublicKey{authorityKeyCosignPub},
+					HashAlgorithm:     signaturealgo.DefaultSignatureAlgorithm,
+					HashAlgorithmCode: crypto.SHA256,
+				},
+			}},
+			AnnotateVerification: true,
+		},
+	}
+
+	baseCtx := context.WithValue(context.Background(), kubeclient.Key{}, kc)
+	baseCtx = policycontrollerconfig.ToContext(baseCtx, &policycontrollerconfig.PolicyControllerConfig{
+		NoMatchPolicy:         policycontrollerconfig.DenyAll,
+		OwnerTrustChainSecret: "trust-chain",
+	})
+	testCtx := config.ToContext(baseCtx, &config.Config{
+		ImagePolicyConfig: &config.ImagePolicyConfig{Policies: policies},
+	})
+
+	deployment := &duckv1.WithPod{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "deployment",
+			Namespace: "default",
+		},
+		Spec: duckv1.WithPodSpec{
+			Template: duckv1.PodSpecable{
+				ObjectMeta: metav1.ObjectMeta{
+					Labels: map[string]string{"app": "test"},
+				},
+				Spec: corev1.PodSpec{
+					Containers: []corev1.Container{{
+						Name:  "user-container",
+						Image: digest.String(),
+					}},
+				},
+			},
+		},
+	}
+	v.ResolvePodSpecable(apis.WithinCreate(testCtx), deployment)
+	stamp, ok := deployment.Spec.Template.Annotations[VerificationStampAnnotation]
+	if !ok {
+		t.Fatal("ResolvePodSpecable() did not stamp the pod template")
+	}
+	if _, ok := deployment.Annotations[VerificationAnnotation]; !ok {
+		t.Error("ResolvePodSpecable() did not annotate the verification")
+	}
+	// Stamping and annotating share a single verification.
+	if got := verifications.Load(); got != 1 {
+		t.Errorf("ResolvePodSpecable() verified %d times, wanted 1", got)
+	}
+
+	// From now on, the image does not verify anymore.
+	verified = false
+	newPod := func(stamp string) *duckv1.Pod {
+		return &duckv1.Pod{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:        "deployment-abcde",
+				Namespace:   "default",
+				Labels:      map[string]string{"app": "test"},
+				Annotations: map[string]string{VerificationStampAnnotation: stamp},
+			},
+			Spec: deployment.Spec.Template.Spec,
+		}
+	}
+
+	// A child carrying the stamp is admitted without being verified.
+	if err := v.ValidatePod(apis.WithinCreate(testCtx), newPod(stamp)); err != nil {
+		t.Errorf("ValidatePod() with a valid stamp = %v", err)
+	}
+
+	// A tampered stamp is not trusted.
+	tampered := "0" + stamp[1:]
+	if tampered == stamp {
+		tampered = "1" + stamp[1:]
+	}
+	if err := v.ValidatePod(apis.WithinCreate(testCtx), newPod(tampered)); err == nil {
+		t.Error("ValidatePod() with a tampered stamp succeeded")
+	}
+
+	// A stamp for another namespace is not trusted.
+	pod := newPod(stamp)
+	pod.Namespace = "other"
+	if err := v.ValidatePod(apis.WithinCreate(testCtx), pod); err == nil {
+		t.Error("ValidatePod() with a stamp for another namespace succeeded")
+	}
+
+	// The stamp is not trusted once the compiled policy changes, even if its
+	// resourceVersion does not.
+	policies["cluster-image-policy"].Authorities[0].Key.Data = authorityKeyCosignPubString
+	if err := v.ValidatePod(apis.WithinCreate(testCtx), newPod(stamp)); err == nil {
+		t.Error("ValidatePod() with a stamp for another key succeeded")
+	}
+	policies["cluster-image-policy"].Authorities[0].Key.Data = ""
+
+	// The stamp is not trusted once the policy changes, and is then removed
+	// from the parent.
+	cip := policies["cluster-image-policy"]
+	cip.ResourceVersion = "2"
+	policies["cluster-image-policy"] = cip
+	if err := v.ValidatePod(apis.WithinCreate(testCtx), newPod(stamp)); err == nil {
+		t.Error("ValidatePod() with a stamp for an older policy succeeded")
+	}
+	v.ResolvePodSpecable(apis.WithinUpdate(testCtx, deployment), deployment)
+	if got, ok := deployment.Spec.Template.Annotations[VerificationStampAnnotation]; ok {
+		t.Errorf("ResolvePodSpecable() left the stamp %q", got)
+	}
+}
+
+func TestPolicyDigests(t *testing.T) {
+	policies := map[string]webhookcip.ClusterImagePolicy{
+		"cluster-image-policy": {
+			UID:             "7fa8b0d2-cb46-4dd2-8b4b-2b4f4b5b0e1a",
+			ResourceVersion: "1",
+			Authorities: []webhookcip.Authority{{
+				Name: "key",
+				Key:  &webhookcip.KeyRef{Data: authorityKeyCosignPubString},
+			}, {
+				Name:    "keyless",
+				Keyless: &webhookcip.KeylessRef{TrustRootRef: "trust-root"},
+			}},
+		},
+	}
+	sigstoreKeys := &config.SigstoreKeysMap{SigstoreKeys: map[string]*config.SigstoreKeys{
+		"trust-root": {MediaType: "application/vnd.dev.sigstore.trustedroot+json;version=0.1"},
+	}}
+	ctx := config.ToContext(context.Background(), &config.Config{SigstoreKeysConfig: sigstoreKeys})
+	digests := func() string {
+		t.Helper()
+		got, err := policyDigests(ctx, policies)
+		if err != nil {
+			t.Fatalf("policyDigests() = %v", err)
+		}
+		return strings.Join(got, ",")
+	}
+
+	want := digests()
+	if got := digests(); got != want {
+		t.Errorf("policyDigests() = %s, wanted the same digests %s", got, want)
+	}
+
+	// The digests change along with the keys of the policy, even if its
+	// resourceVersion does not.
+	policies["cluster-image-policy"].Authorities[0].Key.Data = "another key"
+	if got := digests(); got == want {
+		t.Error("policyDigests() did not change with the key")
+	}
+	policies["cluster-image-policy"].Authorities[0].Key.Data = authorityKeyCosignPubString
+
+	// The digests change along with the TrustRoots of the policy.
+	sigstoreKeys.SigstoreKeys["trust-root"].CertificateAuthorities = []*config.CertificateAuthority{{Uri: "https://fulcio.example.com"}}
+	if got := digests(); got == want {
+		t.Error("policyDigests() did not change with the TrustRoot")
+	}
+	delete(sigstoreKeys.SigstoreKeys, "trust-root")
+	if got := digests(); got == want {
+		t.Error("policyDigests() did not change without the TrustRoot")
+	}
+}
+
+func TestValidatePodSpecImageVolumes(t *testing.T) {
+	tag := name.MustParseReference("gcr.io/distroless/static:nonroot")
+	// Resolved via crane digest on 2021/09/25
+	digest := name.MustParseReference("gcr.io/distroless/static:nonroot@sha256:be5d77c62dbe7fedfb0a4e5ec2f91078080800ab1f18358e5f31fcc8faa023c4")
+
+	ctx, _ := rtesting.SetupFakeContext(t)
+	kc := fakekube.Get(ctx)
+	kc.CoreV1().ServiceAccounts(system.Namespace()).Create(ctx, &corev1.ServiceAccount{
+		ObjectMeta: metav1.ObjectMeta{
+			Name: "default",
+		},
+	}, metav1.CreateOptions{})
+
+	v := NewValidator(ctx)
+
+	// Only the containers may use the image, not the image volumes.
+	testCtx := context.WithValue(context.Background(), kubeclient.Key{}, kc)
+	testCtx = config.ToContext(testCtx, &config.Config{
+		ImagePolicyConfig: &config.ImagePolicyConfig{
+			Policies: map[string]webhookcip.ClusterImagePolicy{
+				"no-image-volumes": {
+					Images: []v1alpha1.ImagePattern{{
+						Glob: "gcr.io/*/*",
+					}},
+					Authorities: []webhookcip.Authority{{
+						Name: "authority-0",
+						Static: &webhookcip.StaticRef{
+							Action: "pass",
+						},
+					}},
+					Policy: &webhookcip.AttestationPolicy{
+						Name: "no image volumes",
+						Type: "cue",
+						Data: `imageVolume: false`,
+					},
+				},
+			},
+		},
+	})
+
+	ps := &corev1.PodSpec{
+		Containers: []corev1.Container{{
+			Name:  "user-container",
+			Image: digest.String(),
+		}},
+		Volumes: []corev1.Volume{{
+			Name: "tagged",
+			VolumeSource: corev1.VolumeSource{
+				Image: &corev1.ImageVolumeSource{Reference: tag.String()},
+			},
+		}, {
+			Name: "config",
+			VolumeSource: corev1.VolumeSource{
+				ConfigMap: &corev1.ConfigMapVolumeSource{},
+			},
+		}, {
+			Name: "digest",
+			VolumeSource: corev1.VolumeSource{
+				Image: &corev1.ImageVolumeSource{Reference: digest.String()},
+			},
+		}},
+	}
+	got := v.validatePodSpec(testCtx, system.Namespace(), "Pod", "v1", map[string]string{}, ps, k8schain.Options{})
+	if got == nil {
+		t.Fatal("validatePodSpec() succeeded, wanted errors for the image volumes")
+	}
+	paths := got.Paths
+	for _, e := range got.WrappedErrors() {
+		paths = append(paths, e.Paths...)
+	}
+	sort.Strings(paths)
+	want := []string{"volumes[0].image.reference", "volumes[2].image.reference"}
+	if diff := cmp.Diff(want, paths); diff != "" {
+		t.Errorf("unexpected error paths (-want +got): %s\n%v", diff, got)
+	}
+	if !strings.Contains(got.Error(), tag.String()+" must be an image digest") {
+		t.Errorf("validatePodSpec() = %v, wanted the tagged image volume to be rejected", got)
+	}
+	if !strings.Contains(got.Error(), "failed policy: no-image-volumes: policy evaluation failed") {
+		t.Errorf("validatePodSpec() = %v, wanted the image volume to fail the policy", got)
+	}
+}
+
+func TestSkipUnchangedImages(t *testing.T) {
+	// Resolved via crane digest on 2021/09/25
+	digest := name.MustParseReference("gcr.io/distroless/static:nonroot@sha256:be5d77c62dbe7fedfb0a4e5ec2f91078080800ab1f18358e5f31fcc8faa023c4")
+	otherDigest := name.MustParseReference("gcr.io/distroless/static@sha256:0000000000000000000000000000000000000000000000000000000000000000")
+
+	ctx, _ := rtesting.SetupFakeContext(t)
+	kc := fakekube.Get(ctx)
+	kc.CoreV1().ServiceAccounts("default").Create(ctx, &corev1.ServiceAccount{
+		ObjectMeta: metav1.ObjectMeta{
+			Name: "default",
+		},
+	}, metav1.CreateOptions{})
+	fakesecret.Get(ctx).Informer().GetIndexer().Add(&corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "trust-chain",
+			Namespace: system.Namespace(),
+		},
+		Data: map[string][]byte{
+			"key": []byte("not-so-secret"),
+		},
+	})
+
+	var authorityKeyCosignPub crypto.PublicKey
+	pems := parsePems([]byte(authorityKeyCosignPubString))
+	if len(pems) > 0 {
+		authorityKeyCosignPub, _ = x509.ParsePKIXPublicKey(pems[0].Bytes)
+	} else {
+		t.Fatal("Error parsing authority key from string")
+	}
+
+	v := NewValidator(ctx)
+
+	cvs := cosignVerifySignatures
+	defer func() {
+		cosignVerifySignatures = cvs
+	}()
+	verified := true
+	cosignVerifySignatures = func(_ context.Context, _ name.Reference, _ *cosign.CheckOpts) (checkedSignatures []oci.Signature, bundleVerified bool, err error) {
+		if !verified {
+			return nil, false, errors.New("registry is down")
+		}
+		sig, err := static.NewSignature(nil, "")
+		if err != nil {
+			return nil, false, err
+		}
+		return []oci.Signature{sig}, true, nil
+	}
+
+	policies := map[string]webhookcip.ClusterImagePolicy{
+		"cluster-image-policy": {
+			UID:             "7fa8b0d2-cb46-4dd2-8b4b-2b4f4b5b0e1a",
+			ResourceVersion: "1",
+			Images: []v1alpha1.ImagePattern{{
+				Glob: "gcr.io/*/*",
+			}},
+			Authorities: []webhookcip.Authority{{
+				Name: "key",
+				Key: &webhookcip.KeyRef{
+					PublicKeys:        []crypto.PublicKey{authorityKeyCosignPub},
+					HashAlgorithm:     signaturealgo.DefaultSignatureAlgorithm,
+					HashAlgorithmCode: crypto.SHA256,
+				},
+			}},
+		},
+	}
+
+	pcConfig := &policycontrollerconfig.PolicyControllerConfig{
+		NoMatchPolicy:         policycontrollerconfig.DenyAll,
+		SkipUnchangedImages:   true,
+		OwnerTrustChainSecret: "trust-chain",
+	}
+	testCtx := context.WithValue(context.Background(), kubeclient.Key{}, kc)
+	testCtx = policycontrollerconfig.ToContext(testCtx, pcConfig)
+	testCtx = config.ToContext(testCtx, &config.Config{
+		ImagePolicyConfig: &config.ImagePolicyConfig{Policies: policies},
+	})
+
+	old := &duckv1.WithPod{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "deployment",
+			Namespace: "default",
+		},
+		Spec: duckv1.WithPodSpec{
+			Template: duckv1.PodSpecable{
+				Spec: corev1.PodSpec{
+					Containers: []corev1.Container{{
+						Name:  "user-container",
+						Image: digest.String(),
+					}},
+				},
+			},
+		},
+	}
+	v.ResolvePodSpecable(apis.WithinCreate(testCtx), old)
+	if _, ok := old.Annotations[VerifiedPoliciesAnnotation]; !ok {
+		t.Fatal("ResolvePodSpecable() did not record the verified policies")
+	}
+	if err := v.ValidatePodSpecable(apis.WithinCreate(testCtx), old); err != nil {
+		t.Fatalf("ValidatePodSpecable() = %v", err)
+	}
+
+	// From now on, the registry is down.
+	verified = false
+	updated := func() *duckv1.WithPod {
+		wp := old.DeepCopy()
+		wp.Annotations["kubectl.kubernetes.io/restartedAt"] = "2022-01-01T00:00:00Z"
+		return wp
+	}
+
+	// The unchanged image is not verified again.
+	if err := v.ValidatePodSpecable(apis.WithinUpdate(testCtx, old), updated()); err != nil {
+		t.Errorf("ValidatePodSpecable() with an unchanged image = %v", err)
+	}
+
+	// A record that was not made by the webhook is not trusted, e.g. one
+	// made before the namespace was opted in.
+	forged := old.DeepCopy()
+	fingerprint, _ := policiesFingerprint(testCtx, policies)
+	b, err := json.Marshal(map[string]string{digest.String(): fingerprint})
+	if err != nil {
+		t.Fatalf("Failed to marshal verified policies: %v", err)
+	}
+	forged.Annotations[VerifiedPoliciesAnnotation] = string(b)
+	if err := v.ValidatePodSpecable(apis.WithinUpdate(testCtx, forged), updated()); err == nil {
+		t.Error("ValidatePodSpecable() with forged verified policies succeeded")
+	}
+
+	// Nor is the record of another namespace.
+	kc.CoreV1().ServiceAccounts("other").Create(ctx, &corev1.ServiceAccount{
+		ObjectMeta: metav1.ObjectMeta{
+			Name: "default",
+		},
+	}, metav1.CreateOptions{})
+	verified = true
+	moved := old.DeepCopy()
+	moved.Namespace = "other"
+	if err := v.ValidatePodSpecable(apis.WithinUpdate(testCtx, moved), moved); err != nil {
+		t.Fatalf("ValidatePodSpecable() in another namespace = %v", err)
+	}
+	verified = false
+	movedUpdate := updated()
+	movedUpdate.Namespace = "other"
+	if err := v.ValidatePodSpecable(apis.WithinUpdate(testCtx, moved), movedUpdate); err == nil {
+		t.Error("ValidatePodSpecable() with the verified policies of another namespace succeeded")
+	}
+
+	// A changed image is verified.
+	wp := updated()
+	wp.Spec.Template.Spec.Containers[0].Image = otherDigest.String()
+	if err := v.ValidatePodSpecable(apis.WithinUpdate(testCtx, old), wp); err == nil {
+		t.Error("ValidatePodSpecable() with a changed image succeeded")
+	}
+
+	// An image moved from a container to an image volume is verified.
+	wp = updated()
+	wp.Spec.Template.Spec.Containers[0].Image = otherDigest.String()
+	wp.Spec.Template.Spec.Volumes = []corev1.Volume{{
+		Name: "artifact",
+		VolumeSource: corev1.VolumeSource{
+			Image: &corev1.ImageVolumeSource{Reference: digest.String()},
+		},
+	}}
+	if err := v.ValidatePodSpecable(apis.WithinUpdate(testCtx, old), wp); err == nil {
+		t.Error("ValidatePodSpecable() with a moved image succeeded")
+	}
+
+	// Nothing is skipped unless configured.
+	pcConfig.SkipUnchangedImages = false
+	if err := v.ValidatePodSpecable(apis.WithinUpdate(testCtx, old), updated()); err == nil {
+		t.Error("ValidatePodSpecable() without skip-unchanged-images succeeded")
+	}
+	wp = updated()
+	v.ResolvePodSpecable(apis.WithinUpdate(testCtx, old), wp)
+	if got, ok := wp.Annotations[VerifiedPoliciesAnnotation]; ok {
+		t.Errorf("ResolvePodSpecable() left the verified policies %q", got)
+	}
+	pcConfig.SkipUnchangedImages = true
+
+	// Nor without the key of the owner trust chain.
+	pcConfig.OwnerTrustChainSecret = ""
+	if err := v.ValidatePodSpecable(apis.WithinUpdate(testCtx, old), updated()); err == nil {
+		t.Error("ValidatePodSpecable() without owner-trust-chain-secret succeeded")
+	}
+	wp = updated()
+	v.ResolvePodSpecable(apis.WithinUpdate(testCtx, old), wp)
+	if got, ok := wp.Annotations[VerifiedPoliciesAnnotation]; ok {
+		t.Errorf("ResolvePodSpecable() left the verified policies %q", got)
+	}
+	pcConfig.OwnerTrustChainSecret = "trust-chain"
+
+	// The unchanged image is verified again once the compiled policy
+	// changes, even if its resourceVersion does not.
+	policies["cluster-image-policy"].Authorities[0].Key.Data = authorityKeyCosignPubString
+	if err := v.ValidatePodSpecable(apis.WithinUpdate(testCtx, old), updated()); err == nil {
+		t.Error("ValidatePodSpecable() after a key change succeeded")
+	}
+	policies["cluster-image-policy"].Authorities[0].Key.Data = ""
+
+	// The unchanged image is verified again once the policy changes.
+	cip := policies["cluster-image-policy"]
+	cip.ResourceVersion = "2"
+	policies["cluster-image-policy"] = cip
+	if err := v.ValidatePodSpecable(apis.WithinUpdate(testCtx, old), updated()); err == nil {
+		t.Error("ValidatePodSpecable() after a policy change succeeded")
+	}
+
+	// Images that passed a policy in warn mode are not recorded.
+	cip.Mode = "warn"
+	policies["cluster-image-policy"] = cip
+	wp = updated()
+	v.ResolvePodSpecable(apis.WithinUpdate(testCtx, old), wp)
+	if got, ok := wp.Annotations[VerifiedPoliciesAnnotation]; ok {
+		t.Errorf("ResolvePodSpecable() recorded the verified policies %q for a policy in warn mode", got)
+	}
+}
+
+func TestValidatePolicy(t *testing.T) {
+	// Resolved via crane digest on 2021/09/25
+	digest := name.MustParseReference("gcr.io/distroless/static:nonroot@sha256:be5d77c62dbe7fedfb0a4e5ec2f91078080800ab1f18358e5f31fcc8faa023c4")
+
+	// Non-existent URL for testing complete failure
+	badURL := apis.HTTP("http://example.com/")
+	t.Logf("badURL: %s", badURL.String())
+
+	// Spin up a Fulcio that responds with a Root Cert
+	fulcioServer := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, _ *http.Request) {
+		rw.Write([]byte(fulcioRootCert))
+	}))
+	t.Cleanup(fulcioServer.Close)
+
+	fulcioURL, err := apis.ParseURL("https://fulcio.sigstore.dev")
+	if err != nil {
+		t.Fatalf("Failed to parse fake Fulcio URL")
+	}
+
+	rekorServer := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, _ *http.Request) {
+		rw.Write([]byte(rekorResponse))
+	}))
+	t.Cleanup(rekorServer.Close)
+	rekorURL, err := apis.ParseURL(rekorServer.URL)
+	if err != nil {
+		t.Fatalf("Failed to parse fake Rekor URL")
+	}
+	t.Logf("rekorURL: %s", rekorURL.String())
+	var authorityKeyCosignPub *ecdsa.PublicKey
+
+	pems := parsePems([]byte(authorityKeyCosignPubString))
+	if len(pems) > 0 {
+		key, _ := x509.ParsePKIXPublicKey(pems[0].Bytes)
+		authorityKeyCosignPub = key.(*ecdsa.PublicKey)
+	} else {
+		t.Errorf("Error parsing authority key from string")
+	}
+
+	cvs := cosignVerifySignatures
+	defer func() {
+		cosignVerifySignatures = cvs
+	}()
+	// Let's just say that everything is verified.
+	pass := func(_ context.Context, _ name.Reference, _ *cosign.CheckOpts) (checkedSignatures []oci.Signature, bundleVerified bool, err error) {
+		sig, err := static.NewSignature(nil, "")
+		if err != nil {
+			return nil, false, err
+		}
+		return []oci.Signature{sig}, true, nil
+	}
+	// Let's just say that everything is verified.
+	passKeyless := func(_ context.Context, _ name.Reference, _ *cosign.CheckOpts) (checkedSignatures []oci.Signature, bundleVerified bool, err error) {
+		// This is from 2022/07/29
+		// ghcr.io/distroless/static@sha256:a1e82f6a5f6dfc735165d3442e7cc5a615f72abac3db19452481f5f3c90fbfa8
+		payload := []byte(`{"payloadType":"application/vnd.in-toto+json","payload":"eyJfdHlwZSI6Imh0dHBzOi8vaW4tdG90by5pby9TdGF0ZW1lbnQvdjAuMSIsInByZWRpY2F0ZVR5cGUiOiJodHRwczovL2Nvc2lnbi5zaWdzdG9yZS5kZXYvYXR0ZXN0YXRpb24vdnVsbi92MSIsInN1YmplY3QiOlt7Im5hbWUiOiJnaGNyLmlvL2Rpc3Ryb2xlc3Mvc3RhdGljIiwiZGlnZXN0Ijp7InNoYTI1NiI6ImExZTgyZjZhNWY2ZGZjNzM1MTY1ZDM0NDJlN2NjNWE2MTVmNzJhYmFjM2RiMTk0NTI0ODFmNWYzYzkwZmJmYTgifX1dLCJwcmVkaWNhdGUiOnsiaW52b2NhdGlvbiI6eyJwYXJhbWV0ZXJzIjpudWxsLCJ1cmkiOiJodHRwczovL2dpdGh1Yi5jb20vZGlzdHJvbGVzcy9zdGF0aWMvYWN0aW9ucy9ydW5zLzI3NTc5NTMxMzkiLCJldmVudF9pZCI6IjI3NTc5NTMxMzkiLCJidWlsZGVyLmlkIjoiQ3JlYXRlIFJlbGVhc2UifSwic2Nhbm5lciI6eyJ1cmkiOiJodHRwczovL2dpdGh1Yi5jb20vYXF1YXNlY3VyaXR5L3RyaXZ5IiwidmVyc2lvbiI6IjAuMjkuMiIsImRiIjp7InVyaSI6IiIsInZlcnNpb24iOiIifSwicmVzdWx0Ijp7IiRzY2hlbWEiOiJodHRwczovL2pzb24uc2NoZW1hc3RvcmUub3JnL3NhcmlmLTIuMS4wLXJ0bS41Lmpzb24iLCJydW5zIjpbeyJjb2x1bW5LaW5kIjoidXRmMTZDb2RlVW5pdHMiLCJvcmlnaW5hbFVyaUJhc2VJZHMiOnsiUk9PVFBBVEgiOnsidXJpIjoiZmlsZTovLy8ifX0sInJlc3VsdHMiOltdLCJ0b29sIjp7ImRyaXZlciI6eyJmdWxsTmFtZSI6IlRyaXZ5IFZ1bG5lcmFiaWxpdHkgU2Nhbm5lciIsImluZm9ybWF0aW9uVXJpIjoiaHR0cHM6Ly9naXRodWIuY29tL2FxdWFzZWN1cml0eS90cml2eSIsIm5hbWUiOiJUcml2eSIsInJ1bGVzIjpbXSwidmVyc2lvbiI6IjAuMjkuMiJ9fX1dLCJ2ZXJzaW9uIjoiMi4xLjAifX0sIm1ldGFkYXRhIjp7InNjYW5TdGFydGVkT24iOiIyMDIyLTA3LTI5VDAyOjI4OjQyWiIsInNjYW5GaW5pc2hlZE9uIjoiMjAyMi0wNy0yOVQwMjoyODo0OFoifX19","signatures":[{"keyid":"","sig":"MEYCIQDeQXMMojIpNvxEDLDXUC5aAwCbPPr/0uckP8TCcdTLjgIhAJG6M00kY40bz/C90W0FeUc2YcWY+txD4BPXhzd8E+tP"}]}`)
+		set, err := base64.StdEncoding.DecodeString("MEQCIDBYWwwDW+nH+1vFoTOqHS4jAtVm4Yezq2nAy7vjcV8zAiBkznmgMrz9em4NuB/hl5X/umubhLgwoXgUAY2NJJwu5A==")
+		if err != nil {
+			return nil, false, err
+		}
+		sig, err := static.NewSignature(payload, "", static.WithCertChain(
+			[]byte("-----BEGIN CERTIFICATE-----\nMIIDnDCCAyOgAwIBAgIUVGZ4TQgYi4VCLLFghYMU/taKrD8wCgYIKoZIzj0EAwMw\nNzEVMBMGA1UEChMMc2lnc3RvcmUuZGV2MR4wHAYDVQQDExVzaWdzdG9yZS1pbnRl\ncm1lZGlhdGUwHhcNMjIwNzI5MDIyODQ4WhcNMjIwNzI5MDIzODQ4WjAAMFkwEwYH\nKoZIzj0CAQYIKoZIzj0DAQcDQgAEhiVvK5Tqk1+HnXSstf/8byA1RDpZu+Jvn9X6\nZoaCL/IjSJ7fBakvKAQ0BlzFg/JEtDreg/TFNiX2wnlMBlMV16OCAkIwggI+MA4G\nA1UdDwEB/wQEAwIHgDATBgNVHSUEDDAKBggrBgEFBQcDAzAdBgNVHQ4EFgQUiMn3\nza+9v+99n385GpkXzZxZiBIwHwYDVR0jBBgwFoAU39Ppz1YkEZb5qNjpKFWixi4Y\nZD8wYQYDVR0RAQH/BFcwVYZTaHR0cHM6Ly9naXRodWIuY29tL2Rpc3Ryb2xlc3Mv\nc3RhdGljLy5naXRodWIvd29ya2Zsb3dzL3JlbGVhc2UueWFtbEByZWZzL2hlYWRz\nL21haW4wOQYKKwYBBAGDvzABAQQraHR0cHM6Ly90b2tlbi5hY3Rpb25zLmdpdGh1\nYnVzZXJjb250ZW50LmNvbTAWBgorBgEEAYO/MAECBAhzY2hlZHVsZTA2BgorBgEE\nAYO/MAEDBCg3ZTc1NzJlNTc4ZGU3YzUxYTJmMWExNzkxZjAyNWNmMzE1NTAzYWEy\nMBwGCisGAQQBg78wAQQEDkNyZWF0ZSBSZWxlYXNlMB8GCisGAQQBg78wAQUEEWRp\nc3Ryb2xlc3Mvc3RhdGljMB0GCisGAQQBg78wAQYED3JlZnMvaGVhZHMvbWFpbjCB\nigYKKwYBBAHWeQIEAgR8BHoAeAB2AAhgkvAoUv9oRdHRayeEnEVnGKwWPcM40m3m\nvCIGNm9yAAABgkfI9c8AAAQDAEcwRQIgPm4AoftGQF2abbFxMLvtzTjXy+sxwxTp\nCh5ZsoesBDMCIQCNlwmLpuu1KiqjY74l5527AffSd4kOapDMfpHAlMrpCTAKBggq\nhkjOPQQDAwNnADBkAjAe7jfVc1OJNhbaZF8BJRJ9nQOAcY6kwFYMav1XfQsJPE0x\naYpNg/oXVA5UrFcSBLkCMFa4124w3qUzrXSTGq99nlALKQ8HFR8ri17wM5/ZiWxi\nrtABq5eub32TXpAnfqGSmw==\n-----END CERTIFICATE-----\n"),
+			[]byte("-----BEGIN CERTIFICATE-----\nMIICGjCCAaGgAwIBAgIUALnViVfnU0brJasmRkHrn/UnfaQwCgYIKoZIzj0EAwMw\nKjEVMBMGA1UEChMMc2lnc3RvcmUuZGV2MREwDwYDVQQDEwhzaWdzdG9yZTAeFw0y\nMjA0MTMyMDA2MTVaFw0zMTEwMDUxMzU2NThaMDcxFTATBgNVBAoTDHNpZ3N0b3Jl\nLmRldjEeMBwGA1UEAxMVc2lnc3RvcmUtaW50ZXJtZWRpYXRlMHYwEAYHKoZIzj0C\nAQYFK4EEACIDYgAE8RVS/ysH+NOvuDZyPIZtilgUF9NlarYpAd9HP1vBBH1U5CV7\n7LSS7s0ZiH4nE7Hv7ptS6LvvR/STk798LVgMzLlJ4HeIfF3tHSaexLcYpSASr1kS\n0N/RgBJz/9jWCiXno3sweTAOBgNVHQ8BAf8EBAMCAQYwEwYDVR0lBAwwCgYIKwYB\nBQUHAwMwEgYDVR0TAQH/BAgwBgEB/wIBADAdBgNVHQ4EFgQU39Ppz1YkEZb5qNjp\nKFWixi4YZD8wHwYDVR0jBBgwFoAUWMAeX5FFpWapesyQoZMi0CrFxfowCgYIKoZI\nzj0EAwMDZwAwZAIwPCsQK4DYiZYDPIaDi5HFKnfxXx6ASSVmERfsynYBiX2X6SJR\nnZU84/9DZdnFvvxmAjBOt6QpBlc4J/0DxvkTCqpclvziL6BCCPnjdlIB3Pu3BxsP\nmygUY7Ii2zbdCdliiow=\n-----END CERTIFICATE-----\n-----BEGIN CERTIFICATE-----\nMIIB9zCCAXygAwIBAgIUALZNAPFdxHPwjeDloDwyYChAO/4wCgYIKoZIzj0EAwMw\nKjEVMBMGA1UEChMMc2lnc3RvcmUuZGV2MREwDwYDVQQDEwhzaWdzdG9yZTAeFw0y\nMTEwMDcxMzU2NTlaFw0zMTEwMDUxMzU2NThaMCoxFTATBgNVBAoTDHNpZ3N0b3Jl\nLmRldjERMA8GA1UEAxMIc2lnc3RvcmUwdjAQBgcqhkjOPQIBBgUrgQQAIgNiAAT7\nXeFT4rb3PQGwS4IajtLk3/OlnpgangaBclYpsYBr5i+4ynB07ceb3LP0OIOZdxex\nX69c5iVuyJRQ+Hz05yi+UF3uBWAlHpiS5sh0+H2GHE7SXrk1EC5m1Tr19L9gg92j\nYzBhMA4GA1UdDwEB/wQEAwIBBjAPBgNVHRMBAf8EBTADAQH/MB0GA1UdDgQWBBRY\nwB5fkUWlZql6zJChkyLQKsXF+jAfBgNVHSMEGDAWgBRYwB5fkUWlZql6zJChkyLQ\nKsXF+jAKBggqhkjOPQQDAwNpADBmAjEAj1nHeXZp+13NWBNa+EDsDP8G1WWg1tCM\nWP/WHPqpaVo0jhsweNFZgSs0eE7wYI4qAjEA2WB9ot98sIkoF3vZYdd3/VtWB5b9\nTNMea7Ix/stJ5TfcLLeABLE4BNJOsQ4vnBHJ\n-----END CERTIFICATE-----"),
+		), static.WithBundle(&bundle.RekorBundle{
+			SignedEntryTimestamp: set,
+			Payload: bundle.RekorPayload{
+				Body:           "eyJhcGlWZXJzaW9uIjoiMC4wLjEiLCJraW5kIjoiaW50b3RvIiwic3BlYyI6eyJjb250ZW50Ijp7Imhhc2giOnsiYWxnb3JpdGhtIjoic2hhMjU2IiwidmFsdWUiOiIyYjY1Y2JmMGU3OTAxYmEzMWQ1NWIxMmQzMTliY2EzOTQyMGFmNDM4OGQzZTU3MTRkMTZmMjAxOWQ3NGUzYWI3In0sInBheWxvYWRIYXNoIjp7ImFsZ29yaXRobSI6InNoYTI1NiIsInZhbHVlIjoiYzFiNWYwZjRiOGVjZDU1ZWRhMjUwY2Q4NDk2NGQwYzFmYjVkN2E4YTM0OGY0YjdmZmI3ZGFhMmUwNmM0ODM3MyJ9fSwicHVibGljS2V5IjoiTFMwdExTMUNSVWRKVGlCRFJWSlVTVVpKUTBGVVJTMHRMUzB0Q2sxSlNVUnVSRU5EUVhsUFowRjNTVUpCWjBsVlZrZGFORlJSWjFscE5GWkRURXhHWjJoWlRWVXZkR0ZMY2tRNGQwTm5XVWxMYjFwSmVtb3dSVUYzVFhjS1RucEZWazFDVFVkQk1WVkZRMmhOVFdNeWJHNWpNMUoyWTIxVmRWcEhWakpOVWpSM1NFRlpSRlpSVVVSRmVGWjZZVmRrZW1SSE9YbGFVekZ3WW01U2JBcGpiVEZzV2tkc2FHUkhWWGRJYUdOT1RXcEpkMDU2U1RWTlJFbDVUMFJSTkZkb1kwNU5ha2wzVG5wSk5VMUVTWHBQUkZFMFYycEJRVTFHYTNkRmQxbElDa3R2V2tsNmFqQkRRVkZaU1V0dldrbDZhakJFUVZGalJGRm5RVVZvYVZaMlN6VlVjV3N4SzBodVdGTnpkR1l2T0dKNVFURlNSSEJhZFN0S2RtNDVXRFlLV205aFEwd3ZTV3BUU2pkbVFtRnJka3RCVVRCQ2JIcEdaeTlLUlhSRWNtVm5MMVJHVG1sWU1uZHViRTFDYkUxV01UWlBRMEZyU1hkblowa3JUVUUwUndwQk1WVmtSSGRGUWk5M1VVVkJkMGxJWjBSQlZFSm5UbFpJVTFWRlJFUkJTMEpuWjNKQ1owVkdRbEZqUkVGNlFXUkNaMDVXU0ZFMFJVWm5VVlZwVFc0ekNucGhLemwyS3prNWJqTTROVWR3YTFoNlduaGFhVUpKZDBoM1dVUldVakJxUWtKbmQwWnZRVlV6T1ZCd2VqRlphMFZhWWpWeFRtcHdTMFpYYVhocE5Ga0tXa1E0ZDFsUldVUldVakJTUVZGSUwwSkdZM2RXV1ZwVVlVaFNNR05JVFRaTWVUbHVZVmhTYjJSWFNYVlpNamwwVERKU2NHTXpVbmxpTW5oc1l6Tk5kZ3BqTTFKb1pFZHNha3g1Tlc1aFdGSnZaRmRKZG1ReU9YbGhNbHB6WWpOa2Vrd3pTbXhpUjFab1l6SlZkV1ZYUm5SaVJVSjVXbGRhZWt3eWFHeFpWMUo2Q2t3eU1XaGhWelIzVDFGWlMwdDNXVUpDUVVkRWRucEJRa0ZSVVhKaFNGSXdZMGhOTmt4NU9UQmlNblJzWW1rMWFGa3pVbkJpTWpWNlRHMWtjR1JIYURFS1dXNVdlbHBZU21waU1qVXdXbGMxTUV4dFRuWmlWRUZYUW1kdmNrSm5SVVZCV1U4dlRVRkZRMEpCYUhwWk1taHNXa2hXYzFwVVFUSkNaMjl5UW1kRlJRcEJXVTh2VFVGRlJFSkRaek5hVkdNeFRucEtiRTVVWXpSYVIxVXpXWHBWZUZsVVNtMU5WMFY0VG5wcmVGcHFRWGxPVjA1dFRYcEZNVTVVUVhwWlYwVjVDazFDZDBkRGFYTkhRVkZSUW1jM09IZEJVVkZGUkd0T2VWcFhSakJhVTBKVFdsZDRiRmxZVG14TlFqaEhRMmx6UjBGUlVVSm5OemgzUVZGVlJVVlhVbkFLWXpOU2VXSXllR3hqTTAxMll6TlNhR1JIYkdwTlFqQkhRMmx6UjBGUlVVSm5OemgzUVZGWlJVUXpTbXhhYmsxMllVZFdhRnBJVFhaaVYwWndZbXBEUWdwcFoxbExTM2RaUWtKQlNGZGxVVWxGUVdkU09FSkliMEZsUVVJeVFVRm9aMnQyUVc5VmRqbHZVbVJJVW1GNVpVVnVSVlp1UjB0M1YxQmpUVFF3YlROdENuWkRTVWRPYlRsNVFVRkJRbWRyWmtrNVl6aEJRVUZSUkVGRlkzZFNVVWxuVUcwMFFXOW1kRWRSUmpKaFltSkdlRTFNZG5SNlZHcFllU3R6ZUhkNFZIQUtRMmcxV25OdlpYTkNSRTFEU1ZGRFRteDNiVXh3ZFhVeFMybHhhbGszTkd3MU5USTNRV1ptVTJRMGEwOWhjRVJOWm5CSVFXeE5jbkJEVkVGTFFtZG5jUXBvYTJwUFVGRlJSRUYzVG01QlJFSnJRV3BCWlRkcVpsWmpNVTlLVG1oaVlWcEdPRUpLVWtvNWJsRlBRV05aTm10M1JsbE5ZWFl4V0daUmMwcFFSVEI0Q21GWmNFNW5MMjlZVmtFMVZYSkdZMU5DVEd0RFRVWmhOREV5TkhjemNWVjZjbGhUVkVkeE9UbHViRUZNUzFFNFNFWlNPSEpwTVRkM1RUVXZXbWxYZUdrS2NuUkJRbkUxWlhWaU16SlVXSEJCYm1aeFIxTnRkejA5Q2kwdExTMHRSVTVFSUVORlVsUkpSa2xEUVZSRkxTMHRMUzBLIn19",
+				IntegratedTime: 1659061729,
+				LogIndex:       3059470,
+				LogID:          "c0d23d6ad406973f9559f3ba2d1ca01f84147d8ffc5b8445c224f98b9591801d",
+			},
+		}))
+		if err != nil {
+			return nil, false, err
+		}
+		return []oci.Signature{sig}, true, nil
+	}
+	// Let's just say that everything is not verified.
+	fail := func(_ context.Context, _ name.Reference, _ *cosign.CheckOpts) (checkedSignatures []oci.Signature, bundleVerified bool, err error) {
+		return nil, false, errors.New("bad signature")
+	}
+
+	// Let's say it is verified if it is the expected Public Key
+	authorityPublicKeyCVS := func(ctx context.Context, signedImgRef name.Reference, co *cosign.CheckOpts) (checkedSignatures []oci.Signature, bundleVerified bool, err error) {
+		// Because we use this below and it gets called for both key / keyless
+		// in the keyless case there's no SigVerifier, so fail it.
+		if co.SigVerifier == nil {
+			return nil, false, errors.New("Keyless used for key")
+		}
+		actualPublicKey, _ := co.SigVerifier.PublicKey()
+		actualECDSAPubkey := actualPublicKey.(*ecdsa.PublicKey)
+
+		actualPubKey, err := actualECDSAPubkey.ECDH()
+		if err != nil {
+			return nil, false, errors.New("failed to get edch pub key")
+		}
+
+		authorityKeyPubKey, err := authorityKeyCosignPub.ECDH()
+		if err != nil {
+			return nil, false, errors.New("failed to get edch pub key")
+		}
+
+		if bytes.Equal(actualPubKey.Bytes(), authorityKeyPubKey.Bytes()) {
+			return pass(ctx, signedImgRef, co)
+		}
+
+		return fail(ctx, signedImgRef, co)
+	}
+
+	tests := []struct {
+		name          string
+		policy        webhookcip.ClusterImagePolicy
+		want          *PolicyResult
+		wantErrs      []string
+		cva           func(context.Context, name.Reference, *cosign.CheckOpts) ([]oci.Signature, bool, error)
+		cvs           func(context.Context, name.Reference, *cosign.CheckOpts) ([]oci.Signature, bool, error)
+		customContext context.Context
+	}{{
+		name: "fail with no public key",
+		policy: webhookcip.ClusterImagePolicy{
+			Authorities: []webhookcip.Authority{{
+				Name: "authority-0",
+				Key:  &webhookcip.KeyRef{},
+			}},
+		},
+		wantErrs: []string{"there are no public keys for authority authority-0"},
+	}, {
+		name: "simple, public key, no matches",
+		policy: webhookcip.ClusterImagePolicy{
+			Authorities: []webhookcip.Authority{{
+				Name: "authority-0",
+				Key: &webhookcip.KeyRef{
+					PublicKeys:        []crypto.PublicKey{authorityKeyCosignPub},
+					HashAlgorithm:     signaturealgo.DefaultSignatureAlgorithm,
+					HashAlgorithmCode: crypto.SHA256,
+				},
+			}},
+		},
+		wantErrs: []string{"key validation failed for authority authority-0 for gcr.io/distroless/static@sha256:be5d77c62dbe7fedfb0a4e5ec2f91078080800ab1f18358e5f31fcc8faa023c4: bad signature"},
+		cvs:      fail,
+	}, {
+		name: "simple, public key, works",
+		policy: webhookcip.ClusterImagePolicy{
+			Authorities: []webhookcip.Authority{{
+				Name: "authority-0",
+				Key: &webhookcip.KeyRef{
+					PublicKeys:        []crypto.PublicKey{authorityKeyCosignPub},
+					HashAlgorithm:     signaturealgo.DefaultSignatureAlgorithm,
+					HashAlgorithmCode: crypto.SHA256,
+				},
+			}},
+		},
+		want: &PolicyResult{
+			AuthorityMatches: map[string]AuthorityMatch{
+				"authority-0": {
+					Signatures: []PolicySignature{{
+						ID:    "e3b0c44298fc1c149afbf4c8996fb92427ae41e4649b934ca495991b7852b855",
+						KeyID: authorityKeyCosignPubID,
+					}},
+				}},
+		},
+		cvs: pass,
+	}, {
+		name: "simple, public key and keyless, one works, one doesn't",
+		policy: webhookcip.ClusterImagePolicy{
+			Authorities: []webhookcip.Authority{{
+				Name: "authority-0",
+				Key: &webhookcip.KeyRef{
+					PublicKeys:        []crypto.PublicKey{authorityKeyCosignPub},
+					HashAlgorithm:     signaturealgo.DefaultSignatureAlgorithm,
+					HashAlgorithmCode: crypto.SHA256,
+				},
+			}, {
+				Name: "authority-1",
+				Keyless: &webhookcip.KeylessRef{
+					URL: badURL,
+				},
+			}},
+		},
+		want: &PolicyResult{
+			AuthorityMatches: map[string]AuthorityMatch{
+				"authority-0": {
+					Signatures: []PolicySignature{{
+						ID:    "e3b0c44298fc1c149afbf4c8996fb92427ae41e4649b934ca495991b7852b855",
+						KeyID: authorityKeyCosignPubID,
+					}},
+				}},
+		},
+		wantErrs: []string{`signature keyless validation failed for authority authority-1 for gcr.io/distroless/static@sha256:be5d77c62dbe7fedfb0a4e5ec2f91078080800ab1f18358e5f31fcc8faa023c4: Keyless used for key`},
+		cvs:      authorityPublicKeyCVS,
+	}, {
+		name: "simple, static set to pass",
+		policy: webhookcip.ClusterImagePolicy{
+			Authorities: []webhookcip.Authority{{
+				Name: "authority-0",
+				Static: &webhookcip.StaticRef{
+					Action: "pass",
+				},
+			}},
+		},
+		want: &PolicyResult{
+			AuthorityMatches: map[string]AuthorityMatch{
+				"authority-0": {
+					Static: true,
+				},
+			},
+		},
+	}, {
+		name: "simple, static set to fail",
+		policy: webhookcip.ClusterImagePolicy{
+			Authorities: []webhookcip.Authority{{
+				Name: "authority-0",
+				Static: &webhookcip.StaticRef{
+					Action: "fail",
+				},
+			}},
+		},
+		wantErrs: []string{"disallowed by static policy"},
+	}, {
+		name: "simple, static set to fail with custom message",
+		policy: webhookcip.ClusterImagePolicy{
+			Authorities: []webhookcip.Authority{{
+				Name: "authority-0",
+				Static: &webhookcip.StaticRef{
+					Action:  "fail",
+					Message: "test custom message here",
+				},
+			}},
+		},
+		wantErrs: []string{"disallowed by static policy: test custom message here"},
+	}, {
+		name: "simple, public key, no error",
+		policy: webhookcip.ClusterImagePolicy{
+			Authorities: []webhookcip.Authority{{
+				Name: "authority-0",
+				Key: &webhookcip.KeyRef{
+					PublicKeys:        []crypto.PublicKey{authorityKeyCosignPub},
+					HashAlgorithm:     signaturealgo.DefaultSignatureAlgorithm,
+					HashAlgorithmCode: crypto.SHA256,
+				},
+			}},
+		},
+		want: &PolicyResult{
+			AuthorityMatches: map[string]AuthorityMatch{
+				"authority-0": {
+					Signatures: []PolicySignature{{
+						ID:    "e3b0c44298fc1c149afbf4c8996fb92427ae41e4649b934ca495991b7852b855",
+						KeyID: authorityKeyCosignPubID,
+					}},
+				}},
+		},
+		cvs: authorityPublicKeyCVS,
+	}, {
+		name: "simple, keyless attestation, works",
+		policy: webhookcip.ClusterImagePolicy{
+			Authorities: []webhookcip.Authority{{
+				Name: "authority-0",
+				Keyless: &webhookcip.KeylessRef{
+					URL: fulcioURL,
+				},
 				Attestations: []webhookcip.AttestationPolicy{{
 					Name:          "test-att",
 					PredicateType: "vuln",
@@ -2191,10 +2931,10 @@ func TestValidatePodSpecNonDefaultNamespace(t *testing.T) {
 		),
 		want: func() *apis.FieldError {
 			var errs *apis.FieldError
-			fe := apis.ErrGeneric("failed policy: cluster-image-policy-keyless", "image").ViaFieldIndex("containers", 0)
+			fe := apis.ErrGeneric("failed policy: cluster-image-policy-keyless: verification failed", "image").ViaFieldIndex("containers", 0)
 			fe.Details = fmt.Sprintf("%s %s", digest.String(), `signature keyless validation failed for authority  for gcr.io/distroless/static@sha256:be5d77c62dbe7fedfb0a4e5ec2f91078080800ab1f18358e5f31fcc8faa023c4: bad signature`)
 			errs = errs.Also(fe)
-			fe2 := apis.ErrGeneric("failed policy: cluster-image-policy-keyless", "image").ViaFieldIndex("initContainers", 0)
+			fe2 := apis.ErrGeneric("failed policy: cluster-image-policy-keyless: verification failed", "image").ViaFieldIndex("initContainers", 0)
 			fe2.Details = fe.Details
 			errs = errs.Also(fe2)
 			return errs
@@ -2234,10 +2974,10 @@ func TestValidatePodSpecNonDefaultNamespace(t *testing.T) {
 		),
 		want: func() *apis.FieldError {
 			var errs *apis.FieldError
-			fe := apis.ErrGeneric("failed policy: cluster-image-policy-keyless", "image").ViaFieldIndex("initContainers", 0)
+			fe := apis.ErrGeneric("failed policy: cluster-image-policy-keyless: verification failed", "image").ViaFieldIndex("initContainers", 0)
 			fe.Details = fmt.Sprintf("%s signature keyless validation failed for authority  for %s: bad signature", digest.String(), digest.Name())
 			errs = errs.Also(fe)
-			fe2 := apis.ErrGeneric("failed policy: cluster-image-policy-keyless", "image").ViaFieldIndex("containers", 0)
+			fe2 := apis.ErrGeneric("failed policy: cluster-image-policy-keyless: verification failed", "image").ViaFieldIndex("containers", 0)
 			fe2.Details = fmt.Sprintf("%s signature keyless validation failed for authority  for %s: bad signature", digest.String(), digest.Name())
 			errs = errs.Also(fe2)
 			return errs
@@ -2282,10 +3022,10 @@ func TestValidatePodSpecNonDefaultNamespace(t *testing.T) {
 		),
 		want: func() *apis.FieldError {
 			var errs *apis.FieldError
-			fe := apis.ErrGeneric("failed policy: cluster-image-policy-keyless-bad-cip", "image").ViaFieldIndex("initContainers", 0)
+			fe := apis.ErrGeneric("failed policy: cluster-image-policy-keyless-bad-cip: policy evaluation failed", "image").ViaFieldIndex("initContainers", 0)
 			fe.Details = fmt.Sprintf("%s failed evaluating cue policy for ClusterImagePolicy: failed to compile the cue policy with error: string literal not terminated", digest.String())
 			errs = errs.Also(fe)
-			fe2 := apis.ErrGeneric("failed policy: cluster-image-policy-keyless-bad-cip", "image").ViaFieldIndex("containers", 0)
+			fe2 := apis.ErrGeneric("failed policy: cluster-image-policy-keyless-bad-cip: policy evaluation failed", "image").ViaFieldIndex("containers", 0)
 			fe2.Details = fmt.Sprintf("%s failed evaluating cue policy for ClusterImagePolicy: failed to compile the cue policy with error: string literal not terminated", digest.String())
 			errs = errs.Also(fe2)
 			return errs
@@ -2361,10 +3101,10 @@ func TestValidatePodSpecNonDefaultNamespace(t *testing.T) {
 		),
 		want: func() *apis.FieldError {
 			var errs *apis.FieldError
-			fe := apis.ErrGeneric("failed policy: cluster-image-policy-keyless", "image").ViaFieldIndex("initContainers", 0)
+			fe := apis.ErrGeneric("failed policy: cluster-image-policy-keyless: verification failed", "image").ViaFieldIndex("initContainers", 0)
 			fe.Details = fmt.Sprintf("%s signature keyless validation failed for authority  for %s: bad signature", digest.String(), digest.Name())
 			errs = errs.Also(fe)
-			fe2 := apis.ErrGeneric("failed policy: cluster-image-policy-keyless", "image").ViaFieldIndex("containers", 0)
+			fe2 := apis.ErrGeneric("failed policy: cluster-image-policy-keyless: verification failed", "image").ViaFieldIndex("containers", 0)
 			fe2.Details = fmt.Sprintf("%s signature keyless validation failed for authority  for %s: bad signature", digest.String(), digest.Name())
 			errs = errs.Also(fe2)
 			return errs
@@ -2773,59 +3513,470 @@ func TestValidatePolicyCancelled(t *testing.T) {
 	// Resolved via crane digest on 2021/09/25
 	digest := name.MustParseReference("gcr.io/distroless/static:nonroot@sha256:be5d77c62dbe7fedfb0a4e5ec2f91078080800ab1f18358e5f31fcc8faa023c4")
 
-	testContext, cancelFunc := context.WithCancel(context.Background())
-	cip := webhookcip.ClusterImagePolicy{
-		Authorities: []webhookcip.Authority{{
-			Name: "authority-0",
-			Key: &webhookcip.KeyRef{
-				PublicKeys:        []crypto.PublicKey{authorityKeyCosignPub},
-				HashAlgorithm:     signaturealgo.DefaultSignatureAlgorithm,
-				HashAlgorithmCode: crypto.SHA256,
-			},
-		}},
+	testContext, cancelFunc := context.WithCancel(context.Background())
+	cip := webhookcip.ClusterImagePolicy{
+		Authorities: []webhookcip.Authority{{
+			Name: "authority-0",
+			Key: &webhookcip.KeyRef{
+				PublicKeys:        []crypto.PublicKey{authorityKeyCosignPub},
+				HashAlgorithm:     signaturealgo.DefaultSignatureAlgorithm,
+				HashAlgorithmCode: crypto.SHA256,
+			},
+		}},
+	}
+	kc, err := k8schain.NewNoClient(testContext)
+	if err != nil {
+		t.Fatalf("Failed to construct no client k8schain for testing")
+	}
+
+	wantErrs := []string{"context canceled before validation completed"}
+	cancelFunc()
+	_, gotErrs := ValidatePolicy(testContext, system.Namespace(), digest, cip, kc)
+	validateErrors(t, wantErrs, gotErrs)
+}
+
+func TestValidatePoliciesCancelled(t *testing.T) {
+	var authorityKeyCosignPub *ecdsa.PublicKey
+	pems := parsePems([]byte(authorityKeyCosignPubString))
+	if len(pems) > 0 {
+		key, _ := x509.ParsePKIXPublicKey(pems[0].Bytes)
+		authorityKeyCosignPub = key.(*ecdsa.PublicKey)
+	} else {
+		t.Errorf("Error parsing authority key from string")
+	}
+	// Resolved via crane digest on 2021/09/25
+	digest := name.MustParseReference("gcr.io/distroless/static:nonroot@sha256:be5d77c62dbe7fedfb0a4e5ec2f91078080800ab1f18358e5f31fcc8faa023c4")
+
+	testContext, cancelFunc := context.WithCancel(context.Background())
+	cip := webhookcip.ClusterImagePolicy{
+		Authorities: []webhookcip.Authority{{
+			Name: "authority-0",
+			Key: &webhookcip.KeyRef{
+				PublicKeys:        []crypto.PublicKey{authorityKeyCosignPub},
+				HashAlgorithm:     signaturealgo.DefaultSignatureAlgorithm,
+				HashAlgorithmCode: crypto.SHA256,
+			},
+		}},
+	}
+	kc, err := k8schain.NewNoClient(testContext)
+	if err != nil {
+		t.Fatalf("Failed to construct no client k8schain for testing")
+	}
+	wantErrs := []string{"context was canceled before validation completed"}
+	cancelFunc()
+	_, gotErrs := validatePolicies(testContext, system.Namespace(), digest, map[string]webhookcip.ClusterImagePolicy{"testcip": cip}, kc)
+	validateErrors(t, wantErrs, gotErrs["internalerror"])
+}
+
+func TestValidatePolicyTimeouts(t *testing.T) {
+	var authorityKeyCosignPub *ecdsa.PublicKey
+	pems := parsePems([]byte(authorityKeyCosignPubString))
+	if len(pems) > 0 {
+		key, _ := x509.ParsePKIXPublicKey(pems[0].Bytes)
+		authorityKeyCosignPub = key.(*ecdsa.PublicKey)
+	} else {
+		t.Errorf("Error parsing authority key from string")
+	}
+
+	// A registry that never answers.
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		select {
+		case <-r.Context().Done():
+		case <-time.After(10 * time.Second):
+		}
+	}))
+	defer server.Close()
+	u, err := url.Parse(server.URL)
+	if err != nil {
+		t.Fatalf("Failed to parse %s: %v", server.URL, err)
+	}
+	digest, err := name.ParseReference(u.Host+"/static@sha256:be5d77c62dbe7fedfb0a4e5ec2f91078080800ab1f18358e5f31fcc8faa023c4", name.Insecure)
+	if err != nil {
+		t.Fatalf("Failed to parse reference: %v", err)
+	}
+
+	cip := webhookcip.ClusterImagePolicy{
+		Authorities: []webhookcip.Authority{{
+			Name: "authority-0",
+			Key: &webhookcip.KeyRef{
+				PublicKeys:        []crypto.PublicKey{authorityKeyCosignPub},
+				HashAlgorithm:     signaturealgo.DefaultSignatureAlgorithm,
+				HashAlgorithmCode: crypto.SHA256,
+			},
+		}},
+	}
+	kc, err := k8schain.NewNoClient(context.Background())
+	if err != nil {
+		t.Fatalf("Failed to construct no client k8schain for testing")
+	}
+
+	for _, tc := range []struct {
+		name    string
+		cfg     *policycontrollerconfig.PolicyControllerConfig
+		wantErr string
+	}{{
+		name:    "registry operation timeout",
+		cfg:     &policycontrollerconfig.PolicyControllerConfig{RegistryOperationTimeout: 100 * time.Millisecond},
+		wantErr: "registry operation GET http://" + u.Host + "/v2/",
+	}, {
+		name:    "authority timeout",
+		cfg:     &policycontrollerconfig.PolicyControllerConfig{AuthorityTimeout: 100 * time.Millisecond},
+		wantErr: "authority authority-0 timed out after 100ms",
+	}, {
+		name:    "policy timeout",
+		cfg:     &policycontrollerconfig.PolicyControllerConfig{PolicyTimeout: 100 * time.Millisecond},
+		wantErr: "policy testcip timed out after 100ms",
+	}} {
+		t.Run(tc.name, func(t *testing.T) {
+			ctx := policycontrollerconfig.ToContext(context.Background(), tc.cfg)
+			_, gotErrs := validatePolicies(ctx, system.Namespace(), digest, map[string]webhookcip.ClusterImagePolicy{"testcip": cip}, kc)
+			if len(gotErrs["testcip"]) == 0 {
+				t.Fatalf("validatePolicies() did not fail")
+			}
+			for _, err := range gotErrs["testcip"] {
+				if !strings.Contains(err.Error(), tc.wantErr) {
+					t.Errorf("%q does not contain %q", err.Error(), tc.wantErr)
+				}
+				if got := classifyError(err); got != ReasonInfrastructure {
+					t.Errorf("classifyError() = %s, wanted %s", got, ReasonInfrastructure)
+				}
+			}
+		})
+	}
+}
+
+func TestValidatePolicyRemoteOptions(t *testing.T) {
+	var authorityKeyCosignPub *ecdsa.PublicKey
+	pems := parsePems([]byte(authorityKeyCosignPubString))
+	if len(pems) > 0 {
+		key, _ := x509.ParsePKIXPublicKey(pems[0].Bytes)
+		authorityKeyCosignPub = key.(*ecdsa.PublicKey)
+	} else {
+		t.Errorf("Error parsing authority key from string")
+	}
+
+	// A registry that has nothing, and records the user agents.
+	var mu sync.Mutex
+	var userAgents []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		userAgents = append(userAgents, r.UserAgent())
+		mu.Unlock()
+		w.WriteHeader(http.StatusNotFound)
+	}))
+	defer server.Close()
+	u, err := url.Parse(server.URL)
+	if err != nil {
+		t.Fatalf("Failed to parse %s: %v", server.URL, err)
+	}
+	digest, err := name.ParseReference(u.Host+"/static@sha256:be5d77c62dbe7fedfb0a4e5ec2f91078080800ab1f18358e5f31fcc8faa023c4", name.Insecure)
+	if err != nil {
+		t.Fatalf("Failed to parse reference: %v", err)
+	}
+
+	cip := webhookcip.ClusterImagePolicy{
+		Authorities: []webhookcip.Authority{{
+			Name: "authority-0",
+			Key: &webhookcip.KeyRef{
+				PublicKeys:        []crypto.PublicKey{authorityKeyCosignPub},
+				HashAlgorithm:     signaturealgo.DefaultSignatureAlgorithm,
+				HashAlgorithmCode: crypto.SHA256,
+			},
+		}},
+	}
+	kc, err := k8schain.NewNoClient(context.Background())
+	if err != nil {
+		t.Fatalf("Failed to construct no client k8schain for testing")
+	}
+
+	// The remote.Options of the caller, either in its context or in its
+	// options, are merged with those of the stage. The transport of the
+	// stage is not overridden by the ones of the authority.
+	cip.Authorities[0].RemoteOpts = []remote.Option{remote.WithRemoteOptions(ggcrremote.WithTransport(failingTransport{}))}
+	for _, c := range []struct {
+		name string
+		ctx  context.Context
+		opts []remote.Option
+	}{{
+		name: "context",
+		ctx:  WithRemoteOptions(context.Background(), ggcrremote.WithUserAgent("policy-tester")),
+	}, {
+		name: "options",
+		ctx:  context.Background(),
+		opts: []remote.Option{remote.WithRemoteOptions(ggcrremote.WithUserAgent("policy-tester"))},
+	}} {
+		t.Run(c.name, func(t *testing.T) {
+			mu.Lock()
+			userAgents = nil
+			mu.Unlock()
+			if _, gotErrs := ValidatePolicy(c.ctx, system.Namespace(), digest, cip, kc, c.opts...); len(gotErrs) == 0 {
+				t.Fatal("ValidatePolicy() did not fail")
+			}
+			mu.Lock()
+			defer mu.Unlock()
+			if len(userAgents) == 0 {
+				t.Fatal("ValidatePolicy() did not reach the registry")
+			}
+			for _, ua := range userAgents {
+				if !strings.Contains(ua, "policy-tester") {
+					t.Errorf("User-Agent = %q, wanted it to contain %q", ua, "policy-tester")
+				}
+			}
+		})
+	}
+}
+
+// failingTransport fails every request.
+type failingTransport struct{}
+
+func (failingTransport) RoundTrip(*http.Request) (*http.Response, error) {
+	return nil, errors.New("failing transport")
+}
+
+func TestRemoteOptions(t *testing.T) {
+	if got := RemoteOptions(); got != nil {
+		t.Errorf("RemoteOptions() = %v, wanted nil", got)
+	}
+	if got := RemoteOptions(remote.WithPrefix("prefix")); got != nil {
+		t.Errorf("RemoteOptions(WithPrefix) = %v, wanted nil", got)
+	}
+	// The last ociremote.WithRemoteOptions wins, like for ociremote.
+	got := RemoteOptions(
+		remote.WithRemoteOptions(ggcrremote.WithUserAgent("first")),
+		remote.WithRemoteOptions(ggcrremote.WithUserAgent("second"), ggcrremote.WithJobs(2)),
+		remote.WithPrefix("prefix"))
+	if len(got) != 2 {
+		t.Errorf("RemoteOptions() = %d options, wanted 2", len(got))
+	}
+}
+
+func TestValidatePoliciesCoalesced(t *testing.T) {
+	metrics.InitForTesting()
+	var authorityKeyCosignPub *ecdsa.PublicKey
+	pems := parsePems([]byte(authorityKeyCosignPubString))
+	if len(pems) > 0 {
+		key, _ := x509.ParsePKIXPublicKey(pems[0].Bytes)
+		authorityKeyCosignPub = key.(*ecdsa.PublicKey)
+	} else {
+		t.Errorf("Error parsing authority key from string")
+	}
+
+	// A registry without the image, that counts the requests and holds them
+	// until it is released.
+	var requests, aborted atomic.Int32
+	started := make(chan struct{}, 1)
+	release := make(chan struct{})
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests.Add(1)
+		select {
+		case started <- struct{}{}:
+		default:
+		}
+		select {
+		case <-release:
+		case <-r.Context().Done():
+			aborted.Add(1)
+			return
+		}
+		w.WriteHeader(http.StatusNotFound)
+	}))
+	defer server.Close()
+	u, err := url.Parse(server.URL)
+	if err != nil {
+		t.Fatalf("Failed to parse %s: %v", server.URL, err)
+	}
+	digest, err := name.ParseReference(u.Host+"/coalesced@sha256:be5d77c62dbe7fedfb0a4e5ec2f91078080800ab1f18358e5f31fcc8faa023c4", name.Insecure)
+	if err != nil {
+		t.Fatalf("Failed to parse reference: %v", err)
+	}
+	policies := map[string]webhookcip.ClusterImagePolicy{
+		"testcip": {
+			UID:             "test-uid",
+			ResourceVersion: "1",
+			Authorities: []webhookcip.Authority{{
+				Name: "authority-0",
+				Key: &webhookcip.KeyRef{
+					PublicKeys:        []crypto.PublicKey{authorityKeyCosignPub},
+					HashAlgorithm:     signaturealgo.DefaultSignatureAlgorithm,
+					HashAlgorithmCode: crypto.SHA256,
+				},
+			}},
+		},
+	}
+	kc, err := k8schain.NewNoClient(context.Background())
+	if err != nil {
+		t.Fatalf("Failed to construct no client k8schain for testing")
+	}
+	key := strings.Join([]string{system.Namespace(), keychainInputs(context.Background()), resultCacheImage(context.Background(), digest.String()), "testcip", "test-uid", "1"}, "|")
+
+	// Hold a flight for the validation, so that the concurrent validations
+	// all join it.
+	want := &PolicyResult{}
+	held := policyFlights.DoChan(key, func() (interface{}, error) {
+		<-release
+		return &CacheResult{PolicyResult: want}, nil
+	})
+	const concurrent = 5
+	joined := make(chan struct{}, concurrent+1)
+	jpf := joinPolicyFlight
+	defer func() {
+		joinPolicyFlight = jpf
+	}()
+	joinPolicyFlight = func(key string, fn func() (interface{}, error)) <-chan singleflight.Result {
+		defer func() { joined <- struct{}{} }()
+		return jpf(key, fn)
+	}
+	coalescedBefore := countPolicyVerifications(t, "true")
+
+	wg := new(sync.WaitGroup)
+	for i := 0; i < concurrent; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if got, gotErrs := validatePolicies(context.Background(), system.Namespace(), digest, policies, kc); got["testcip"] != want || len(gotErrs) != 0 {
+				t.Errorf("validatePolicies() = %v, %v, wanted the result of the flight", got, gotErrs)
+			}
+		}()
+	}
+
+	// A validation that gives up does not wait for the flight.
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if _, gotErrs := validatePolicies(ctx, system.Namespace(), digest, policies, kc); len(gotErrs) == 0 {
+		t.Error("canceled validatePolicies() did not fail")
+	}
+
+	for i := 0; i < concurrent+1; i++ {
+		<-joined
+	}
+	close(release)
+	<-held
+	wg.Wait()
+	if got := requests.Load(); got != 0 {
+		t.Errorf("coalesced validations made %d requests, wanted none", got)
+	}
+	if got := countPolicyVerifications(t, "true") - coalescedBefore; got != concurrent {
+		t.Errorf("%d coalesced validations were recorded, wanted %d", got, concurrent)
+	}
+	joinPolicyFlight = jpf
+
+	// The validation that starts the flight is canceled while it is in
+	// flight, which does not cancel the flight.
+	release = make(chan struct{})
+	ctx, cancel = context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		if _, gotErrs := validatePolicies(ctx, system.Namespace(), digest, policies, kc); len(gotErrs) == 0 {
+			t.Error("canceled validatePolicies() did not fail")
+		}
+	}()
+	<-started
+	cancel()
+	<-done
+	close(release)
+	// Wait for the flight to land.
+	policyFlights.Do(key, func() (interface{}, error) { return nil, nil })
+	if got := aborted.Load(); got != 0 {
+		t.Errorf("canceling the validation aborted %d requests of the flight", got)
+	}
+
+	// A flight started without a deadline is still bounded, so that a hung
+	// registry does not hold on to its key.
+	release = make(chan struct{})
+	defer close(release)
+	mpfd := maxPolicyFlightDuration
+	defer func() {
+		maxPolicyFlightDuration = mpfd
+	}()
+	maxPolicyFlightDuration = time.Second
+	if _, gotErrs := validatePolicies(context.Background(), system.Namespace(), digest, policies, kc); len(gotErrs) == 0 {
+		t.Error("hung validatePolicies() did not fail")
+	}
+	ran := false
+	policyFlights.Do(key, func() (interface{}, error) {
+		ran = true
+		return nil, nil
+	})
+	if !ran {
+		t.Error("the hung flight held on to its key")
+	}
+}
+
+func TestValidatePoliciesKeychainInputs(t *testing.T) {
+	ctx := context.Background()
+	sa := withKeychainInputs(ctx, k8schain.Options{Namespace: "default", ServiceAccountName: "sa"})
+	other := withKeychainInputs(ctx, k8schain.Options{Namespace: "default", ServiceAccountName: "other"})
+	secrets := withKeychainInputs(ctx, k8schain.Options{Namespace: "default", ServiceAccountName: "sa", ImagePullSecrets: []string{"secret"}})
+	again := withKeychainInputs(ctx, k8schain.Options{Namespace: "default", ServiceAccountName: "sa"})
+
+	if keychainInputs(ctx) != "" {
+		t.Errorf("keychainInputs() = %q, wanted none", keychainInputs(ctx))
 	}
-	kc, err := k8schain.NewNoClient(testContext)
-	if err != nil {
-		t.Fatalf("Failed to construct no client k8schain for testing")
+	if keychainInputs(sa) != keychainInputs(again) {
+		t.Error("the same keychain inputs have different digests")
+	}
+	if keychainInputs(sa) == keychainInputs(other) || keychainInputs(sa) == keychainInputs(secrets) {
+		t.Error("different keychain inputs have the same digest")
 	}
+}
 
-	wantErrs := []string{"context canceled before validation completed"}
-	cancelFunc()
-	_, gotErrs := ValidatePolicy(testContext, system.Namespace(), digest, cip, kc)
-	validateErrors(t, wantErrs, gotErrs)
+// countPolicyVerifications returns the number of policy verifications
+// recorded with the given coalesced tag.
+func countPolicyVerifications(t *testing.T, coalesced string) int64 {
+	t.Helper()
+	rows, err := view.RetrieveData(policyVerificationsName)
+	if err != nil {
+		t.Fatalf("Failed to retrieve %s: %v", policyVerificationsName, err)
+	}
+	for _, row := range rows {
+		for _, tg := range row.Tags {
+			if tg.Key == coalescedKey && tg.Value == coalesced {
+				return row.Data.(*view.CountData).Value
+			}
+		}
+	}
+	return 0
 }
 
-func TestValidatePoliciesCancelled(t *testing.T) {
-	var authorityKeyCosignPub *ecdsa.PublicKey
-	pems := parsePems([]byte(authorityKeyCosignPubString))
-	if len(pems) > 0 {
-		key, _ := x509.ParsePKIXPublicKey(pems[0].Bytes)
-		authorityKeyCosignPub = key.(*ecdsa.PublicKey)
-	} else {
-		t.Errorf("Error parsing authority key from string")
+func TestWithStageTimeout(t *testing.T) {
+	// Without a budget or a deadline there is no deadline.
+	ctx, cancel := withStageTimeout(context.Background(), "policy", 0)
+	defer cancel()
+	if _, ok := ctx.Deadline(); ok {
+		t.Error("withStageTimeout() set a deadline without a budget")
 	}
-	// Resolved via crane digest on 2021/09/25
-	digest := name.MustParseReference("gcr.io/distroless/static:nonroot@sha256:be5d77c62dbe7fedfb0a4e5ec2f91078080800ab1f18358e5f31fcc8faa023c4")
 
-	testContext, cancelFunc := context.WithCancel(context.Background())
-	cip := webhookcip.ClusterImagePolicy{
-		Authorities: []webhookcip.Authority{{
-			Name: "authority-0",
-			Key: &webhookcip.KeyRef{
-				PublicKeys:        []crypto.PublicKey{authorityKeyCosignPub},
-				HashAlgorithm:     signaturealgo.DefaultSignatureAlgorithm,
-				HashAlgorithmCode: crypto.SHA256,
-			},
-		}},
+	// The budget is carved out of the deadline of the enclosing stage.
+	parent, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	parentDeadline, _ := parent.Deadline()
+	for _, timeout := range []time.Duration{0, time.Minute} {
+		ctx, cancel := withStageTimeout(parent, "policy", timeout)
+		defer cancel()
+		if deadline, ok := ctx.Deadline(); !ok || parentDeadline.Sub(deadline) < admissionResponseReserve-time.Millisecond {
+			t.Errorf("withStageTimeout(%s) deadline = %v, wanted %s before %v", timeout, deadline, admissionResponseReserve, parentDeadline)
+		}
 	}
-	kc, err := k8schain.NewNoClient(testContext)
-	if err != nil {
-		t.Fatalf("Failed to construct no client k8schain for testing")
+
+	// The budget is used when it is shorter than the enclosing stage.
+	ctx, cancel = withStageTimeout(parent, "policy", time.Second)
+	defer cancel()
+	if deadline, _ := ctx.Deadline(); deadline.After(time.Now().Add(time.Second)) {
+		t.Errorf("withStageTimeout(1s) deadline = %v, wanted within 1s", deadline)
+	}
+
+	// The admission request deadline comes from the timeout the API server
+	// sets on the request.
+	req := httptest.NewRequest(http.MethodPost, "/validations?timeout=10s", nil)
+	ctx, cancel = withAdmissionTimeout(apis.WithHTTPRequest(context.Background(), req))
+	defer cancel()
+	if deadline, ok := ctx.Deadline(); !ok || deadline.After(time.Now().Add(10*time.Second-admissionResponseReserve)) {
+		t.Errorf("withAdmissionTimeout() deadline = %v, wanted before %v", deadline, time.Now().Add(10*time.Second-admissionResponseReserve))
+	}
+	if err := stageError(ctx, errors.New("oops")); err.Error() != "oops" {
+		t.Errorf("stageError() = %v, wanted the error as is before the deadline", err)
 	}
-	wantErrs := []string{"context was canceled before validation completed"}
-	cancelFunc()
-	_, gotErrs := validatePolicies(testContext, system.Namespace(), digest, map[string]webhookcip.ClusterImagePolicy{"testcip": cip}, kc)
-	validateErrors(t, wantErrs, gotErrs["internalerror"])
 }
 
 func TestPolicyControllerConfigNoMatchPolicy(t *testing.T) {
@@ -3391,6 +4542,69 @@ func TestCheckOptsFromAuthority(t *testing.T) {
 	}
 }
 
+func TestTrustedMaterialFromTrustRoot(t *testing.T) {
+	pbpkRekor, pkRekor, err := config.DeserializePublicKey([]byte(rekorPublicKey))
+	if err != nil {
+		t.Fatalf("Failed to unmarshal public key for testing: %v", err)
+	}
+	rekorLogID, err := cosign.GetTransparencyLogID(pkRekor)
+	if err != nil {
+		t.Fatalf("Failed to get the log ID for testing: %v", err)
+	}
+	certChainPB, err := config.DeserializeCertChain([]byte(certChain))
+	if err != nil {
+		t.Fatalf("Failed to unmarshal cert chain for testing: %v", err)
+	}
+	// These are like the SigstoreKeys written by the TrustRoot reconciler,
+	// with the hex encoded log IDs, and no media type or hash algorithm.
+	sk := &config.SigstoreKeys{
+		CertificateAuthorities: []*config.CertificateAuthority{{
+			CertChain: certChainPB,
+		}},
+		Tlogs: []*config.TransparencyLogInstance{{
+			PublicKey: pbpkRekor,
+			LogId:     &config.LogID{KeyId: []byte(rekorLogID)},
+			BaseUrl:   "rekor.example.com",
+		}},
+	}
+	keysCtx := func(sk *config.SigstoreKeys) context.Context {
+		return config.ToContext(context.Background(), &config.Config{
+			SigstoreKeysConfig: &config.SigstoreKeysMap{
+				SigstoreKeys: map[string]*config.SigstoreKeys{"test-trust-root": sk},
+			},
+		})
+	}
+
+	material, err := trustedMaterialFromTrustRoot(keysCtx(sk), "test-trust-root")
+	if err != nil {
+		t.Fatalf("trustedMaterialFromTrustRoot() = %v", err)
+	}
+	if _, ok := material.RekorLogs()[rekorLogID]; !ok {
+		t.Errorf("RekorLogs() = %v, wanted one with ID %s", material.RekorLogs(), rekorLogID)
+	}
+	if got := len(material.FulcioCertificateAuthorities()); got != 1 {
+		t.Errorf("FulcioCertificateAuthorities() = %d, wanted 1", got)
+	}
+	if sk.MediaType != "" || string(sk.Tlogs[0].LogId.KeyId) != rekorLogID {
+		t.Error("trustedMaterialFromTrustRoot() modified the SigstoreKeys")
+	}
+
+	// The SigstoreKeys are parsed again when any TrustRoot changes.
+	same := proto.Clone(sk).(*config.SigstoreKeys)
+	if got, err := trustedMaterialFromTrustRoot(keysCtx(same), "test-trust-root"); err != nil || got != material {
+		t.Errorf("trustedMaterialFromTrustRoot() = %v, %v, wanted the cached material", got, err)
+	}
+	changed := proto.Clone(sk).(*config.SigstoreKeys)
+	changed.Tlogs[0].BaseUrl = "rekor2.example.com"
+	if got, err := trustedMaterialFromTrustRoot(keysCtx(changed), "test-trust-root"); err != nil || got == material {
+		t.Errorf("trustedMaterialFromTrustRoot() = %v, %v, wanted new material", got, err)
+	}
+
+	if _, err := trustedMaterialFromTrustRoot(keysCtx(sk), "not-there"); err == nil || err.Error() != "trustRootRef not-there not found" {
+		t.Errorf("trustedMaterialFromTrustRoot() = %v, wanted trustRootRef not-there not found", err)
+	}
+}
+
 func TestSignatureID(t *testing.T) {
 	cert := mustRead(t, "testdata/cert.pem")
 	for _, tc := range []struct {
@@ -3441,3 +4655,442 @@ func newStaticSig(t *testing.T, payload []byte, cert []byte) oci.Signature {
 	}
 	return out
 }
+
+func TestClassifyError(t *testing.T) {
+	_, evalErr := policy.EvaluatePolicyAgainstJSON(context.Background(), "test", "cue", `predicateType: "foo"`, []byte(`{"predicateType": "bar"}`))
+	if evalErr == nil {
+		t.Fatal("EvaluatePolicyAgainstJSON() did not fail")
+	}
+	for _, tc := range []struct {
+		name string
+		err  error
+		want Reason
+	}{{
+		name: "registry auth",
+		err:  fmt.Errorf("fetching signatures: %w", &transport.Error{StatusCode: http.StatusUnauthorized}),
+		want: ReasonRegistryAuth,
+	}, {
+		name: "registry denied",
+		err:  fmt.Errorf("fetching signatures: %w", &transport.Error{StatusCode: http.StatusNotFound, Errors: []transport.Diagnostic{{Code: transport.DeniedErrorCode}}}),
+		want: ReasonRegistryAuth,
+	}, {
+		name: "static fail",
+		err:  cosign.NewVerificationError("disallowed by static policy: %s", "nope"),
+		want: ReasonPolicyEvaluation,
+	}, {
+		name: "attestation policy",
+		err:  evalErr,
+		want: ReasonPolicyEvaluation,
+	}, {
+		name: "no signatures",
+		err:  errors.New("signature keyless validation failed for authority authority-0 for gcr.io/foo/bar@sha256:be5d: no signatures found"),
+		want: ReasonMissingSignature,
+	}, {
+		name: "no attestations",
+		err:  errors.New("no matching attestations for authority authority-0 for gcr.io/foo/bar@sha256:be5d"),
+		want: ReasonMissingSignature,
+	}, {
+		name: "wrong identity",
+		err:  errors.New("no matching signatures: none of the expected identities matched what was in the certificate, got subjects [foo@example.com] with issuer https://accounts.google.com"),
+		want: ReasonWrongIdentity,
+	}, {
+		name: "wrong key",
+		err:  errors.New("no matching signatures: invalid signature when validating ASN.1 encoded signature"),
+		want: ReasonWrongIdentity,
+	}, {
+		name: "untrusted root",
+		err:  errors.New("no matching signatures: cert verification failed: x509: certificate signed by unknown authority"),
+		want: ReasonUntrustedRoot,
+	}, {
+		name: "registry unavailable",
+		err:  fmt.Errorf("fetching signatures: %w", &transport.Error{StatusCode: http.StatusServiceUnavailable}),
+		want: ReasonInfrastructure,
+	}, {
+		name: "registry throttling",
+		err:  fmt.Errorf("fetching signatures: %w", &transport.Error{StatusCode: http.StatusTooManyRequests}),
+		want: ReasonInfrastructure,
+	}, {
+		name: "deadline exceeded",
+		err:  fmt.Errorf("%w before validation completed", context.DeadlineExceeded),
+		want: ReasonInfrastructure,
+	}, {
+		name: "network error",
+		err:  fmt.Errorf("getting rekor entry: %w", &net.OpError{Op: "dial", Net: "tcp", Err: errors.New("connection refused")}),
+		want: ReasonInfrastructure,
+	}, {
+		name: "unwrapped timeout",
+		err:  errors.New("signature keyless validation failed for authority authority-0 for gcr.io/foo/bar@sha256:be5d: Get \"https://gcr.io/v2/\": net/http: TLS handshake timeout"),
+		want: ReasonInfrastructure,
+	}, {
+		name: "untrusted root wins over infrastructure",
+		err:  errors.New("no matching signatures: cert verification failed: x509: certificate signed by unknown authority; context deadline exceeded"),
+		want: ReasonUntrustedRoot,
+	}, {
+		name: "unknown",
+		err:  errors.New("bad signature"),
+		want: ReasonUnknown,
+	}} {
+		t.Run(tc.name, func(t *testing.T) {
+			if got := classifyError(tc.err); got != tc.want {
+				t.Errorf("classifyError() = %s, wanted %s", got, tc.want)
+			}
+		})
+	}
+}
+
+func TestErrorsToFieldErrors(t *testing.T) {
+	image := "gcr.io/distroless/static:nonroot@sha256:be5d77c62dbe7fedfb0a4e5ec2f91078080800ab1f18358e5f31fcc8faa023c4"
+	fieldErrors := map[string][]error{
+		"cip": {
+			newPolicyError(webhookcip.ClusterImagePolicy{}, "authority-0", errors.New("no matching signatures: none of the expected identities matched what was in the certificate")),
+			newPolicyError(webhookcip.ClusterImagePolicy{}, "authority-1", errors.New("no signatures found")),
+		},
+	}
+
+	want := apis.ErrGeneric("failed policy: cip: authority-0: wrong identity, authority-1: missing signature", "image").ViaFieldIndex("containers", 0)
+	want.Details = image + " no matching signatures: none of the expected identities matched what was in the certificate no signatures found"
+	got := errorsToFieldErrors(context.Background(), image, "containers", 0, fieldErrors)
+	if diff := cmp.Diff(want.Error(), got.Error()); diff != "" {
+		t.Errorf("unexpected errors (-want +got): %s", diff)
+	}
+
+	// With the JSON details enabled.
+	ctx := policycontrollerconfig.ToContext(context.Background(), &policycontrollerconfig.PolicyControllerConfig{JSONDenialDetails: true})
+	got = errorsToFieldErrors(ctx, image, "containers", 0, fieldErrors)
+	msg := got.Error()
+	detail := msg[strings.LastIndex(msg, "\n")+1:]
+	var gotDetail DenialDetail
+	if err := json.Unmarshal([]byte(detail), &gotDetail); err != nil {
+		t.Fatalf("Failed to unmarshal %q: %v", detail, err)
+	}
+	wantDetail := DenialDetail{
+		Image:  image,
+		Policy: "cip",
+		Failures: []DenialFailure{{
+			Authority: "authority-0",
+			Reason:    ReasonWrongIdentity,
+			Message:   "no matching signatures: none of the expected identities matched what was in the certificate",
+		}, {
+			Authority: "authority-1",
+			Reason:    ReasonMissingSignature,
+			Message:   "no signatures found",
+		}},
+	}
+	if diff := cmp.Diff(wantDetail, gotDetail); diff != "" {
+		t.Errorf("unexpected details (-want +got): %s", diff)
+	}
+}
+
+func TestErrorsToFieldErrorsPerPolicy(t *testing.T) {
+	image := "gcr.io/distroless/static:nonroot@sha256:be5d77c62dbe7fedfb0a4e5ec2f91078080800ab1f18358e5f31fcc8faa023c4"
+	fieldErrors := map[string][]error{
+		"enforced": {
+			newPolicyError(webhookcip.ClusterImagePolicy{Mode: "enforce"}, "authority-0", errors.New("no signatures found")),
+		},
+		"plain": {
+			errors.New("failed to process policy: plain"),
+		},
+		"warned": {
+			newPolicyError(webhookcip.ClusterImagePolicy{Mode: "warn"}, "authority-0", errors.New("no signatures found")),
+		},
+	}
+
+	// Whatever the order in which the policies are reported, each of them
+	// is only reported at its own level.
+	for i := 0; i < 10; i++ {
+		got := errorsToFieldErrors(context.Background(), image, "containers", 0, fieldErrors)
+		gotErrs := got.Filter(apis.ErrorLevel).Error()
+		gotWarns := got.Filter(apis.WarningLevel).Error()
+		for _, want := range []string{"failed policy: enforced: authority-0: missing signature", "failed policy: plain: "} {
+			if !strings.Contains(gotErrs, want) {
+				t.Errorf("errors %q do not contain %q", gotErrs, want)
+			}
+		}
+		if strings.Contains(gotErrs, "warned") {
+			t.Errorf("errors %q contain the warn-only policy", gotErrs)
+		}
+		if want := "failed policy: warned: authority-0: missing signature"; !strings.Contains(gotWarns, want) {
+			t.Errorf("warnings %q do not contain %q", gotWarns, want)
+		}
+		if strings.Contains(gotWarns, "enforced") || strings.Contains(gotWarns, "plain") {
+			t.Errorf("warnings %q contain an enforced policy", gotWarns)
+		}
+	}
+}
+
+func TestInfraFailureMode(t *testing.T) {
+	image := "gcr.io/distroless/static:nonroot@sha256:be5d77c62dbe7fedfb0a4e5ec2f91078080800ab1f18358e5f31fcc8faa023c4"
+	infraErr := fmt.Errorf("fetching signatures: %w", &transport.Error{StatusCode: http.StatusServiceUnavailable})
+	verifyErr := errors.New("no signatures found")
+	failOpen := webhookcip.ClusterImagePolicy{Mode: "enforce", InfraFailureMode: "warn"}
+
+	for _, tc := range []struct {
+		name       string
+		cip        webhookcip.ClusterImagePolicy
+		errs       []error
+		wantErr    bool
+		wantWarn   bool
+		wantSubstr string
+	}{{
+		name:       "infrastructure failure fails closed by default",
+		cip:        webhookcip.ClusterImagePolicy{Mode: "enforce"},
+		errs:       []error{infraErr},
+		wantErr:    true,
+		wantSubstr: "authority-0: infrastructure failure",
+	}, {
+		name:       "infrastructure failure fails open",
+		cip:        failOpen,
+		errs:       []error{infraErr},
+		wantWarn:   true,
+		wantSubstr: "authority-0: infrastructure failure (failed open)",
+	}, {
+		name:       "timeout fails open",
+		cip:        failOpen,
+		errs:       []error{fmt.Errorf("fetching signatures: %w", context.DeadlineExceeded)},
+		wantWarn:   true,
+		wantSubstr: "authority-0: infrastructure failure (failed open)",
+	}, {
+		name:       "infrastructure message without a typed error stays fatal",
+		cip:        failOpen,
+		errs:       []error{errors.New("policy evaluation failed: got 503 Service Unavailable")},
+		wantErr:    true,
+		wantSubstr: "authority-0: infrastructure failure",
+	}, {
+		name:       "verification failure stays fatal",
+		cip:        failOpen,
+		errs:       []error{verifyErr},
+		wantErr:    true,
+		wantSubstr: "authority-0: missing signature",
+	}, {
+		name:       "verification failure with infrastructure failure stays fatal",
+		cip:        failOpen,
+		errs:       []error{infraErr, verifyErr},
+		wantErr:    true,
+		wantWarn:   true,
+		wantSubstr: "authority-1: missing signature",
+	}} {
+		t.Run(tc.name, func(t *testing.T) {
+			policyErrs := make([]error, 0, len(tc.errs))
+			for i, err := range tc.errs {
+				policyErrs = append(policyErrs, newPolicyError(tc.cip, fmt.Sprintf("authority-%d", i), err))
+			}
+			got := errorsToFieldErrors(context.Background(), image, "containers", 0, map[string][]error{"cip": policyErrs})
+			if gotErr := got.Filter(apis.ErrorLevel) != nil; gotErr != tc.wantErr {
+				t.Errorf("got error = %t, wanted %t: %v", gotErr, tc.wantErr, got)
+			}
+			if gotWarn := got.Filter(apis.WarningLevel) != nil; gotWarn != tc.wantWarn {
+				t.Errorf("got warning = %t, wanted %t: %v", gotWarn, tc.wantWarn, got)
+			}
+			if !strings.Contains(got.Error(), tc.wantSubstr) {
+				t.Errorf("%q does not contain %q", got.Error(), tc.wantSubstr)
+			}
+		})
+	}
+}
+
+func TestRekorKeysFromTrustRefNonECDSA(t *testing.T) {
+	pbpkRekor, _, err := config.DeserializePublicKey([]byte(rekorPublicKey))
+	if err != nil {
+		t.Fatalf("Failed to unmarshal public key for testing: %v", err)
+	}
+	edpk, _, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatalf("Failed to generate ed25519 key: %v", err)
+	}
+	edPEM, err := cryptoutils.MarshalPublicKeyToPEM(edpk)
+	if err != nil {
+		t.Fatalf("Failed to marshal ed25519 key: %v", err)
+	}
+	pbpkEd, _, err := config.DeserializePublicKey(edPEM)
+	if err != nil {
+		t.Fatalf("Failed to unmarshal ed25519 key: %v", err)
+	}
+	edLogID, err := cosign.GetTransparencyLogID(edpk)
+	if err != nil {
+		t.Fatalf("Failed to get the log ID for testing: %v", err)
+	}
+	edTlog := &config.TransparencyLogInstance{
+		PublicKey: pbpkEd,
+		LogId:     &config.LogID{KeyId: []byte(edLogID)},
+		BaseUrl:   "rekor.example.com",
+	}
+	ecTlog := &config.TransparencyLogInstance{
+		PublicKey: pbpkRekor,
+		LogId:     &config.LogID{KeyId: []byte(rekorLogID)},
+		BaseUrl:   "rekor.example.com",
+	}
+	ctx := config.ToContext(context.Background(), &config.Config{
+		SigstoreKeysConfig: &config.SigstoreKeysMap{
+			SigstoreKeys: map[string]*config.SigstoreKeys{
+				"mixed":   {Tlogs: []*config.TransparencyLogInstance{edTlog, ecTlog}},
+				"ed25519": {Tlogs: []*config.TransparencyLogInstance{edTlog}},
+			},
+		},
+	})
+
+	// cosign is only given the ecdsa keys.
+	keys, _, err := rekorKeysFromTrustRef(ctx, "mixed")
+	if err != nil {
+		t.Fatalf("rekorKeysFromTrustRef() = %v", err)
+	}
+	if _, ok := keys.Keys[rekorLogID]; !ok || len(keys.Keys) != 1 {
+		t.Errorf("rekorKeysFromTrustRef() = %v, wanted only the ecdsa key %s", keys.Keys, rekorLogID)
+	}
+	if _, _, err := rekorKeysFromTrustRef(ctx, "ed25519"); err == nil || !strings.Contains(err.Error(), "signatureFormat is bundle") {
+		t.Errorf("rekorKeysFromTrustRef() = %v, wanted an error pointing to the bundle signature format", err)
+	}
+
+	// While the bundle verification uses all of them.
+	material, err := trustedMaterialFromTrustRoot(ctx, "mixed")
+	if err != nil {
+		t.Fatalf("trustedMaterialFromTrustRoot() = %v", err)
+	}
+	for _, logID := range []string{edLogID, rekorLogID} {
+		if _, ok := material.RekorLogs()[logID]; !ok {
+			t.Errorf("RekorLogs() = %v, wanted one with ID %s", material.RekorLogs(), logID)
+		}
+	}
+}
+
+func TestAuthorityTrustRootRefs(t *testing.T) {
+	// The first TrustRoot has another CA and only an ed25519 Rekor key, so
+	// that only the second one verifies.
+	otherRoot, _, err := test.GenerateRootCa()
+	if err != nil {
+		t.Fatalf("Failed to generate CA: %v", err)
+	}
+	otherRootPEM, err := cryptoutils.MarshalCertificatesToPEM([]*x509.Certificate{otherRoot})
+	if err != nil {
+		t.Fatalf("Failed to marshal CA: %v", err)
+	}
+	otherChainPB, err := config.DeserializeCertChain(otherRootPEM)
+	if err != nil {
+		t.Fatalf("Failed to unmarshal cert chain for testing: %v", err)
+	}
+	edpk, _, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatalf("Failed to generate ed25519 key: %v", err)
+	}
+	edPEM, err := cryptoutils.MarshalPublicKeyToPEM(edpk)
+	if err != nil {
+		t.Fatalf("Failed to marshal ed25519 key: %v", err)
+	}
+	pbpkEd, _, err := config.DeserializePublicKey(edPEM)
+	if err != nil {
+		t.Fatalf("Failed to unmarshal ed25519 key: %v", err)
+	}
+	edLogID, err := cosign.GetTransparencyLogID(edpk)
+	if err != nil {
+		t.Fatalf("Failed to get the log ID for testing: %v", err)
+	}
+
+	pbpkRekor, pkRekor, err := config.DeserializePublicKey([]byte(rekorPublicKey))
+	if err != nil {
+		t.Fatalf("Failed to unmarshal public key for testing: %v", err)
+	}
+	pbpkCTFE, _, err := config.DeserializePublicKey([]byte(ctfePublicKey))
+	if err != nil {
+		t.Fatalf("Failed to unmarshal public key for testing: %v", err)
+	}
+	certChainPB, err := config.DeserializeCertChain([]byte(certChain))
+	if err != nil {
+		t.Fatalf("Failed to unmarshal cert chain for testing: %v", err)
+	}
+	certs, err := cryptoutils.UnmarshalCertificatesFromPEM([]byte(certChain))
+	if err != nil {
+		t.Fatalf("Failed to unmarshal certs for testing: %v", err)
+	}
+
+	ctx := config.ToContext(context.Background(), &config.Config{
+		SigstoreKeysConfig: &config.SigstoreKeysMap{
+			SigstoreKeys: map[string]*config.SigstoreKeys{
+				"first": {
+					CertificateAuthorities: []*config.CertificateAuthority{{CertChain: otherChainPB}},
+					Tlogs: []*config.TransparencyLogInstance{{
+						PublicKey: pbpkEd,
+						LogId:     &config.LogID{KeyId: []byte(edLogID)},
+						BaseUrl:   "rekor.example.com",
+					}},
+				},
+				"second": {
+					CertificateAuthorities: []*config.CertificateAuthority{{CertChain: certChainPB}},
+					Ctlogs: []*config.TransparencyLogInstance{{
+						PublicKey: pbpkCTFE,
+						LogId:     &config.LogID{KeyId: []byte(ctfeLogID)},
+					}},
+					Tlogs: []*config.TransparencyLogInstance{{
+						PublicKey: pbpkRekor,
+						LogId:     &config.LogID{KeyId: []byte(rekorLogID)},
+						BaseUrl:   "rekor.example.com",
+					}},
+				},
+			},
+		},
+	})
+	authority := webhookcip.Authority{
+		Name:    "test-authority",
+		Keyless: &webhookcip.KeylessRef{TrustRootRefs: []string{"first", "second"}},
+		CTLog:   &v1alpha1.TLog{TrustRootRefs: []string{"first", "second"}},
+	}
+	verifies := func(roots, intermediates *x509.CertPool) bool {
+		_, err := certs[0].Verify(x509.VerifyOptions{
+			Roots:         roots,
+			Intermediates: intermediates,
+			KeyUsages:     []x509.ExtKeyUsage{x509.ExtKeyUsageAny},
+		})
+		return err == nil
+	}
+
+	roots, intermediates, ctlogKeys, err := fulcioCertsFromAuthority(ctx, authority.Keyless)
+	if err != nil {
+		t.Fatalf("fulcioCertsFromAuthority() = %v", err)
+	}
+	if !verifies(roots, intermediates) {
+		t.Error("fulcioCertsFromAuthority() did not trust the CA of the second TrustRoot")
+	}
+	if _, ok := ctlogKeys.Keys[ctfeLogID]; !ok {
+		t.Errorf("fulcioCertsFromAuthority() = %v, wanted the CT log of the second TrustRoot", ctlogKeys.Keys)
+	}
+
+	rekorClient, rekorKeys, err := rekorClientAndKeysFromAuthority(ctx, authority)
+	if err != nil {
+		t.Fatalf("rekorClientAndKeysFromAuthority() = %v", err)
+	}
+	if rekorClient == nil {
+		t.Error("rekorClientAndKeysFromAuthority() did not return a rekor client")
+	}
+	if diff := cmp.Diff(rekorKeys.Keys, map[string]cosign.TransparencyLogPubKey{rekorLogID: {PubKey: pkRekor, Status: tuf.Active}}); diff != "" {
+		t.Errorf("rekorClientAndKeysFromAuthority() keys differ: %s", diff)
+	}
+
+	checkOpts, err := checkOptsFromAuthority(ctx, authority)
+	if err != nil {
+		t.Fatalf("checkOptsFromAuthority() = %v", err)
+	}
+	if !verifies(checkOpts.RootCerts, checkOpts.IntermediateCerts) {
+		t.Error("checkOptsFromAuthority() did not trust the CA of the second TrustRoot")
+	}
+	if _, ok := checkOpts.RekorPubKeys.Keys[rekorLogID]; !ok || len(checkOpts.RekorPubKeys.Keys) != 1 {
+		t.Errorf("checkOptsFromAuthority() = %v, wanted the Rekor key of the second TrustRoot", checkOpts.RekorPubKeys.Keys)
+	}
+
+	// Without the second TrustRoot, there are no keys for cosign.
+	authority.CTLog = &v1alpha1.TLog{TrustRootRefs: []string{"first"}}
+	if _, err := checkOptsFromAuthority(ctx, authority); err == nil || !strings.Contains(err.Error(), "signatureFormat is bundle") {
+		t.Errorf("checkOptsFromAuthority() = %v, wanted an error pointing to the bundle signature format", err)
+	}
+
+	// The bundle verification trusts the logs of the TrustRoots of the
+	// CTLog, along with those of the keyless authority.
+	authority.Keyless = &webhookcip.KeylessRef{TrustRootRef: "first"}
+	authority.CTLog = &v1alpha1.TLog{TrustRootRef: "second"}
+	material, err := trustedMaterialFromAuthority(ctx, authority)
+	if err != nil {
+		t.Fatalf("trustedMaterialFromAuthority() = %v", err)
+	}
+	for _, logID := range []string{edLogID, rekorLogID} {
+		if _, ok := material.RekorLogs()[logID]; !ok {
+			t.Errorf("RekorLogs() = %v, wanted one with ID %s", material.RekorLogs(), logID)
+		}
+	}
+}
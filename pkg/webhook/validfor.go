@@ -0,0 +1,367 @@
+// Copyright 2022 The Sigstore Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package webhook
+
+import (
+	"bytes"
+	"context"
+	"crypto/x509"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"slices"
+	"time"
+
+	"github.com/digitorus/timestamp"
+	"github.com/google/certificate-transparency-go/x509util"
+	"github.com/sigstore/cosign/v2/pkg/cosign/bundle"
+	"github.com/sigstore/policy-controller/pkg/apis/config"
+	webhookcip "github.com/sigstore/policy-controller/pkg/webhook/clusterimagepolicy"
+	pbcommon "github.com/sigstore/protobuf-specs/gen/pb-go/common/v1"
+)
+
+// bundledSignature is implemented by the signatures that carry a Rekor
+// bundle (i.e. oci.Signature).
+type bundledSignature interface {
+	Bundle() (*bundle.RekorBundle, error)
+}
+
+// filterValidFor returns the signatures verified by cosign that were made
+// within the validFor of the TrustRoot CA that issued their certificate, of
+// the TrustRoot CT logs whose SCTs are embedded in that certificate, of the
+// TrustRoot transparency log that integrated them, and of the TrustRoot
+// timestamp authority that timestamped them. The CAs, logs and timestamp
+// authorities of all the TrustRoots referenced by the authority are
+// considered. Detached SCTs are not checked. The bundle path does not need
+// this, since sigstore-go enforces validFor itself.
+func filterValidFor(ctx context.Context, authority webhookcip.Authority, sigs []Signature) ([]Signature, error) {
+	var cas []*config.CertificateAuthority
+	var ctlogs []*config.TransparencyLogInstance
+	if authority.Keyless != nil {
+		ignoreSCT := authority.Keyless.InsecureIgnoreSCT != nil && *authority.Keyless.InsecureIgnoreSCT
+		for _, trustRootRef := range authority.Keyless.GetTrustRootRefs() {
+			sk, err := sigstoreKeysForTrustRoot(ctx, trustRootRef)
+			if err != nil {
+				return nil, err
+			}
+			cas = append(cas, sk.CertificateAuthorities...)
+			if !ignoreSCT {
+				ctlogs = append(ctlogs, sk.Ctlogs...)
+			}
+		}
+	}
+	var tlogs []*config.TransparencyLogInstance
+	if authority.CTLog != nil {
+		for _, trustRootRef := range authority.CTLog.GetTrustRootRefs() {
+			sk, err := sigstoreKeysForTrustRoot(ctx, trustRootRef)
+			if err != nil {
+				return nil, err
+			}
+			tlogs = append(tlogs, sk.Tlogs...)
+		}
+	}
+	var tsas []*config.CertificateAuthority
+	if authority.RFC3161Timestamp != nil {
+		for _, trustRootRef := range authority.RFC3161Timestamp.GetTrustRootRefs() {
+			sk, err := sigstoreKeysForTrustRoot(ctx, trustRootRef)
+			if err != nil {
+				return nil, err
+			}
+			tsas = append(tsas, sk.TimestampAuthorities...)
+		}
+	}
+	if cas == nil && ctlogs == nil && tlogs == nil && tsas == nil {
+		return sigs, nil
+	}
+
+	ret := make([]Signature, 0, len(sigs))
+	var lastErr error
+	for _, sig := range sigs {
+		if cas != nil {
+			if err := checkCertificateValidFor(sig, cas); err != nil {
+				lastErr = err
+				continue
+			}
+		}
+		if ctlogs != nil {
+			if err := checkSCTValidFor(sig, ctlogs); err != nil {
+				lastErr = err
+				continue
+			}
+		}
+		if tlogs != nil {
+			if err := checkTlogValidFor(sig, tlogs); err != nil {
+				lastErr = err
+				continue
+			}
+		}
+		if tsas != nil {
+			if err := checkTimestampValidFor(sig, tsas); err != nil {
+				lastErr = err
+				continue
+			}
+		}
+		ret = append(ret, sig)
+	}
+	if len(ret) == 0 && lastErr != nil {
+		return nil, lastErr
+	}
+	return ret, nil
+}
+
+// sigstoreKeysForTrustRoot returns the SigstoreKeys of the TrustRoot named
+// trustRootRef.
+func sigstoreKeysForTrustRoot(ctx context.Context, trustRootRef string) (*config.SigstoreKeys, error) {
+	sigstoreKeys, err := sigstoreKeysFromContext(ctx, trustRootRef)
+	if err != nil {
+		return nil, fmt.Errorf("getting SigstoreKeys: %w", err)
+	}
+	sk, ok := sigstoreKeys.SigstoreKeys[trustRootRef]
+	if !ok {
+		return nil, fmt.Errorf("trustRootRef %s not found", trustRootRef)
+	}
+	return sk, nil
+}
+
+// signatureTrustRootRef returns the TrustRoot of the authority that
+// satisfied the match of the signature: the one whose CA issued its
+// certificate, or else the one whose transparency log integrated it. For
+// timestamp authorities, it is only known with a single TrustRoot. It
+// returns "" if the authority does not refer to TrustRoots.
+func signatureTrustRootRef(ctx context.Context, authority webhookcip.Authority, sig Signature) string {
+	if authority.Keyless != nil {
+		if cert, err := sig.Cert(); err == nil && cert != nil {
+			ref := findTrustRoot(ctx, authority.Keyless.GetTrustRootRefs(), func(sk *config.SigstoreKeys) bool {
+				return slices.ContainsFunc(sk.CertificateAuthorities, func(ca *config.CertificateAuthority) bool {
+					return issuedBy(cert, ca)
+				})
+			})
+			if ref != "" {
+				return ref
+			}
+		}
+	}
+	if authority.CTLog != nil {
+		if b, err := rekorBundle(sig); err == nil && b != nil {
+			ref := findTrustRoot(ctx, authority.CTLog.GetTrustRootRefs(), func(sk *config.SigstoreKeys) bool {
+				return slices.ContainsFunc(sk.Tlogs, func(tlog *config.TransparencyLogInstance) bool {
+					return string(tlog.GetLogId().GetKeyId()) == b.Payload.LogID
+				})
+			})
+			if ref != "" {
+				return ref
+			}
+		}
+	}
+	if authority.RFC3161Timestamp != nil {
+		if refs := authority.RFC3161Timestamp.GetTrustRootRefs(); len(refs) == 1 {
+			return refs[0]
+		}
+	}
+	return ""
+}
+
+// findTrustRoot returns the first of the TrustRoots named trustRootRefs
+// whose SigstoreKeys match, or "" if none does.
+func findTrustRoot(ctx context.Context, trustRootRefs []string, match func(*config.SigstoreKeys) bool) string {
+	for _, trustRootRef := range trustRootRefs {
+		sk, err := sigstoreKeysForTrustRoot(ctx, trustRootRef)
+		if err != nil {
+			continue
+		}
+		if match(sk) {
+			return trustRootRef
+		}
+	}
+	return ""
+}
+
+// checkCertificateValidFor checks that the certificate of the signature was
+// issued within the validFor of one of the CAs that issued it.
+func checkCertificateValidFor(sig Signature, cas []*config.CertificateAuthority) error {
+	cert, err := sig.Cert()
+	if err != nil {
+		return fmt.Errorf("getting certificate: %w", err)
+	}
+	if cert == nil {
+		return nil
+	}
+	for _, ca := range cas {
+		if !issuedBy(cert, ca) {
+			continue
+		}
+		if timeRangeContains(ca.GetValidFor(), cert.NotBefore) {
+			return nil
+		}
+	}
+	return fmt.Errorf("certificate issued at %s is not within the validFor of its certificate authority", cert.NotBefore.UTC().Format(time.RFC3339))
+}
+
+// issuedBy returns true if the certificate was signed by one of the
+// certificates of the CA chain.
+func issuedBy(cert *x509.Certificate, ca *config.CertificateAuthority) bool {
+	for _, raw := range ca.GetCertChain().GetCertificates() {
+		issuer, err := x509.ParseCertificate(raw.GetRawBytes())
+		if err != nil {
+			continue
+		}
+		if cert.CheckSignatureFrom(issuer) == nil {
+			return true
+		}
+	}
+	return false
+}
+
+// checkTlogValidFor checks that the signature was integrated in the
+// transparency log within the validFor of its key.
+func checkTlogValidFor(sig Signature, tlogs []*config.TransparencyLogInstance) error {
+	b, err := rekorBundle(sig)
+	if err != nil {
+		return fmt.Errorf("getting bundle: %w", err)
+	}
+	if b == nil {
+		return nil
+	}
+	integratedTime := time.Unix(b.Payload.IntegratedTime, 0)
+	for _, tlog := range tlogs {
+		// The TrustRoot reconciler sets the LogId to the hex encoded key ID,
+		// as found in the bundles.
+		if string(tlog.GetLogId().GetKeyId()) != b.Payload.LogID {
+			continue
+		}
+		if timeRangeContains(tlog.GetPublicKey().GetValidFor(), integratedTime) {
+			return nil
+		}
+		return fmt.Errorf("transparency log entry integrated at %s is not within the validFor of log %s", integratedTime.UTC().Format(time.RFC3339), b.Payload.LogID)
+	}
+	return errors.New("transparency log entry is not from a log of the TrustRoot")
+}
+
+// checkSCTValidFor checks that one of the SCTs embedded in the certificate of
+// the signature was issued within the validFor of its CT log.
+func checkSCTValidFor(sig Signature, ctlogs []*config.TransparencyLogInstance) error {
+	cert, err := sig.Cert()
+	if err != nil {
+		return fmt.Errorf("getting certificate: %w", err)
+	}
+	if cert == nil {
+		return nil
+	}
+	scts, err := x509util.ParseSCTsFromCertificate(cert.Raw)
+	if err != nil {
+		return fmt.Errorf("parsing embedded SCTs: %w", err)
+	}
+	if len(scts) == 0 {
+		return nil
+	}
+	var lastErr error
+	for _, sct := range scts {
+		logID := hex.EncodeToString(sct.LogID.KeyID[:])
+		timestamp := time.UnixMilli(int64(sct.Timestamp)) //nolint: gosec
+		for _, ctlog := range ctlogs {
+			// The TrustRoot reconciler sets the LogId to the hex encoded key
+			// ID, which is the log ID of the SCTs.
+			if string(ctlog.GetLogId().GetKeyId()) != logID {
+				continue
+			}
+			if timeRangeContains(ctlog.GetPublicKey().GetValidFor(), timestamp) {
+				return nil
+			}
+			lastErr = fmt.Errorf("certificate timestamp issued at %s is not within the validFor of CT log %s", timestamp.UTC().Format(time.RFC3339), logID)
+		}
+	}
+	if lastErr != nil {
+		return lastErr
+	}
+	return errors.New("certificate has no SCT from a CT log of the TrustRoot")
+}
+
+// timestampedSignature is implemented by the signatures that carry an
+// RFC3161 timestamp (i.e. oci.Signature).
+type timestampedSignature interface {
+	RFC3161Timestamp() (*bundle.RFC3161Timestamp, error)
+}
+
+// checkTimestampValidFor checks that the RFC3161 timestamp of the signature
+// was signed within the validFor of one of the timestamp authorities that
+// may have signed it: those that issued one of the certificates embedded in
+// the timestamp, or all of them if it embeds none.
+func checkTimestampValidFor(sig Signature, tsas []*config.CertificateAuthority) error {
+	if ks, ok := sig.(keyedSignature); ok {
+		sig = ks.sig
+	}
+	tsig, ok := sig.(timestampedSignature)
+	if !ok {
+		return nil
+	}
+	rfc3161, err := tsig.RFC3161Timestamp()
+	if err != nil {
+		return fmt.Errorf("getting RFC3161 timestamp: %w", err)
+	}
+	if rfc3161 == nil {
+		return nil
+	}
+	ts, err := timestamp.ParseResponse(rfc3161.SignedRFC3161Timestamp)
+	if err != nil {
+		return fmt.Errorf("parsing RFC3161 timestamp: %w", err)
+	}
+	for _, tsa := range tsas {
+		if len(ts.Certificates) > 0 && !slices.ContainsFunc(ts.Certificates, func(cert *x509.Certificate) bool {
+			return inChain(cert, tsa) || issuedBy(cert, tsa)
+		}) {
+			continue
+		}
+		if timeRangeContains(tsa.GetValidFor(), ts.Time) {
+			return nil
+		}
+	}
+	return fmt.Errorf("timestamp signed at %s is not within the validFor of its timestamp authority", ts.Time.UTC().Format(time.RFC3339))
+}
+
+// inChain returns true if the certificate is one of the certificates of the
+// CA chain.
+func inChain(cert *x509.Certificate, ca *config.CertificateAuthority) bool {
+	return slices.ContainsFunc(ca.GetCertChain().GetCertificates(), func(raw *pbcommon.X509Certificate) bool {
+		return bytes.Equal(raw.GetRawBytes(), cert.Raw)
+	})
+}
+
+// rekorBundle returns the Rekor bundle of the signature, or nil if it has
+// none.
+func rekorBundle(sig Signature) (*bundle.RekorBundle, error) {
+	if ks, ok := sig.(keyedSignature); ok {
+		sig = ks.sig
+	}
+	bs, ok := sig.(bundledSignature)
+	if !ok {
+		return nil, nil
+	}
+	return bs.Bundle()
+}
+
+// timeRangeContains returns true if t is within the time range, which is
+// unbounded if nil or without an end.
+func timeRangeContains(tr *pbcommon.TimeRange, t time.Time) bool {
+	if tr == nil {
+		return true
+	}
+	if tr.GetStart() != nil && t.Before(tr.GetStart().AsTime()) {
+		return false
+	}
+	if tr.GetEnd() != nil && t.After(tr.GetEnd().AsTime()) {
+		return false
+	}
+	return true
+}
@@ -0,0 +1,401 @@
+// Copyright 2022 The Sigstore Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package webhook
+
+import (
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/asn1"
+	"encoding/hex"
+	"math/big"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/digitorus/timestamp"
+	ct "github.com/google/certificate-transparency-go"
+	cttls "github.com/google/certificate-transparency-go/tls"
+	"github.com/google/certificate-transparency-go/x509util"
+	"github.com/sigstore/cosign/v2/pkg/cosign"
+	"github.com/sigstore/cosign/v2/pkg/cosign/bundle"
+	"github.com/sigstore/cosign/v2/pkg/oci/static"
+	"github.com/sigstore/policy-controller/pkg/apis/config"
+	"github.com/sigstore/policy-controller/pkg/apis/policy/v1alpha1"
+	webhookcip "github.com/sigstore/policy-controller/pkg/webhook/clusterimagepolicy"
+	"github.com/sigstore/policy-controller/test"
+	pbcommon "github.com/sigstore/protobuf-specs/gen/pb-go/common/v1"
+	"github.com/sigstore/sigstore/pkg/cryptoutils"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+func TestFilterValidFor(t *testing.T) {
+	rootCert, rootKey, err := test.GenerateRootCa()
+	if err != nil {
+		t.Fatal(err)
+	}
+	rootPEM, err := cryptoutils.MarshalCertificatesToPEM([]*x509.Certificate{rootCert})
+	if err != nil {
+		t.Fatal(err)
+	}
+	certChainPB, err := config.DeserializeCertChain(rootPEM)
+	if err != nil {
+		t.Fatal(err)
+	}
+	pbpkRekor, pkRekor, err := config.DeserializePublicKey([]byte(rekorPublicKey))
+	if err != nil {
+		t.Fatal(err)
+	}
+	rekorLogID, err := cosign.GetTransparencyLogID(pkRekor)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctlogKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctlogPEM, err := cryptoutils.MarshalPublicKeyToPEM(&ctlogKey.PublicKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	pbpkCTLog, pkCTLog, err := config.DeserializePublicKey(ctlogPEM)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctlogID, err := cosign.GetTransparencyLogID(pkCTLog)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	now := time.Now()
+	current := &pbcommon.TimeRange{Start: timestamppb.New(now.Add(-time.Hour))}
+	expired := &pbcommon.TimeRange{Start: timestamppb.New(now.Add(-2 * time.Hour)), End: timestamppb.New(now.Add(-time.Hour))}
+
+	// A certificate with an SCT embedded by the CT log.
+	ctlogKeyID, err := hex.DecodeString(ctlogID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	sctList, err := x509util.MarshalSCTsIntoSCTList([]*ct.SignedCertificateTimestamp{{
+		SCTVersion: ct.V1,
+		LogID:      ct.LogID{KeyID: [32]byte(ctlogKeyID)},
+		Timestamp:  uint64(now.UnixMilli()),
+	}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	sctListBytes, err := cttls.Marshal(*sctList)
+	if err != nil {
+		t.Fatal(err)
+	}
+	sctExtension, err := asn1.Marshal(sctListBytes)
+	if err != nil {
+		t.Fatal(err)
+	}
+	leafKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	leafDER, err := x509.CreateCertificate(rand.Reader, &x509.Certificate{
+		SerialNumber:   big.NewInt(1),
+		EmailAddresses: []string{"subject@example.com"},
+		NotBefore:      now.Add(-time.Minute),
+		NotAfter:       now.Add(time.Hour),
+		KeyUsage:       x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:    []x509.ExtKeyUsage{x509.ExtKeyUsageCodeSigning},
+		ExtraExtensions: []pkix.Extension{{
+			Id:    asn1.ObjectIdentifier{1, 3, 6, 1, 4, 1, 11129, 2, 4, 2},
+			Value: sctExtension,
+		}},
+	}, rootCert, &leafKey.PublicKey, rootKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	leafPEM := cryptoutils.PEMEncode(cryptoutils.CertificatePEMType, leafDER)
+
+	// A timestamp signed by the timestamp authority.
+	tsaKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tsaDER, err := x509.CreateCertificate(rand.Reader, &x509.Certificate{
+		SerialNumber: big.NewInt(2),
+		Subject:      pkix.Name{CommonName: "tsa"},
+		NotBefore:    now.Add(-time.Minute),
+		NotAfter:     now.Add(time.Hour),
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageTimeStamping},
+	}, rootCert, &tsaKey.PublicKey, rootKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tsaCert, err := x509.ParseCertificate(tsaDER)
+	if err != nil {
+		t.Fatal(err)
+	}
+	digest := sha256.Sum256([]byte("signature"))
+	ts := timestamp.Timestamp{
+		HashAlgorithm:     crypto.SHA256,
+		HashedMessage:     digest[:],
+		Time:              now,
+		Policy:            asn1.ObjectIdentifier{1, 2, 3, 4, 1},
+		AddTSACertificate: true,
+	}
+	tsResponse, err := ts.CreateResponseWithOpts(tsaCert, tsaKey, crypto.SHA256)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	keysCtx := func(caValidFor, ctlogValidFor, tlogValidFor, tsaValidFor *pbcommon.TimeRange) context.Context {
+		pbpk := proto.Clone(pbpkRekor).(*pbcommon.PublicKey)
+		pbpk.ValidFor = tlogValidFor
+		pbctpk := proto.Clone(pbpkCTLog).(*pbcommon.PublicKey)
+		pbctpk.ValidFor = ctlogValidFor
+		return config.ToContext(context.Background(), &config.Config{
+			SigstoreKeysConfig: &config.SigstoreKeysMap{
+				SigstoreKeys: map[string]*config.SigstoreKeys{"test-trust-root": {
+					CertificateAuthorities: []*config.CertificateAuthority{{
+						CertChain: certChainPB,
+						ValidFor:  caValidFor,
+					}},
+					Ctlogs: []*config.TransparencyLogInstance{{
+						PublicKey: pbctpk,
+						LogId:     &config.LogID{KeyId: []byte(ctlogID)},
+					}},
+					Tlogs: []*config.TransparencyLogInstance{{
+						PublicKey: pbpk,
+						LogId:     &config.LogID{KeyId: []byte(rekorLogID)},
+					}},
+					TimestampAuthorities: []*config.CertificateAuthority{{
+						CertChain: certChainPB,
+						ValidFor:  tsaValidFor,
+					}},
+				}},
+			},
+		})
+	}
+
+	sig, err := static.NewSignature(nil, "", static.WithCertChain(leafPEM, rootPEM), static.WithBundle(&bundle.RekorBundle{
+		Payload: bundle.RekorPayload{LogID: rekorLogID, IntegratedTime: now.Unix()},
+	}), static.WithRFC3161Timestamp(&bundle.RFC3161Timestamp{SignedRFC3161Timestamp: tsResponse}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	authority := webhookcip.Authority{
+		Keyless:          &webhookcip.KeylessRef{TrustRootRef: "test-trust-root"},
+		CTLog:            &v1alpha1.TLog{TrustRootRef: "test-trust-root"},
+		RFC3161Timestamp: &webhookcip.RFC3161Timestamp{TrustRootRef: "test-trust-root"},
+	}
+
+	tests := []struct {
+		name          string
+		caValidFor    *pbcommon.TimeRange
+		ctlogValidFor *pbcommon.TimeRange
+		tlogValidFor  *pbcommon.TimeRange
+		tsaValidFor   *pbcommon.TimeRange
+		ignoreSCT     bool
+		wantErr       string
+	}{{
+		name: "no validFor",
+	}, {
+		name:          "within validFor",
+		caValidFor:    current,
+		ctlogValidFor: current,
+		tlogValidFor:  current,
+		tsaValidFor:   current,
+	}, {
+		name:       "certificate issued after the CA validFor",
+		caValidFor: expired,
+		wantErr:    "not within the validFor of its certificate authority",
+	}, {
+		name:          "SCT issued after the CT log validFor",
+		caValidFor:    current,
+		ctlogValidFor: expired,
+		wantErr:       "not within the validFor of CT log " + ctlogID,
+	}, {
+		name:          "SCT ignored",
+		caValidFor:    current,
+		ctlogValidFor: expired,
+		ignoreSCT:     true,
+	}, {
+		name:         "entry integrated after the log validFor",
+		caValidFor:   current,
+		tlogValidFor: expired,
+		wantErr:      "not within the validFor of log " + rekorLogID,
+	}, {
+		name:        "timestamp signed after the timestamp authority validFor",
+		tsaValidFor: expired,
+		wantErr:     "not within the validFor of its timestamp authority",
+	}}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			authority := authority
+			authority.Keyless = &webhookcip.KeylessRef{TrustRootRef: "test-trust-root", InsecureIgnoreSCT: &tc.ignoreSCT}
+			got, err := filterValidFor(keysCtx(tc.caValidFor, tc.ctlogValidFor, tc.tlogValidFor, tc.tsaValidFor), authority, []Signature{sig})
+			if tc.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tc.wantErr) {
+					t.Errorf("filterValidFor() = %v, wanted error containing %q", err, tc.wantErr)
+				}
+				return
+			}
+			if err != nil || len(got) != 1 {
+				t.Errorf("filterValidFor() = %d signatures, %v, wanted the signature", len(got), err)
+			}
+		})
+	}
+}
+
+func TestSignatureTrustRootRef(t *testing.T) {
+	oldRoot, oldKey, err := test.GenerateRootCa()
+	if err != nil {
+		t.Fatal(err)
+	}
+	newRoot, newKey, err := test.GenerateRootCa()
+	if err != nil {
+		t.Fatal(err)
+	}
+	otherRoot, otherKey, err := test.GenerateRootCa()
+	if err != nil {
+		t.Fatal(err)
+	}
+	pbpkRekor, pkRekor, err := config.DeserializePublicKey([]byte(rekorPublicKey))
+	if err != nil {
+		t.Fatal(err)
+	}
+	rekorLogID, err := cosign.GetTransparencyLogID(pkRekor)
+	if err != nil {
+		t.Fatal(err)
+	}
+	certChain := func(root *x509.Certificate) *config.CertificateAuthority {
+		rootPEM, err := cryptoutils.MarshalCertificatesToPEM([]*x509.Certificate{root})
+		if err != nil {
+			t.Fatal(err)
+		}
+		certChainPB, err := config.DeserializeCertChain(rootPEM)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return &config.CertificateAuthority{CertChain: certChainPB}
+	}
+	ctx := config.ToContext(context.Background(), &config.Config{
+		SigstoreKeysConfig: &config.SigstoreKeysMap{
+			SigstoreKeys: map[string]*config.SigstoreKeys{
+				"old-root": {
+					CertificateAuthorities: []*config.CertificateAuthority{certChain(oldRoot)},
+					Tlogs: []*config.TransparencyLogInstance{{
+						PublicKey: pbpkRekor,
+						LogId:     &config.LogID{KeyId: []byte(rekorLogID)},
+					}},
+				},
+				"new-root": {
+					CertificateAuthorities: []*config.CertificateAuthority{certChain(newRoot)},
+				},
+			},
+		},
+	})
+	signedBy := func(root *x509.Certificate, key crypto.Signer) Signature {
+		leafCert, _, err := test.GenerateLeafCert("subject@example.com", "oidc-issuer", root, key)
+		if err != nil {
+			t.Fatal(err)
+		}
+		leafPEM, err := cryptoutils.MarshalCertificatesToPEM([]*x509.Certificate{leafCert})
+		if err != nil {
+			t.Fatal(err)
+		}
+		sig, err := static.NewSignature(nil, "", static.WithCertChain(leafPEM, nil))
+		if err != nil {
+			t.Fatal(err)
+		}
+		return sig
+	}
+	integrated, err := static.NewSignature(nil, "", static.WithBundle(&bundle.RekorBundle{
+		Payload: bundle.RekorPayload{LogID: rekorLogID},
+	}))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	keyless := webhookcip.Authority{
+		Keyless: &webhookcip.KeylessRef{TrustRootRef: "old-root", TrustRootRefs: []string{"new-root"}},
+	}
+	key := webhookcip.Authority{
+		Key:   &webhookcip.KeyRef{},
+		CTLog: &v1alpha1.TLog{TrustRootRefs: []string{"new-root", "old-root"}},
+	}
+	tests := []struct {
+		name      string
+		authority webhookcip.Authority
+		sig       Signature
+		want      string
+	}{{
+		name:      "certificate issued by the old CA",
+		authority: keyless,
+		sig:       signedBy(oldRoot, oldKey),
+		want:      "old-root",
+	}, {
+		name:      "certificate issued by the new CA",
+		authority: keyless,
+		sig:       signedBy(newRoot, newKey),
+		want:      "new-root",
+	}, {
+		name:      "certificate issued by another CA",
+		authority: keyless,
+		sig:       signedBy(otherRoot, otherKey),
+	}, {
+		name:      "entry integrated in the log of the old TrustRoot",
+		authority: key,
+		sig:       integrated,
+		want:      "old-root",
+	}, {
+		name: "single TSA TrustRoot",
+		authority: webhookcip.Authority{
+			Key:              &webhookcip.KeyRef{},
+			RFC3161Timestamp: &webhookcip.RFC3161Timestamp{TrustRootRefs: []string{"new-root"}},
+		},
+		sig:  integrated,
+		want: "new-root",
+	}, {
+		name:      "no TrustRoot",
+		authority: webhookcip.Authority{Key: &webhookcip.KeyRef{}},
+		sig:       integrated,
+	}}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			if got := signatureTrustRootRef(ctx, tc.authority, tc.sig); got != tc.want {
+				t.Errorf("signatureTrustRootRef() = %q, wanted %q", got, tc.want)
+			}
+		})
+	}
+
+	// The CAs of both TrustRoots are trusted.
+	roots, intermediates, _, err := fulcioCertsFromAuthority(ctx, keyless.Keyless)
+	if err != nil {
+		t.Fatalf("fulcioCertsFromAuthority() = %v", err)
+	}
+	for _, root := range []*x509.Certificate{oldRoot, newRoot} {
+		if _, err := root.Verify(x509.VerifyOptions{Roots: roots, Intermediates: intermediates}); err != nil {
+			t.Errorf("root %s is not trusted: %v", root.Subject, err)
+		}
+	}
+	if _, err := otherRoot.Verify(x509.VerifyOptions{Roots: roots, Intermediates: intermediates}); err == nil {
+		t.Error("another root is trusted")
+	}
+}